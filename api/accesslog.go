@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedHeaders and redactedParams carry credentials and are never
+// written to the access log
+var (
+	redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+	redactedParams  = []string{"key", "api_key", "token", "access_token", "secret", "password", "sig", "signature"}
+)
+
+const redacted = "[REDACTED]"
+
+// accessLogEntry is one JSON line of the access log
+type accessLogEntry struct {
+	Time       time.Time         `json:"time"`
+	Level      string            `json:"level"`
+	Msg        string            `json:"msg"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Route      string            `json:"route,omitempty"`
+	Query      string            `json:"query,omitempty"`
+	Status     int               `json:"status"`
+	DurationMS float64           `json:"duration_ms"`
+	Bytes      int               `json:"bytes"`
+	ClientIP   string            `json:"client_ip"`
+	UserAgent  string            `json:"user_agent,omitempty"`
+	APIKey     string            `json:"api_key,omitempty"`
+	Tenant     string            `json:"tenant,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Slow       bool              `json:"slow,omitempty"`
+	SampleRate float64           `json:"sample_rate,omitempty"`
+	Errors     string            `json:"errors,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// accessLogger writes one JSON line per request. Past rate lines a second
+// the remaining requests are sampled, but errors and slow requests are
+// always written
+type accessLogger struct {
+	out     io.Writer
+	rate    int
+	sample  float64
+	slow    time.Duration
+	headers bool
+
+	mu     sync.Mutex
+	second int64
+	count  int
+}
+
+// newAccessLogger is configured by ACCESS_LOG (off disables it),
+// ACCESS_LOG_RATE (lines a second before sampling, default 100),
+// ACCESS_LOG_SAMPLE (share of requests kept past that, default 0.01),
+// ACCESS_LOG_SLOW (default 1s) and ACCESS_LOG_HEADERS (true adds the
+// request headers). It returns nil when logging is off
+func newAccessLogger() *accessLogger {
+	if os.Getenv("ACCESS_LOG") == "off" {
+		return nil
+	}
+	l := &accessLogger{out: os.Stdout, rate: 100, sample: 0.01, slow: time.Second}
+	if value := os.Getenv("ACCESS_LOG_RATE"); value != "" {
+		if rate, err := strconv.Atoi(value); err == nil && rate >= 0 {
+			l.rate = rate
+		} else {
+			log.Printf("Ignoring invalid ACCESS_LOG_RATE %q", value)
+		}
+	}
+	if value := os.Getenv("ACCESS_LOG_SAMPLE"); value != "" {
+		if sample, err := strconv.ParseFloat(value, 64); err == nil && sample >= 0 && sample <= 1 {
+			l.sample = sample
+		} else {
+			log.Printf("Ignoring invalid ACCESS_LOG_SAMPLE %q", value)
+		}
+	}
+	if value := os.Getenv("ACCESS_LOG_SLOW"); value != "" {
+		if slow, err := time.ParseDuration(value); err == nil && slow > 0 {
+			l.slow = slow
+		} else {
+			log.Printf("Ignoring invalid ACCESS_LOG_SLOW %q", value)
+		}
+	}
+	l.headers = os.Getenv("ACCESS_LOG_HEADERS") == "true"
+	return l
+}
+
+// keep decides whether a request is logged and at what sample rate, 0
+// meaning every such request is
+func (l *accessLogger) keep(status int, duration time.Duration, now time.Time) (bool, float64) {
+	if status >= http.StatusInternalServerError || duration >= l.slow {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if second := now.Unix(); second != l.second {
+		l.second, l.count = second, 0
+	}
+	l.count++
+	if l.count <= l.rate {
+		return true, 0
+	}
+	return rand.Float64() < l.sample, l.sample
+}
+
+// middleware logs every request once it has been handled. It replaces
+// Gin's default text logger
+func (l *accessLogger) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		duration := time.Since(start)
+		status := c.Writer.Status()
+
+		keep, sampleRate := l.keep(status, duration, start)
+		if !keep {
+			return
+		}
+
+		entry := accessLogEntry{
+			Time:       start.UTC(),
+			Level:      "info",
+			Msg:        "request",
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Route:      c.FullPath(),
+			Query:      redactQuery(c.Request.URL.RawQuery),
+			Status:     status,
+			DurationMS: float64(duration.Microseconds()) / 1000,
+			Bytes:      c.Writer.Size(),
+			ClientIP:   c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			APIKey:     c.GetString(keyNameContextKey),
+			Slow:       duration >= l.slow,
+			SampleRate: sampleRate,
+			Errors:     c.Errors.String(),
+		}
+		if entry.Bytes < 0 {
+			entry.Bytes = 0
+		}
+		if t := currentTenant(c); t != nil {
+			entry.Tenant = t.name
+		}
+		if subject := currentUser(c); subject != "" {
+			// Subjects can be email addresses, so only a stable hash is kept
+			sum := sha256.Sum256([]byte(subject))
+			entry.User = hex.EncodeToString(sum[:8])
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Level = "error"
+		case entry.Slow || status >= http.StatusBadRequest:
+			entry.Level = "warn"
+		}
+		if l.headers {
+			entry.Headers = redactHeaders(c.Request.Header)
+		}
+		l.write(entry)
+	}
+}
+
+func (l *accessLogger) write(entry accessLogEntry) {
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		log.Printf("Error encoding access log entry: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line.Bytes())
+}
+
+// redactQuery blanks the values of query parameters that carry
+// credentials, leaving the rest of the query as it was sent
+func redactQuery(raw string) string {
+	parts := strings.Split(raw, "&")
+	for i, part := range parts {
+		name, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if containsString(redactedParams, strings.ToLower(name)) {
+			parts[i] = name + "=" + redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// redactHeaders flattens request headers, blanking credentials
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		for _, secret := range redactedHeaders {
+			if strings.EqualFold(name, secret) {
+				value = redacted
+				break
+			}
+		}
+		headers[name] = value
+	}
+	return headers
+}
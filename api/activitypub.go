@@ -0,0 +1,731 @@
+package handler
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/secrets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityPub settings
+const (
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	activityPublic         = "https://www.w3.org/ns/activitystreams#Public"
+	activityContentType    = "application/activity+json"
+	activityOutboxSize     = 50
+	activitySignatureAge   = 12 * time.Hour
+)
+
+// activityPubActor is a fediverse account (e.g. @dailytopnews@news.example.com)
+// that posts new top headlines as Notes. Followers and recent posts are
+// kept in a JSON file so a restart doesn't lose them
+type activityPubActor struct {
+	mu        sync.Mutex
+	domain    string
+	username  string
+	key       *rsa.PrivateKey
+	publicPEM string
+	statePath string
+	perScrape int
+	followers map[string]string // actor ID -> inbox to deliver to
+	outbox    []map[string]interface{}
+	client    *http.Client
+}
+
+// activityPubState is what the actor persists
+type activityPubState struct {
+	Followers map[string]string        `json:"followers"`
+	Outbox    []map[string]interface{} `json:"outbox"`
+}
+
+// newActivityPubActor configures the actor from ACTIVITYPUB_DOMAIN (the
+// public host name, which enables it), ACTIVITYPUB_USERNAME (default
+// dailytopnews), ACTIVITYPUB_DIR, ACTIVITYPUB_PRIVATE_KEY and
+// ACTIVITYPUB_PER_SCRAPE, how many new headlines one scrape may post
+// (default 3). Without a private key one is generated and saved in the dir
+func newActivityPubActor() *activityPubActor {
+	domain := strings.TrimSpace(os.Getenv("ACTIVITYPUB_DOMAIN"))
+	if domain == "" {
+		return nil
+	}
+	username := os.Getenv("ACTIVITYPUB_USERNAME")
+	if username == "" {
+		username = "dailytopnews"
+	}
+	dir := os.Getenv("ACTIVITYPUB_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "top-news-activitypub")
+	}
+	perScrape := 3
+	if value := os.Getenv("ACTIVITYPUB_PER_SCRAPE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			perScrape = parsed
+		} else {
+			log.Printf("Invalid ACTIVITYPUB_PER_SCRAPE %q, using %d", value, perScrape)
+		}
+	}
+
+	key, err := loadActivityPubKey(dir)
+	if err != nil {
+		log.Printf("ActivityPub is disabled: %v", err)
+		return nil
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		log.Printf("ActivityPub is disabled: failed to encode public key: %v", err)
+		return nil
+	}
+
+	a := &activityPubActor{
+		domain:    domain,
+		username:  username,
+		key:       key,
+		publicPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		statePath: filepath.Join(dir, "state.json"),
+		perScrape: perScrape,
+		followers: map[string]string{},
+		client:    outboundClient(15 * time.Second),
+	}
+	if data, err := os.ReadFile(a.statePath); err == nil {
+		var state activityPubState
+		if err := json.Unmarshal(data, &state); err != nil {
+			log.Printf("Error decoding ActivityPub state, starting empty: %v", err)
+		} else {
+			if state.Followers != nil {
+				a.followers = state.Followers
+			}
+			a.outbox = state.Outbox
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Error reading ActivityPub state, starting empty: %v", err)
+	}
+	return a
+}
+
+// loadActivityPubKey reads the signing key from ACTIVITYPUB_PRIVATE_KEY or
+// dir/actor.pem, generating the latter on first start. Either may hold the
+// key encrypted. With SECRETS_KEY set the file is kept encrypted: a plain
+// or rotated-out one is sealed again with the current key
+func loadActivityPubKey(dir string) (*rsa.PrivateKey, error) {
+	path := filepath.Join(dir, "actor.pem")
+	value, err := secretKeys().Open(os.Getenv("ACTIVITYPUB_PRIVATE_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt ACTIVITYPUB_PRIVATE_KEY: %v", err)
+	}
+	if value == "" {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate key: %v", err)
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return nil, fmt.Errorf("failed to create ActivityPub dir: %v", err)
+			}
+			encoded := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+			if err := saveActivityPubKey(path, string(encoded)); err != nil {
+				return nil, err
+			}
+			return key, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %v", err)
+		}
+		stored := strings.TrimSpace(string(data))
+		if value, err = secretKeys().Open(stored); err != nil {
+			return nil, fmt.Errorf("failed to decrypt key: %v", err)
+		}
+		// Plain keys, and keys sealed with a rotated-out key, are sealed
+		// again with the current one
+		if keys := secretKeys(); keys != nil {
+			if _, rotated, _ := keys.Rotate(stored); rotated || !secrets.IsSealed(stored) {
+				if err := saveActivityPubKey(path, value); err != nil {
+					log.Printf("Error encrypting the ActivityPub key, leaving it as it is: %v", err)
+				}
+			}
+		}
+	}
+
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// saveActivityPubKey writes the PEM signing key to path, encrypted when
+// SECRETS_KEY is set
+func saveActivityPubKey(path, key string) error {
+	if keys := secretKeys(); keys != nil {
+		sealed, err := keys.Seal(key)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt key: %v", err)
+		}
+		key = sealed
+	}
+	// Through a temporary file, so a failed write never loses the key
+	if err := os.WriteFile(path+".tmp", []byte(key), 0o600); err != nil {
+		return fmt.Errorf("failed to save key: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save key: %v", err)
+	}
+	return nil
+}
+
+// url returns the absolute URL of an actor path
+func (a *activityPubActor) url(path string) string {
+	return "https://" + a.domain + path
+}
+
+// id is the actor's ActivityPub ID
+func (a *activityPubActor) id() string {
+	return a.url("/ap/actor")
+}
+
+// persist writes followers and the outbox to disk; callers must hold the lock
+func (a *activityPubActor) persist() error {
+	data, err := json.Marshal(activityPubState{Followers: a.followers, Outbox: a.outbox})
+	if err != nil {
+		return fmt.Errorf("failed to encode ActivityPub state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(a.statePath), 0o700); err != nil {
+		return fmt.Errorf("failed to create ActivityPub dir: %v", err)
+	}
+	tmp := a.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write ActivityPub state: %v", err)
+	}
+	if err := os.Rename(tmp, a.statePath); err != nil {
+		return fmt.Errorf("failed to replace ActivityPub state: %v", err)
+	}
+	return nil
+}
+
+func (a *activityPubActor) name() string {
+	return "activitypub"
+}
+
+// publish posts up to perScrape of the new articles as Notes and delivers
+// them to every follower
+func (a *activityPubActor) publish(articles []models.NewsArticle) error {
+	a.mu.Lock()
+	posted := map[string]bool{}
+	for _, activity := range a.outbox {
+		posted[fmt.Sprint(activity["id"])] = true
+	}
+	created := []map[string]interface{}{}
+	for _, article := range articles {
+		if len(created) == a.perScrape {
+			break
+		}
+		activity := a.note(article)
+		if posted[activity["id"].(string)] {
+			continue
+		}
+		created = append(created, activity)
+	}
+	if len(created) == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	a.outbox = append(created, a.outbox...)
+	if len(a.outbox) > activityOutboxSize {
+		a.outbox = a.outbox[:activityOutboxSize]
+	}
+	err := a.persist()
+	inboxes := a.inboxes()
+	a.mu.Unlock()
+	if err != nil {
+		log.Printf("Error saving ActivityPub outbox: %v", err)
+	}
+
+	failed := 0
+	for _, activity := range created {
+		for _, inbox := range inboxes {
+			if err := a.deliver(inbox, activity); err != nil {
+				log.Printf("Error delivering to %s: %v", inbox, err)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deliveries failed", failed, len(created)*len(inboxes))
+	}
+	return nil
+}
+
+// note wraps an article in a Create activity for a public Note
+func (a *activityPubActor) note(article models.NewsArticle) map[string]interface{} {
+	noteID := a.url("/ap/notes/" + articleKey(article.URL))
+	published := time.Now().UTC().Format(time.RFC3339)
+	content := fmt.Sprintf(`<p>%s</p><p><a href="%s">%s</a></p>`,
+		html.EscapeString(article.Title), html.EscapeString(article.URL), html.EscapeString(article.URL))
+	note := map[string]interface{}{
+		"id":           noteID,
+		"type":         "Note",
+		"attributedTo": a.id(),
+		"content":      content,
+		"url":          article.URL,
+		"published":    published,
+		"to":           []string{activityPublic},
+		"cc":           []string{a.url("/ap/followers")},
+	}
+	if article.ContentWarning != "" {
+		note["summary"] = "CW: " + strings.ReplaceAll(article.ContentWarning, "_", " ")
+		note["sensitive"] = true
+	}
+	return map[string]interface{}{
+		"@context":  activityStreamsContext,
+		"id":        noteID + "/activity",
+		"type":      "Create",
+		"actor":     a.id(),
+		"published": published,
+		"to":        note["to"],
+		"cc":        note["cc"],
+		"object":    note,
+	}
+}
+
+// inboxes returns the distinct inboxes of all followers; callers must hold
+// the lock
+func (a *activityPubActor) inboxes() []string {
+	seen := map[string]bool{}
+	inboxes := []string{}
+	for _, inbox := range a.followers {
+		if !seen[inbox] {
+			seen[inbox] = true
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes
+}
+
+// deliver POSTs an activity to an inbox with an HTTP signature
+func (a *activityPubActor) deliver(inbox string, activity interface{}) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to encode activity: %v", err)
+	}
+	req, err := http.NewRequest("POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set("Content-Type", activityContentType)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	if err := a.sign(req); err != nil {
+		return err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delivery failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("inbox returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign adds a draft-cavage HTTP signature over the request target, host,
+// date and digest, which is what Mastodon expects
+func (a *activityPubActor) sign(req *http.Request) error {
+	headers := []string{"(request-target)", "host", "date", "digest"}
+	signed := signingString(headers, req.Method, req.URL.RequestURI(), req.URL.Host, req.Header)
+	hashed := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %v", err)
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s#main-key",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		a.id(), strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// signingString builds the string an HTTP signature covers
+func signingString(headers []string, method, target, host string, values http.Header) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		switch name {
+		case "(request-target)":
+			lines = append(lines, "(request-target): "+strings.ToLower(method)+" "+target)
+		case "host":
+			lines = append(lines, "host: "+host)
+		default:
+			lines = append(lines, name+": "+values.Get(name))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// remoteActor is the part of another server's actor document we use
+type remoteActor struct {
+	ID        string `json:"id"`
+	Inbox     string `json:"inbox"`
+	Endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	PublicKey struct {
+		ID           string `json:"id"`
+		Owner        string `json:"owner"`
+		PublicKeyPem string `json:"publicKeyPem"`
+	} `json:"publicKey"`
+}
+
+// fetchActor downloads a remote actor document
+func (a *activityPubActor) fetchActor(id string) (*remoteActor, error) {
+	if _, err := outbound().CheckURL(id); err != nil {
+		return nil, fmt.Errorf("actor %s: %v", id, err)
+	}
+	req, err := http.NewRequest("GET", id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", activityContentType)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch actor %s: %v", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code for actor %s: %d", id, resp.StatusCode)
+	}
+	var actor remoteActor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("failed to decode actor %s: %v", id, err)
+	}
+	return &actor, nil
+}
+
+// verify checks the HTTP signature of an inbox request and returns the
+// actor that signed it
+func (a *activityPubActor) verify(c *gin.Context, body []byte) (*remoteActor, error) {
+	params := map[string]string{}
+	for _, part := range strings.Split(c.GetHeader("Signature"), ",") {
+		if name, value, found := strings.Cut(strings.TrimSpace(part), "="); found {
+			params[name] = strings.Trim(value, `"`)
+		}
+	}
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, fmt.Errorf("missing signature")
+	}
+	headers := strings.Fields(params["headers"])
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	if !containsString(headers, "(request-target)") || !containsString(headers, "digest") {
+		return nil, fmt.Errorf("signature must cover (request-target) and digest")
+	}
+	date, err := http.ParseTime(c.GetHeader("Date"))
+	if err != nil || time.Since(date) > activitySignatureAge || time.Until(date) > activitySignatureAge {
+		return nil, fmt.Errorf("missing or stale Date header")
+	}
+	digest := sha256.Sum256(body)
+	if c.GetHeader("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]) {
+		return nil, fmt.Errorf("digest does not match the body")
+	}
+
+	keyID, _, _ := strings.Cut(params["keyId"], "#")
+	actor, err := a.fetchActor(keyID)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(actor.PublicKey.PublicKeyPem))
+	if block == nil {
+		return nil, fmt.Errorf("actor %s has no public key", actor.ID)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of %s: %v", actor.ID, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key of %s is not an RSA key", actor.ID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return nil, fmt.Errorf("signature is not base64: %v", err)
+	}
+	signed := signingString(headers, c.Request.Method, c.Request.URL.RequestURI(), c.Request.Host, c.Request.Header)
+	hashed := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return nil, fmt.Errorf("signature does not verify")
+	}
+	return actor, nil
+}
+
+// activityPubDisabled answers requests to the fediverse endpoints when no
+// actor is configured
+func activityPubDisabled(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Success: false,
+		Error:   "activitypub_disabled",
+		Message: "ActivityPub is not configured, set ACTIVITYPUB_DOMAIN",
+	})
+}
+
+// WebFinger resolves acct:username@domain to the actor, which is how
+// Mastodon users find the account
+func (ns *NewsService) WebFinger(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	subject := "acct:" + a.username + "@" + a.domain
+	if !strings.EqualFold(c.Query("resource"), subject) && c.Query("resource") != a.id() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "unknown_resource",
+			Message: fmt.Sprintf("Only %s is hosted here", subject),
+		})
+		return
+	}
+	c.Header("Content-Type", "application/jrd+json")
+	c.JSON(http.StatusOK, gin.H{
+		"subject": subject,
+		"aliases": []string{a.id()},
+		"links": []gin.H{
+			{"rel": "self", "type": activityContentType, "href": a.id()},
+		},
+	})
+}
+
+// activityJSON writes an ActivityStreams document
+func activityJSON(c *gin.Context, document interface{}) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, activityContentType, data)
+}
+
+// GetActor serves the actor document
+func (ns *NewsService) GetActor(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	activityJSON(c, gin.H{
+		"@context":                  []string{activityStreamsContext, "https://w3id.org/security/v1"},
+		"id":                        a.id(),
+		"type":                      "Service",
+		"preferredUsername":         a.username,
+		"name":                      "Top News",
+		"summary":                   "<p>New top headlines from " + html.EscapeString(a.domain) + "</p>",
+		"url":                       a.url("/"),
+		"inbox":                     a.url("/ap/inbox"),
+		"outbox":                    a.url("/ap/outbox"),
+		"followers":                 a.url("/ap/followers"),
+		"manuallyApprovesFollowers": false,
+		"discoverable":              true,
+		"publicKey": gin.H{
+			"id":           a.id() + "#main-key",
+			"owner":        a.id(),
+			"publicKeyPem": a.publicPEM,
+		},
+	})
+}
+
+// GetOutbox lists the most recent posts
+func (ns *NewsService) GetOutbox(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	a.mu.Lock()
+	items := append([]map[string]interface{}{}, a.outbox...)
+	a.mu.Unlock()
+	activityJSON(c, gin.H{
+		"@context":     activityStreamsContext,
+		"id":           a.url("/ap/outbox"),
+		"type":         "OrderedCollection",
+		"totalItems":   len(items),
+		"orderedItems": items,
+	})
+}
+
+// GetFollowers reports the follower count without listing followers
+func (ns *NewsService) GetFollowers(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	a.mu.Lock()
+	count := len(a.followers)
+	a.mu.Unlock()
+	activityJSON(c, gin.H{
+		"@context":   activityStreamsContext,
+		"id":         a.url("/ap/followers"),
+		"type":       "OrderedCollection",
+		"totalItems": count,
+	})
+}
+
+// GetNote serves a single posted Note from the outbox
+func (ns *NewsService) GetNote(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	id := a.url("/ap/notes/" + c.Param("key"))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, activity := range a.outbox {
+		if note, ok := activity["object"].(map[string]interface{}); ok && note["id"] == id {
+			document := map[string]interface{}{"@context": activityStreamsContext}
+			for k, v := range note {
+				document[k] = v
+			}
+			activityJSON(c, document)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Success: false,
+		Error:   "note_not_found",
+		Message: "No recent post has this ID",
+	})
+}
+
+// PostInbox handles Follow and Undo Follow activities from signed requests;
+// everything else is accepted and ignored
+func (ns *NewsService) PostInbox(c *gin.Context) {
+	a := ns.activityPub
+	if a == nil {
+		activityPubDisabled(c)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	var activity struct {
+		ID     string          `json:"id"`
+		Type   string          `json:"type"`
+		Actor  string          `json:"actor"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_activity",
+			Message: fmt.Sprintf("Invalid activity: %v", err),
+		})
+		return
+	}
+	sender, err := a.verify(c, body)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_signature",
+			Message: err.Error(),
+		})
+		return
+	}
+	if sender.ID != activity.Actor {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Success: false,
+			Error:   "actor_mismatch",
+			Message: "The activity was not signed by its actor",
+		})
+		return
+	}
+
+	switch activity.Type {
+	case "Follow":
+		inbox := sender.Endpoints.SharedInbox
+		if inbox == "" {
+			inbox = sender.Inbox
+		}
+		if _, err := outbound().CheckURL(inbox); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_inbox",
+				Message: fmt.Sprintf("The actor's inbox can't be delivered to: %v", err),
+			})
+			return
+		}
+		a.mu.Lock()
+		a.followers[sender.ID] = inbox
+		err := a.persist()
+		a.mu.Unlock()
+		if err != nil {
+			log.Printf("Error saving ActivityPub followers: %v", err)
+		}
+		accept := gin.H{
+			"@context": activityStreamsContext,
+			"id":       a.url("/ap/accepts/" + articleKey(activity.ID)),
+			"type":     "Accept",
+			"actor":    a.id(),
+			"object":   json.RawMessage(body),
+		}
+		go func() {
+			if err := a.deliver(sender.Inbox, accept); err != nil {
+				log.Printf("Error accepting follow from %s: %v", sender.ID, err)
+			}
+		}()
+	case "Undo", "Delete":
+		// Only an undone Follow or the follower's own account being
+		// deleted ends the subscription
+		var object struct {
+			Type string `json:"type"`
+		}
+		var objectID string
+		json.Unmarshal(activity.Object, &object)
+		json.Unmarshal(activity.Object, &objectID)
+		if (activity.Type == "Undo" && object.Type != "Follow") || (activity.Type == "Delete" && objectID != sender.ID) {
+			break
+		}
+		a.mu.Lock()
+		if _, following := a.followers[sender.ID]; following {
+			delete(a.followers, sender.ID)
+			if err := a.persist(); err != nil {
+				log.Printf("Error saving ActivityPub followers: %v", err)
+			}
+		}
+		a.mu.Unlock()
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// role is what an API key may do; each role includes the ones below it
+type role int
+
+const (
+	roleNone role = iota
+	// roleReader sees inactive sources and selector debugging, for
+	// consumers such as dashboards; the admin endpoints are not for it
+	roleReader
+	// roleEditor also reads the admin endpoints, pins and edits articles,
+	// purges caches and replays snapshots
+	roleEditor
+	// roleAdmin can do everything, including fault injection and dropping
+	// deliveries
+	roleAdmin
+)
+
+var roleNames = []string{"none", "reader", "editor", "admin"}
+
+func (r role) String() string {
+	return roleNames[r]
+}
+
+// parseRole reads a role name from the API key configuration
+func parseRole(name string) (role, bool) {
+	for i, roleName := range roleNames {
+		if i > 0 && name == roleName {
+			return role(i), true
+		}
+	}
+	return roleNone, false
+}
+
+// Context keys set by identifyKey
+const (
+	roleContextKey    = "role"
+	keyNameContextKey = "api_key_name"
+	keyTierContextKey = "api_key_tier"
+	keyLaneContextKey = "api_key_lane"
+)
+
+// apiKey is a named key with a role, a quota tier and a scrape lane
+type apiKey struct {
+	name string
+	role role
+	tier string
+	lane string
+}
+
+// apiKeys finds keys by their hash
+type apiKeys map[[32]byte]apiKey
+
+// newAPIKeys loads role keys from the JSON file at API_KEYS_PATH, or
+// inline JSON in API_KEYS. ADMIN_API_KEY, read on every request, is always
+// an admin key. Invalid configuration is logged and only ADMIN_API_KEY works
+func newAPIKeys(tiers *quotaTiers) apiKeys {
+	data := []byte(os.Getenv("API_KEYS"))
+	if path := os.Getenv("API_KEYS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading API keys, only ADMIN_API_KEY is accepted: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	data, err := openSecrets(data)
+	if err != nil {
+		log.Printf("Error decrypting API keys, only ADMIN_API_KEY is accepted: %v", err)
+		return nil
+	}
+
+	var config models.APIKeysConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding API keys, only ADMIN_API_KEY is accepted: %v", err)
+		return nil
+	}
+	keys, err := compileAPIKeys(config, tiers)
+	if err != nil {
+		log.Printf("Invalid API keys, only ADMIN_API_KEY is accepted: %v", err)
+		return nil
+	}
+	return keys
+}
+
+func compileAPIKeys(config models.APIKeysConfig, tiers *quotaTiers) (apiKeys, error) {
+	keys := apiKeys{}
+	for i, key := range config.Keys {
+		if key.Name == "" || key.Key == "" {
+			return nil, fmt.Errorf("key %d: name and key are required", i+1)
+		}
+		r, ok := parseRole(key.Role)
+		if !ok {
+			return nil, fmt.Errorf("key %s: unknown role %q, use reader, editor or admin", key.Name, key.Role)
+		}
+		if err := tiers.check(key.Tier); err != nil {
+			return nil, fmt.Errorf("key %s: %v", key.Name, err)
+		}
+		if err := checkLane(key.Lane); err != nil {
+			return nil, fmt.Errorf("key %s: %v", key.Name, err)
+		}
+		hash := sha256.Sum256([]byte(key.Key))
+		if _, taken := keys[hash]; taken {
+			return nil, fmt.Errorf("key %s: the same key is configured twice", key.Name)
+		}
+		keys[hash] = apiKey{name: key.Name, role: r, tier: key.Tier, lane: key.Lane}
+	}
+	return keys, nil
+}
+
+// lookup returns the key's name and role, roleNone when it is unknown
+func (k apiKeys) lookup(key string) apiKey {
+	if key == "" {
+		return apiKey{}
+	}
+	if adminKey := os.Getenv("ADMIN_API_KEY"); adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+		return apiKey{name: "admin", role: roleAdmin}
+	}
+	return k[sha256.Sum256([]byte(key))]
+}
+
+// identifyKey is a middleware that records the role and name of the
+// request's API key, sent either as an X-API-Key header or as a bearer
+// token
+func (ns *NewsService) identifyKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := ns.apiKeys.lookup(requestAPIKey(c))
+		c.Set(roleContextKey, key.role)
+		c.Set(keyNameContextKey, key.name)
+		c.Set(keyTierContextKey, key.tier)
+		c.Set(keyLaneContextKey, key.lane)
+		c.Next()
+	}
+}
+
+// requestRole returns the role identifyKey found for the request
+func requestRole(c *gin.Context) role {
+	if value, ok := c.Get(roleContextKey); ok {
+		return value.(role)
+	}
+	return roleNone
+}
+
+// isAdmin reports whether the request may see inactive sources and
+// selector debugging, which every role may
+func isAdmin(c *gin.Context) bool {
+	return requestRole(c) >= roleReader
+}
+
+// requestAPIKey returns the API key of a request, from the X-API-Key header
+// or a bearer token that identifyUser didn't take as a user's JWT
+func requestAPIKey(c *gin.Context) string {
+	key := c.GetHeader("X-API-Key")
+	if key == "" && currentUser(c) == "" {
+		key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return key
+}
+
+// abortUnauthorized rejects a request that needs admin access
+func abortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+		Success: false,
+		Error:   "unauthorized",
+		Message: "A valid admin API key is required",
+	})
+}
+
+// includeInactive reads the include_inactive query flag. It returns false
+// for ok after writing a 401 when the flag is set without admin access
+func includeInactive(c *gin.Context) (include bool, ok bool) {
+	if c.Query("include_inactive") != "true" {
+		return false, true
+	}
+	if !isAdmin(c) {
+		abortUnauthorized(c)
+		return false, false
+	}
+	return true, true
+}
+
+// requireAdmin is a middleware that only lets keys that may read admin
+// data, editors and admins, through
+func requireAdmin() gin.HandlerFunc {
+	return requireRole(roleEditor)
+}
+
+// requireRole is a middleware that only lets keys with at least the given
+// role through
+func requireRole(needed role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		have := requestRole(c)
+		if have == roleNone {
+			abortUnauthorized(c)
+			return
+		}
+		if have < needed {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Success: false,
+				Error:   "forbidden",
+				Message: fmt.Sprintf("This API key has the %s role, %s is required", have, needed),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// logAdminActions is a middleware that logs every admin request that
+// changes something, with the key that made it
+func logAdminActions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			return
+		}
+		log.Printf("Admin action by %s (%s): %s %s -> %d", c.GetString(keyNameContextKey), requestRole(c), c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+	}
+}
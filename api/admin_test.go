@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminEndpointsNeedEditorRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ACCESS_LOG", "off")
+	t.Setenv("API_KEYS", `{"keys": [
+		{"name": "dashboard", "key": "reader-key", "role": "reader"},
+		{"name": "news-desk", "key": "editor-key", "role": "editor"}
+	]}`)
+	router := setupRouter()
+
+	cases := []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"reader-key", http.StatusForbidden},
+		{"editor-key", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit", nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("GET /api/v1/admin/audit with key %q = %d, want %d: %s", tc.key, w.Code, tc.want, w.Body)
+		}
+	}
+}
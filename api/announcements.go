@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+)
+
+// announcementCues maps each kind of announcement to the URL sections that
+// hold it and the headline words that give it away. Headlines alone are
+// matched, since a news story may well mention a funeral or prayer times
+var announcementCues = []struct {
+	kind     string
+	sections *regexp.Regexp
+	keywords []string
+}{
+	{"obituary", regexp.MustCompile(`(?i)/(obituar(y|ies)|death-anniversar(y|ies))(/|$)`), []string{
+		"obituary", "obituaries", "in memoriam", "janaza", "namaz e janaza", "kulkhani", "জানাজা", "কুলখানি", "শোক সংবাদ",
+	}},
+	{"notice", regexp.MustCompile(`(?i)/(notices?|classifieds?|tenders?)(/|$)`), []string{
+		"public notice", "tender notice", "notice inviting tender", "corrigendum", "lost and found", "auction notice",
+		"গণবিজ্ঞপ্তি", "দরপত্র বিজ্ঞপ্তি", "নিলাম বিজ্ঞপ্তি", "হারানো বিজ্ঞপ্তি",
+	}},
+	{"horoscope", regexp.MustCompile(`(?i)/(horoscopes?|rashifal|astrology)(/|$)`), []string{
+		"horoscope", "horoscopes", "rashifal", "রাশিফল",
+	}},
+	{"prayer_times", regexp.MustCompile(`(?i)/(prayer-times?|namaz-times?|sehri-iftar)(/|$)`), []string{
+		"prayer times", "prayer time", "prayer schedule", "namaz schedule", "sehri and iftar", "sehri iftar", "iftar time", "iftar schedule",
+		"নামাজের সময়", "নামাজের সময়সূচি", "সেহরি ও ইফতার", "সেহরি ইফতার", "ইফতারের সময়সূচি",
+	}},
+}
+
+// foldedAnnouncements holds announcementCues keywords folded like article
+// text
+var foldedAnnouncements = func() map[string][]string {
+	folded := map[string][]string{}
+	for _, cue := range announcementCues {
+		folded[cue.kind] = foldKeywords(cue.keywords)
+	}
+	return folded
+}()
+
+// announcementKind tells obituaries, notices, horoscopes and prayer times
+// from news, "" for anything else
+func announcementKind(article models.NewsArticle) string {
+	title := " " + strings.Join(textnorm.Words(article.Title+" "+article.Category), " ") + " "
+	for _, cue := range announcementCues {
+		if cue.sections.MatchString(article.URL) {
+			return cue.kind
+		}
+		for _, keyword := range foldedAnnouncements[cue.kind] {
+			if strings.Contains(title, keyword) {
+				return cue.kind
+			}
+		}
+	}
+	return ""
+}
+
+// anyAnnouncements reports whether a default feed would have to drop any of
+// the articles
+func anyAnnouncements(articles []models.NewsArticle) bool {
+	for _, article := range articles {
+		if article.Type == typeAnnouncement {
+			return true
+		}
+	}
+	return false
+}
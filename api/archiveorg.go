@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+const (
+	// archiveTopStories is how many of a scrape's new articles, in page
+	// order, count as top stories
+	archiveTopStories = 5
+	// archiveQueueSize caps the URLs waiting to be submitted; more are
+	// dropped rather than piling up behind the rate limit
+	archiveQueueSize = 200
+	// archiveSeenSize caps the URLs remembered as submitted
+	archiveSeenSize = 10000
+)
+
+// waybackSaveURL is the Wayback Machine's Save Page Now endpoint
+var waybackSaveURL = "https://web.archive.org/save"
+
+// archiveSubmitter submits newly discovered top stories to the Wayback
+// Machine, one at a time and no faster than its hourly rate
+type archiveSubmitter struct {
+	accessKey, secretKey string
+	interval             time.Duration
+	client               *http.Client
+	queue                chan string
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// newArchiveSubmitter turns submissions on when ARCHIVE_SUBMIT is true.
+// ARCHIVE_SUBMIT_PER_HOUR sets the rate (default 60), and
+// ARCHIVE_ORG_ACCESS_KEY and ARCHIVE_ORG_SECRET_KEY use an archive.org
+// account's S3 keys for the higher signed-in limits. It returns nil when
+// submissions are off
+func newArchiveSubmitter() *archiveSubmitter {
+	if os.Getenv("ARCHIVE_SUBMIT") != "true" {
+		return nil
+	}
+	perHour := 60
+	if value := os.Getenv("ARCHIVE_SUBMIT_PER_HOUR"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid ARCHIVE_SUBMIT_PER_HOUR %q, using %d", value, perHour)
+		} else {
+			perHour = parsed
+		}
+	}
+	a := &archiveSubmitter{
+		accessKey: secretEnv("ARCHIVE_ORG_ACCESS_KEY"),
+		secretKey: secretEnv("ARCHIVE_ORG_SECRET_KEY"),
+		interval:  time.Hour / time.Duration(perHour),
+		client:    &http.Client{Timeout: 2 * time.Minute},
+		queue:     make(chan string, archiveQueueSize),
+		seen:      map[string]bool{},
+	}
+	if (a.accessKey == "") != (a.secretKey == "") {
+		log.Printf("Signed-in archive.org submissions are disabled: set both ARCHIVE_ORG_ACCESS_KEY and ARCHIVE_ORG_SECRET_KEY")
+		a.accessKey, a.secretKey = "", ""
+	}
+	go a.run()
+	return a
+}
+
+// subscribe queues the top stories of every scrape that finds new articles
+func (a *archiveSubmitter) subscribe(bus *eventBus) {
+	bus.subscribe("archive.org", func(event models.Event) {
+		for i, article := range event.Articles {
+			if i == archiveTopStories {
+				break
+			}
+			a.enqueue(article.URL)
+		}
+	}, eventArticleDiscovered)
+}
+
+// enqueue queues a URL unless it was queued before or the queue is full
+func (a *archiveSubmitter) enqueue(articleURL string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.seen[articleURL] {
+		return
+	}
+	if len(a.seen) >= archiveSeenSize {
+		a.seen = map[string]bool{}
+	}
+	select {
+	case a.queue <- articleURL:
+		a.seen[articleURL] = true
+	default:
+		log.Printf("Archive.org queue is full, not submitting %s", articleURL)
+	}
+}
+
+// run submits queued URLs, waiting the interval between submissions
+func (a *archiveSubmitter) run() {
+	for articleURL := range a.queue {
+		snapshot, err := a.submit(articleURL)
+		if err != nil {
+			log.Printf("Error submitting %s to archive.org: %v", articleURL, err)
+		} else {
+			log.Printf("Submitted %s to archive.org: %s", articleURL, snapshot)
+		}
+		time.Sleep(a.interval)
+	}
+}
+
+// submit asks the Wayback Machine to save a URL and returns the snapshot
+// it made, when it says
+func (a *archiveSubmitter) submit(articleURL string) (string, error) {
+	var req *http.Request
+	var err error
+	if a.accessKey != "" {
+		req, err = http.NewRequest("POST", waybackSaveURL, strings.NewReader(url.Values{"url": {articleURL}}.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", fmt.Sprintf("LOW %s:%s", a.accessKey, a.secretKey))
+		}
+	} else {
+		req, err = http.NewRequest("GET", waybackSaveURL+"/"+articleURL, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.Header.Get("Content-Location"), nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditMemory is how many recent entries are kept in memory and served
+const auditMemory = 1000
+
+// auditLog records who changed what through the admin API. With AUDIT_PATH
+// set, entries are appended to that file as JSON lines and never rewritten
+type auditLog struct {
+	mu      sync.Mutex
+	path    string
+	entries []models.AuditEntry // oldest first
+}
+
+// newAuditLog loads the most recent entries from AUDIT_PATH
+func newAuditLog() *auditLog {
+	a := &auditLog{path: os.Getenv("AUDIT_PATH")}
+	if a.path == "" {
+		return a
+	}
+
+	file, err := os.Open(a.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading audit log, starting empty: %v", err)
+		}
+		return a
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Skipping unreadable audit log line: %v", err)
+			continue
+		}
+		a.entries = append(a.entries, entry)
+		if len(a.entries) > auditMemory {
+			a.entries = a.entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error reading audit log: %v", err)
+	}
+	return a
+}
+
+// record adds an entry for a change made by the request's API key.
+// previous and current are the changed value before and after, nil when
+// there is none
+func (a *auditLog) record(c *gin.Context, action, target string, previous, current interface{}) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	entry := models.AuditEntry{
+		ID:       hex.EncodeToString(id),
+		Actor:    c.GetString(keyNameContextKey),
+		Role:     requestRole(c).String(),
+		Action:   action,
+		Target:   target,
+		Previous: auditValue(previous),
+		Current:  auditValue(current),
+		At:       time.Now().UTC(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > auditMemory {
+		a.entries = a.entries[1:]
+	}
+	if err := a.append(entry); err != nil {
+		log.Printf("Error writing audit log entry %s %s: %v", action, target, err)
+	}
+}
+
+// auditValue encodes a value for an entry, nil for nothing
+func auditValue(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// append writes one entry to the end of the file; callers must hold the
+// lock
+func (a *auditLog) append(entry models.AuditEntry) error {
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// list returns matching entries, newest first
+func (a *auditLog) list(action, actor string, limit int) []models.AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := []models.AuditEntry{}
+	for i := len(a.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := a.entries[i]
+		if (action == "" || entry.Action == action) && (actor == "" || entry.Actor == actor) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ListAudit returns recent admin changes, optionally filtered by action
+// and actor (the API key's name)
+func (ns *NewsService) ListAudit(c *gin.Context) {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > auditMemory {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_limit",
+				Message: "limit must be a number between 1 and " + strconv.Itoa(auditMemory),
+			})
+			return
+		}
+		limit = parsed
+	}
+	entries := ns.audit.list(c.Query("action"), c.Query("actor"), limit)
+	c.JSON(http.StatusOK, models.AuditResponse{Success: true, Entries: entries, Count: len(entries)})
+}
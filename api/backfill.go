@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"top-news/models"
+)
+
+// sitemapDocument covers both sitemap indexes and URL sets, including the
+// Google News extension that carries publication dates and titles
+type sitemapDocument struct {
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+		News    struct {
+			PublicationDate string `xml:"publication_date"`
+			Title           string `xml:"title"`
+		} `xml:"news"`
+	} `xml:"url"`
+}
+
+// sitemapEntry is an article URL found in a sitemap
+type sitemapEntry struct {
+	URL         string
+	Title       string
+	PublishedAt time.Time
+}
+
+// BackfillOptions controls a historical backfill
+type BackfillOptions struct {
+	From time.Time
+	// To defaults to now
+	To time.Time
+	// MaxArticles caps how many article pages are fetched, 0 means 500
+	MaxArticles int
+	// Progress is called after each article page is processed
+	Progress func(done, total int, article models.NewsArticle, err error)
+}
+
+// Backfill walks a source's sitemap for articles published between
+// opts.From and opts.To and adds them to the article store. It returns the
+// number of articles that were new to the store
+func (ns *NewsService) Backfill(sourceName string, opts BackfillOptions) (int, error) {
+	source, exists := ns.sources[sourceName]
+	if !exists {
+		return 0, fmt.Errorf("unknown source: %s", sourceName)
+	}
+	if source.SitemapURL == "" {
+		return 0, fmt.Errorf("source %s has no sitemap configured", sourceName)
+	}
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if opts.MaxArticles <= 0 {
+		opts.MaxArticles = 500
+	}
+
+	entries, err := ns.collectSitemapEntries(source.SitemapURL, opts, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) > opts.MaxArticles {
+		entries = entries[:opts.MaxArticles]
+	}
+
+	added := 0
+	for i, entry := range entries {
+		article, err := ns.backfillArticle(sourceName, i, entry)
+		if err == nil {
+			if reason := ns.filters.reject(article); reason != "" {
+				err = fmt.Errorf("%s", reason)
+			}
+			classifyArticle(&article)
+		}
+		if err == nil {
+			var n int
+			n, err = ns.store.Save(article)
+			added += n
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(entries), article, err)
+		}
+	}
+
+	return added, nil
+}
+
+// collectSitemapEntries returns the article URLs of a sitemap that fall in
+// the backfill window, descending into sitemap indexes
+func (ns *NewsService) collectSitemapEntries(sitemapURL string, opts BackfillOptions, depth int) ([]sitemapEntry, error) {
+	if depth > 3 {
+		return nil, nil
+	}
+
+	doc, err := ns.fetchSitemap(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []sitemapEntry{}
+	for _, child := range doc.Sitemaps {
+		// Child sitemaps last modified before the window can't hold new articles
+		if lastMod, ok := parseSitemapTime(child.LastMod); ok && lastMod.Before(opts.From) {
+			continue
+		}
+		childEntries, err := ns.collectSitemapEntries(strings.TrimSpace(child.Loc), opts, depth+1)
+		if err != nil {
+			log.Printf("Error reading sitemap %s: %v", child.Loc, err)
+			continue
+		}
+		entries = append(entries, childEntries...)
+		if len(entries) >= opts.MaxArticles {
+			return entries, nil
+		}
+	}
+
+	for _, u := range doc.URLs {
+		published, ok := parseSitemapTime(u.News.PublicationDate)
+		if !ok {
+			published, ok = parseSitemapTime(u.LastMod)
+		}
+		if !ok || published.Before(opts.From) || published.After(opts.To) {
+			continue
+		}
+		entries = append(entries, sitemapEntry{
+			URL:         strings.TrimSpace(u.Loc),
+			Title:       strings.TrimSpace(u.News.Title),
+			PublishedAt: published.UTC(),
+		})
+	}
+
+	return entries, nil
+}
+
+// fetchSitemap downloads and decodes a sitemap document
+func (ns *NewsService) fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequest("GET", sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := ns.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: %v", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code for sitemap %s: %d", sitemapURL, resp.StatusCode)
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %v", sitemapURL, err)
+	}
+	return &doc, nil
+}
+
+// backfillArticle builds an article for a sitemap entry from its own page
+func (ns *NewsService) backfillArticle(sourceName string, index int, entry sitemapEntry) (models.NewsArticle, error) {
+	article := models.NewsArticle{
+		ID:          fmt.Sprintf("%s_backfill_%d", sourceName, index),
+		Title:       entry.Title,
+		Key:         articleKey(entry.URL),
+		URL:         entry.URL,
+		Source:      sourceName,
+		PublishedAt: entry.PublishedAt,
+	}
+
+	source := ns.sources[sourceName]
+	page, _, err := ns.fetchArticlePage(entry.URL, source, pageValidators{})
+	if err != nil {
+		return article, err
+	}
+	if article.Title == "" {
+		article.Title = pageTitle(page.Doc)
+	}
+	if article.Title == "" {
+		return article, fmt.Errorf("no title found for %s", entry.URL)
+	}
+	ns.enrichArticle(page, &article, enabledStages(source, nil), nil)
+
+	return article, nil
+}
+
+// parseSitemapTime parses the W3C datetime formats used in sitemaps
+func parseSitemapTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"top-news/models"
+	"top-news/scraper"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//go:embed fixtures/bench/*.html
+var benchFixtures embed.FS
+
+// BenchOptions configure BenchCases. Dir holds homepage and article pages
+// named <source>_home.html and <source>_article.html, e.g. saved
+// snapshots, and replaces the built-in fixtures it has files for. Articles
+// is how many articles the JSON encoding benchmarks write. Filter, a
+// regular expression, picks benchmarks by name
+type BenchOptions struct {
+	Dir      string
+	Articles int
+	Filter   string
+}
+
+// BenchCase is one operation of the benchmark suite: homepage parsing,
+// article enrichment or JSON encoding. PerOp counts the articles Run
+// handles each time, for reporting throughput. The suite is timed by
+// go test -bench and by newsctl bench
+type BenchCase struct {
+	Name  string
+	PerOp int
+	Run   func() error
+}
+
+// BenchCases sets up the benchmark suite against fixture pages, so
+// selector changes that slow scraping down show up before they are
+// deployed
+func BenchCases(opts BenchOptions) ([]BenchCase, error) {
+	if opts.Articles < 1 {
+		opts.Articles = 100
+	}
+	filter, err := regexp.Compile(opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+
+	ns := NewNewsService()
+	names := make([]string, 0, len(ns.sources))
+	for name := range ns.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cases := []BenchCase{}
+	add := func(name string, perOp int, run func() error) {
+		if filter.MatchString(name) {
+			cases = append(cases, BenchCase{Name: name, PerOp: perOp, Run: run})
+		}
+	}
+
+	for _, name := range names {
+		source := ns.sources[name]
+		home, err := benchPage(opts.Dir, name+"_home.html")
+		if err != nil {
+			return nil, err
+		}
+		if home != nil {
+			scrapeOpts := scraper.Options{Limit: 100, Transport: replayTransport(home)}
+			// Parse once up front to know how many articles each run finds
+			found, _, err := scraper.Homepage(context.Background(), name, source.URL, scrapeOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s homepage: %v", name, err)
+			}
+			add("parse/"+name, len(found), func() error {
+				_, _, err := scraper.Homepage(context.Background(), name, source.URL, scrapeOpts)
+				return err
+			})
+		}
+
+		page, err := benchPage(opts.Dir, name+"_article.html")
+		if err != nil {
+			return nil, err
+		}
+		if page != nil {
+			stages := enabledStages(source, nil)
+			add("enrich/"+name, 1, func() error {
+				doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+				if err != nil {
+					return err
+				}
+				article := models.NewsArticle{ID: "bench", URL: source.URL, Source: name}
+				ns.enrichArticle(&articlePage{URL: source.URL, Source: source, Doc: doc}, &article, stages, nil)
+				return nil
+			})
+		}
+	}
+
+	articles, err := benchArticles(opts.Articles)
+	if err != nil {
+		return nil, err
+	}
+	response := models.NewsResponse{Success: true, Data: articles, Count: len(articles)}
+	add("encode/"+strconv.Itoa(opts.Articles), opts.Articles, func() error {
+		_, err := json.Marshal(response)
+		return err
+	})
+	buf := []byte{}
+	add("encode-fast/"+strconv.Itoa(opts.Articles), opts.Articles, func() error {
+		buf = appendNewsResponse(buf[:0], response)
+		return nil
+	})
+	return cases, nil
+}
+
+// benchPage reads a fixture page from dir, falling back to the built-in
+// fixtures. It returns nil when neither has the page
+func benchPage(dir, name string) ([]byte, error) {
+	if dir != "" {
+		page, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return page, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+	}
+	page, err := benchFixtures.ReadFile("fixtures/bench/" + name)
+	if err != nil {
+		return nil, nil
+	}
+	return page, nil
+}
+
+// benchArticles repeats the mock articles until there are n of them
+func benchArticles(n int) ([]models.NewsArticle, error) {
+	var fixtures map[string][]models.NewsArticle
+	if err := json.Unmarshal(mockFixtures, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to decode mock fixtures: %v", err)
+	}
+	all := []models.NewsArticle{}
+	for _, source := range []string{"thedailystar", "cnn"} {
+		for _, article := range fixtures[source] {
+			article.Source = source
+			all = append(all, article)
+		}
+	}
+	articles := make([]models.NewsArticle, n)
+	for i := range articles {
+		articles[i] = all[i%len(all)]
+		articles[i].ID = fmt.Sprintf("%s_%d", articles[i].Source, i)
+		articles[i].Key = articleKey(articles[i].URL)
+	}
+	return articles, nil
+}
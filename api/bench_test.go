@@ -0,0 +1,22 @@
+package handler
+
+import "testing"
+
+// BenchmarkSuite runs the benchmark suite that newsctl bench runs, one
+// sub-benchmark per case, e.g. BenchmarkSuite/parse/cnn
+func BenchmarkSuite(b *testing.B) {
+	cases, err := BenchCases(BenchOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bench := range cases {
+		b.Run(bench.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bench.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
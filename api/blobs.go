@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBlobSize caps how much of a snapshot object is read
+const maxBlobSize = 10 << 20
+
+// vercelBlobAPI is where Vercel Blob uploads go, a variable so it can point
+// elsewhere
+var vercelBlobAPI = "https://blob.vercel-storage.com"
+
+// blobSnapshots keeps each source's latest news response as a JSON object
+// in blob storage. Scrapes upload it, and plain source requests are served
+// from it, so a cold serverless function answers without scraping
+type blobSnapshots struct {
+	readURL     string
+	uploadURL   string
+	uploadToken string
+	vercel      bool
+	maxAge      time.Duration
+	client      *http.Client
+
+	mu        sync.Mutex
+	objects   map[string]blobObject
+	published map[string][32]byte
+}
+
+// blobObject is a snapshot as last read from storage
+type blobObject struct {
+	etag     string
+	body     []byte
+	modified time.Time
+}
+
+// newBlobSnapshots reads NEWS_BLOB_URL, the public base URL snapshots are
+// read from, and where they are uploaded to: NEWS_BLOB_UPLOAD_URL with the
+// bearer token NEWS_BLOB_UPLOAD_TOKEN, or Vercel Blob under
+// NEWS_BLOB_PREFIX (default news) with BLOB_READ_WRITE_TOKEN. Snapshots
+// older than NEWS_BLOB_MAX_AGE (default 1h) are not served. It returns nil
+// when neither reading nor uploading is set up
+func newBlobSnapshots() *blobSnapshots {
+	b := &blobSnapshots{
+		readURL:     strings.TrimRight(os.Getenv("NEWS_BLOB_URL"), "/"),
+		uploadURL:   strings.TrimRight(os.Getenv("NEWS_BLOB_UPLOAD_URL"), "/"),
+		uploadToken: secretEnv("NEWS_BLOB_UPLOAD_TOKEN"),
+		maxAge:      time.Hour,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		objects:     map[string]blobObject{},
+		published:   map[string][32]byte{},
+	}
+	if token := secretEnv("BLOB_READ_WRITE_TOKEN"); token != "" && b.uploadURL == "" {
+		prefix := strings.Trim(os.Getenv("NEWS_BLOB_PREFIX"), "/")
+		if prefix == "" {
+			prefix = "news"
+		}
+		b.uploadURL, b.uploadToken, b.vercel = vercelBlobAPI+"/"+prefix, token, true
+	}
+	if value := os.Getenv("NEWS_BLOB_MAX_AGE"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			log.Printf("Invalid NEWS_BLOB_MAX_AGE %q, using %s", value, b.maxAge)
+		} else {
+			b.maxAge = maxAge
+		}
+	}
+	if b.readURL == "" && b.uploadURL == "" {
+		return nil
+	}
+	return b
+}
+
+// publish uploads a source's news response in the background, unless it
+// is the same as the one uploaded last
+func (b *blobSnapshots) publish(source string, articles []models.NewsArticle) {
+	if b == nil || b.uploadURL == "" {
+		return
+	}
+	body, err := json.Marshal(models.NewsResponse{Success: true, Data: articles, Count: len(articles), Source: source})
+	if err != nil {
+		log.Printf("Error encoding the %s snapshot: %v", source, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	b.mu.Lock()
+	if b.published[source] == sum {
+		b.mu.Unlock()
+		return
+	}
+	b.published[source] = sum
+	b.mu.Unlock()
+
+	go func() {
+		if err := b.upload(source, body); err != nil {
+			log.Printf("Error uploading the %s snapshot: %v", source, err)
+			b.mu.Lock()
+			delete(b.published, source)
+			b.mu.Unlock()
+		}
+	}()
+}
+
+// upload puts a snapshot object, overwriting the previous one
+func (b *blobSnapshots) upload(source string, body []byte) error {
+	req, err := http.NewRequest("PUT", b.uploadURL+"/"+source+".json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.uploadToken != "" {
+		req.Header.Set("Authorization", "Bearer "+b.uploadToken)
+	}
+	if b.vercel {
+		// Keep the object's path stable, so its public URL never changes
+		req.Header.Set("x-api-version", "7")
+		req.Header.Set("x-content-type", "application/json")
+		req.Header.Set("x-add-random-suffix", "0")
+		req.Header.Set("x-allow-overwrite", "1")
+		req.Header.Set("x-cache-control-max-age", "60")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// fetch reads a source's snapshot, revalidating the copy read before with
+// its ETag
+func (b *blobSnapshots) fetch(source string) (blobObject, error) {
+	b.mu.Lock()
+	cached, hasCached := b.objects[source]
+	b.mu.Unlock()
+
+	req, err := http.NewRequest("GET", b.readURL+"/"+source+".json", nil)
+	if err != nil {
+		return blobObject{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	if hasCached && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return blobObject{}, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return blobObject{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+	if err != nil {
+		return blobObject{}, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	object := blobObject{etag: resp.Header.Get("ETag"), body: body, modified: time.Now()}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		object.modified = modified
+	}
+	if !strings.HasPrefix(strings.TrimPrefix(object.etag, "W/"), `"`) {
+		object.etag = ""
+	}
+	b.mu.Lock()
+	b.objects[source] = object
+	b.mu.Unlock()
+	return object, nil
+}
+
+// serve answers a source request from its snapshot, with the snapshot's
+// ETag. It returns false, having written nothing, when the request needs
+// more than the plain response, or the snapshot is missing or older than
+// maxAge
+func (b *blobSnapshots) serve(c *gin.Context, source string) bool {
+	if b == nil || b.readURL == "" || c.Request.URL.RawQuery != "" || currentTenant(c) != nil {
+		return false
+	}
+	object, err := b.fetch(source)
+	if err != nil {
+		log.Printf("Error reading the %s snapshot, scraping instead: %v", source, err)
+		return false
+	}
+	if time.Since(object.modified) > b.maxAge {
+		return false
+	}
+	var snapshot struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(object.body, &snapshot); err != nil {
+		log.Printf("Error reading the %s snapshot, scraping instead: %v", source, err)
+		return false
+	}
+	if object.etag != "" {
+		c.Header("ETag", object.etag)
+	}
+	c.Header("X-Snapshot-Modified", object.modified.UTC().Format(http.TimeFormat))
+	// The snapshot was uploaded right after the scrape it holds
+	setFreshnessHeaders(c, snapshot.Count, &object.modified)
+	setSurrogateKeys(c, []string{cdnAllKey, sourceKey(source)})
+	c.Data(http.StatusOK, "application/json; charset=utf-8", object.body)
+	return true
+}
@@ -0,0 +1,372 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// briefingHeadlines is how many headlines each source contributes
+const briefingHeadlines = 4
+
+// briefingFeedPath is where the podcast feed is served
+const briefingFeedPath = "/api/v1/briefing/feed.xml"
+
+// speechSynthesizer turns a briefing script into MP3 audio
+type speechSynthesizer interface {
+	synthesize(text string) ([]byte, error)
+}
+
+// newSpeechSynthesizer picks a TTS provider from TTS_PROVIDER ("openai" or
+// "google"), returning nil when audio briefings are not configured
+func newSpeechSynthesizer() speechSynthesizer {
+	provider := os.Getenv("TTS_PROVIDER")
+	if provider == "" {
+		return nil
+	}
+	apiKey := secretEnv("TTS_API_KEY")
+	if apiKey == "" {
+		log.Printf("TTS_PROVIDER is %s but TTS_API_KEY is not set, audio briefings are disabled", provider)
+		return nil
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	voice := os.Getenv("TTS_VOICE")
+
+	switch provider {
+	case "openai":
+		if voice == "" {
+			voice = "alloy"
+		}
+		model := os.Getenv("TTS_MODEL")
+		if model == "" {
+			model = "tts-1"
+		}
+		return &openAISpeech{client: client, apiKey: apiKey, voice: voice, model: model}
+	case "google":
+		if voice == "" {
+			voice = "en-US-Neural2-D"
+		}
+		return &googleSpeech{client: client, apiKey: apiKey, voice: voice}
+	default:
+		log.Printf("Unknown TTS_PROVIDER %q, audio briefings are disabled", provider)
+		return nil
+	}
+}
+
+// openAISpeech uses the OpenAI audio speech API
+type openAISpeech struct {
+	client *http.Client
+	apiKey string
+	voice  string
+	model  string
+}
+
+func (s *openAISpeech) synthesize(text string) ([]byte, error) {
+	payload, err := json.Marshal(map[string]string{
+		"model":           s.model,
+		"voice":           s.voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode TTS request: %v", err)
+	}
+	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/speech", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TTS request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	return readTTSResponse(s.client.Do(req))
+}
+
+// googleSpeech uses the Google Cloud Text-to-Speech API
+type googleSpeech struct {
+	client *http.Client
+	apiKey string
+	voice  string
+}
+
+func (s *googleSpeech) synthesize(text string) ([]byte, error) {
+	// Voice names start with their language code, e.g. bn-IN-Standard-A
+	language := s.voice
+	if parts := strings.SplitN(s.voice, "-", 3); len(parts) == 3 {
+		language = parts[0] + "-" + parts[1]
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"input":       map[string]string{"text": text},
+		"voice":       map[string]string{"languageCode": language, "name": s.voice},
+		"audioConfig": map[string]string{"audioEncoding": "MP3"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode TTS request: %v", err)
+	}
+	req, err := http.NewRequest("POST", "https://texttospeech.googleapis.com/v1/text:synthesize?key="+s.apiKey, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TTS request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := readTTSResponse(s.client.Do(req))
+	if err != nil {
+		return nil, err
+	}
+	var decoded struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode TTS response: %v", err)
+	}
+	return base64.StdEncoding.DecodeString(decoded.AudioContent)
+}
+
+// readTTSResponse reads a provider response body, failing on non-200s
+func readTTSResponse(resp *http.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, fmt.Errorf("TTS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TTS response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TTS provider returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// briefingStore keeps one generated MP3 briefing per day on disk
+type briefingStore struct {
+	mu       sync.Mutex
+	dir      string
+	synth    speechSynthesizer
+	location *time.Location
+}
+
+// newBriefingStore configures audio briefings from TTS_PROVIDER,
+// BRIEFING_DIR and BRIEFING_TIMEZONE. It returns nil when no TTS provider
+// is configured
+func newBriefingStore() *briefingStore {
+	synth := newSpeechSynthesizer()
+	if synth == nil {
+		return nil
+	}
+
+	dir := os.Getenv("BRIEFING_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "top-news-briefings")
+	}
+	location := time.UTC
+	if name := os.Getenv("BRIEFING_TIMEZONE"); name != "" {
+		if loaded, err := time.LoadLocation(name); err == nil {
+			location = loaded
+		} else {
+			log.Printf("Invalid BRIEFING_TIMEZONE %q, using UTC: %v", name, err)
+		}
+	} else if loaded, err := time.LoadLocation("Asia/Dhaka"); err == nil {
+		location = loaded
+	}
+
+	return &briefingStore{dir: dir, synth: synth, location: location}
+}
+
+// path returns where the briefing for date is stored
+func (b *briefingStore) path(date string) string {
+	return filepath.Join(b.dir, date+".mp3")
+}
+
+// briefingAudio returns the MP3 for date, generating today's on first use
+func (ns *NewsService) briefingAudio(date string) ([]byte, error) {
+	b := ns.briefings
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if audio, err := os.ReadFile(b.path(date)); err == nil {
+		return audio, nil
+	}
+	if date != time.Now().In(b.location).Format("2006-01-02") {
+		return nil, os.ErrNotExist
+	}
+
+	script := ns.briefingScript(time.Now().In(b.location))
+	audio, err := b.synth.synthesize(script)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create briefing dir: %v", err)
+	}
+	if err := os.WriteFile(b.path(date), audio, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write briefing: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(b.dir, date+".txt"), []byte(script), 0o644); err != nil {
+		log.Printf("Error writing briefing script for %s: %v", date, err)
+	}
+	ns.websub.notify(briefingFeedPath)
+	return audio, nil
+}
+
+// briefingScript reads out the top headlines of every active source
+func (ns *NewsService) briefingScript(now time.Time) string {
+	names := []string{}
+	for name, source := range ns.sources {
+		if source.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var script strings.Builder
+	fmt.Fprintf(&script, "Here is your Top News briefing for %s.\n\n", now.Format("Monday, 2 January 2006"))
+	for _, name := range names {
+		news, err := ns.fetchNewsFromSource(name, ns.sources[name].URL, scrapeOptions{limit: briefingHeadlines, enrich: []string{}})
+		if err != nil || len(news) == 0 {
+			log.Printf("Error fetching %s headlines for the briefing: %v", name, err)
+			continue
+		}
+		fmt.Fprintf(&script, "From %s.\n", ns.sources[name].DisplayName)
+		for _, article := range news {
+			title := strings.TrimRight(strings.TrimSpace(article.Title), ".")
+			fmt.Fprintf(&script, "%s.\n", title)
+		}
+		script.WriteString("\n")
+	}
+	script.WriteString("That's the briefing. Full stories are available from each source.")
+	return script.String()
+}
+
+// GetBriefing serves the MP3 briefing for ?date=YYYY-MM-DD, today by default
+func (ns *NewsService) GetBriefing(c *gin.Context) {
+	if ns.briefings == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "briefing_disabled",
+			Message: "Audio briefings are not configured, set TTS_PROVIDER and TTS_API_KEY",
+		})
+		return
+	}
+
+	date := c.DefaultQuery("date", time.Now().In(ns.briefings.location).Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_date",
+			Message: "date must be formatted as YYYY-MM-DD",
+		})
+		return
+	}
+
+	audio, err := ns.briefingAudio(date)
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "briefing_not_found",
+			Message: fmt.Sprintf("No briefing was generated on %s", date),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error generating briefing for %s: %v", date, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "briefing_failed",
+			Message: "Failed to generate the audio briefing",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "audio/mpeg", audio)
+}
+
+// podcastFeed is an RSS 2.0 podcast feed with the iTunes extensions
+type podcastFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	ITunes  string   `xml:"xmlns:itunes,attr"`
+	Atom    string   `xml:"xmlns:atom,attr"`
+	Channel struct {
+		Links       []atomLink       `xml:"atom:link"`
+		Title       string           `xml:"title"`
+		Link        string           `xml:"link"`
+		Description string           `xml:"description"`
+		Language    string           `xml:"language"`
+		Author      string           `xml:"itunes:author"`
+		Items       []podcastEpisode `xml:"item"`
+	} `xml:"channel"`
+}
+
+type podcastEpisode struct {
+	Title     string `xml:"title"`
+	GUID      string `xml:"guid"`
+	PubDate   string `xml:"pubDate"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"enclosure"`
+}
+
+// GetBriefingFeed serves a podcast RSS feed of the stored briefings
+func (ns *NewsService) GetBriefingFeed(c *gin.Context) {
+	if ns.briefings == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "briefing_disabled",
+			Message: "Audio briefings are not configured, set TTS_PROVIDER and TTS_API_KEY",
+		})
+		return
+	}
+
+	base := requestBaseURL(c)
+
+	feed := podcastFeed{Version: "2.0", ITunes: "http://www.itunes.com/dtds/podcast-1.0.dtd", Atom: "http://www.w3.org/2005/Atom"}
+	feed.Channel.Links = ns.websub.advertise(c, base+briefingFeedPath)
+	feed.Channel.Title = "Top News Daily Briefing"
+	feed.Channel.Link = base + "/api/v1/briefing.mp3"
+	feed.Channel.Description = "A short daily audio briefing of the top headlines."
+	feed.Channel.Language = "en"
+	feed.Channel.Author = "Top News"
+
+	files, _ := filepath.Glob(filepath.Join(ns.briefings.dir, "*.mp3"))
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	for _, file := range files {
+		date := strings.TrimSuffix(filepath.Base(file), ".mp3")
+		day, err := time.ParseInLocation("2006-01-02", date, ns.briefings.location)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		episode := podcastEpisode{
+			Title:   "Briefing for " + day.Format("2 January 2006"),
+			GUID:    "top-news-briefing-" + date,
+			PubDate: info.ModTime().Format(time.RFC1123Z),
+		}
+		episode.Enclosure.URL = base + "/api/v1/briefing.mp3?date=" + date
+		episode.Enclosure.Length = info.Size()
+		episode.Enclosure.Type = "audio/mpeg"
+		feed.Channel.Items = append(feed.Channel.Items, episode)
+	}
+
+	c.XML(http.StatusOK, feed)
+}
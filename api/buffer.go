@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"top-news/models"
+)
+
+// defaultSourceLimit is the most articles a source returns when the
+// request sets no limit, used to size buffers up front
+const defaultSourceLimit = 15
+
+// maxResponseArticles reads MAX_RESPONSE_ARTICLES, the hard cap on the
+// articles in one response, defaulting to 200
+func maxResponseArticles() int {
+	max := 200
+	if value := os.Getenv("MAX_RESPONSE_ARTICLES"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid MAX_RESPONSE_ARTICLES %q, using %d", value, max)
+		} else {
+			max = parsed
+		}
+	}
+	return max
+}
+
+// articleBuffer merges the articles of concurrent scrapes into a single
+// slice allocated once, dropping whatever would go past max
+type articleBuffer struct {
+	mu       sync.Mutex
+	articles []models.NewsArticle
+	max      int
+	dropped  int
+}
+
+// newArticleBuffer sizes the buffer for the expected number of articles,
+// never more than max
+func newArticleBuffer(expected, max int) *articleBuffer {
+	if expected > max {
+		expected = max
+	}
+	return &articleBuffer{articles: make([]models.NewsArticle, 0, expected), max: max}
+}
+
+// add appends as many articles as still fit
+func (b *articleBuffer) add(articles []models.NewsArticle) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := b.max - len(b.articles)
+	if len(articles) > room {
+		b.dropped += len(articles) - room
+		articles = articles[:room]
+	}
+	b.articles = append(b.articles, articles...)
+}
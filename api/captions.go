@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// creditPattern finds a photo credit at the end of a caption, e.g. "Photo:
+// Star", "(Photo: Reuters)", "File photo: AFP", "Photo/Collected" or
+// "ছবি: সংগৃহীত"
+var creditPattern = regexp.MustCompile(`(?i)(?:^|[\s(\[|–—-]+)(?:file\s+)?(?:photos?|photograph|picture|image|credit|courtesy|ছবি)(?:\s+by)?\s*[:/]\s*([^()\[\]:]{1,80}?)[\s)\]]*\.?$`)
+
+// Where captions and credits sit in figure markup
+const (
+	captionSelector = "figcaption, .caption, [class*='caption']"
+	creditSelector  = "[class*='credit'], [class*='copyright'], .photographer"
+)
+
+// leadFigureSelectors locate the figure of an article's lead image when no
+// figure shows the image itself
+var leadFigureSelectors = []string{
+	"div.section-media",
+	".image__lede",
+	".article__lede",
+	"article figure",
+}
+
+// imageCaption finds the caption and credit of an article's lead image,
+// from the JSON-LD image object or the figure that shows the image. It
+// returns how it found them, "" when it found neither
+func imageCaption(page *articlePage, imageURL string) (caption, credit, method string) {
+	if object := jsonLDImageObject(page.structured()); object != nil {
+		caption, credit = splitCredit(jsonLDString(object, "caption"))
+		for _, key := range []string{"creditText", "copyrightHolder", "author"} {
+			if value := jsonLDString(object, key, "name"); value != "" {
+				credit = value
+				break
+			}
+		}
+		if caption != "" || credit != "" {
+			return caption, credit, "json-ld image caption"
+		}
+	}
+
+	figure, selector := leadFigure(page.Doc, imageURL)
+	if figure == nil {
+		return "", "", ""
+	}
+	caption, credit = figureCaption(figure)
+	if caption == "" && credit == "" {
+		return "", "", ""
+	}
+	return caption, credit, selector + " " + captionSelector
+}
+
+// jsonLDImageObject returns the article's first image when JSON-LD
+// describes it as an ImageObject rather than a bare URL
+func jsonLDImageObject(article map[string]interface{}) map[string]interface{} {
+	switch image := article["image"].(type) {
+	case map[string]interface{}:
+		return image
+	case []interface{}:
+		if len(image) > 0 {
+			object, _ := image[0].(map[string]interface{})
+			return object
+		}
+	}
+	return nil
+}
+
+// leadFigure finds the figure showing an image, by its file name, falling
+// back to the page's lead media. It returns the selector that matched
+func leadFigure(doc *goquery.Document, imageURL string) (*goquery.Selection, string) {
+	if parsed, err := url.Parse(imageURL); err == nil {
+		if name := path.Base(parsed.Path); name != "." && name != "/" {
+			var found *goquery.Selection
+			doc.Find("figure").EachWithBreak(func(i int, s *goquery.Selection) bool {
+				s.Find("img, source").EachWithBreak(func(i int, img *goquery.Selection) bool {
+					for _, attr := range []string{"src", "data-src", "srcset", "data-srcset"} {
+						if strings.Contains(img.AttrOr(attr, ""), name) {
+							found = s
+							return false
+						}
+					}
+					return true
+				})
+				return found == nil
+			})
+			if found != nil {
+				return found, "figure"
+			}
+		}
+	}
+	for _, selector := range leadFigureSelectors {
+		if match := doc.Find(selector).First(); match.Length() > 0 {
+			return match, selector
+		}
+	}
+	return nil, ""
+}
+
+// figureCaption reads the caption and credit of a figure. Credits are taken
+// from their own element when the markup has one and split off the end of
+// the caption otherwise
+func figureCaption(figure *goquery.Selection) (caption, credit string) {
+	credit = strings.Join(strings.Fields(figure.Find(creditSelector).First().Text()), " ")
+	captionElement := figure.Find(captionSelector).First().Clone()
+	captionElement.Find(creditSelector).Remove()
+	caption, split := splitCredit(captionElement.Text())
+	if credit == "" {
+		credit = split
+	}
+	return caption, strings.TrimSpace(creditPattern.ReplaceAllString(credit, "$1"))
+}
+
+// splitCredit separates a trailing photo credit from a caption
+func splitCredit(text string) (caption, credit string) {
+	text = strings.Join(strings.Fields(text), " ")
+	match := creditPattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		return text, ""
+	}
+	return strings.TrimRight(text[:match[0]], " ,;|/–—-"), strings.TrimSpace(text[match[2]:match[3]])
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cdnAllKey tags every news response, so one purge clears them all
+const cdnAllKey = "news"
+
+// surrogateKeys lists the cache keys of a news response: one for all news,
+// one per source and one per category in it
+func surrogateKeys(articles []models.NewsArticle, source string) []string {
+	seen := map[string]bool{cdnAllKey: true}
+	if source != "" {
+		seen[sourceKey(source)] = true
+	}
+	for _, article := range articles {
+		seen[sourceKey(article.Source)] = true
+		if article.Category != "" {
+			seen[categoryKey(article.Category)] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sourceKey(source string) string {
+	return "source-" + source
+}
+
+// categoryKey turns "Middle East" into "category-middle-east"; keys can not
+// hold spaces or commas
+func categoryKey(category string) string {
+	return "category-" + strings.Join(strings.FieldsFunc(strings.ToLower(category), func(r rune) bool {
+		return r == ' ' || r == ',' || r == '/'
+	}), "-")
+}
+
+// setSurrogateKeys tags a response for CDNs: Surrogate-Key for Fastly and
+// Cache-Tag for Cloudflare
+func setSurrogateKeys(c *gin.Context, keys []string) {
+	c.Header("Surrogate-Key", strings.Join(keys, " "))
+	c.Header("Cache-Tag", strings.Join(keys, ","))
+}
+
+// cdnPurger invalidates cached responses on Fastly and Cloudflare by key
+// when a scrape finds new or changed articles
+type cdnPurger struct {
+	fastlyService, fastlyToken string
+	cloudflareZone, cloudflare string
+	client                     *http.Client
+}
+
+// newCDNPurger configures purging from FASTLY_SERVICE_ID and
+// FASTLY_API_TOKEN, and CLOUDFLARE_ZONE_ID and CLOUDFLARE_API_TOKEN (a
+// token with the Cache Purge permission). It returns nil when neither CDN
+// is set up
+func newCDNPurger() *cdnPurger {
+	p := &cdnPurger{
+		fastlyService:  os.Getenv("FASTLY_SERVICE_ID"),
+		fastlyToken:    secretEnv("FASTLY_API_TOKEN"),
+		cloudflareZone: os.Getenv("CLOUDFLARE_ZONE_ID"),
+		cloudflare:     secretEnv("CLOUDFLARE_API_TOKEN"),
+		client:         &http.Client{Timeout: 15 * time.Second},
+	}
+	if (p.fastlyService == "") != (p.fastlyToken == "") {
+		log.Printf("Fastly purging is disabled: set both FASTLY_SERVICE_ID and FASTLY_API_TOKEN")
+		p.fastlyService, p.fastlyToken = "", ""
+	}
+	if (p.cloudflareZone == "") != (p.cloudflare == "") {
+		log.Printf("Cloudflare purging is disabled: set both CLOUDFLARE_ZONE_ID and CLOUDFLARE_API_TOKEN")
+		p.cloudflareZone, p.cloudflare = "", ""
+	}
+	if p.fastlyService == "" && p.cloudflareZone == "" {
+		return nil
+	}
+	return p
+}
+
+// subscribe purges a source's responses whenever its articles change
+func (p *cdnPurger) subscribe(bus *eventBus) {
+	bus.subscribe("cdn", func(event models.Event) {
+		// Leave out the aggregate key, which would wipe every source's
+		// cache; the aggregate responses carry the source's key too
+		keys := []string{}
+		for _, key := range surrogateKeys(event.Articles, event.Source) {
+			if key != cdnAllKey {
+				keys = append(keys, key)
+			}
+		}
+		if err := p.purge(keys); err != nil {
+			log.Printf("Error purging %s from the CDN: %v", strings.Join(keys, " "), err)
+		}
+	}, eventArticleDiscovered, eventArticleUpdated)
+}
+
+// purge invalidates the keys on every configured CDN
+func (p *cdnPurger) purge(keys []string) error {
+	var errs []string
+	if p.fastlyService != "" {
+		if err := p.purgeFastly(keys); err != nil {
+			errs = append(errs, "fastly: "+err.Error())
+		}
+	}
+	if p.cloudflareZone != "" {
+		if err := p.purgeCloudflare(keys); err != nil {
+			errs = append(errs, "cloudflare: "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (p *cdnPurger) purgeFastly(keys []string) error {
+	req, err := http.NewRequest("POST", "https://api.fastly.com/service/"+p.fastlyService+"/purge", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Fastly-Key", p.fastlyToken)
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (p *cdnPurger) purgeCloudflare(keys []string) error {
+	// Cloudflare takes at most 30 tags per call
+	for start := 0; start < len(keys); start += 30 {
+		end := start + 30
+		if end > len(keys) {
+			end = len(keys)
+		}
+		err := postJSON(p.client, "https://api.cloudflare.com/client/v4/zones/"+p.cloudflareZone+"/purge_cache",
+			map[string][]string{"tags": keys[start:end]},
+			map[string]string{"Authorization": "Bearer " + p.cloudflare})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeAllNews purges every cached news response in the background, for
+// changes such as editorial overrides that can touch any of them
+func (ns *NewsService) purgeAllNews() {
+	if ns.cdn == nil {
+		return
+	}
+	go func() {
+		if err := ns.cdn.purge([]string{cdnAllKey}); err != nil {
+			log.Printf("Error purging news from the CDN: %v", err)
+		}
+	}()
+}
+
+// PurgeCDN invalidates cached responses by surrogate key
+func (ns *NewsService) PurgeCDN(c *gin.Context) {
+	if ns.cdn == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Success: false,
+			Error:   "cdn_not_configured",
+			Message: "Set FASTLY_SERVICE_ID and FASTLY_API_TOKEN or CLOUDFLARE_ZONE_ID and CLOUDFLARE_API_TOKEN to purge",
+		})
+		return
+	}
+	var request struct {
+		Keys []string `json:"keys"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Keys) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: `Send {"keys": [...]}, e.g. "news", "source-cnn" or "category-sport"`,
+		})
+		return
+	}
+	if err := ns.cdn.purge(request.Keys); err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "purge_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	ns.audit.record(c, "cdn.purge", strings.Join(request.Keys, " "), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true, "purged": request.Keys})
+}
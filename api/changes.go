@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChanges serves what changed in the store after since=<RFC 3339 time>:
+// the articles published since then and tombstones for the ones removed,
+// of one source with source=<name> or of all. Clients pass the until of
+// each answer as the since of the next call
+func (ns *NewsService) GetChanges(c *gin.Context) {
+	since, err := time.Parse(time.RFC3339, c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_since",
+			Message: "since must be an RFC 3339 time, e.g. 2026-01-02T15:04:05Z",
+		})
+		return
+	}
+	tenant := currentTenant(c)
+	source := c.Query("source")
+	if _, exists := ns.sources[source]; source != "" && (!exists || !tenant.allows(source)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "source_not_found",
+			Message: "News source not found",
+		})
+		return
+	}
+
+	until := time.Now().UTC()
+	articles := []models.NewsArticle{}
+	// Since is exclusive, so the until of one call can be the next since
+	for _, article := range ns.store.List(store.Filter{Source: source, Since: since.Add(time.Nanosecond), Until: until}) {
+		if tenant.allows(article.Source) {
+			articles = append(articles, article)
+		}
+	}
+	deleted := []models.Tombstone{}
+	for _, tombstone := range ns.store.Tombstones(since, source) {
+		if tenant.allows(tombstone.Source) && tombstone.RemovedAt.Before(until) {
+			deleted = append(deleted, tombstone)
+		}
+	}
+	articles = tenant.filter(articles)
+	c.JSON(http.StatusOK, models.ChangesResponse{
+		Success: true,
+		Since:   since,
+		Until:   until,
+		Data:    articles,
+		Count:   len(articles),
+		Deleted: deleted,
+	})
+}
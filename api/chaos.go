@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaosStatuses are the upstream failures chaos mode fakes
+var chaosStatuses = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// chaosConfig is the fault injection an admin has switched on. It is off
+// by default and is never persisted, so a restart always turns it off
+type chaosConfig struct {
+	mu       sync.RWMutex
+	settings models.ChaosSettings
+	stats    models.ChaosStats
+}
+
+func newChaosConfig() *chaosConfig {
+	return &chaosConfig{}
+}
+
+// transport wraps next (the default transport when nil) so every upstream
+// request can be slowed down, failed or cut short
+func (cc *chaosConfig) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &chaosTransport{next: next, config: cc}
+}
+
+// chaosTransport injects faults into upstream fetches
+type chaosTransport struct {
+	next   http.RoundTripper
+	config *chaosConfig
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cc := t.config
+	cc.mu.RLock()
+	settings := cc.settings
+	cc.mu.RUnlock()
+	if !settings.Enabled || (len(settings.Hosts) > 0 && !containsString(settings.Hosts, req.URL.Hostname())) {
+		return t.next.RoundTrip(req)
+	}
+	cc.count(func(stats *models.ChaosStats) { stats.Requests++ })
+
+	if settings.LatencyMS > 0 && rand.Float64() < settings.LatencyRate {
+		cc.count(func(stats *models.ChaosStats) { stats.Delays++ })
+		select {
+		case <-time.After(time.Duration(settings.LatencyMS) * time.Millisecond):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if rand.Float64() < settings.ErrorRate {
+		cc.count(func(stats *models.ChaosStats) { stats.Errors++ })
+		status := chaosStatuses[rand.Intn(len(chaosStatuses))]
+		body := fmt.Sprintf("chaos: injected %d", status)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"text/plain"}},
+			Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || rand.Float64() >= settings.TruncateRate {
+		return resp, err
+	}
+	cc.count(func(stats *models.ChaosStats) { stats.Truncations++ })
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = &truncatedBody{Reader: bytes.NewReader(body[:len(body)/2])}
+	return resp, nil
+}
+
+// truncatedBody ends with an unexpected EOF, like a dropped connection
+type truncatedBody struct {
+	*bytes.Reader
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return nil
+}
+
+// count updates the injection stats under the lock
+func (cc *chaosConfig) count(change func(stats *models.ChaosStats)) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	change(&cc.stats)
+}
+
+// GetChaos returns the fault injection settings and what has been injected
+func (ns *NewsService) GetChaos(c *gin.Context) {
+	ns.chaos.mu.RLock()
+	defer ns.chaos.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"success": true, "settings": ns.chaos.settings, "stats": ns.chaos.stats})
+}
+
+// SetChaos replaces the fault injection settings and resets the stats
+func (ns *NewsService) SetChaos(c *gin.Context) {
+	var settings models.ChaosSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Invalid chaos settings: %v", err),
+		})
+		return
+	}
+	for _, rate := range []float64{settings.ErrorRate, settings.LatencyRate, settings.TruncateRate} {
+		if rate < 0 || rate > 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_rate",
+				Message: "error_rate, latency_rate and truncate_rate must be between 0 and 1",
+			})
+			return
+		}
+	}
+	if settings.LatencyMS < 0 || settings.LatencyMS > 120000 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_latency",
+			Message: "latency_ms must be between 0 and 120000",
+		})
+		return
+	}
+
+	ns.chaos.mu.Lock()
+	previous := ns.chaos.settings
+	ns.chaos.settings = settings
+	ns.chaos.stats = models.ChaosStats{}
+	ns.chaos.mu.Unlock()
+	ns.audit.record(c, "chaos.update", "", previous, settings)
+	c.JSON(http.StatusOK, gin.H{"success": true, "settings": settings})
+}
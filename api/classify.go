@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentWarnings maps a warning to the words that trigger it. Matching is
+// on whole words of the folded title, description and tags
+var contentWarnings = []struct {
+	warning  string
+	keywords []string
+}{
+	{"sexual_violence", []string{"rape", "raped", "rapist", "sexual assault", "sexually assaulted", "molested", "ধর্ষণ", "ধর্ষিত", "ধর্ষক", "যৌন নিপীড়ন"}},
+	{"self_harm", []string{"suicide", "suicides", "took her own life", "took his own life", "self harm", "আত্মহত্যা", "আত্মহত্যার"}},
+	{"graphic_violence", []string{
+		"beheaded", "beheading", "decapitated", "dismembered", "mutilated", "massacre", "massacred",
+		"stabbed to death", "hacked to death", "beaten to death", "burnt alive", "burned alive", "lynched", "lynching", "gore",
+		"গলা কেটে", "কুপিয়ে হত্যা", "পিটিয়ে হত্যা", "গণপিটুনি", "পুড়িয়ে হত্যা",
+	}},
+	{"violence", []string{
+		"murder", "murdered", "killed", "killing", "shot dead", "gunman", "shooting", "bomb blast", "explosion", "terror attack", "corpse", "bodies",
+		"হত্যা", "খুন", "নিহত", "গুলি", "লাশ", "বিস্ফোরণ",
+	}},
+}
+
+// foldedWarnings holds contentWarnings keywords folded like article text
+var foldedWarnings = func() map[string][]string {
+	folded := map[string][]string{}
+	for _, group := range contentWarnings {
+		folded[group.warning] = foldKeywords(group.keywords)
+	}
+	return folded
+}()
+
+// Article types
+const (
+	typeNews     = "news"
+	typeOpinion  = "opinion"
+	typeAnalysis = "analysis"
+	// typeGallery marks photo galleries, whose photos are in Images
+	typeGallery = "gallery"
+	// typeAnnouncement covers obituaries, notices, horoscopes and prayer
+	// times, which default feeds leave out
+	typeAnnouncement = "announcement"
+)
+
+var articleTypes = []string{typeNews, typeOpinion, typeAnalysis, typeGallery, typeAnnouncement}
+
+// Opinion and analysis cues in URLs, headline labels and section names
+var (
+	opinionURLPattern    = regexp.MustCompile(`(?i)/(opinions?|op-?eds?|editorials?|views|perspectives?|columns?|columnists?|letters|commentary)(/|$)`)
+	analysisURLPattern   = regexp.MustCompile(`(?i)/(analysis|explainers?|in-focus|deep-dive|investigations?)(/|$)`)
+	opinionTitlePattern  = regexp.MustCompile(textnorm.NFC(`(?i)^(opinion|op-ed|editorial|column|letter|মতামত|সম্পাদকীয়)\s*[:|–-]`))
+	analysisTitlePattern = regexp.MustCompile(textnorm.NFC(`(?i)^(analysis|explainer|explained|বিশ্লেষণ)\s*[:|–-]`))
+	opinionSections      = []string{"opinion", "opinions", "editorial", "op-ed", "views", "columns", "মতামত", "সম্পাদকীয়", "উপসম্পাদকীয়"}
+	analysisSections     = []string{"analysis", "explainer", "বিশ্লেষণ"}
+)
+
+// articleType tells opinion and analysis pieces from news reporting using
+// the URL, headline label, section and byline
+func articleType(article models.NewsArticle) string {
+	switch {
+	case opinionURLPattern.MatchString(article.URL), opinionTitlePattern.MatchString(textnorm.NFC(article.Title)):
+		return typeOpinion
+	case analysisURLPattern.MatchString(article.URL), analysisTitlePattern.MatchString(textnorm.NFC(article.Title)):
+		return typeAnalysis
+	}
+
+	sections := append([]string{article.Category}, article.Tags...)
+	for _, section := range sections {
+		section = textnorm.ForSearch(section)
+		for _, name := range opinionSections {
+			if section == textnorm.ForSearch(name) {
+				return typeOpinion
+			}
+		}
+		for _, name := range analysisSections {
+			if section == textnorm.ForSearch(name) {
+				return typeAnalysis
+			}
+		}
+	}
+	if author := strings.ToLower(article.Author); strings.Contains(author, "editorial board") || strings.Contains(author, "editorial desk") {
+		return typeOpinion
+	}
+	return typeNews
+}
+
+// Sponsored content and wire copy markers
+var (
+	sponsoredURLPattern   = regexp.MustCompile(`(?i)/(sponsored|press-releases?|brand-stories|brandstories|partner-content|paid-post|advertorials?|corporate)(/|$)`)
+	sponsoredLabelPattern = regexp.MustCompile(textnorm.NFC(`(?i)^\W*(press release|sponsored|advertorial|partner content|paid post|paid content|প্রেস বিজ্ঞপ্তি|স্পনসরড)([^\pL\pM]|$)`))
+	sponsoredTagPattern   = regexp.MustCompile(textnorm.NFC(`(?i)\b(press release|sponsored|advertorial|partner content|paid post|brand story)\b|প্রেস বিজ্ঞপ্তি|স্পনসরড`))
+	wireBylinePattern     = regexp.MustCompile(`(?i)^(unb|bss|afp|reuters|ap|associated press|agence france-presse|ians|pti|xinhua|anadolu|bernama|united news of bangladesh|bangladesh sangbad sangstha)\b`)
+	wireDatelinePattern   = regexp.MustCompile(`^[A-Z][A-Za-z0-9 ,.]{2,40}\((UNB|BSS|AFP|Reuters|AP|IANS|PTI|Xinhua)\)`)
+)
+
+// isSponsored spots press releases and paid content by URL section,
+// headline labels, tags and byline
+func isSponsored(article models.NewsArticle) bool {
+	if sponsoredURLPattern.MatchString(article.URL) {
+		return true
+	}
+	if sponsoredLabelPattern.MatchString(textnorm.NFC(article.Title)) {
+		return true
+	}
+	for _, text := range append([]string{article.Author, article.Category}, article.Tags...) {
+		if sponsoredTagPattern.MatchString(textnorm.NFC(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWire spots agency copy by byline or a "DHAKA, May 1 (BSS) -" dateline
+func isWire(article models.NewsArticle) bool {
+	return wireBylinePattern.MatchString(strings.TrimSpace(article.Author)) ||
+		wireDatelinePattern.MatchString(strings.TrimSpace(article.Description)) ||
+		wireDatelinePattern.MatchString(strings.TrimSpace(article.Summary))
+}
+
+// articleText folds the parts of an article the classifiers read into
+// space-padded words
+func articleText(article models.NewsArticle) string {
+	return " " + strings.Join(textnorm.Words(article.Title+" "+article.Description+" "+strings.Join(article.Tags, " ")), " ") + " "
+}
+
+// classify tags articles with content warnings, their type and whether
+// they are sponsored or wire copy
+func classify(articles []models.NewsArticle) {
+	for i := range articles {
+		classifyArticle(&articles[i])
+	}
+}
+
+// classifyArticle tags a single article
+func classifyArticle(article *models.NewsArticle) {
+	article.ContentWarning = contentWarning(*article)
+	article.Type = articleType(*article)
+	if len(article.Images) > 0 || isGallery(*article) {
+		article.Type = typeGallery
+	}
+	if article.Announcement = announcementKind(*article); article.Announcement != "" {
+		article.Type = typeAnnouncement
+	}
+	article.IsSponsored = isSponsored(*article)
+	article.IsWire = isWire(*article)
+	article.Locations = geotag(*article)
+	article.Score = parseScore(*article)
+	article.Figures = extractFigures(*article)
+}
+
+// contentWarning returns the most severe warning that applies, or ""
+func contentWarning(article models.NewsArticle) string {
+	text := articleText(article)
+	for _, group := range contentWarnings {
+		for _, keyword := range foldedWarnings[group.warning] {
+			if strings.Contains(text, keyword) {
+				return group.warning
+			}
+		}
+	}
+	return ""
+}
+
+// servedFilter drops articles the request asked to exclude: safe=true
+// removes anything with a content warning, type=news,analysis keeps only
+// those types (announcements are left out unless asked for by type),
+// sponsored/wire=true|false keep only or drop sponsored and wire stories,
+// and district=sylhet keeps those mentioning a district. desc_len=120
+// shortens the descriptions of what is left. It writes a 400 and returns
+// false for ok when a value is invalid
+func servedFilter(c *gin.Context, articles []models.NewsArticle) (filtered []models.NewsArticle, ok bool) {
+	safe := c.Query("safe") == "true"
+	sponsored, ok := boolFilter(c, "sponsored")
+	if !ok {
+		return nil, false
+	}
+	wire, ok := boolFilter(c, "wire")
+	if !ok {
+		return nil, false
+	}
+	types := map[string]bool{}
+	if value := c.Query("type"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if !containsString(articleTypes, name) {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Success: false,
+					Error:   "invalid_type",
+					Message: fmt.Sprintf("Unknown article type %q, use any of: %s", name, strings.Join(articleTypes, ",")),
+				})
+				return nil, false
+			}
+			types[name] = true
+		}
+	}
+	var district string
+	if value := c.Query("district"); value != "" {
+		location, known := lookupDistrict(value)
+		if !known {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_district",
+				Message: fmt.Sprintf("Unknown district %q", value),
+			})
+			return nil, false
+		}
+		district = location.Name
+	}
+	descLen, ok := parseDescLen(c)
+	if !ok {
+		return nil, false
+	}
+	if descLen > 0 {
+		defer func() {
+			filtered = shortenDescriptions(filtered, descLen)
+		}()
+	}
+	if !safe && len(types) == 0 && sponsored == nil && wire == nil && district == "" && !anyAnnouncements(articles) {
+		if articles == nil {
+			// Clients expect "data": [], never null
+			return []models.NewsArticle{}, true
+		}
+		return articles, true
+	}
+
+	kept := []models.NewsArticle{}
+	for _, article := range articles {
+		if safe && article.ContentWarning != "" {
+			continue
+		}
+		if (len(types) > 0 && !types[article.Type]) || (len(types) == 0 && article.Type == typeAnnouncement) {
+			continue
+		}
+		if (sponsored != nil && article.IsSponsored != *sponsored) || (wire != nil && article.IsWire != *wire) {
+			continue
+		}
+		if district != "" && !mentions(withLocations(article), district) {
+			continue
+		}
+		kept = append(kept, article)
+	}
+	return kept, true
+}
+
+// boolFilter reads an optional true/false query parameter, nil when absent
+func boolFilter(c *gin.Context, name string) (*bool, bool) {
+	value, present := c.GetQuery(name)
+	if !present {
+		return nil, true
+	}
+	if value != "true" && value != "false" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_" + name,
+			Message: fmt.Sprintf("%s must be true or false", name),
+		})
+		return nil, false
+	}
+	include := value == "true"
+	return &include, true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
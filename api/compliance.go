@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// complianceRoutes serve full article text or proxy source images. They
+// are turned off in compliance mode
+var complianceRoutes = map[string]bool{
+	"/api/v1/article/:id/view":    true,
+	"/api/v1/article/:id/updates": true,
+	"/api/v1/image":               true,
+}
+
+// fullTextStages are the enrichment stages that extract article text
+// rather than metadata
+var fullTextStages = map[string]bool{
+	"summary": true,
+}
+
+// complianceMode reports whether COMPLIANCE_MODE=true restricts the
+// service to headlines, links and snippets
+func complianceMode() bool {
+	return os.Getenv("COMPLIANCE_MODE") == "true"
+}
+
+// complianceGuard answers 403 for the routes compliance mode turns off,
+// whatever the caller's role
+func complianceGuard() gin.HandlerFunc {
+	enabled := complianceMode()
+	return func(c *gin.Context) {
+		if enabled && complianceRoutes[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Success: false,
+				Error:   "compliance_mode",
+				Message: "This deployment serves headlines, links and snippets only",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// stripFullText removes the summaries that articles stored before
+// compliance mode was turned on were given
+func (ns *NewsService) stripFullText() {
+	stripped := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{}) {
+		if article.Summary != "" {
+			article.Summary = ""
+			stripped = append(stripped, article)
+		}
+	}
+	if len(stripped) == 0 {
+		return
+	}
+	if _, err := ns.store.Save(stripped...); err != nil {
+		log.Printf("Error removing summaries from the store: %v", err)
+		return
+	}
+	log.Printf("Compliance mode removed the summaries of %d stored articles", len(stripped))
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useMock turns mock mode on for one test
+func useMock(t *testing.T, opts MockOptions) {
+	t.Helper()
+	EnableMock(opts)
+	t.Cleanup(func() {
+		mockMu.Lock()
+		defer mockMu.Unlock()
+		mockSettings = nil
+	})
+}
+
+// newsRequest serves a request with both news encoders and checks the
+// contract on each response: data is an array, never null, and count is
+// always there
+func newsRequest(t *testing.T, configure func(ns *NewsService), path string, wantStatus int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ACCESS_LOG", "off")
+	router := setupRouter()
+	ns := startup.ns
+	if configure != nil {
+		configure(ns)
+	}
+	for _, fast := range []bool{false, true} {
+		ns.fastJSON = fast
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != wantStatus {
+			t.Fatalf("GET %s (fast encoder %v) = %d, want %d: %s", path, fast, w.Code, wantStatus, w.Body)
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s (fast encoder %v): invalid JSON: %v", path, fast, err)
+		}
+		if data := string(body["data"]); data != "[]" {
+			t.Errorf("GET %s (fast encoder %v): data = %s, want []", path, fast, data)
+		}
+		if count, ok := body["count"]; !ok || string(count) != "0" {
+			t.Errorf("GET %s (fast encoder %v): count = %s, want 0", path, fast, count)
+		}
+	}
+}
+
+func TestAllNewsWhenEverySourceFails(t *testing.T) {
+	useMock(t, MockOptions{ErrorRate: 1})
+	newsRequest(t, nil, "/api/v1/news", http.StatusOK)
+}
+
+func TestSourceWithNoArticles(t *testing.T) {
+	useMock(t, MockOptions{})
+	// Mock mode has no fixture articles for this source
+	newsRequest(t, func(ns *NewsService) {
+		ns.sources["empty"] = models.Source{Name: "empty", URL: "https://empty.example/", Active: true}
+	}, "/api/v1/news/empty", http.StatusOK)
+}
+
+func TestSourceFetchError(t *testing.T) {
+	useMock(t, MockOptions{ErrorRate: 1})
+	newsRequest(t, nil, "/api/v1/news/thedailystar", http.StatusInternalServerError)
+}
+
+// listRequest serves a request and checks that each of the named lists is
+// an array, never null, and, when counted, that count is the length of
+// the first
+func listRequest(t *testing.T, path string, counted bool, lists ...string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ACCESS_LOG", "off")
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200: %s", path, w.Code, w.Body)
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s: invalid JSON: %v", path, err)
+	}
+	for _, name := range lists {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body[name], &items); err != nil || items == nil {
+			t.Errorf("GET %s: %s = %s, want an array", path, name, body[name])
+		}
+	}
+	if !counted {
+		return
+	}
+	var first []json.RawMessage
+	json.Unmarshal(body[lists[0]], &first)
+	var count int
+	if err := json.Unmarshal(body["count"], &count); err != nil || count != len(first) {
+		t.Errorf("GET %s: count = %s, want %d", path, body["count"], len(first))
+	}
+}
+
+func TestListResponsesCarryCount(t *testing.T) {
+	listRequest(t, "/api/v1/sources", true, "sources")
+	listRequest(t, "/api/v1/search?q=budget", true, "results")
+	listRequest(t, "/api/v1/coverage?q=budget", true, "sources")
+	listRequest(t, "/api/v1/news/local/dhaka", true, "data")
+}
+
+func TestSearchSuggestionsAreArrays(t *testing.T) {
+	listRequest(t, "/api/v1/search/suggest?q=bud", false, "titles", "keywords")
+}
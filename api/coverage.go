@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coverageArticlesMax caps how many matching articles are listed per source
+const coverageArticlesMax = 20
+
+// GetCoverage compares how each source covered a keyword over a date range
+// (default the last 30 days): how many stories matched and when each
+// source first and last published one
+func (ns *NewsService) GetCoverage(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	keywords := foldKeywords([]string{query})
+	if len(keywords) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "missing_query",
+			Message: "The q query parameter is required",
+		})
+		return
+	}
+	since, until, ok := parseDateRange(c, 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	coverage := map[string]*models.SourceCoverage{}
+	for name, source := range ns.sources {
+		coverage[name] = &models.SourceCoverage{Source: name, DisplayName: source.DisplayName, Articles: []models.RelatedArticle{}}
+	}
+
+	// List returns newest first, so walk it backwards to see first
+	// publications first
+	articles := ns.store.List(store.Filter{Since: since, Until: until})
+	for i := len(articles) - 1; i >= 0; i-- {
+		article := articles[i]
+		if !strings.Contains(articleText(article), keywords[0]) {
+			continue
+		}
+		entry, exists := coverage[article.Source]
+		if !exists {
+			entry = &models.SourceCoverage{Source: article.Source, DisplayName: article.Source, Articles: []models.RelatedArticle{}}
+			coverage[article.Source] = entry
+		}
+		published := article.PublishedAt
+		if entry.Count == 0 {
+			entry.FirstPublishedAt = &published
+		}
+		entry.LastPublishedAt = &published
+		entry.Count++
+		if len(entry.Articles) < coverageArticlesMax {
+			entry.Articles = append(entry.Articles, models.RelatedArticle{
+				Key:         articleKey(article.URL),
+				Title:       article.Title,
+				URL:         article.URL,
+				Source:      article.Source,
+				PublishedAt: &published,
+			})
+		}
+	}
+
+	sources := make([]models.SourceCoverage, 0, len(coverage))
+	total := 0
+	for _, entry := range coverage {
+		sources = append(sources, *entry)
+		total += entry.Count
+	}
+	// Sources that broke the story first come first, silent ones last
+	sort.Slice(sources, func(i, j int) bool {
+		a, b := sources[i].FirstPublishedAt, sources[j].FirstPublishedAt
+		switch {
+		case a == nil || b == nil:
+			if a == nil && b == nil {
+				return sources[i].Source < sources[j].Source
+			}
+			return b == nil
+		default:
+			return a.Before(*b)
+		}
+	})
+
+	c.JSON(http.StatusOK, models.CoverageResponse{
+		Success: true,
+		Query:   query,
+		Since:   since,
+		Until:   until,
+		Total:   total,
+		Sources: sources,
+		Count:   len(sources),
+	})
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryRetries are the pauses before each retry of a failed delivery.
+// After the last one the delivery is dead and waits for an operator
+var deliveryRetries = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour}
+
+// deliveryTick is how often due retries are looked for
+const deliveryTick = 10 * time.Second
+
+// Delivery statuses
+const (
+	deliveryRetrying = "retrying"
+	deliveryDead     = "dead"
+)
+
+// deliveryQueue keeps failed notifier deliveries until they succeed or an
+// operator drops them. When DELIVERIES_PATH is set the queue is persisted
+// there, so a restart doesn't lose deliveries and notifiers get every event
+// at least once
+type deliveryQueue struct {
+	mu         sync.Mutex
+	path       string
+	dispatcher *notificationDispatcher
+	deliveries map[string]*models.Delivery
+}
+
+// newDeliveryQueue loads the queue persisted at path, if any
+func newDeliveryQueue(dispatcher *notificationDispatcher, path string) *deliveryQueue {
+	q := &deliveryQueue{
+		path:       path,
+		dispatcher: dispatcher,
+		deliveries: map[string]*models.Delivery{},
+	}
+	if q.path == "" {
+		return q
+	}
+
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading failed deliveries, starting empty: %v", err)
+		}
+		return q
+	}
+	var deliveries []models.Delivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		log.Printf("Error decoding failed deliveries, starting empty: %v", err)
+		return q
+	}
+	for i := range deliveries {
+		q.deliveries[deliveries[i].ID] = &deliveries[i]
+	}
+	return q
+}
+
+// add queues a delivery that failed its first attempt
+func (q *deliveryQueue) add(notifier string, event models.Event, err error) {
+	now := time.Now().UTC()
+	id := make([]byte, 8)
+	rand.Read(id)
+	delivery := &models.Delivery{
+		ID:        hex.EncodeToString(id),
+		Notifier:  notifier,
+		Event:     event,
+		Attempts:  1,
+		CreatedAt: now,
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.record(delivery, err, now)
+	q.deliveries[delivery.ID] = delivery
+	q.persist()
+}
+
+// record notes a failed attempt and schedules the next one, or marks the
+// delivery dead when the retries are used up; callers must hold the lock
+func (q *deliveryQueue) record(delivery *models.Delivery, err error, now time.Time) {
+	delivery.LastError = err.Error()
+	delivery.UpdatedAt = now
+	if delivery.Attempts > len(deliveryRetries) {
+		delivery.Status = deliveryDead
+		delivery.NextAttemptAt = nil
+		log.Printf("Delivery %s to %s is dead after %d attempts: %v", delivery.ID, delivery.Notifier, delivery.Attempts, err)
+		return
+	}
+	next := now.Add(deliveryRetries[delivery.Attempts-1])
+	delivery.Status = deliveryRetrying
+	delivery.NextAttemptAt = &next
+}
+
+// attempt retries a delivery, removing it on success. It reports whether
+// the delivery went through
+func (q *deliveryQueue) attempt(id string) (bool, error) {
+	q.mu.Lock()
+	delivery, ok := q.deliveries[id]
+	if !ok {
+		q.mu.Unlock()
+		return false, nil
+	}
+	copied := *delivery
+	q.mu.Unlock()
+
+	target, configured := q.dispatcher.notifiers[copied.Notifier]
+	err := fmt.Errorf("notifier %s is no longer configured", copied.Notifier)
+	if configured {
+		err = target.notify(copied.Event)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delivery, ok = q.deliveries[id]
+	if !ok {
+		// Dropped while we were trying
+		return err == nil, err
+	}
+	if err == nil {
+		delete(q.deliveries, id)
+	} else {
+		delivery.Attempts++
+		q.record(delivery, err, time.Now().UTC())
+	}
+	q.persist()
+	return err == nil, err
+}
+
+// run retries due deliveries until the process exits
+func (q *deliveryQueue) run() {
+	for range time.Tick(deliveryTick) {
+		q.mu.Lock()
+		due := []string{}
+		now := time.Now()
+		for id, delivery := range q.deliveries {
+			if delivery.Status == deliveryRetrying && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) {
+				due = append(due, id)
+			}
+		}
+		q.mu.Unlock()
+		for _, id := range due {
+			q.attempt(id)
+		}
+	}
+}
+
+// list returns queued deliveries with the given status, or all of them,
+// most recently failed first
+func (q *deliveryQueue) list(status string) []models.Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	deliveries := []models.Delivery{}
+	for _, delivery := range q.deliveries {
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].UpdatedAt.After(deliveries[j].UpdatedAt)
+	})
+	return deliveries
+}
+
+// has reports whether a delivery is queued
+func (q *deliveryQueue) has(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.deliveries[id]
+	return ok
+}
+
+// drop removes a delivery, reporting whether there was one
+func (q *deliveryQueue) drop(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.deliveries[id]; !ok {
+		return false
+	}
+	delete(q.deliveries, id)
+	q.persist()
+	return true
+}
+
+// persist writes the queue to disk; callers must hold the lock. Errors are
+// logged, a failing disk must not stop deliveries
+func (q *deliveryQueue) persist() {
+	if q.path == "" {
+		return
+	}
+	deliveries := make([]models.Delivery, 0, len(q.deliveries))
+	for _, delivery := range q.deliveries {
+		deliveries = append(deliveries, *delivery)
+	}
+	data, err := json.Marshal(deliveries)
+	if err != nil {
+		log.Printf("Error encoding failed deliveries: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		log.Printf("Error creating deliveries dir: %v", err)
+		return
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Error writing failed deliveries: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		log.Printf("Error replacing failed deliveries: %v", err)
+	}
+}
+
+// notificationsDisabled answers delivery requests when no notifiers are
+// configured; it returns true when it wrote a response
+func (ns *NewsService) notificationsDisabled(c *gin.Context) bool {
+	if ns.notifications != nil {
+		return false
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Success: false,
+		Error:   "notifications_disabled",
+		Message: "No notifiers are configured, set NOTIFIERS or NOTIFIERS_PATH",
+	})
+	return true
+}
+
+// deliveryNotFound writes a 404 for an unknown delivery ID
+func deliveryNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Success: false,
+		Error:   "delivery_not_found",
+		Message: "No failed delivery has this ID",
+	})
+}
+
+// ListDeliveries returns failed deliveries, optionally only ?status=retrying
+// or ?status=dead
+func (ns *NewsService) ListDeliveries(c *gin.Context) {
+	if ns.notificationsDisabled(c) {
+		return
+	}
+	status := c.Query("status")
+	if status != "" && status != deliveryRetrying && status != deliveryDead {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_status",
+			Message: "status must be retrying or dead",
+		})
+		return
+	}
+	deliveries := ns.notifications.deliveries.list(status)
+	c.JSON(http.StatusOK, models.DeliveriesResponse{
+		Success:    true,
+		Deliveries: deliveries,
+		Count:      len(deliveries),
+	})
+}
+
+// RetryDelivery attempts a failed delivery right away, dead ones included
+func (ns *NewsService) RetryDelivery(c *gin.Context) {
+	if ns.notificationsDisabled(c) {
+		return
+	}
+	id := c.Param("id")
+	q := ns.notifications.deliveries
+	if !q.has(id) {
+		deliveryNotFound(c)
+		return
+	}
+	delivered, err := q.attempt(id)
+	ns.audit.record(c, "delivery.retry", id, nil, nil)
+	response := gin.H{"success": true, "delivered": delivered}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DropDelivery gives up on a failed delivery
+func (ns *NewsService) DropDelivery(c *gin.Context) {
+	if ns.notificationsDisabled(c) {
+		return
+	}
+	if !ns.notifications.deliveries.drop(c.Param("id")) {
+		deliveryNotFound(c)
+		return
+	}
+	ns.audit.record(c, "delivery.drop", c.Param("id"), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true, "dropped": c.Param("id")})
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"sort"
+	"sync"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// selectorDiagnostics records which selectors a scrape tried, which ones
+// matched, and why elements were skipped. A nil *selectorDiagnostics is
+// valid and records nothing, so scrapers can call it unconditionally
+type selectorDiagnostics struct {
+	mu       sync.Mutex
+	tried    []string
+	hits     map[string]int
+	articles []models.ArticleSelectors
+	skipped  map[string]int
+	// provenance adds a method and confidence score to each article field
+	provenance bool
+}
+
+func newSelectorDiagnostics() *selectorDiagnostics {
+	return &selectorDiagnostics{
+		hits:    map[string]int{},
+		skipped: map[string]int{},
+	}
+}
+
+// try registers candidate selectors so unmatched ones can be reported
+func (d *selectorDiagnostics) try(selectors ...string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, selector := range selectors {
+		if _, seen := d.hits[selector]; !seen {
+			d.hits[selector] = 0
+			d.tried = append(d.tried, selector)
+		}
+	}
+}
+
+// hit counts a selector that produced a value
+func (d *selectorDiagnostics) hit(selector string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, seen := d.hits[selector]; !seen {
+		d.tried = append(d.tried, selector)
+	}
+	d.hits[selector]++
+}
+
+// skip counts an element dropped for the given reason
+func (d *selectorDiagnostics) skip(reason string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.skipped[reason]++
+}
+
+// article records which selector filled each field of a kept article
+func (d *selectorDiagnostics) article(id string, fields map[string]string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.articles = append(d.articles, models.ArticleSelectors{ID: id, Fields: fields})
+}
+
+// field updates the selector recorded for one field of a kept article
+func (d *selectorDiagnostics) field(id, field, selector string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.articles {
+		if d.articles[i].ID == id {
+			d.articles[i].Fields[field] = selector
+			return
+		}
+	}
+}
+
+// diagRecorder hands a scraper's selector reports to the diagnostics
+type diagRecorder struct{ d *selectorDiagnostics }
+
+func (r diagRecorder) Try(selectors ...string)                     { r.d.try(selectors...) }
+func (r diagRecorder) Hit(selector string)                         { r.d.hit(selector) }
+func (r diagRecorder) Skip(reason string)                          { r.d.skip(reason) }
+func (r diagRecorder) Article(id string, fields map[string]string) { r.d.article(id, fields) }
+
+// report builds the debug section of the response
+func (d *selectorDiagnostics) report() *models.SelectorDebug {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	unmatched := []string{}
+	for _, selector := range d.tried {
+		if d.hits[selector] == 0 {
+			unmatched = append(unmatched, selector)
+		}
+	}
+	sort.Strings(unmatched)
+
+	if d.provenance {
+		for i := range d.articles {
+			d.articles[i].Provenance = map[string]models.FieldProvenance{}
+			for field, selector := range d.articles[i].Fields {
+				d.articles[i].Provenance[field] = provenanceOf(selector)
+			}
+		}
+	}
+
+	articles := d.articles
+	if articles == nil {
+		articles = []models.ArticleSelectors{}
+	}
+	return &models.SelectorDebug{
+		Articles:  articles,
+		Hits:      d.hits,
+		Unmatched: unmatched,
+		Skipped:   d.skipped,
+	}
+}
+
+// selectorDebug reads the debug=selectors and debug=provenance query flags.
+// It returns false for ok after writing a 401 when a flag is set without
+// admin access
+func selectorDebug(c *gin.Context) (diag *selectorDiagnostics, ok bool) {
+	mode := c.Query("debug")
+	if mode != "selectors" && mode != "provenance" {
+		return nil, true
+	}
+	if !isAdmin(c) {
+		abortUnauthorized(c)
+		return nil, false
+	}
+	diag = newSelectorDiagnostics()
+	diag.provenance = mode == "provenance"
+	return diag, true
+}
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// duplicateTitleSimilarity is the word overlap above which two headlines
+// are treated as the same story
+const duplicateTitleSimilarity = 0.6
+
+// digestStore freezes each day's digest on disk once its cutoff has passed,
+// so "today's paper" stops changing
+type digestStore struct {
+	mu       sync.Mutex
+	dir      string
+	size     int
+	cutoff   time.Duration
+	location *time.Location
+}
+
+// newDigestStore configures digests from DIGEST_DIR, DIGEST_SIZE (default
+// 20), DIGEST_CUTOFF (time of day, default 18:00) and DIGEST_TIMEZONE
+// (default Asia/Dhaka)
+func newDigestStore() *digestStore {
+	d := &digestStore{
+		dir:      os.Getenv("DIGEST_DIR"),
+		size:     20,
+		cutoff:   18 * time.Hour,
+		location: time.UTC,
+	}
+	if d.dir == "" {
+		d.dir = filepath.Join(os.TempDir(), "top-news-digests")
+	}
+	if value := os.Getenv("DIGEST_SIZE"); value != "" {
+		if size, err := strconv.Atoi(value); err == nil && size > 0 {
+			d.size = size
+		} else {
+			log.Printf("Invalid DIGEST_SIZE %q, using %d", value, d.size)
+		}
+	}
+	if value := os.Getenv("DIGEST_CUTOFF"); value != "" {
+		if cutoff, err := time.Parse("15:04", value); err == nil {
+			d.cutoff = time.Duration(cutoff.Hour())*time.Hour + time.Duration(cutoff.Minute())*time.Minute
+		} else {
+			log.Printf("Invalid DIGEST_CUTOFF %q, using 18:00: %v", value, err)
+		}
+	}
+	name := os.Getenv("DIGEST_TIMEZONE")
+	if name == "" {
+		name = "Asia/Dhaka"
+	}
+	if location, err := time.LoadLocation(name); err == nil {
+		d.location = location
+	} else {
+		log.Printf("Invalid DIGEST_TIMEZONE %q, using UTC: %v", name, err)
+	}
+	return d
+}
+
+// load returns the frozen digest for date, if there is one
+func (d *digestStore) load(date string) (models.DigestResponse, bool) {
+	data, err := os.ReadFile(filepath.Join(d.dir, date+".json"))
+	if err != nil {
+		return models.DigestResponse{}, false
+	}
+	var digest models.DigestResponse
+	if err := json.Unmarshal(data, &digest); err != nil {
+		log.Printf("Error decoding digest for %s: %v", date, err)
+		return models.DigestResponse{}, false
+	}
+	return digest, true
+}
+
+// freeze writes a digest to disk so later requests get the same stories
+func (d *digestStore) freeze(digest models.DigestResponse) error {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create digest dir: %v", err)
+	}
+	data, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest: %v", err)
+	}
+	return os.WriteFile(filepath.Join(d.dir, digest.Date+".json"), data, 0o644)
+}
+
+// GetDigest returns the curated top stories of a day (YYYY-MM-DD, or
+// "today"). Until the day's cutoff the digest is provisional; after it, the
+// digest is frozen and never changes
+func (ns *NewsService) GetDigest(c *gin.Context) {
+	d := ns.digests
+	date := c.Param("date")
+	if date == "today" {
+		date = time.Now().In(d.location).Format("2006-01-02")
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, d.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_date",
+			Message: "date must be formatted as YYYY-MM-DD or be today",
+		})
+		return
+	}
+	cutoff := day.Add(d.cutoff)
+	if day.After(time.Now()) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "digest_not_found",
+			Message: "There is no digest for a future date",
+		})
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if digest, ok := d.load(date); ok {
+		ns.writeDigest(c, digest)
+		return
+	}
+
+	articles := rankStories(ns.store.List(store.Filter{Since: day, Until: cutoff}), day, d.size)
+	digest := models.DigestResponse{
+		Success:  true,
+		Date:     date,
+		CutoffAt: cutoff.UTC(),
+		Frozen:   time.Now().After(cutoff),
+		Data:     articles,
+		Count:    len(articles),
+	}
+	if digest.Frozen && digest.Count > 0 {
+		if err := d.freeze(digest); err != nil {
+			log.Printf("Error freezing digest for %s: %v", date, err)
+		}
+	}
+
+	ns.writeDigest(c, digest)
+}
+
+// writeDigest sends a digest with editorial overrides and the request's
+// filters applied, so they also reach digests frozen before the override
+// was made
+func (ns *NewsService) writeDigest(c *gin.Context, digest models.DigestResponse) {
+	data, ok := servedFilter(c, ns.overrides.apply(digest.Data, ""))
+	if !ok {
+		return
+	}
+	digest.Data = data
+	digest.Count = len(data)
+	c.JSON(http.StatusOK, digest)
+}
+
+// story is a cluster of articles about the same event
+type story struct {
+	lead    models.NewsArticle
+	words   map[string]bool
+	sources map[string]bool
+	score   float64
+}
+
+// rankStories merges articles that cover the same story and returns the
+// best limit stories. Stories covered by more sources rank higher, then
+// later and more complete ones
+func rankStories(articles []models.NewsArticle, day time.Time, limit int) []models.NewsArticle {
+	stories := []*story{}
+	for _, article := range articles {
+		words := wordSet(article.Title)
+		var match *story
+		for _, candidate := range stories {
+			if similarity(words, candidate.words) >= duplicateTitleSimilarity {
+				match = candidate
+				break
+			}
+		}
+		if match == nil {
+			stories = append(stories, &story{lead: article, words: words, sources: map[string]bool{article.Source: true}})
+			continue
+		}
+		match.sources[article.Source] = true
+		if completeness(article) > completeness(match.lead) {
+			match.lead = article
+		}
+	}
+
+	for _, s := range stories {
+		s.score = 2*float64(len(s.sources)-1) + completeness(s.lead)
+		if elapsed := s.lead.PublishedAt.Sub(day); elapsed > 0 {
+			s.score += elapsed.Hours() / 24
+		}
+	}
+	sort.SliceStable(stories, func(i, j int) bool {
+		return stories[i].score > stories[j].score
+	})
+
+	ranked := []models.NewsArticle{}
+	for _, s := range stories {
+		if len(ranked) == limit {
+			break
+		}
+		ranked = append(ranked, s.lead)
+	}
+	return ranked
+}
+
+// completeness rewards articles with the fields a front page shows
+func completeness(article models.NewsArticle) float64 {
+	score := 0.0
+	if article.ImageURL != "" {
+		score += 0.5
+	}
+	if article.Description != "" {
+		score += 0.3
+	}
+	return score
+}
+
+// wordSet returns the distinct words of a headline
+func wordSet(title string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range textnorm.Words(title) {
+		words[word] = true
+	}
+	return words
+}
+
+// similarity is the Jaccard similarity of two word sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
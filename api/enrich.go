@@ -0,0 +1,539 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+)
+
+// articlePage is an article's own page, fetched once and shared by every
+// enrichment stage
+type articlePage struct {
+	URL    string
+	Source models.Source
+	Doc    *goquery.Document
+	// RobotsHeaders are the page's X-Robots-Tag headers
+	RobotsHeaders []string
+
+	jsonLDOnce sync.Once
+	jsonLD     map[string]interface{}
+}
+
+// structured returns the page's JSON-LD article object, parsed once
+func (p *articlePage) structured() map[string]interface{} {
+	p.jsonLDOnce.Do(func() {
+		p.jsonLD = articleJSONLD(p.Doc)
+	})
+	return p.jsonLD
+}
+
+// enrichmentStage fills one field of an article from its page. run returns
+// the selector or method that produced the value, or "" when it found
+// nothing. copy moves the stage's field between articles, for caching
+type enrichmentStage struct {
+	name  string
+	field string
+	run   func(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error)
+	copy  func(dst *models.NewsArticle, src models.NewsArticle)
+}
+
+// enrichmentStages lists every stage in the order they run
+var enrichmentStages = []enrichmentStage{
+	{name: "robots", field: "robots", run: enrichRobots, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.Robots = src.Robots
+	}},
+	{name: "image", field: "image_url", run: enrichImage, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		if dst.ImageURL == "" {
+			dst.ImageURL = src.ImageURL
+		}
+		if dst.ImageURL == src.ImageURL && dst.ImageCaption == "" && dst.ImageCredit == "" {
+			dst.ImageCaption, dst.ImageCredit = src.ImageCaption, src.ImageCredit
+		}
+	}},
+	{name: "images", field: "images", run: enrichImages, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.Images = src.Images
+	}},
+	{name: "description", field: "description", run: enrichDescription, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		if dst.Description == "" {
+			dst.Description = src.Description
+		}
+	}},
+	{name: "published_at", field: "published_at", run: enrichPublishedAt, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.PublishedAt = src.PublishedAt
+	}},
+	{name: "author", field: "author", run: enrichAuthor, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.Author = src.Author
+	}},
+	{name: "tags", field: "tags", run: enrichTags, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.Tags = src.Tags
+	}},
+	{name: "summary", field: "summary", run: enrichSummary, copy: func(dst *models.NewsArticle, src models.NewsArticle) {
+		dst.Summary = src.Summary
+	}},
+}
+
+// enrichmentStageNames returns the names of all stages
+func enrichmentStageNames() []string {
+	names := make([]string, len(enrichmentStages))
+	for i, stage := range enrichmentStages {
+		names[i] = stage.name
+	}
+	return names
+}
+
+// enabledStages picks the stages to run for a source: the request's list
+// when one was given, otherwise the source's configured list, otherwise all.
+// Compliance mode leaves out the stages that extract article text
+func enabledStages(source models.Source, requested []string) []enrichmentStage {
+	names := source.Enrichment
+	if requested != nil {
+		names = requested
+	}
+	compliance := complianceMode()
+	if names == nil && !compliance {
+		return enrichmentStages
+	}
+
+	stages := []enrichmentStage{}
+	for _, stage := range enrichmentStages {
+		if compliance && fullTextStages[stage.name] {
+			continue
+		}
+		if names == nil {
+			stages = append(stages, stage)
+			continue
+		}
+		for _, name := range names {
+			if name == stage.name {
+				stages = append(stages, stage)
+				break
+			}
+		}
+	}
+	return stages
+}
+
+// parseEnrich reads the optional enrich query parameter, a comma-separated
+// list of stages or "none". It writes a 400 and returns false for ok when
+// a stage is unknown
+func parseEnrich(c *gin.Context) (stages []string, ok bool) {
+	value, present := c.GetQuery("enrich")
+	if !present {
+		return nil, true
+	}
+
+	stages = []string{}
+	if value == "none" || value == "" {
+		return stages, true
+	}
+	known := strings.Join(enrichmentStageNames(), ",")
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !strings.Contains(","+known+",", ","+name+",") {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_enrich",
+				Message: fmt.Sprintf("Unknown enrichment stage %q, use none or any of: %s", name, known),
+			})
+			return nil, false
+		}
+		stages = append(stages, name)
+	}
+	return stages, true
+}
+
+// stageMetrics are the running totals for one enrichment stage
+type stageMetrics struct {
+	Runs     int64         `json:"runs"`
+	Filled   int64         `json:"filled"`
+	Errors   int64         `json:"errors"`
+	Timeouts int64         `json:"timeouts"`
+	Total    time.Duration `json:"-"`
+	AvgMs    float64       `json:"avg_ms"`
+}
+
+// enrichmentMetrics collects per-stage metrics across all scrapes
+type enrichmentMetrics struct {
+	mu     sync.Mutex
+	stages map[string]*stageMetrics
+}
+
+func newEnrichmentMetrics() *enrichmentMetrics {
+	return &enrichmentMetrics{stages: map[string]*stageMetrics{}}
+}
+
+func (m *enrichmentMetrics) record(stage string, took time.Duration, filled bool, err error, timedOut bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics, ok := m.stages[stage]
+	if !ok {
+		metrics = &stageMetrics{}
+		m.stages[stage] = metrics
+	}
+	metrics.Runs++
+	metrics.Total += took
+	if filled {
+		metrics.Filled++
+	}
+	if err != nil {
+		metrics.Errors++
+	}
+	if timedOut {
+		metrics.Timeouts++
+	}
+}
+
+// snapshot returns a copy of the metrics with averages filled in
+func (m *enrichmentMetrics) snapshot() map[string]stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := map[string]stageMetrics{}
+	for name, metrics := range m.stages {
+		copied := *metrics
+		if copied.Runs > 0 {
+			copied.AvgMs = float64(copied.Total.Microseconds()) / float64(copied.Runs) / 1000
+		}
+		out[name] = copied
+	}
+	return out
+}
+
+// GetEnrichmentMetrics returns per-stage enrichment metrics and how often
+// the enrichment cache avoided work
+func (ns *NewsService) GetEnrichmentMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"stages":  ns.enrichMetrics.snapshot(),
+		"cache":   ns.enrichCache.stats(),
+	})
+}
+
+// stageTimeout is how long a single stage may run, from ENRICH_STAGE_TIMEOUT
+func stageTimeout() time.Duration {
+	if value := os.Getenv("ENRICH_STAGE_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil {
+			return timeout
+		}
+	}
+	return 2 * time.Second
+}
+
+// enrichArticle runs the given stages over an article's page. Each stage
+// works on a copy of the article that is only kept if it finishes in time.
+// It returns the method that filled each field
+func (ns *NewsService) enrichArticle(page *articlePage, article *models.NewsArticle, stages []enrichmentStage, diag *selectorDiagnostics) map[string]string {
+	methods := map[string]string{}
+	timeout := stageTimeout()
+	for _, stage := range stages {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		candidate := *article
+		type result struct {
+			method string
+			err    error
+		}
+		done := make(chan result, 1)
+		started := time.Now()
+		go func() {
+			method, err := stage.run(ctx, page, &candidate)
+			done <- result{method, err}
+		}()
+
+		select {
+		case res := <-done:
+			ns.enrichMetrics.record(stage.name, time.Since(started), res.method != "", res.err, false)
+			if res.err != nil {
+				log.Printf("Enrichment stage %s failed for %s: %v", stage.name, article.URL, res.err)
+			} else if res.method != "" {
+				*article = candidate
+				methods[stage.field] = res.method
+				diag.field(article.ID, stage.field, "article page: "+res.method)
+			}
+		case <-ctx.Done():
+			ns.enrichMetrics.record(stage.name, time.Since(started), false, nil, true)
+			log.Printf("Enrichment stage %s timed out for %s", stage.name, article.URL)
+		}
+		cancel()
+	}
+	for _, stage := range stages {
+		if stage.name == "robots" {
+			ns.terms.record(article.Source, article.Robots)
+		}
+	}
+	return methods
+}
+
+// fetchArticlePage downloads and parses an article's own page. With
+// validators from an earlier fetch it makes a conditional request and
+// returns a nil page when the page has not changed: either the server
+// answered 304 or the relevant content hashes the same as before
+func (ns *NewsService) fetchArticlePage(url string, source models.Source, prev pageValidators) (*articlePage, pageValidators, error) {
+	// Create HTTP client with timeout
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: ns.upstreamTransport(),
+	}
+
+	// Make HTTP GET request
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, prev, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Set User-Agent to avoid being blocked
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	if prev.etag != "" {
+		req.Header.Set("If-None-Match", prev.etag)
+	}
+	if prev.lastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, prev, fmt.Errorf("failed to fetch URL %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && prev.contentHash != "" {
+		return nil, prev, nil
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, prev, fmt.Errorf("%w (status code %d)", errPageGone, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, prev, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, prev, fmt.Errorf("failed to read page: %v", err)
+	}
+
+	validators := pageValidators{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		contentHash:  contentHash(body),
+	}
+	if prev.contentHash != "" && validators.contentHash == prev.contentHash {
+		return nil, validators, nil
+	}
+
+	// Parse HTML using goquery
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, validators, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+
+	return &articlePage{URL: url, Source: source, Doc: doc, RobotsHeaders: resp.Header.Values("X-Robots-Tag")}, validators, nil
+}
+
+// firstAttr returns the first non-empty attribute value among the
+// selectors, together with the selector that matched
+func firstAttr(doc *goquery.Document, attr string, selectors ...string) (string, string) {
+	for _, selector := range selectors {
+		value := ""
+		doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if v, exists := s.Attr(attr); exists && strings.TrimSpace(v) != "" {
+				value = strings.TrimSpace(v)
+				return false
+			}
+			return true
+		})
+		if value != "" {
+			return value, selector + "[" + attr + "]"
+		}
+	}
+	return "", ""
+}
+
+// pageTitle returns the headline of an article page
+func pageTitle(doc *goquery.Document) string {
+	if title, _ := firstAttr(doc, "content", "meta[property='og:title']"); title != "" {
+		return title
+	}
+	return strings.TrimSpace(doc.Find("h1").First().Text())
+}
+
+// enrichImage fills in a missing image, then the image's caption and
+// credit
+func enrichImage(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	method := ""
+	if article.ImageURL == "" {
+		article.ImageURL, method = leadImage(page)
+	}
+	if article.ImageURL == "" || article.ImageCaption != "" || article.ImageCredit != "" {
+		return method, nil
+	}
+	caption, credit, captionMethod := imageCaption(page, article.ImageURL)
+	article.ImageCaption, article.ImageCredit = caption, credit
+	if method == "" {
+		method = captionMethod
+	}
+	return method, nil
+}
+
+// leadImage finds the URL of an article page's main image
+func leadImage(page *articlePage) (string, string) {
+	if image := jsonLDString(page.structured(), "image", "url", "contentUrl"); image != "" {
+		return image, "json-ld image"
+	}
+	for _, candidate := range []struct{ attr, selector string }{
+		{"data-srcset", "picture img"},
+		{"data-src", "span.lg-gallery"},
+		{"content", "meta[property='og:image']"},
+		{"src", "article img, div.section-media img"},
+	} {
+		if value, method := firstAttr(page.Doc, candidate.attr, candidate.selector); value != "" {
+			return value, method
+		}
+	}
+	return "", ""
+}
+
+func enrichDescription(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	if article.Description != "" {
+		return "", nil
+	}
+
+	description, method := jsonLDString(page.structured(), "description"), "json-ld description"
+	if description == "" {
+		description, method = firstAttr(page.Doc, "content", "meta[property='og:description']", "meta[name='description']")
+	}
+	if description == "" {
+		selector := ".article__content p, .article-body p, .paragraph, .zn-body__paragraph"
+		page.Doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if pText := strings.TrimSpace(s.Text()); len(pText) > 50 {
+				description = pText
+				method = "heuristic: first long paragraph in " + selector
+				return false
+			}
+			return true
+		})
+	}
+	if description == "" {
+		return "", nil
+	}
+
+	article.Description = truncateText(description, descriptionLength())
+	return method, nil
+}
+
+func enrichPublishedAt(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	raw, method := jsonLDString(page.structured(), "datePublished"), "json-ld datePublished"
+	if raw == "" {
+		raw, method = firstAttr(page.Doc, "content", "meta[property='article:published_time']", "meta[itemprop='datePublished']", "meta[name='pubdate']", "meta[name='publish-date']")
+	}
+	if raw == "" {
+		raw, method = firstAttr(page.Doc, "datetime", "time")
+	}
+	if raw == "" {
+		method = ".date, .publish-date, .timestamp, .byline__date"
+		raw = strings.TrimSpace(page.Doc.Find(method).First().Text())
+	}
+
+	publishedAt, ok := parsePublishedAt(raw, page.Source)
+	if !ok {
+		return "", nil
+	}
+	article.PublishedAt = publishedAt
+	return method, nil
+}
+
+func enrichAuthor(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	author, method := jsonLDString(page.structured(), "author", "name"), "json-ld author"
+	if author == "" || strings.HasPrefix(author, "http") {
+		author, method = firstAttr(page.Doc, "content", "meta[name='author']", "meta[property='article:author']")
+	}
+	if author == "" {
+		method = "[rel='author'], .byline__name, .author-name, .byline a"
+		author = strings.TrimSpace(page.Doc.Find(method).First().Text())
+	}
+	// Some sites put a profile URL in article:author
+	if author == "" || strings.HasPrefix(author, "http") {
+		return "", nil
+	}
+	article.Author = strings.Join(strings.Fields(author), " ")
+	return method, nil
+}
+
+func enrichTags(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	tags := []string{}
+	seen := map[string]bool{}
+	add := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	method := "json-ld keywords"
+	for _, keyword := range jsonLDStrings(page.structured(), "keywords") {
+		add(keyword)
+	}
+	if len(tags) == 0 {
+		method = "meta[property='article:tag']"
+		page.Doc.Find(method).Each(func(i int, s *goquery.Selection) {
+			add(s.AttrOr("content", ""))
+		})
+	}
+	if len(tags) == 0 {
+		method = "meta[name='keywords']"
+		keywords, _ := firstAttr(page.Doc, "content", method)
+		for _, keyword := range strings.Split(keywords, ",") {
+			add(keyword)
+		}
+	}
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	if len(tags) > 10 {
+		tags = tags[:10]
+	}
+	article.Tags = tags
+	return method, nil
+}
+
+// sentenceEnd finds sentence boundaries, including the Bangla dari (।)
+var sentenceEnd = regexp.MustCompile(`[.!?।]["'”’]?\s`)
+
+func enrichSummary(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	selector := "article p, .article__content p, .article-body p, .section-content p, .paragraph"
+	text := ""
+	page.Doc.Find(selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if pText := strings.Join(strings.Fields(s.Text()), " "); len(pText) > 60 {
+			text += pText + " "
+		}
+		return len(text) < 1000
+	})
+	if text == "" {
+		return "", nil
+	}
+
+	// Keep the first two sentences
+	summary := text
+	if bounds := sentenceEnd.FindAllStringIndex(text, 2); len(bounds) > 0 {
+		summary = text[:bounds[len(bounds)-1][1]]
+	}
+	article.Summary = truncateText(strings.TrimSpace(summary), 400)
+	return "heuristic: first sentences of " + selector, nil
+}
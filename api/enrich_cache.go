@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// enrichmentCacheEntry holds an enriched article, which stages produced it,
+// the method that filled each field and the validators of the page it came
+// from
+type enrichmentCacheEntry struct {
+	article    models.NewsArticle
+	stages     map[string]bool
+	methods    map[string]string
+	validators pageValidators
+	expires    time.Time
+}
+
+// pageValidators are what we remember about an article page to tell
+// whether it changed since it was enriched
+type pageValidators struct {
+	etag         string
+	lastModified string
+	contentHash  string
+}
+
+// enrichmentCacheStats counts how enrichment requests were served
+type enrichmentCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Unchanged int64 `json:"unchanged"`
+	Fetched   int64 `json:"fetched"`
+}
+
+// enrichmentCache remembers enrichment results by canonical article URL, so
+// headlines that stay on a homepage for hours are only enriched once.
+// Expired entries are kept around for staleFor so their page can be
+// revalidated cheaply instead of re-enriched
+type enrichmentCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	staleFor   time.Duration
+	maxEntries int
+	entries    map[string]*enrichmentCacheEntry
+	counts     enrichmentCacheStats
+}
+
+// newEnrichmentCache configures the cache from ENRICH_CACHE_TTL (default
+// 12h, 0 disables it)
+func newEnrichmentCache() *enrichmentCache {
+	ttl := 12 * time.Hour
+	if value := os.Getenv("ENRICH_CACHE_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			ttl = parsed
+		}
+	}
+	return &enrichmentCache{
+		ttl:        ttl,
+		staleFor:   7 * 24 * time.Hour,
+		maxEntries: 5000,
+		entries:    map[string]*enrichmentCacheEntry{},
+	}
+}
+
+// get returns a copy of the cached entry for url if every requested stage
+// has already run for it
+func (c *enrichmentCache) get(articleURL string, stages []enrichmentStage) (enrichmentCacheEntry, bool) {
+	if c.ttl <= 0 {
+		return enrichmentCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[canonicalURL(articleURL)]
+	if !ok || time.Now().After(entry.expires) || !entry.covers(stages) {
+		return enrichmentCacheEntry{}, false
+	}
+	c.counts.Hits++
+	return *entry, true
+}
+
+// stale returns a copy of an expired entry covering the requested stages,
+// so its page can be revalidated with the stored validators
+func (c *enrichmentCache) stale(articleURL string, stages []enrichmentStage) (enrichmentCacheEntry, bool) {
+	if c.ttl <= 0 {
+		return enrichmentCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[canonicalURL(articleURL)]
+	if !ok || !entry.covers(stages) {
+		return enrichmentCacheEntry{}, false
+	}
+	return *entry, true
+}
+
+// refresh extends an entry whose page turned out to be unchanged
+func (c *enrichmentCache) refresh(articleURL string, validators pageValidators) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[canonicalURL(articleURL)]; ok {
+		entry.validators = validators
+		entry.expires = time.Now().Add(c.ttl)
+		c.counts.Unchanged++
+	}
+}
+
+// put stores an enriched article, merging with stages cached earlier
+func (c *enrichmentCache) put(article models.NewsArticle, stages []enrichmentStage, methods map[string]string, validators pageValidators) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := canonicalURL(article.URL)
+	entry, ok := c.entries[key]
+	if !ok || entry.validators.contentHash != validators.contentHash {
+		entry = &enrichmentCacheEntry{article: article, stages: map[string]bool{}, methods: map[string]string{}}
+	}
+	for _, stage := range stages {
+		stage.copy(&entry.article, article)
+		entry.stages[stage.name] = true
+		if method, ok := methods[stage.field]; ok {
+			entry.methods[stage.field] = method
+		}
+	}
+	entry.validators = validators
+	entry.expires = time.Now().Add(c.ttl)
+	c.entries[key] = entry
+	c.counts.Fetched++
+
+	if len(c.entries) > c.maxEntries {
+		c.evict()
+	}
+}
+
+// prime stores an article loaded from the article store as if its stages
+// had just run. Articles already cached are left alone
+func (c *enrichmentCache) prime(article models.NewsArticle, stages []enrichmentStage) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := canonicalURL(article.URL)
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	entry := &enrichmentCacheEntry{article: article, stages: map[string]bool{}, methods: map[string]string{}, expires: time.Now().Add(c.ttl)}
+	for _, stage := range stages {
+		entry.stages[stage.name] = true
+	}
+	c.entries[key] = entry
+	if len(c.entries) > c.maxEntries {
+		c.evict()
+	}
+}
+
+// stats returns how often the cache avoided fetching or re-enriching
+func (c *enrichmentCache) stats() enrichmentCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts
+}
+
+// covers reports whether every stage has already run for the entry
+func (e *enrichmentCacheEntry) covers(stages []enrichmentStage) bool {
+	for _, stage := range stages {
+		if !e.stages[stage.name] {
+			return false
+		}
+	}
+	return true
+}
+
+// evict drops entries that are past revalidation, then the ones closest to
+// expiring until the cache is back under its size limit; callers must hold
+// the lock
+func (c *enrichmentCache) evict() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires.Add(c.staleFor)) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) > c.maxEntries {
+		oldestKey := ""
+		var oldest time.Time
+		for key, entry := range c.entries {
+			if oldestKey == "" || entry.expires.Before(oldest) {
+				oldestKey, oldest = key, entry.expires
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+}
+
+// articleKey derives an article's stable key from its canonical URL
+func articleKey(articleURL string) string {
+	sum := sha256.Sum256([]byte(canonicalURL(articleURL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// trackingParams are query parameters that never change the page content
+var trackingParams = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid", "ref", "cmpid"}
+
+// canonicalURL normalizes an article URL so the same page always maps to
+// the same key: lower-case host, no fragment, no tracking parameters and no
+// trailing slash
+func canonicalURL(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return raw
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	parsed.Fragment = ""
+	if parsed.Path != "/" {
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		for _, prefix := range trackingParams {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				query.Del(key)
+			}
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// volatileMarkup matches parts of a page that change on every load without
+// the article changing: scripts, styles, comments and ad slots
+var volatileMarkup = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<!--.*?-->|<noscript\b.*?</noscript>|<iframe\b.*?</iframe>`)
+
+// contentHash hashes a page's relevant content, ignoring volatile markup and
+// whitespace so cache-busting tokens and ad rotations don't count as changes
+func contentHash(body []byte) string {
+	relevant := volatileMarkup.ReplaceAll(body, nil)
+	relevant = bytes.Join(bytes.Fields(relevant), []byte(" "))
+	sum := sha256.Sum256(relevant)
+	return hex.EncodeToString(sum[:])
+}
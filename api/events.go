@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// Event types published on the bus
+const (
+	eventArticleDiscovered = "article.discovered"
+	eventArticleUpdated    = "article.updated"
+	eventSourceFailed      = "source.failed"
+	// eventArticleBreaking is published once per new article of a running
+	// special event
+	eventArticleBreaking = "article.breaking"
+)
+
+var eventTypes = []string{eventArticleDiscovered, eventArticleUpdated, eventSourceFailed, eventArticleBreaking}
+
+// eventBus fans scrape events out to everything that reacts to them:
+// integrations, notifiers and streams subscribe instead of being called
+// from the scraper
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]eventSubscriber
+}
+
+// eventSubscriber is a named handler, the name is used in logs
+type eventSubscriber struct {
+	name   string
+	handle func(event models.Event)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: map[string][]eventSubscriber{}}
+}
+
+// subscribe registers handle for the given event types, or every type when
+// none are given
+func (b *eventBus) subscribe(name string, handle func(event models.Event), types ...string) {
+	if len(types) == 0 {
+		types = eventTypes
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, eventType := range types {
+		b.subscribers[eventType] = append(b.subscribers[eventType], eventSubscriber{name: name, handle: handle})
+	}
+}
+
+// publish delivers an event to its subscribers, each in its own goroutine
+// so a slow subscriber never holds up scraping or the others. A panicking
+// subscriber is logged and does not take the service down
+func (b *eventBus) publish(event models.Event) {
+	if event.ID == "" {
+		id := make([]byte, 8)
+		rand.Read(id)
+		event.ID = hex.EncodeToString(id)
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers[event.Type]
+	b.mu.RUnlock()
+	for _, subscriber := range subscribers {
+		go func(subscriber eventSubscriber) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Event subscriber %s panicked on %s: %v", subscriber.name, event.Type, r)
+				}
+			}()
+			subscriber.handle(event)
+		}(subscriber)
+	}
+}
+
+// publishArticles publishes an article event unless there are no articles
+func (b *eventBus) publishArticles(eventType, source string, articles []models.NewsArticle) {
+	if len(articles) == 0 {
+		return
+	}
+	b.publish(models.Event{Type: eventType, Source: source, Articles: articles})
+}
@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export limits
+const (
+	exportLinkTTL     = 24 * time.Hour
+	exportRetention   = 48 * time.Hour
+	exportConcurrency = 2
+)
+
+// exportFormats maps each format to its file extension and content type
+var exportFormats = map[string]struct{ ext, contentType string }{
+	"csv":    {"csv", "text/csv; charset=utf-8"},
+	"ndjson": {"ndjson", "application/x-ndjson"},
+	"xlsx":   {"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+// exportColumns are the columns of csv and xlsx exports
+var exportColumns = []string{"key", "title", "url", "source", "published_at", "author", "category", "type", "description", "tags", "is_sponsored", "is_wire", "content_warning"}
+
+// exportManager runs export jobs in the background and keeps their files
+// in EXPORT_DIR until they expire
+type exportManager struct {
+	mu     sync.Mutex
+	dir    string
+	secret []byte
+	jobs   map[string]*models.ExportJob
+	// owners are the tenants that created the jobs, "" for none
+	owners  map[string]string
+	running chan struct{}
+}
+
+// newExportManager configures exports from EXPORT_DIR and
+// EXPORT_SIGNING_KEY. Without a signing key a random one is used, so
+// download links stop working after a restart
+func newExportManager() *exportManager {
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "top-news-exports")
+	}
+	secret := []byte(os.Getenv("EXPORT_SIGNING_KEY"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+	return &exportManager{
+		dir:     dir,
+		secret:  secret,
+		jobs:    map[string]*models.ExportJob{},
+		owners:  map[string]string{},
+		running: make(chan struct{}, exportConcurrency),
+	}
+}
+
+// get returns a copy of a job
+func (m *exportManager) get(id string) (models.ExportJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return models.ExportJob{}, false
+	}
+	return *job, true
+}
+
+// getFor returns a copy of a job the request's tenant created, so tenants
+// never see each other's exports
+func (m *exportManager) getFor(c *gin.Context, id string) (models.ExportJob, bool) {
+	m.mu.Lock()
+	owner, ok := m.owners[id]
+	m.mu.Unlock()
+	if !ok || owner != tenantName(currentTenant(c)) {
+		return models.ExportJob{}, false
+	}
+	return m.get(id)
+}
+
+// tenantName is a tenant's name, "" for requests without one
+func tenantName(t *tenant) string {
+	if t == nil {
+		return ""
+	}
+	return t.name
+}
+
+// update changes a job under the lock
+func (m *exportManager) update(id string, change func(job *models.ExportJob)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// prune forgets jobs and deletes files older than the retention period
+func (m *exportManager) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, job := range m.jobs {
+		if time.Since(job.CreatedAt) > exportRetention {
+			os.Remove(m.path(*job))
+			delete(m.jobs, id)
+			delete(m.owners, id)
+		}
+	}
+}
+
+// path is where a job's file is written
+func (m *exportManager) path(job models.ExportJob) string {
+	return filepath.Join(m.dir, job.ID+"."+exportFormats[job.Format].ext)
+}
+
+// signature signs a job ID and expiry time for download links
+func (m *exportManager) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, m.secret)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateExport starts a background export of stored articles matching the
+// filters and returns the job, whose status can be polled
+func (ns *NewsService) CreateExport(c *gin.Context) {
+	var request models.ExportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Invalid export request: %v", err),
+		})
+		return
+	}
+	if request.Format == "" {
+		request.Format = "csv"
+	}
+	if _, known := exportFormats[request.Format]; !known {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_format",
+			Message: "format must be csv, ndjson or xlsx",
+		})
+		return
+	}
+	if request.Filters.Type != "" && !containsString(articleTypes, request.Filters.Type) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_type",
+			Message: fmt.Sprintf("type must be one of: %s", strings.Join(articleTypes, ",")),
+		})
+		return
+	}
+
+	m := ns.exports
+	m.prune()
+	idBytes := make([]byte, 12)
+	rand.Read(idBytes)
+	job := &models.ExportJob{
+		ID:        hex.EncodeToString(idBytes),
+		Status:    "queued",
+		Format:    request.Format,
+		Filters:   request.Filters,
+		CreatedAt: time.Now().UTC(),
+	}
+	tenant := currentTenant(c)
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.owners[job.ID] = tenantName(tenant)
+	m.mu.Unlock()
+
+	go ns.runExport(job.ID, request, tenant)
+
+	c.Header("Location", "/api/v1/exports/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "job": *job})
+}
+
+// runExport writes the export file, at most exportConcurrency at a time
+func (ns *NewsService) runExport(id string, request models.ExportRequest, tenant *tenant) {
+	m := ns.exports
+	m.running <- struct{}{}
+	defer func() { <-m.running }()
+	m.update(id, func(job *models.ExportJob) { job.Status = "running" })
+
+	count, err := ns.writeExport(id, request, tenant)
+	m.update(id, func(job *models.ExportJob) {
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+		job.Rows = count
+		if err != nil {
+			log.Printf("Export %s failed: %v", id, err)
+			job.Status = "failed"
+			job.Error = err.Error()
+			return
+		}
+		job.Status = "done"
+	})
+}
+
+// exportArticles returns the stored articles matching the filters that
+// the tenant, if any, may see
+func (ns *NewsService) exportArticles(filters models.ExportFilters, tenant *tenant) []models.NewsArticle {
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{Source: filters.Source, Since: filters.Since, Until: filters.Until}) {
+		if tenant.allows(article.Source) {
+			articles = append(articles, article)
+		}
+	}
+	articles = tenant.filter(articles)
+	keywords := foldKeywords([]string{filters.Query})
+
+	matched := []models.NewsArticle{}
+	for _, article := range articles {
+		if filters.Type != "" && article.Type != filters.Type {
+			continue
+		}
+		if len(keywords) > 0 && !strings.Contains(articleText(article), keywords[0]) {
+			continue
+		}
+		matched = append(matched, article)
+	}
+	return matched
+}
+
+// writeExport writes the matching articles in the requested format
+func (ns *NewsService) writeExport(id string, request models.ExportRequest, tenant *tenant) (int, error) {
+	m := ns.exports
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create export dir: %v", err)
+	}
+	job, _ := m.get(id)
+	file, err := os.Create(m.path(job))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %v", err)
+	}
+	defer file.Close()
+
+	articles := ns.exportArticles(request.Filters, tenant)
+	out := bufio.NewWriter(file)
+	switch request.Format {
+	case "ndjson":
+		encoder := json.NewEncoder(out)
+		for _, article := range articles {
+			if err := encoder.Encode(article); err != nil {
+				return 0, fmt.Errorf("failed to write article: %v", err)
+			}
+		}
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write(exportColumns)
+		for _, article := range articles {
+			writer.Write(exportRow(article))
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return 0, fmt.Errorf("failed to write csv: %v", err)
+		}
+	case "xlsx":
+		rows := [][]string{exportColumns}
+		for _, article := range articles {
+			rows = append(rows, exportRow(article))
+		}
+		if err := writeXLSX(out, rows); err != nil {
+			return 0, err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %v", err)
+	}
+	return len(articles), nil
+}
+
+// exportRow flattens an article into exportColumns order
+func exportRow(article models.NewsArticle) []string {
+	published := ""
+	if !article.PublishedAt.IsZero() {
+		published = article.PublishedAt.Format(time.RFC3339)
+	}
+	return []string{
+		articleKey(article.URL), article.Title, article.URL, article.Source, published,
+		article.Author, article.Category, article.Type, article.Description, strings.Join(article.Tags, "; "),
+		strconv.FormatBool(article.IsSponsored), strconv.FormatBool(article.IsWire), article.ContentWarning,
+	}
+}
+
+// GetExport returns an export job's status, with a signed download link
+// once it is done
+func (ns *NewsService) GetExport(c *gin.Context) {
+	job, ok := ns.exports.getFor(c, c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "export_not_found",
+			Message: "No export job has this ID",
+		})
+		return
+	}
+	if job.Status == "done" {
+		expires := time.Now().Add(exportLinkTTL).Unix()
+		job.DownloadURL = fmt.Sprintf("/api/v1/exports/%s/download?expires=%d&signature=%s", job.ID, expires, ns.exports.signature(job.ID, expires))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "job": job})
+}
+
+// DownloadExport serves a finished export file to holders of a valid,
+// unexpired signed link, on top of the usual key and tenant checks
+func (ns *NewsService) DownloadExport(c *gin.Context) {
+	m := ns.exports
+	id := c.Param("id")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	valid := err == nil && time.Now().Unix() <= expires &&
+		hmac.Equal([]byte(c.Query("signature")), []byte(m.signature(id, expires)))
+	if !valid {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_signature",
+			Message: "The download link is invalid or has expired",
+		})
+		return
+	}
+
+	job, ok := m.getFor(c, id)
+	if !ok || job.Status != "done" {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "export_not_found",
+			Message: "The export is not available",
+		})
+		return
+	}
+	file, err := os.Open(m.path(job))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "export_not_found",
+			Message: "The export file has been removed",
+		})
+		return
+	}
+	defer file.Close()
+
+	info, _ := file.Stat()
+	format := exportFormats[job.Format]
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="articles-%s.%s"`, job.ID, format.ext))
+	c.DataFromReader(http.StatusOK, info.Size(), format.contentType, io.Reader(file), nil)
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// factCheckCacheTTL is how long fetched fact-check feeds are reused
+const factCheckCacheTTL = 15 * time.Minute
+
+// defaultFactCheckSources are the fact-checkers read by default. They are
+// a distinct kind of source: RSS feeds of verdicts rather than homepages
+var defaultFactCheckSources = []models.Source{
+	{Name: "afp-factcheck", DisplayName: "AFP Fact Check", URL: "https://factcheck.afp.com/", FeedURL: "https://factcheck.afp.com/rss.xml", Active: true, Kind: "factcheck", Locale: "en"},
+	{Name: "rumorscanner", DisplayName: "Rumor Scanner Bangladesh", URL: "https://rumorscanner.com/", FeedURL: "https://rumorscanner.com/feed", Active: true, Kind: "factcheck", Timezone: "Asia/Dhaka", Locale: "bn-BD"},
+}
+
+// Verdict words fact-checkers put in headlines and categories
+var verdictPattern = regexp.MustCompile(textnorm.NFC(`(?i)\b(false|fake|misleading|missing context|altered|satire|partly false|true|unproven|doctored)\b|ভুয়া|মিথ্যা|বিভ্রান্তিকর|সত্য|এডিটেড|বানোয়াট`))
+
+// htmlTagPattern strips markup from feed descriptions
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// stopWords are too common to link a fact-check to a news story
+var stopWords = foldStopWords(map[string]bool{
+	"the": true, "a": true, "an": true, "and": true, "or": true, "of": true, "to": true, "in": true, "on": true, "for": true,
+	"is": true, "are": true, "was": true, "were": true, "be": true, "with": true, "as": true, "at": true, "by": true, "from": true,
+	"this": true, "that": true, "it": true, "its": true, "not": true, "no": true, "has": true, "have": true, "after": true, "over": true,
+	"video": true, "photo": true, "image": true, "claim": true, "claims": true, "false": true, "fake": true, "misleading": true, "shows": true,
+	"ও": true, "এবং": true, "না": true, "এই": true, "থেকে": true, "করে": true, "হয়": true, "নয়": true, "দাবি": true, "ভিডিও": true, "ছবি": true, "ভুয়া": true,
+})
+
+// foldStopWords normalizes stop words the way article text is folded
+func foldStopWords(words map[string]bool) map[string]bool {
+	folded := map[string]bool{}
+	for word := range words {
+		folded[textnorm.ForSearch(word)] = true
+	}
+	return folded
+}
+
+// rssFeed is the subset of RSS 2.0 that fact-check and fallback news feeds
+// use, Media RSS images and Dublin Core authors included
+type rssFeed struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+// rssItem is one story or verdict of an RSS feed
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Enclosure   struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Media []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// factCheckCache keeps the last fetch of every fact-check feed
+type factCheckCache struct {
+	mu      sync.Mutex
+	sources []models.Source
+	checks  []models.FactCheck
+	fetched time.Time
+}
+
+// newFactCheckCache configures the fact-check feeds. FACTCHECK_FEEDS
+// replaces the defaults with a comma-separated list of name=url pairs,
+// or disables fact-checks when set to "off"
+func newFactCheckCache() *factCheckCache {
+	value := os.Getenv("FACTCHECK_FEEDS")
+	if value == "off" {
+		return &factCheckCache{}
+	}
+	if value == "" {
+		return &factCheckCache{sources: defaultFactCheckSources}
+	}
+
+	sources := []models.Source{}
+	for _, pair := range strings.Split(value, ",") {
+		name, feedURL, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || name == "" || feedURL == "" {
+			log.Printf("Ignoring invalid FACTCHECK_FEEDS entry %q, expected name=url", pair)
+			continue
+		}
+		sources = append(sources, models.Source{Name: name, DisplayName: name, URL: feedURL, FeedURL: feedURL, Active: true, Kind: "factcheck"})
+	}
+	return &factCheckCache{sources: sources}
+}
+
+// factChecks returns the latest fact-checks from every feed, newest first,
+// refetching them when the cache is stale
+func (ns *NewsService) factChecks() []models.FactCheck {
+	cache := ns.factCheckFeeds
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if time.Since(cache.fetched) < factCheckCacheTTL {
+		return cache.checks
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	checks := []models.FactCheck{}
+	for _, source := range cache.sources {
+		wg.Add(1)
+		go func(source models.Source) {
+			defer wg.Done()
+			feedChecks, err := ns.fetchFactCheckFeed(source)
+			if err != nil {
+				log.Printf("Error fetching fact-checks from %s: %v", source.Name, err)
+				return
+			}
+			mu.Lock()
+			checks = append(checks, feedChecks...)
+			mu.Unlock()
+		}(source)
+	}
+	wg.Wait()
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].PublishedAt.After(checks[j].PublishedAt)
+	})
+	cache.checks = checks
+	cache.fetched = time.Now()
+	return checks
+}
+
+// fetchRSS downloads and parses an RSS feed
+func (ns *NewsService) fetchRSS(feedURL string) (*rssFeed, error) {
+	req, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := ns.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed %s: %v", feedURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code for feed %s: %d", feedURL, resp.StatusCode)
+	}
+
+	var feed rssFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %v", feedURL, err)
+	}
+	return &feed, nil
+}
+
+// fetchFactCheckFeed downloads and parses one fact-checker's RSS feed
+func (ns *NewsService) fetchFactCheckFeed(source models.Source) ([]models.FactCheck, error) {
+	feed, err := ns.fetchRSS(source.FeedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []models.FactCheck{}
+	for _, item := range feed.Items {
+		title := strings.TrimSpace(item.Title)
+		link := strings.TrimSpace(item.Link)
+		if title == "" || link == "" {
+			continue
+		}
+		summary := strings.Join(strings.Fields(htmlTagPattern.ReplaceAllString(item.Description, " ")), " ")
+		if len(summary) > 300 {
+			summary = summary[:300] + "..."
+		}
+		check := models.FactCheck{
+			Key:     articleKey(link),
+			Title:   title,
+			URL:     link,
+			Source:  source.Name,
+			Summary: summary,
+			Verdict: verdictOf(append([]string{title}, item.Categories...)),
+		}
+		if published, err := time.Parse(time.RFC1123Z, strings.TrimSpace(item.PubDate)); err == nil {
+			check.PublishedAt = published.UTC()
+		} else if published, ok := parsePublishedAt(item.PubDate, source); ok {
+			check.PublishedAt = published
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// verdictOf finds the first verdict word in a fact-check's title or
+// categories
+func verdictOf(texts []string) string {
+	for _, text := range texts {
+		if match := verdictPattern.FindString(textnorm.NFC(text)); match != "" {
+			return strings.ToLower(match)
+		}
+	}
+	return ""
+}
+
+// significantWords returns the words of text worth matching on
+func significantWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range textnorm.Words(text) {
+		if len([]rune(word)) > 2 && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// relatedArticles links a fact-check to stored news from the two weeks
+// before it that share enough significant words
+func relatedArticles(check models.FactCheck, articles []models.NewsArticle) []models.RelatedArticle {
+	checkWords := significantWords(check.Title + " " + check.Summary)
+	type scored struct {
+		article models.NewsArticle
+		shared  int
+	}
+	matches := []scored{}
+	for _, article := range articles {
+		shared := 0
+		for word := range significantWords(article.Title + " " + article.Description) {
+			if checkWords[word] {
+				shared++
+			}
+		}
+		if shared >= 3 {
+			matches = append(matches, scored{article, shared})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].shared > matches[j].shared
+	})
+
+	related := []models.RelatedArticle{}
+	for _, match := range matches {
+		if len(related) == 3 {
+			break
+		}
+		related = append(related, models.RelatedArticle{
+			Key:    articleKey(match.article.URL),
+			Title:  match.article.Title,
+			URL:    match.article.URL,
+			Source: match.article.Source,
+		})
+	}
+	return related
+}
+
+// GetFactChecks returns recent fact-checks, each cross-linked to the
+// stored news stories it matches. Supports ?source= and ?limit=
+func (ns *NewsService) GetFactChecks(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = 20
+	}
+	sourceName := c.Query("source")
+
+	recent := ns.store.List(store.Filter{Since: time.Now().AddDate(0, 0, -14)})
+	checks := []models.FactCheck{}
+	for _, check := range ns.factChecks() {
+		if sourceName != "" && check.Source != sourceName {
+			continue
+		}
+		if len(checks) == limit {
+			break
+		}
+		check.Related = relatedArticles(check, recent)
+		checks = append(checks, check)
+	}
+
+	c.JSON(http.StatusOK, models.FactChecksResponse{
+		Success: true,
+		Data:    checks,
+		Count:   len(checks),
+		Sources: ns.factCheckFeeds.sources,
+	})
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// fallbackCooldown is how long an endpoint that failed is tried after the
+// ones that did not
+const fallbackCooldown = 10 * time.Minute
+
+// fallbackKinds are the kinds of fallback endpoint a source can list: RSS
+// feeds and text-only mirrors
+var fallbackKinds = map[string]bool{
+	"rss":  true,
+	"lite": true,
+}
+
+// homepageEndpoint is the kind of a source's own homepage scrape
+const homepageEndpoint = "homepage"
+
+// endpointFailures remembers when each source endpoint last failed, so
+// broken ones stop being tried first
+type endpointFailures struct {
+	mu     sync.Mutex
+	failed map[string]time.Time
+}
+
+func newEndpointFailures() *endpointFailures {
+	return &endpointFailures{failed: map[string]time.Time{}}
+}
+
+// record notes the outcome of trying an endpoint
+func (f *endpointFailures) record(endpoint string, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ok {
+		delete(f.failed, endpoint)
+	} else {
+		f.failed[endpoint] = time.Now()
+	}
+}
+
+// recent reports whether an endpoint failed within the cooldown
+func (f *endpointFailures) recent(endpoint string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failed, ok := f.failed[endpoint]
+	return ok && time.Since(failed) < fallbackCooldown
+}
+
+// applySourceFallbacks replaces the fallbacks of the sources named in
+// SOURCE_FALLBACKS, a JSON object of source name to fallback list, or the
+// file at SOURCE_FALLBACKS_PATH. An empty list turns a source's fallbacks
+// off
+func applySourceFallbacks(sources map[string]models.Source) {
+	data := []byte(os.Getenv("SOURCE_FALLBACKS"))
+	if path := os.Getenv("SOURCE_FALLBACKS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading source fallbacks, using the defaults: %v", err)
+			return
+		}
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	var config map[string][]models.SourceFallback
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding source fallbacks, using the defaults: %v", err)
+		return
+	}
+	if err := checkSourceFallbacks(sources, config); err != nil {
+		log.Printf("Invalid source fallbacks, using the defaults: %v", err)
+		return
+	}
+	for name, fallbacks := range config {
+		source := sources[name]
+		source.Fallbacks = fallbacks
+		sources[name] = source
+	}
+}
+
+// checkSourceFallbacks checks that every fallback belongs to a known source
+// and has a known kind and an absolute URL
+func checkSourceFallbacks(sources map[string]models.Source, config map[string][]models.SourceFallback) error {
+	for name, fallbacks := range config {
+		if _, ok := sources[name]; !ok {
+			return fmt.Errorf("unknown source %q", name)
+		}
+		for _, fallback := range fallbacks {
+			if !fallbackKinds[fallback.Kind] {
+				return fmt.Errorf("%s: unknown fallback kind %q", name, fallback.Kind)
+			}
+			parsed, err := url.Parse(fallback.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("%s: invalid fallback url %q", name, fallback.URL)
+			}
+			if _, known := textMirrors[parsed.Hostname()]; fallback.Kind == "lite" && !known {
+				return fmt.Errorf("%s: %s is not a known text-only mirror", name, parsed.Hostname())
+			}
+		}
+	}
+	return nil
+}
+
+// endpointOrder lists a source's homepage and its fallbacks in the order to
+// try them: text-only mirrors, which are quicker and sturdier to parse,
+// then the homepage, then the rest as configured. Endpoints which failed
+// within the cooldown go last
+func (ns *NewsService) endpointOrder(source models.Source) []models.SourceFallback {
+	endpoints := []models.SourceFallback{}
+	for _, fallback := range source.Fallbacks {
+		if fallback.Kind == "lite" {
+			endpoints = append(endpoints, fallback)
+		}
+	}
+	endpoints = append(endpoints, models.SourceFallback{Kind: homepageEndpoint, URL: source.URL})
+	for _, fallback := range source.Fallbacks {
+		if fallback.Kind != "lite" {
+			endpoints = append(endpoints, fallback)
+		}
+	}
+	failing := map[models.SourceFallback]bool{}
+	for _, endpoint := range endpoints {
+		failing[endpoint] = ns.endpointFailures.recent(endpointName(source.Name, endpoint))
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return !failing[endpoints[i]] && failing[endpoints[j]]
+	})
+	return endpoints
+}
+
+// endpointName identifies an endpoint in logs and failure records, e.g.
+// cnn or cnn/rss
+func endpointName(source string, endpoint models.SourceFallback) string {
+	if endpoint.Kind == homepageEndpoint {
+		return source
+	}
+	return source + "/" + endpoint.Kind
+}
+
+// scrapeWithFallbacks fetches a source's headlines from the first of its
+// endpoints that works. An endpoint that errors or finds no articles is
+// passed over for the next
+func (ns *NewsService) scrapeWithFallbacks(source models.Source, opts scrapeOptions) ([]models.NewsArticle, error) {
+	failures := []string{}
+	for _, endpoint := range ns.endpointOrder(source) {
+		var articles []models.NewsArticle
+		var err error
+		switch endpoint.Kind {
+		case homepageEndpoint:
+			articles, err = ns.scrapeHomepage(source.Name, endpoint.URL, opts)
+		case "rss":
+			articles, err = ns.fetchFeedArticles(source.Name, endpoint.URL, opts)
+		case "lite":
+			articles, err = ns.fetchMirrorArticles(source.Name, endpoint.URL, opts)
+		}
+
+		name := endpointName(source.Name, endpoint)
+		ok := err == nil && len(articles) > 0
+		ns.endpointFailures.record(name, ok)
+		if ok {
+			if len(failures) > 0 {
+				log.Printf("Served %s from %s after: %s", source.Name, name, strings.Join(failures, "; "))
+			}
+			return articles, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("no articles found")
+		}
+		log.Printf("Error fetching %s from %s: %v", name, endpoint.URL, err)
+		failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+	}
+	return nil, fmt.Errorf("every endpoint failed: %s", strings.Join(failures, "; "))
+}
+
+// fetchFeedArticles reads a source's headlines from its RSS feed and
+// enriches them like scraped ones
+func (ns *NewsService) fetchFeedArticles(sourceName, feedURL string, opts scrapeOptions) ([]models.NewsArticle, error) {
+	feed, err := ns.fetchRSS(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.limit
+	if limit == 0 {
+		limit = defaultSourceLimit
+	}
+
+	source := ns.sources[sourceName]
+	base, _ := url.Parse(feedURL)
+	articles := make([]models.NewsArticle, 0, limit)
+	for _, item := range feed.Items {
+		if len(articles) == limit {
+			break
+		}
+		title := strings.Join(strings.Fields(html.UnescapeString(item.Title)), " ")
+		link := strings.TrimSpace(item.Link)
+		if title == "" || link == "" {
+			continue
+		}
+		description := strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(item.Description, " "))), " ")
+		article := models.NewsArticle{
+			ID:          fmt.Sprintf("%s_feed_%d", sourceName, len(articles)),
+			Title:       title,
+			Description: truncateText(description, descriptionLength()),
+			URL:         resolveURL(base, link),
+			Source:      sourceName,
+			Author:      strings.TrimSpace(item.Creator),
+			PublishedAt: time.Now(),
+		}
+		if published, ok := feedItemTime(item.PubDate, source); ok {
+			article.PublishedAt = published
+		}
+		article.ImageURL = feedItemImage(item)
+		articles = append(articles, article)
+	}
+
+	ns.updateArticleDetails(&articles, opts)
+	return articles, nil
+}
+
+// feedItemTime parses an item's pubDate, which feeds write in RFC 1123 but
+// not always with a numeric zone
+func feedItemTime(raw string, source models.Source) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return parsePublishedAt(raw, source)
+}
+
+// feedItemImage picks an item's image from Media RSS or an image enclosure
+func feedItemImage(item rssItem) string {
+	for _, media := range item.Media {
+		if media.URL != "" {
+			return media.URL
+		}
+	}
+	if item.Thumbnail.URL != "" {
+		return item.Thumbnail.URL
+	}
+	if strings.HasPrefix(item.Enclosure.Type, "image/") {
+		return item.Enclosure.URL
+	}
+	return ""
+}
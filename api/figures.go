@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+)
+
+// maxFigures caps how many figures an article lists
+const maxFigures = 10
+
+// Money as written in business copy: "Tk 7.97 lakh crore", "$5bn",
+// "Rs 300 crore", "120 taka" or "2 billion dollars"
+var (
+	moneyBefore = regexp.MustCompile(`(?i)(?:\b(Tk\.?|Taka|BDT|USD|EUR|GBP|Rs\.?|INR)|(US\$|\$|৳|€|£))\s?(\d[\d,]*(?:\.\d+)?)(?:\s?(lakh crore|thousand|lakh|crore|million|billion|trillion|mn|bn|m|b|k)\b)?`)
+	moneyAfter  = regexp.MustCompile(`(?i)\b(\d[\d,]*(?:\.\d+)?)\s?(lakh crore|thousand|lakh|crore|million|billion|trillion|mn|bn)?\s(taka|dollars?|euros?|pounds?|rupees?)\b`)
+	// perClause follows a figure that is a rate or a price, as in "Tk 122
+	// per dollar" or "Tk 60 a kg"
+	perClause = regexp.MustCompile(`(?i)^\s*(?:per|a|an|/|against\s+(?:the|each|a|one)|for\s+(?:each|one|every|a))\s*(?:US\s+)?(dollar|greenback|euro|pound|rupee|yuan|usd|eur|gbp|inr|kg|kilogram|litre|liter|barrel|maund|bhori|tonne|ton|ounce|mmbtu|dozen|piece)s?\b`)
+)
+
+// currencies maps how currencies are written to their ISO codes
+var currencies = map[string]string{
+	"tk": "BDT", "tk.": "BDT", "taka": "BDT", "bdt": "BDT", "৳": "BDT",
+	"$": "USD", "us$": "USD", "usd": "USD", "dollar": "USD", "dollars": "USD", "greenback": "USD",
+	"€": "EUR", "eur": "EUR", "euro": "EUR", "euros": "EUR",
+	"£": "GBP", "gbp": "GBP", "pound": "GBP", "pounds": "GBP",
+	"rs": "INR", "rs.": "INR", "inr": "INR", "rupee": "INR", "rupees": "INR",
+	"yuan": "CNY",
+}
+
+// scales are the multipliers written after amounts, lakh and crore being
+// the South Asian 100,000 and 10,000,000
+var scales = map[string]float64{
+	"thousand": 1e3, "k": 1e3,
+	"lakh": 1e5, "crore": 1e7, "lakh crore": 1e12,
+	"million": 1e6, "mn": 1e6, "m": 1e6,
+	"billion": 1e9, "bn": 1e9, "b": 1e9,
+	"trillion": 1e12,
+}
+
+// commodities are what prices in the news are usually for
+var commodities = []string{"rice", "onion", "potato", "lentil", "sugar", "soybean oil", "palm oil", "oil", "egg", "gold", "silver",
+	"crude", "brent", "diesel", "petrol", "octane", "fuel", "lng", "lpg", "gas", "wheat", "chicken", "beef", "hilsa", "fish", "fertiliser", "fertilizer"}
+
+// businessSections are the categories and URL sections of business news
+var businessSections = []string{"business", "economy", "markets", "finance", "money", "stock", "বাণিজ্য", "অর্থনীতি"}
+
+// extractFigures finds the money amounts, exchange rates and commodity
+// prices mentioned in a business article
+func extractFigures(article models.NewsArticle) []models.Figure {
+	if !isBusiness(article) {
+		return nil
+	}
+	text := article.Title + ". " + article.Description
+	type found struct {
+		start, end int
+		figure     models.Figure
+	}
+	matches := []found{}
+	for _, m := range moneyBefore.FindAllStringSubmatchIndex(text, -1) {
+		symbol := submatch(text, m, 1) + submatch(text, m, 2)
+		figure, ok := moneyFigure(text[m[0]:m[1]], symbol, submatch(text, m, 3), submatch(text, m, 4))
+		if ok {
+			matches = append(matches, found{m[0], m[1], figure})
+		}
+	}
+	for _, m := range moneyAfter.FindAllStringSubmatchIndex(text, -1) {
+		overlaps := false
+		for _, other := range matches {
+			if m[0] < other.end && other.start < m[1] {
+				overlaps = true
+			}
+		}
+		if overlaps {
+			continue
+		}
+		figure, ok := moneyFigure(text[m[0]:m[1]], submatch(text, m, 3), submatch(text, m, 1), submatch(text, m, 2))
+		if ok {
+			matches = append(matches, found{m[0], m[1], figure})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	figures := []models.Figure{}
+	seen := map[string]bool{}
+	for _, match := range matches {
+		figure := match.figure
+		if per := perClause.FindStringSubmatch(text[match.end:]); per != nil {
+			unit := strings.ToLower(per[1])
+			if code, ok := currencies[unit]; ok {
+				figure.Kind, figure.Per = "exchange_rate", code
+			} else {
+				figure.Kind, figure.Per = "price", unit
+				figure.Commodity = commodityBefore(text[:match.start])
+			}
+			figure.Text += per[0]
+			figure.Text = strings.TrimSpace(figure.Text)
+		}
+		if seen[figure.Text] {
+			continue
+		}
+		seen[figure.Text] = true
+		figures = append(figures, figure)
+		if len(figures) == maxFigures {
+			break
+		}
+	}
+	if len(figures) == 0 {
+		return nil
+	}
+	return figures
+}
+
+// moneyFigure reads an amount and its currency and scale
+func moneyFigure(text, symbol, number, scale string) (models.Figure, bool) {
+	currency, ok := currencies[strings.ToLower(symbol)]
+	if !ok {
+		return models.Figure{}, false
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return models.Figure{}, false
+	}
+	if multiplier, ok := scales[strings.ToLower(scale)]; ok {
+		amount *= multiplier
+	}
+	return models.Figure{Kind: "amount", Text: strings.TrimSpace(text), Amount: amount, Currency: currency}, true
+}
+
+// commodityBefore names the commodity mentioned last in the sentence
+// leading up to a price, "" when there is none
+func commodityBefore(text string) string {
+	if end := strings.LastIndexAny(text, ".;!?"); end >= 0 {
+		text = text[end+1:]
+	}
+	text = " " + strings.Join(textnorm.Words(text), " ") + " "
+	best, at := "", -1
+	for _, commodity := range commodities {
+		if i := strings.LastIndex(text, " "+commodity+" "); i > at {
+			best, at = commodity, i
+		}
+	}
+	return best
+}
+
+// isBusiness reports whether an article is business coverage, by its
+// category or URL section
+func isBusiness(article models.NewsArticle) bool {
+	category := textnorm.ForSearch(article.Category)
+	for _, section := range businessSections {
+		if category == section || strings.Contains(article.URL, "/"+section+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// submatch returns group n of a FindAllStringSubmatchIndex match, "" when
+// it did not take part
+func submatch(text string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return ""
+	}
+	return text[m[2*n]:m[2*n+1]]
+}
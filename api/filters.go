@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// filterRules decide which scraped articles are dropped before they are
+// stored or served
+type filterRules struct {
+	config models.FilterRules
+
+	blockKeywords  []string
+	excludeURLs    []*regexp.Regexp
+	sourceKeywords map[string][]string
+	sourceExcludes map[string][]*regexp.Regexp
+	sourceAllows   map[string][]*regexp.Regexp
+}
+
+// newFilterRules loads rules from the JSON file at FILTER_RULES_PATH, or
+// inline JSON in FILTER_RULES. Invalid rules are logged and ignored, so a
+// typo never takes the feeds down
+func newFilterRules() *filterRules {
+	data := []byte(os.Getenv("FILTER_RULES"))
+	if path := os.Getenv("FILTER_RULES_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading filter rules, filtering is disabled: %v", err)
+			return &filterRules{}
+		}
+	}
+	if len(data) == 0 {
+		return &filterRules{}
+	}
+
+	var config models.FilterRules
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding filter rules, filtering is disabled: %v", err)
+		return &filterRules{}
+	}
+	rules, err := compileFilterRules(config)
+	if err != nil {
+		log.Printf("Invalid filter rules, filtering is disabled: %v", err)
+		return &filterRules{}
+	}
+	return rules
+}
+
+// compileFilterRules folds keywords and compiles URL patterns
+func compileFilterRules(config models.FilterRules) (*filterRules, error) {
+	rules := &filterRules{
+		config:         config,
+		blockKeywords:  foldKeywords(config.BlockKeywords),
+		sourceKeywords: map[string][]string{},
+		sourceExcludes: map[string][]*regexp.Regexp{},
+		sourceAllows:   map[string][]*regexp.Regexp{},
+	}
+
+	var err error
+	if rules.excludeURLs, err = compilePatterns(config.ExcludeURLs); err != nil {
+		return nil, err
+	}
+	for name, source := range config.Sources {
+		rules.sourceKeywords[name] = foldKeywords(source.BlockKeywords)
+		if rules.sourceExcludes[name], err = compilePatterns(source.ExcludeURLs); err != nil {
+			return nil, fmt.Errorf("source %s: %v", name, err)
+		}
+		if rules.sourceAllows[name], err = compilePatterns(source.AllowURLs); err != nil {
+			return nil, fmt.Errorf("source %s: %v", name, err)
+		}
+	}
+	return rules, nil
+}
+
+// foldKeywords normalizes keywords into space-padded word sequences, so
+// they only match whole words ("ad" doesn't match "Bangladesh")
+func foldKeywords(keywords []string) []string {
+	folded := []string{}
+	for _, keyword := range keywords {
+		if words := textnorm.Words(keyword); len(words) > 0 {
+			folded = append(folded, " "+strings.Join(words, " ")+" ")
+		}
+	}
+	return folded
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// reject returns why an article is filtered out, or "" to keep it
+func (r *filterRules) reject(article models.NewsArticle) string {
+	if allows := r.sourceAllows[article.Source]; len(allows) > 0 && !anyMatch(allows, article.URL) {
+		return "filtered: not in source allow list"
+	}
+	if anyMatch(r.excludeURLs, article.URL) || anyMatch(r.sourceExcludes[article.Source], article.URL) {
+		return "filtered: excluded url"
+	}
+	for _, category := range r.config.ExcludeCategories {
+		if article.Category != "" && strings.EqualFold(article.Category, category) {
+			return "filtered: excluded category"
+		}
+	}
+
+	text := articleText(article)
+	for _, keywords := range [][]string{r.blockKeywords, r.sourceKeywords[article.Source]} {
+		for _, keyword := range keywords {
+			if strings.Contains(text, keyword) {
+				return "filtered: blocked keyword"
+			}
+		}
+	}
+	return ""
+}
+
+// filter drops articles matching the rules, counting each in diag
+func (r *filterRules) filter(articles []models.NewsArticle, diag *selectorDiagnostics) []models.NewsArticle {
+	kept := articles[:0]
+	for _, article := range articles {
+		if reason := r.reject(article); reason != "" {
+			diag.skip(reason)
+			continue
+		}
+		kept = append(kept, article)
+	}
+	return kept
+}
+
+func anyMatch(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFilterRules returns the filtering rules in effect
+func (ns *NewsService) GetFilterRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "rules": ns.filters.config})
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// minGalleryImages is how many photos a page needs to count as a
+	// gallery
+	minGalleryImages = 2
+	// maxGalleryImages caps how many photos a gallery lists
+	maxGalleryImages = 50
+)
+
+// Gallery cues in URLs and headline labels
+var (
+	galleryURLPattern   = regexp.MustCompile(`(?i)/(photo-?galler(y|ies)|galler(y|ies)|photos|pictures|in-pictures|photo-?stories|photo-?essays?|slideshows?)(/|$)`)
+	galleryTitlePattern = regexp.MustCompile(textnorm.NFC(`(?i)^(in pictures|in photos|photos|gallery|photo story|photo essay|ছবিতে|ছবির গল্প)\s*[:|–-]`))
+)
+
+// gallerySelectors locate the photos of gallery pages, most specific first
+var gallerySelectors = []string{
+	".photo-gallery figure",
+	".gallery figure",
+	".lg-gallery figure",
+	"[class*='gallery'] figure",
+	"article figure",
+	"figure",
+}
+
+// isGallery reports whether an article is a photo gallery by its URL
+// section or headline label
+func isGallery(article models.NewsArticle) bool {
+	return galleryURLPattern.MatchString(article.URL) || galleryTitlePattern.MatchString(textnorm.NFC(article.Title))
+}
+
+// enrichImages lists the photos of gallery pages with their captions and
+// credits, from the JSON-LD ImageGallery when there is one and the page's
+// figures otherwise. Other pages are left alone
+func enrichImages(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	gallery := jsonLDObject(page.Doc, "ImageGallery")
+	if gallery == nil && !isGallery(*article) {
+		return "", nil
+	}
+
+	base, _ := url.Parse(page.URL)
+	if gallery != nil {
+		if images := jsonLDImages(gallery, base); len(images) >= minGalleryImages {
+			article.Images = images
+			return "json-ld ImageGallery", nil
+		}
+	}
+	for _, selector := range gallerySelectors {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if images := figureImages(page.Doc.Find(selector), base); len(images) >= minGalleryImages {
+			article.Images = images
+			return selector, nil
+		}
+	}
+	return "", nil
+}
+
+// jsonLDImages reads the photos of an ImageGallery, which lists them as
+// associatedMedia or image, each an ImageObject or a bare URL
+func jsonLDImages(gallery map[string]interface{}, base *url.URL) []models.ArticleImage {
+	images := []models.ArticleImage{}
+	seen := map[string]bool{}
+	for _, key := range []string{"associatedMedia", "image"} {
+		items, ok := gallery[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range items {
+			image := models.ArticleImage{}
+			switch value := item.(type) {
+			case string:
+				image.URL = value
+			case map[string]interface{}:
+				image.URL = jsonLDString(value, "contentUrl")
+				if image.URL == "" {
+					image.URL = jsonLDString(value, "url")
+				}
+				image.Caption = jsonLDString(value, "caption")
+				if image.Caption == "" {
+					image.Caption = jsonLDString(value, "description")
+				}
+				image.Caption, image.Credit = splitCredit(image.Caption)
+				for _, key := range []string{"creditText", "copyrightHolder", "author"} {
+					if credit := jsonLDString(value, key, "name"); credit != "" {
+						image.Credit = credit
+						break
+					}
+				}
+			}
+			images = addImage(images, seen, image, base)
+		}
+	}
+	return images
+}
+
+// figureImages reads a photo, its caption and its credit from each figure
+func figureImages(figures *goquery.Selection, base *url.URL) []models.ArticleImage {
+	images := []models.ArticleImage{}
+	seen := map[string]bool{}
+	figures.Each(func(i int, s *goquery.Selection) {
+		img := s.Find("img").First()
+		caption, credit := figureCaption(s)
+		images = addImage(images, seen, models.ArticleImage{
+			URL:     img.AttrOr("data-src", img.AttrOr("src", "")),
+			Caption: caption,
+			Credit:  credit,
+		}, base)
+	})
+	return images
+}
+
+// addImage appends a photo with its URL made absolute, skipping inline
+// images, repeats and anything past maxGalleryImages
+func addImage(images []models.ArticleImage, seen map[string]bool, image models.ArticleImage, base *url.URL) []models.ArticleImage {
+	if image.URL == "" || strings.HasPrefix(image.URL, "data:") || len(images) == maxGalleryImages {
+		return images
+	}
+	image.URL = resolveURL(base, image.URL)
+	image.Caption = strings.Join(strings.Fields(image.Caption), " ")
+	if seen[image.URL] {
+		return images
+	}
+	seen[image.URL] = true
+	return append(images, image)
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// place is a gazetteer entry: a Bangladeshi district or a world capital,
+// with the spellings it is found under
+type place struct {
+	location models.Location
+	aliases  []string
+}
+
+// districts are the 64 districts of Bangladesh with their old and Bangla
+// spellings, located at the district town
+var districts = []place{
+	// Dhaka division
+	{models.Location{Name: "Dhaka", Division: "Dhaka", Lat: 23.81, Lon: 90.41}, []string{"Dhaka", "Dacca", "ঢাকা"}},
+	{models.Location{Name: "Gazipur", Division: "Dhaka", Lat: 24.00, Lon: 90.42}, []string{"Gazipur", "গাজীপুর"}},
+	{models.Location{Name: "Narayanganj", Division: "Dhaka", Lat: 23.62, Lon: 90.50}, []string{"Narayanganj", "নারায়ণগঞ্জ"}},
+	{models.Location{Name: "Narsingdi", Division: "Dhaka", Lat: 23.92, Lon: 90.72}, []string{"Narsingdi", "Narsinghdi", "নরসিংদী"}},
+	{models.Location{Name: "Manikganj", Division: "Dhaka", Lat: 23.86, Lon: 90.00}, []string{"Manikganj", "মানিকগঞ্জ"}},
+	{models.Location{Name: "Munshiganj", Division: "Dhaka", Lat: 23.54, Lon: 90.53}, []string{"Munshiganj", "মুন্সীগঞ্জ", "মুন্সিগঞ্জ"}},
+	{models.Location{Name: "Tangail", Division: "Dhaka", Lat: 24.25, Lon: 89.92}, []string{"Tangail", "টাঙ্গাইল"}},
+	{models.Location{Name: "Kishoreganj", Division: "Dhaka", Lat: 24.44, Lon: 90.78}, []string{"Kishoreganj", "কিশোরগঞ্জ"}},
+	{models.Location{Name: "Faridpur", Division: "Dhaka", Lat: 23.61, Lon: 89.84}, []string{"Faridpur", "ফরিদপুর"}},
+	{models.Location{Name: "Gopalganj", Division: "Dhaka", Lat: 23.01, Lon: 89.82}, []string{"Gopalganj", "গোপালগঞ্জ"}},
+	{models.Location{Name: "Madaripur", Division: "Dhaka", Lat: 23.17, Lon: 90.20}, []string{"Madaripur", "মাদারীপুর"}},
+	{models.Location{Name: "Shariatpur", Division: "Dhaka", Lat: 23.21, Lon: 90.35}, []string{"Shariatpur", "শরীয়তপুর"}},
+	{models.Location{Name: "Rajbari", Division: "Dhaka", Lat: 23.76, Lon: 89.64}, []string{"Rajbari", "রাজবাড়ী"}},
+	// Mymensingh division
+	{models.Location{Name: "Mymensingh", Division: "Mymensingh", Lat: 24.75, Lon: 90.41}, []string{"Mymensingh", "ময়মনসিংহ"}},
+	{models.Location{Name: "Jamalpur", Division: "Mymensingh", Lat: 24.92, Lon: 89.95}, []string{"Jamalpur", "জামালপুর"}},
+	{models.Location{Name: "Sherpur", Division: "Mymensingh", Lat: 25.02, Lon: 90.02}, []string{"Sherpur", "শেরপুর"}},
+	{models.Location{Name: "Netrokona", Division: "Mymensingh", Lat: 24.88, Lon: 90.73}, []string{"Netrokona", "Netrakona", "নেত্রকোনা"}},
+	// Chattogram division
+	{models.Location{Name: "Chattogram", Division: "Chattogram", Lat: 22.36, Lon: 91.78}, []string{"Chattogram", "Chittagong", "চট্টগ্রাম"}},
+	{models.Location{Name: "Cox's Bazar", Division: "Chattogram", Lat: 21.43, Lon: 92.01}, []string{"Cox's Bazar", "Coxs Bazar", "Coxsbazar", "কক্সবাজার"}},
+	{models.Location{Name: "Cumilla", Division: "Chattogram", Lat: 23.46, Lon: 91.18}, []string{"Cumilla", "Comilla", "কুমিল্লা"}},
+	{models.Location{Name: "Feni", Division: "Chattogram", Lat: 23.02, Lon: 91.40}, []string{"Feni", "ফেনী"}},
+	{models.Location{Name: "Noakhali", Division: "Chattogram", Lat: 22.87, Lon: 91.10}, []string{"Noakhali", "নোয়াখালী"}},
+	{models.Location{Name: "Lakshmipur", Division: "Chattogram", Lat: 22.94, Lon: 90.84}, []string{"Lakshmipur", "Laxmipur", "লক্ষ্মীপুর"}},
+	{models.Location{Name: "Chandpur", Division: "Chattogram", Lat: 23.23, Lon: 90.67}, []string{"Chandpur", "চাঁদপুর"}},
+	{models.Location{Name: "Brahmanbaria", Division: "Chattogram", Lat: 23.96, Lon: 91.11}, []string{"Brahmanbaria", "ব্রাহ্মণবাড়িয়া"}},
+	{models.Location{Name: "Khagrachhari", Division: "Chattogram", Lat: 23.12, Lon: 91.98}, []string{"Khagrachhari", "Khagrachari", "খাগড়াছড়ি"}},
+	{models.Location{Name: "Rangamati", Division: "Chattogram", Lat: 22.65, Lon: 92.18}, []string{"Rangamati", "রাঙ্গামাটি", "রাঙামাটি"}},
+	{models.Location{Name: "Bandarban", Division: "Chattogram", Lat: 22.20, Lon: 92.22}, []string{"Bandarban", "বান্দরবান"}},
+	// Sylhet division
+	{models.Location{Name: "Sylhet", Division: "Sylhet", Lat: 24.90, Lon: 91.87}, []string{"Sylhet", "সিলেট"}},
+	{models.Location{Name: "Moulvibazar", Division: "Sylhet", Lat: 24.48, Lon: 91.78}, []string{"Moulvibazar", "Maulvibazar", "মৌলভীবাজার"}},
+	{models.Location{Name: "Habiganj", Division: "Sylhet", Lat: 24.37, Lon: 91.42}, []string{"Habiganj", "Hobiganj", "হবিগঞ্জ"}},
+	{models.Location{Name: "Sunamganj", Division: "Sylhet", Lat: 25.07, Lon: 91.40}, []string{"Sunamganj", "সুনামগঞ্জ"}},
+	// Rajshahi division
+	{models.Location{Name: "Rajshahi", Division: "Rajshahi", Lat: 24.37, Lon: 88.60}, []string{"Rajshahi", "রাজশাহী"}},
+	{models.Location{Name: "Bogura", Division: "Rajshahi", Lat: 24.85, Lon: 89.37}, []string{"Bogura", "Bogra", "বগুড়া"}},
+	{models.Location{Name: "Pabna", Division: "Rajshahi", Lat: 24.01, Lon: 89.24}, []string{"Pabna", "পাবনা"}},
+	{models.Location{Name: "Sirajganj", Division: "Rajshahi", Lat: 24.45, Lon: 89.70}, []string{"Sirajganj", "সিরাজগঞ্জ"}},
+	{models.Location{Name: "Natore", Division: "Rajshahi", Lat: 24.41, Lon: 88.98}, []string{"Natore", "নাটোর"}},
+	{models.Location{Name: "Naogaon", Division: "Rajshahi", Lat: 24.81, Lon: 88.93}, []string{"Naogaon", "নওগাঁ"}},
+	{models.Location{Name: "Joypurhat", Division: "Rajshahi", Lat: 25.10, Lon: 89.02}, []string{"Joypurhat", "জয়পুরহাট"}},
+	{models.Location{Name: "Chapainawabganj", Division: "Rajshahi", Lat: 24.60, Lon: 88.27}, []string{"Chapainawabganj", "Chapai Nawabganj", "চাঁপাইনবাবগঞ্জ"}},
+	// Rangpur division
+	{models.Location{Name: "Rangpur", Division: "Rangpur", Lat: 25.75, Lon: 89.25}, []string{"Rangpur", "রংপুর"}},
+	{models.Location{Name: "Dinajpur", Division: "Rangpur", Lat: 25.63, Lon: 88.64}, []string{"Dinajpur", "দিনাজপুর"}},
+	{models.Location{Name: "Kurigram", Division: "Rangpur", Lat: 25.81, Lon: 89.64}, []string{"Kurigram", "কুড়িগ্রাম"}},
+	{models.Location{Name: "Gaibandha", Division: "Rangpur", Lat: 25.33, Lon: 89.53}, []string{"Gaibandha", "গাইবান্ধা"}},
+	{models.Location{Name: "Nilphamari", Division: "Rangpur", Lat: 25.93, Lon: 88.86}, []string{"Nilphamari", "নীলফামারী"}},
+	{models.Location{Name: "Lalmonirhat", Division: "Rangpur", Lat: 25.92, Lon: 89.45}, []string{"Lalmonirhat", "লালমনিরহাট"}},
+	{models.Location{Name: "Thakurgaon", Division: "Rangpur", Lat: 26.03, Lon: 88.46}, []string{"Thakurgaon", "ঠাকুরগাঁও"}},
+	{models.Location{Name: "Panchagarh", Division: "Rangpur", Lat: 26.34, Lon: 88.55}, []string{"Panchagarh", "পঞ্চগড়"}},
+	// Khulna division
+	{models.Location{Name: "Khulna", Division: "Khulna", Lat: 22.82, Lon: 89.55}, []string{"Khulna", "খুলনা"}},
+	{models.Location{Name: "Jashore", Division: "Khulna", Lat: 23.17, Lon: 89.21}, []string{"Jashore", "Jessore", "যশোর"}},
+	{models.Location{Name: "Satkhira", Division: "Khulna", Lat: 22.72, Lon: 89.07}, []string{"Satkhira", "সাতক্ষীরা"}},
+	{models.Location{Name: "Bagerhat", Division: "Khulna", Lat: 22.65, Lon: 89.79}, []string{"Bagerhat", "বাগেরহাট"}},
+	{models.Location{Name: "Kushtia", Division: "Khulna", Lat: 23.90, Lon: 89.12}, []string{"Kushtia", "কুষ্টিয়া"}},
+	{models.Location{Name: "Jhenaidah", Division: "Khulna", Lat: 23.54, Lon: 89.15}, []string{"Jhenaidah", "ঝিনাইদহ"}},
+	{models.Location{Name: "Magura", Division: "Khulna", Lat: 23.49, Lon: 89.42}, []string{"Magura", "মাগুরা"}},
+	{models.Location{Name: "Narail", Division: "Khulna", Lat: 23.17, Lon: 89.50}, []string{"Narail", "নড়াইল"}},
+	{models.Location{Name: "Chuadanga", Division: "Khulna", Lat: 23.64, Lon: 88.84}, []string{"Chuadanga", "চুয়াডাঙ্গা"}},
+	{models.Location{Name: "Meherpur", Division: "Khulna", Lat: 23.76, Lon: 88.63}, []string{"Meherpur", "মেহেরপুর"}},
+	// Barishal division
+	{models.Location{Name: "Barishal", Division: "Barishal", Lat: 22.70, Lon: 90.37}, []string{"Barishal", "Barisal", "বরিশাল"}},
+	{models.Location{Name: "Bhola", Division: "Barishal", Lat: 22.69, Lon: 90.65}, []string{"Bhola", "ভোলা"}},
+	{models.Location{Name: "Patuakhali", Division: "Barishal", Lat: 22.36, Lon: 90.33}, []string{"Patuakhali", "পটুয়াখালী"}},
+	{models.Location{Name: "Pirojpur", Division: "Barishal", Lat: 22.58, Lon: 89.97}, []string{"Pirojpur", "পিরোজপুর"}},
+	{models.Location{Name: "Jhalokati", Division: "Barishal", Lat: 22.64, Lon: 90.20}, []string{"Jhalokati", "Jhalakati", "Jhalokathi", "ঝালকাঠি"}},
+	{models.Location{Name: "Barguna", Division: "Barishal", Lat: 22.15, Lon: 90.12}, []string{"Barguna", "বরগুনা"}},
+}
+
+// capitals are world capitals that come up in the news. Names that are
+// also common words or first names (Male, Victoria, Sofia) are left out
+var capitals = []place{
+	{models.Location{Name: "New Delhi", Country: "India", Lat: 28.61, Lon: 77.21}, []string{"New Delhi", "Delhi", "দিল্লি"}},
+	{models.Location{Name: "Islamabad", Country: "Pakistan", Lat: 33.68, Lon: 73.05}, []string{"Islamabad", "ইসলামাবাদ"}},
+	{models.Location{Name: "Kathmandu", Country: "Nepal", Lat: 27.72, Lon: 85.32}, []string{"Kathmandu", "কাঠমান্ডু"}},
+	{models.Location{Name: "Thimphu", Country: "Bhutan", Lat: 27.47, Lon: 89.64}, []string{"Thimphu", "থিম্পু"}},
+	{models.Location{Name: "Colombo", Country: "Sri Lanka", Lat: 6.93, Lon: 79.85}, []string{"Colombo", "কলম্বো"}},
+	{models.Location{Name: "Naypyidaw", Country: "Myanmar", Lat: 19.76, Lon: 96.13}, []string{"Naypyidaw", "Nay Pyi Taw", "নেপিডো"}},
+	{models.Location{Name: "Kabul", Country: "Afghanistan", Lat: 34.53, Lon: 69.17}, []string{"Kabul", "কাবুল"}},
+	{models.Location{Name: "Beijing", Country: "China", Lat: 39.90, Lon: 116.41}, []string{"Beijing", "বেইজিং"}},
+	{models.Location{Name: "Tokyo", Country: "Japan", Lat: 35.68, Lon: 139.69}, []string{"Tokyo", "টোকিও"}},
+	{models.Location{Name: "Seoul", Country: "South Korea", Lat: 37.57, Lon: 126.98}, []string{"Seoul", "সিউল"}},
+	{models.Location{Name: "Pyongyang", Country: "North Korea", Lat: 39.04, Lon: 125.76}, []string{"Pyongyang"}},
+	{models.Location{Name: "Taipei", Country: "Taiwan", Lat: 25.03, Lon: 121.57}, []string{"Taipei"}},
+	{models.Location{Name: "Bangkok", Country: "Thailand", Lat: 13.76, Lon: 100.50}, []string{"Bangkok", "ব্যাংকক"}},
+	{models.Location{Name: "Hanoi", Country: "Vietnam", Lat: 21.03, Lon: 105.85}, []string{"Hanoi"}},
+	{models.Location{Name: "Kuala Lumpur", Country: "Malaysia", Lat: 3.14, Lon: 101.69}, []string{"Kuala Lumpur", "কুয়ালালামপুর"}},
+	{models.Location{Name: "Singapore", Country: "Singapore", Lat: 1.35, Lon: 103.82}, []string{"Singapore", "সিঙ্গাপুর"}},
+	{models.Location{Name: "Jakarta", Country: "Indonesia", Lat: -6.21, Lon: 106.85}, []string{"Jakarta"}},
+	{models.Location{Name: "Manila", Country: "Philippines", Lat: 14.60, Lon: 120.98}, []string{"Manila"}},
+	{models.Location{Name: "Phnom Penh", Country: "Cambodia", Lat: 11.56, Lon: 104.92}, []string{"Phnom Penh"}},
+	{models.Location{Name: "Vientiane", Country: "Laos", Lat: 17.98, Lon: 102.63}, []string{"Vientiane"}},
+	{models.Location{Name: "Ulaanbaatar", Country: "Mongolia", Lat: 47.89, Lon: 106.91}, []string{"Ulaanbaatar", "Ulan Bator"}},
+	{models.Location{Name: "Tehran", Country: "Iran", Lat: 35.69, Lon: 51.39}, []string{"Tehran", "তেহরান"}},
+	{models.Location{Name: "Baghdad", Country: "Iraq", Lat: 33.31, Lon: 44.36}, []string{"Baghdad", "বাগদাদ"}},
+	{models.Location{Name: "Riyadh", Country: "Saudi Arabia", Lat: 24.71, Lon: 46.68}, []string{"Riyadh", "রিয়াদ"}},
+	{models.Location{Name: "Abu Dhabi", Country: "United Arab Emirates", Lat: 24.45, Lon: 54.38}, []string{"Abu Dhabi", "আবুধাবি"}},
+	{models.Location{Name: "Doha", Country: "Qatar", Lat: 25.29, Lon: 51.53}, []string{"Doha", "দোহা"}},
+	{models.Location{Name: "Kuwait City", Country: "Kuwait", Lat: 29.38, Lon: 47.99}, []string{"Kuwait City"}},
+	{models.Location{Name: "Manama", Country: "Bahrain", Lat: 26.23, Lon: 50.59}, []string{"Manama"}},
+	{models.Location{Name: "Muscat", Country: "Oman", Lat: 23.59, Lon: 58.41}, []string{"Muscat", "মাস্কাট"}},
+	{models.Location{Name: "Sanaa", Country: "Yemen", Lat: 15.37, Lon: 44.19}, []string{"Sanaa", "Sana'a"}},
+	{models.Location{Name: "Amman", Country: "Jordan", Lat: 31.95, Lon: 35.93}, []string{"Amman"}},
+	{models.Location{Name: "Beirut", Country: "Lebanon", Lat: 33.89, Lon: 35.50}, []string{"Beirut", "বৈরুত"}},
+	{models.Location{Name: "Damascus", Country: "Syria", Lat: 33.51, Lon: 36.28}, []string{"Damascus", "দামেস্ক"}},
+	{models.Location{Name: "Ankara", Country: "Turkey", Lat: 39.93, Lon: 32.86}, []string{"Ankara"}},
+	{models.Location{Name: "Cairo", Country: "Egypt", Lat: 30.04, Lon: 31.24}, []string{"Cairo", "কায়রো"}},
+	{models.Location{Name: "Tripoli", Country: "Libya", Lat: 32.89, Lon: 13.19}, []string{"Tripoli"}},
+	{models.Location{Name: "Tunis", Country: "Tunisia", Lat: 36.81, Lon: 10.18}, []string{"Tunis"}},
+	{models.Location{Name: "Algiers", Country: "Algeria", Lat: 36.75, Lon: 3.06}, []string{"Algiers"}},
+	{models.Location{Name: "Rabat", Country: "Morocco", Lat: 34.02, Lon: -6.83}, []string{"Rabat"}},
+	{models.Location{Name: "Khartoum", Country: "Sudan", Lat: 15.50, Lon: 32.56}, []string{"Khartoum"}},
+	{models.Location{Name: "Addis Ababa", Country: "Ethiopia", Lat: 9.03, Lon: 38.74}, []string{"Addis Ababa"}},
+	{models.Location{Name: "Nairobi", Country: "Kenya", Lat: -1.29, Lon: 36.82}, []string{"Nairobi"}},
+	{models.Location{Name: "Kampala", Country: "Uganda", Lat: 0.35, Lon: 32.58}, []string{"Kampala"}},
+	{models.Location{Name: "Kigali", Country: "Rwanda", Lat: -1.94, Lon: 30.06}, []string{"Kigali"}},
+	{models.Location{Name: "Mogadishu", Country: "Somalia", Lat: 2.05, Lon: 45.32}, []string{"Mogadishu"}},
+	{models.Location{Name: "Abuja", Country: "Nigeria", Lat: 9.08, Lon: 7.40}, []string{"Abuja"}},
+	{models.Location{Name: "Accra", Country: "Ghana", Lat: 5.60, Lon: -0.19}, []string{"Accra"}},
+	{models.Location{Name: "Dakar", Country: "Senegal", Lat: 14.72, Lon: -17.47}, []string{"Dakar"}},
+	{models.Location{Name: "Kinshasa", Country: "DR Congo", Lat: -4.44, Lon: 15.27}, []string{"Kinshasa"}},
+	{models.Location{Name: "Luanda", Country: "Angola", Lat: -8.84, Lon: 13.23}, []string{"Luanda"}},
+	{models.Location{Name: "Harare", Country: "Zimbabwe", Lat: -17.83, Lon: 31.05}, []string{"Harare"}},
+	{models.Location{Name: "Lusaka", Country: "Zambia", Lat: -15.39, Lon: 28.32}, []string{"Lusaka"}},
+	{models.Location{Name: "Pretoria", Country: "South Africa", Lat: -25.75, Lon: 28.19}, []string{"Pretoria"}},
+	{models.Location{Name: "London", Country: "United Kingdom", Lat: 51.51, Lon: -0.13}, []string{"London", "লন্ডন"}},
+	{models.Location{Name: "Paris", Country: "France", Lat: 48.86, Lon: 2.35}, []string{"Paris", "প্যারিস"}},
+	{models.Location{Name: "Berlin", Country: "Germany", Lat: 52.52, Lon: 13.40}, []string{"Berlin", "বার্লিন"}},
+	{models.Location{Name: "Madrid", Country: "Spain", Lat: 40.42, Lon: -3.70}, []string{"Madrid"}},
+	{models.Location{Name: "Rome", Country: "Italy", Lat: 41.90, Lon: 12.50}, []string{"Rome", "রোম"}},
+	{models.Location{Name: "Lisbon", Country: "Portugal", Lat: 38.72, Lon: -9.14}, []string{"Lisbon"}},
+	{models.Location{Name: "Dublin", Country: "Ireland", Lat: 53.35, Lon: -6.26}, []string{"Dublin"}},
+	{models.Location{Name: "Brussels", Country: "Belgium", Lat: 50.85, Lon: 4.35}, []string{"Brussels", "ব্রাসেলস"}},
+	{models.Location{Name: "Amsterdam", Country: "Netherlands", Lat: 52.37, Lon: 4.90}, []string{"Amsterdam"}},
+	{models.Location{Name: "Bern", Country: "Switzerland", Lat: 46.95, Lon: 7.45}, []string{"Bern"}},
+	{models.Location{Name: "Vienna", Country: "Austria", Lat: 48.21, Lon: 16.37}, []string{"Vienna"}},
+	{models.Location{Name: "Prague", Country: "Czechia", Lat: 50.08, Lon: 14.44}, []string{"Prague"}},
+	{models.Location{Name: "Warsaw", Country: "Poland", Lat: 52.23, Lon: 21.01}, []string{"Warsaw"}},
+	{models.Location{Name: "Budapest", Country: "Hungary", Lat: 47.50, Lon: 19.04}, []string{"Budapest"}},
+	{models.Location{Name: "Bucharest", Country: "Romania", Lat: 44.43, Lon: 26.10}, []string{"Bucharest"}},
+	{models.Location{Name: "Athens", Country: "Greece", Lat: 37.98, Lon: 23.73}, []string{"Athens"}},
+	{models.Location{Name: "Belgrade", Country: "Serbia", Lat: 44.79, Lon: 20.45}, []string{"Belgrade"}},
+	{models.Location{Name: "Kyiv", Country: "Ukraine", Lat: 50.45, Lon: 30.52}, []string{"Kyiv", "Kiev", "কিয়েভ"}},
+	{models.Location{Name: "Minsk", Country: "Belarus", Lat: 53.90, Lon: 27.56}, []string{"Minsk"}},
+	{models.Location{Name: "Moscow", Country: "Russia", Lat: 55.76, Lon: 37.62}, []string{"Moscow", "মস্কো"}},
+	{models.Location{Name: "Stockholm", Country: "Sweden", Lat: 59.33, Lon: 18.07}, []string{"Stockholm"}},
+	{models.Location{Name: "Oslo", Country: "Norway", Lat: 59.91, Lon: 10.75}, []string{"Oslo"}},
+	{models.Location{Name: "Copenhagen", Country: "Denmark", Lat: 55.68, Lon: 12.57}, []string{"Copenhagen"}},
+	{models.Location{Name: "Helsinki", Country: "Finland", Lat: 60.17, Lon: 24.94}, []string{"Helsinki"}},
+	{models.Location{Name: "Tbilisi", Country: "Georgia", Lat: 41.72, Lon: 44.78}, []string{"Tbilisi"}},
+	{models.Location{Name: "Yerevan", Country: "Armenia", Lat: 40.18, Lon: 44.51}, []string{"Yerevan"}},
+	{models.Location{Name: "Baku", Country: "Azerbaijan", Lat: 40.41, Lon: 49.87}, []string{"Baku"}},
+	{models.Location{Name: "Tashkent", Country: "Uzbekistan", Lat: 41.30, Lon: 69.24}, []string{"Tashkent"}},
+	{models.Location{Name: "Astana", Country: "Kazakhstan", Lat: 51.17, Lon: 71.45}, []string{"Astana"}},
+	{models.Location{Name: "Washington", Country: "United States", Lat: 38.91, Lon: -77.04}, []string{"Washington", "ওয়াশিংটন"}},
+	{models.Location{Name: "Ottawa", Country: "Canada", Lat: 45.42, Lon: -75.70}, []string{"Ottawa", "অটোয়া"}},
+	{models.Location{Name: "Mexico City", Country: "Mexico", Lat: 19.43, Lon: -99.13}, []string{"Mexico City"}},
+	{models.Location{Name: "Havana", Country: "Cuba", Lat: 23.11, Lon: -82.37}, []string{"Havana"}},
+	{models.Location{Name: "Bogota", Country: "Colombia", Lat: 4.71, Lon: -74.07}, []string{"Bogota", "Bogotá"}},
+	{models.Location{Name: "Caracas", Country: "Venezuela", Lat: 10.48, Lon: -66.90}, []string{"Caracas"}},
+	{models.Location{Name: "Lima", Country: "Peru", Lat: -12.05, Lon: -77.04}, []string{"Lima"}},
+	{models.Location{Name: "Santiago", Country: "Chile", Lat: -33.45, Lon: -70.67}, []string{"Santiago"}},
+	{models.Location{Name: "Buenos Aires", Country: "Argentina", Lat: -34.60, Lon: -58.38}, []string{"Buenos Aires"}},
+	{models.Location{Name: "Brasilia", Country: "Brazil", Lat: -15.79, Lon: -47.88}, []string{"Brasilia", "Brasília"}},
+	{models.Location{Name: "Canberra", Country: "Australia", Lat: -35.28, Lon: 149.13}, []string{"Canberra", "ক্যানবেরা"}},
+	{models.Location{Name: "Wellington", Country: "New Zealand", Lat: -41.29, Lon: 174.78}, []string{"Wellington"}},
+}
+
+// gazetteerEntry is a spelling folded for matching against articleText
+type gazetteerEntry struct {
+	folded   string
+	location models.Location
+}
+
+// gazetteer holds every spelling of every place, folded
+var gazetteer, districtsByName = buildGazetteer()
+
+func buildGazetteer() ([]gazetteerEntry, map[string]models.Location) {
+	entries := []gazetteerEntry{}
+	byName := map[string]models.Location{}
+	for _, group := range []struct {
+		kind, country string
+		places        []place
+	}{{"district", "Bangladesh", districts}, {"capital", "", capitals}} {
+		for _, p := range group.places {
+			location := p.location
+			location.Type = group.kind
+			if group.country != "" {
+				location.Country = group.country
+			}
+			for _, alias := range p.aliases {
+				if words := textnorm.Words(alias); len(words) > 0 {
+					folded := strings.Join(words, " ")
+					entries = append(entries, gazetteerEntry{folded: " " + folded + " ", location: location})
+					if group.kind == "district" {
+						byName[folded] = location
+					}
+				}
+			}
+		}
+	}
+	return entries, byName
+}
+
+// geotag finds the districts and capitals an article mentions, in the
+// order they first appear
+func geotag(article models.NewsArticle) []models.Location {
+	text := articleText(article)
+	type found struct {
+		at       int
+		location models.Location
+	}
+	matches := []found{}
+	seen := map[string]bool{}
+	for _, entry := range gazetteer {
+		at := strings.Index(text, entry.folded)
+		if at < 0 || seen[entry.location.Name] {
+			continue
+		}
+		seen[entry.location.Name] = true
+		matches = append(matches, found{at: at, location: entry.location})
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].at < matches[j].at
+	})
+	locations := make([]models.Location, len(matches))
+	for i, match := range matches {
+		locations[i] = match.location
+	}
+	return locations
+}
+
+// lookupDistrict finds a district by any of its spellings, e.g. "sylhet",
+// "chittagong" or "coxs-bazar"
+func lookupDistrict(name string) (models.Location, bool) {
+	location, ok := districtsByName[strings.Join(textnorm.Words(strings.ReplaceAll(name, "-", " ")), " ")]
+	return location, ok
+}
+
+// mentions reports whether an article mentions the named place
+func mentions(article models.NewsArticle, name string) bool {
+	for _, location := range article.Locations {
+		if location.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeGeoJSON sends articles as a GeoJSON FeatureCollection for map UIs,
+// with a point for every place each article mentions. Articles that
+// mention none are left out
+func writeGeoJSON(c *gin.Context, articles []models.NewsArticle) {
+	collection := models.GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []models.GeoJSONFeature{}}
+	for _, article := range articles {
+		for _, location := range article.Locations {
+			collection.Features = append(collection.Features, models.GeoJSONFeature{
+				Type:     "Feature",
+				Geometry: models.GeoJSONPoint{Type: "Point", Coordinates: [2]float64{location.Lon, location.Lat}},
+				Properties: models.GeoJSONProperties{
+					Key:         article.Key,
+					Title:       article.Title,
+					URL:         article.URL,
+					Source:      article.Source,
+					PublishedAt: article.PublishedAt,
+					Location:    location,
+				},
+			})
+		}
+	}
+	c.Header("Content-Type", "application/geo+json; charset=utf-8")
+	c.JSON(http.StatusOK, collection)
+}
+
+// districtSlug is how a district is written in URLs, e.g. coxs-bazar
+func districtSlug(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(name, "'", "")), " ", "-")
+}
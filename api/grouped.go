@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// groupBySource reads the optional group_by query parameter. It writes a
+// 400 and returns false for ok when it is anything but source
+func groupBySource(c *gin.Context) (grouped bool, ok bool) {
+	switch c.Query("group_by") {
+	case "":
+		return false, true
+	case "source":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Success: false,
+		Error:   "invalid_group_by",
+		Message: "group_by must be source",
+	})
+	return false, false
+}
+
+// writeGroupedNews writes each source's articles under its name, passed
+// through the same overrides and filters as a flat feed. Sources are
+// trimmed in name order so the response stays under maxArticles
+func (ns *NewsService) writeGroupedNews(c *gin.Context, groups map[string]models.SourceNews) {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := models.GroupedNewsResponse{Success: true, Sources: map[string]models.SourceNews{}}
+	all := []models.NewsArticle{}
+	for _, name := range names {
+		group := groups[name]
+		articles, ok := servedFilter(c, currentTenant(c).filter(ns.overrides.apply(group.Articles, name)))
+		if !ok {
+			return
+		}
+		if room := ns.maxArticles - response.Count; len(articles) > room {
+			articles = articles[:room]
+		}
+		group.Articles = articles
+		group.Count = len(articles)
+		response.Sources[name] = group
+		response.Count += group.Count
+		all = append(all, articles...)
+	}
+	setSurrogateKeys(c, surrogateKeys(all, ""))
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sourceHealth remembers how the last scrape of each source went
+type sourceHealth struct {
+	mu      sync.Mutex
+	sources map[string]models.SourceHealth
+	// warming starts one scrape of every source, for instances that have
+	// not served any news yet
+	warming sync.Once
+}
+
+func newSourceHealth() *sourceHealth {
+	return &sourceHealth{sources: map[string]models.SourceHealth{}}
+}
+
+// record notes the outcome of a scrape. A scrape without articles counts as
+// a failure, since it leaves nothing to serve
+func (h *sourceHealth) record(source string, articles int, err error) {
+	now := time.Now().UTC()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := h.sources[source]
+	health.LastAttempt = &now
+	health.Articles = articles
+	health.Error = ""
+	health.Healthy = err == nil && articles > 0
+	switch {
+	case err != nil:
+		health.Error = err.Error()
+	case articles == 0:
+		health.Error = "no articles found"
+	default:
+		health.LastSuccess = &now
+	}
+	h.sources[source] = health
+}
+
+// degraded reports whether the last scrape of a source failed
+func (h *sourceHealth) degraded(source string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health, ok := h.sources[source]
+	return ok && !health.Healthy
+}
+
+// lastSuccess returns when a source was last scraped successfully, nil
+// when it has not been yet
+func (h *sourceHealth) lastSuccess(source string) *time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sources[source].LastSuccess
+}
+
+// snapshot returns the health of every source scraped so far
+func (h *sourceHealth) snapshot() map[string]models.SourceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sources := make(map[string]models.SourceHealth, len(h.sources))
+	for name, health := range h.sources {
+		sources[name] = health
+	}
+	return sources
+}
+
+// warmUp scrapes every active source once in the background, so a fresh
+// instance becomes ready without waiting for traffic it isn't getting yet
+func (ns *NewsService) warmUp() {
+	ns.health.warming.Do(func() {
+		for name, source := range ns.sources {
+			if !source.Active {
+				continue
+			}
+			go ns.fetchNewsFromSource(name, source.URL, scrapeOptions{limit: defaultSourceLimit})
+		}
+	})
+}
+
+// Livez reports that the process is up and serving requests
+func (ns *NewsService) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "timestamp": time.Now()})
+}
+
+// Readyz reports whether this instance should get traffic: the store must
+// be writable, there must be news to serve and at least one source must
+// have scraped successfully. Otherwise it answers 503
+func (ns *NewsService) Readyz(c *gin.Context) {
+	sources := ns.health.snapshot()
+	if len(sources) == 0 {
+		ns.warmUp()
+	}
+
+	storeCheck := models.ReadinessCheck{Name: "store", OK: true}
+	if err := ns.store.Check(); err != nil {
+		storeCheck.OK = false
+		storeCheck.Message = err.Error()
+	}
+
+	healthy, scraped := 0, false
+	for _, health := range sources {
+		if health.Healthy {
+			healthy++
+		}
+		if health.LastSuccess != nil {
+			scraped = true
+		}
+	}
+
+	warmCheck := models.ReadinessCheck{Name: "warm", OK: scraped || ns.store.Len() > 0}
+	if !warmCheck.OK {
+		warmCheck.Message = "no news has been scraped yet, warming up"
+	}
+
+	sourcesCheck := models.ReadinessCheck{Name: "sources", OK: healthy > 0, Message: fmt.Sprintf("%d of %d scraped sources are healthy", healthy, len(sources))}
+	if len(sources) == 0 {
+		sourcesCheck.Message = "no source has been scraped yet"
+	}
+
+	response := models.ReadinessResponse{
+		Ready:   storeCheck.OK && warmCheck.OK && sourcesCheck.OK,
+		Checks:  []models.ReadinessCheck{storeCheck, warmCheck, sourcesCheck},
+		Sources: sources,
+	}
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
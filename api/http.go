@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bufferedWriter holds back the response body so headers that depend on it
+// (Content-Length, ETag) can be set before anything reaches the client
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// streamedRoutes send large files, which conditionalGet leaves unbuffered
+var streamedRoutes = map[string]bool{
+	"/api/v1/exports/:id/download": true,
+}
+
+// conditionalGet buffers GET and HEAD responses to add Content-Length and an
+// ETag, answers If-None-Match with 304 and drops the body for HEAD requests.
+// Handlers that know a better ETag than the body's hash may set their own
+func conditionalGet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead || streamedRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		body := buffered.body.Bytes()
+		if buffered.status == http.StatusOK {
+			etag := original.Header().Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(body)
+				etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+				original.Header().Set("ETag", etag)
+			}
+
+			if etagMatches(c.GetHeader("If-None-Match"), etag) {
+				original.Header().Del("Content-Type")
+				original.WriteHeader(http.StatusNotModified)
+				original.WriteHeaderNow()
+				return
+			}
+		}
+
+		// A HEAD handler that wrote nothing has no GET body to size
+		if method == http.MethodGet || len(body) > 0 {
+			original.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		original.WriteHeader(buffered.status)
+		if method == http.MethodHead {
+			original.WriteHeaderNow()
+			return
+		}
+		original.Write(body)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// methodNotAllowed answers OPTIONS requests for known paths with the methods
+// they support and rejects any other unsupported method with 405
+func methodNotAllowed(r *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		methods := allowedMethods(r.Routes(), c.Request.URL.Path)
+		c.Header("Allow", strings.Join(methods, ", "))
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, models.ErrorResponse{
+			Success: false,
+			Error:   "method_not_allowed",
+			Message: "Method not allowed, use one of: " + strings.Join(methods, ", "),
+		})
+	}
+}
+
+// allowedMethods lists the methods registered for the route matching path,
+// always including OPTIONS
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	seen := map[string]bool{http.MethodOptions: true}
+	for _, route := range routes {
+		if routeMatches(route.Path, path) {
+			seen[route.Method] = true
+		}
+	}
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// routeMatches reports whether a request path matches a gin route pattern
+func routeMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
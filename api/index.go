@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 	"time"
 
@@ -16,33 +17,137 @@ func init() {
 
 // Handler is the entry point for Vercel
 func Handler(w http.ResponseWriter, r *http.Request) {
+	if err := Startup(); err != nil {
+		log.Fatal(err)
+	}
 	router.ServeHTTP(w, r)
 }
 
 func setupRouter() *gin.Engine {
-	// Initialize router
-	r := gin.Default()
+	// Initialize router, with structured access logs instead of Gin's
+	r := gin.New()
+	if accessLog := newAccessLogger(); accessLog != nil {
+		r.Use(accessLog.middleware())
+	}
+	r.Use(gin.Recovery())
+	// Compliance mode turns off full-text and image proxy routes for
+	// every caller
+	r.Use(complianceGuard())
 
 	// Configure CORS
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "If-None-Match", "X-Request-Lane"}
+	config.ExposeHeaders = []string{"ETag", "Content-Length", "X-Article-Count", "X-Last-Fetched"}
 	r.Use(cors.New(config))
 
+	// Answer OPTIONS and unsupported methods with an accurate Allow header
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(methodNotAllowed(r))
+
 	// Initialize news service
 	newsService := NewNewsService()
+	startup.ns = newsService
+
+	// Probes for orchestrators: livez while the process is up, readyz only
+	// once the instance has news to serve
+	getAndHead(&r.RouterGroup, "/livez", newsService.Livez)
+	getAndHead(&r.RouterGroup, "/readyz", newsService.Readyz)
+
+	// Text-only headlines for very slow connections
+	r.GET("/lite", conditionalGet(), newsService.LitePage)
+
+	// Fediverse account posting new headlines, when ACTIVITYPUB_DOMAIN is set
+	r.GET("/.well-known/webfinger", newsService.WebFinger)
+	fediverse := r.Group("/ap")
+	{
+		fediverse.GET("/actor", newsService.GetActor)
+		fediverse.POST("/inbox", newsService.PostInbox)
+		fediverse.GET("/outbox", newsService.GetOutbox)
+		fediverse.GET("/followers", newsService.GetFollowers)
+		fediverse.GET("/notes/:key", newsService.GetNote)
+	}
 
 	// Setup routes
 	api := r.Group("/api/v1")
+	api.Use(conditionalGet(), newsService.identifyUser(), newsService.identifyKey(), newsService.identifyTenant(), newsService.meterUsage())
 	{
-		api.GET("/news", newsService.GetAllNews)
-		api.GET("/news/:source", newsService.GetNewsBySource)
-		api.GET("/sources", newsService.GetAvailableSources)
-		api.GET("/health", func(c *gin.Context) {
+		getAndHead(api, "/news", newsService.GetAllNews)
+		// HEAD runs the GET handler, so it scrapes when GET would
+		getAndHead(api, "/news/:source", newsService.GetNewsBySource)
+		getAndHead(api, "/news/:source/latest", newsService.GetLatestArticle)
+		getAndHead(api, "/news/local/:district", newsService.GetLocalNews)
+		getAndHead(api, "/news/local/division/:division", newsService.GetDivisionNews)
+		getAndHead(api, "/sources", newsService.GetAvailableSources)
+		getAndHead(api, "/oembed", newsService.GetOEmbed)
+		getAndHead(api, "/article/:id/view", newsService.ViewArticle)
+		getAndHead(api, "/article/:id/updates", newsService.GetArticleUpdates)
+		getAndHead(api, "/image", newsService.ProxyImage)
+		getAndHead(api, "/briefing.mp3", newsService.GetBriefing)
+		getAndHead(api, "/briefing/feed.xml", newsService.GetBriefingFeed)
+		getAndHead(api, "/digest/:date", newsService.GetDigest)
+		getAndHead(api, "/factchecks", newsService.GetFactChecks)
+		getAndHead(api, "/stats", newsService.GetStats)
+		getAndHead(api, "/coverage", newsService.GetCoverage)
+		getAndHead(api, "/changes", newsService.GetChanges)
+		getAndHead(api, "/search", newsService.Search)
+		getAndHead(api, "/search/suggest", newsService.GetSearchSuggestions)
+		getAndHead(api, "/bookmarks", newsService.ListBookmarks)
+		api.POST("/bookmarks", newsService.AddBookmark)
+		api.DELETE("/bookmarks/:key", newsService.DeleteBookmark)
+		getAndHead(api, "/searches", newsService.ListSavedSearches)
+		api.POST("/searches", newsService.SaveSearch)
+		api.DELETE("/searches/:id", newsService.DeleteSavedSearch)
+		getAndHead(api, "/me/export", newsService.ExportMe)
+		api.DELETE("/me", newsService.DeleteMe)
+		getAndHead(api, "/me/usage", newsService.GetUsage)
+		// Saved search feeds authenticate with the token in the URL
+		getAndHead(api, "/feeds/:file", newsService.GetSearchFeed)
+		getAndHead(api, "/live/:slug", newsService.GetLiveEvent)
+		// Downloads also need the signed link from the job, and stream
+		// past conditionalGet's buffering
+		api.POST("/exports", newsService.CreateExport)
+		api.GET("/exports/:id", newsService.GetExport)
+		api.GET("/exports/:id/download", newsService.DownloadExport)
+		getAndHead(api, "/version", newsService.GetVersion)
+		getAndHead(api, "/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"status": "healthy", "timestamp": time.Now()})
 		})
 	}
 
+	// Every admin key can read; changes need the editor or admin role
+	editor := requireRole(roleEditor)
+	adminOnly := requireRole(roleAdmin)
+	admin := api.Group("/admin", requireAdmin(), logAdminActions())
+	{
+		admin.POST("/sources/test", editor, newsService.TestSource)
+		admin.GET("/snapshots", newsService.ListSnapshots)
+		admin.POST("/snapshots/:id/replay", editor, newsService.ReplaySnapshot)
+		admin.GET("/enrichment/metrics", newsService.GetEnrichmentMetrics)
+		admin.GET("/store", newsService.GetStoreStats)
+		admin.GET("/overrides", newsService.ListOverrides)
+		admin.PUT("/overrides/:key", editor, newsService.SetOverride)
+		admin.DELETE("/overrides/:key", editor, newsService.DeleteOverride)
+		admin.GET("/filters", newsService.GetFilterRules)
+		admin.GET("/chaos", newsService.GetChaos)
+		admin.PUT("/chaos", adminOnly, newsService.SetChaos)
+		admin.GET("/deliveries", newsService.ListDeliveries)
+		admin.POST("/deliveries/:id/retry", editor, newsService.RetryDelivery)
+		admin.DELETE("/deliveries/:id", adminOnly, newsService.DropDelivery)
+		admin.POST("/cdn/purge", editor, newsService.PurgeCDN)
+		admin.GET("/audit", newsService.ListAudit)
+		admin.GET("/schedule", newsService.GetSchedule)
+		admin.GET("/special-event", newsService.GetSpecialEvent)
+		admin.PUT("/special-event", adminOnly, newsService.StartSpecialEvent)
+		admin.DELETE("/special-event", adminOnly, newsService.StopSpecialEvent)
+	}
+
 	return r
-} 
\ No newline at end of file
+}
+
+// getAndHead registers a handler for both GET and HEAD requests on path
+func getAndHead(group *gin.RouterGroup, path string, handler gin.HandlerFunc) {
+	group.GET(path, handler)
+	group.HEAD(path, handler)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"top-news/models"
+)
+
+// integration receives newly scraped articles, e.g. to copy them into a
+// spreadsheet
+type integration interface {
+	name() string
+	publish(articles []models.NewsArticle) error
+}
+
+// integrationFilter narrows down the articles an integration receives.
+// It is configured with query syntax, e.g. "source=cnn&type=news&q=election,budget";
+// an article matches when it contains any of the keywords
+type integrationFilter struct {
+	source      string
+	articleType string
+	keywords    []string
+}
+
+func parseIntegrationFilter(value string) integrationFilter {
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		log.Printf("Invalid integration filter %q, sending every article: %v", value, err)
+		return integrationFilter{}
+	}
+	return integrationFilter{
+		source:      query.Get("source"),
+		articleType: query.Get("type"),
+		keywords:    foldKeywords(strings.Split(strings.Join(query["q"], ","), ",")),
+	}
+}
+
+// matches reports whether an article passes the filter
+func (f integrationFilter) matches(article models.NewsArticle) bool {
+	if f.source != "" && article.Source != f.source {
+		return false
+	}
+	if f.articleType != "" && article.Type != f.articleType {
+		return false
+	}
+	if len(f.keywords) == 0 {
+		return true
+	}
+	text := articleText(article)
+	for _, keyword := range f.keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// newIntegrations returns the integrations configured through env vars
+func newIntegrations() []integration {
+	integrations := []integration{}
+	if sheets := newSheetsIntegration(); sheets != nil {
+		integrations = append(integrations, sheets)
+	}
+	if notion := newNotionIntegration(); notion != nil {
+		integrations = append(integrations, notion)
+	}
+	integrations = append(integrations, newSocialPosters()...)
+	return integrations
+}
+
+// subscribeIntegration hands newly discovered articles to an integration
+func (b *eventBus) subscribeIntegration(target integration) {
+	b.subscribe(target.name(), func(event models.Event) {
+		if err := target.publish(event.Articles); err != nil {
+			log.Printf("Error publishing %d articles to %s: %v", len(event.Articles), target.name(), err)
+		}
+	}, eventArticleDiscovered)
+}
@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userContextKey is where identifyUser stores the subject of a valid token
+const userContextKey = "user"
+
+const (
+	// jwksRefresh is how long fetched signing keys are trusted
+	jwksRefresh = time.Hour
+	// jwksMinRefresh limits refetches caused by tokens with unknown key IDs
+	jwksMinRefresh = time.Minute
+	// jwtLeeway allows for clock skew between us and the identity provider
+	jwtLeeway = time.Minute
+)
+
+// jwtAlgorithms maps the supported signing algorithms to their hash. HMAC
+// and "none" are deliberately missing: only the provider's public keys may
+// sign tokens
+var jwtAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtVerifier checks bearer tokens from an OpenID Connect provider such as
+// Auth0, Keycloak or Firebase Auth against its published signing keys
+type jwtVerifier struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	client   *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // key ID -> key
+	fetchedAt time.Time
+}
+
+// newJWTVerifier configures token validation from JWT_ISSUER and
+// JWT_AUDIENCE. The signing keys come from JWT_JWKS_URL, or when that is
+// unset from the issuer's OpenID configuration. It returns nil when JWT
+// auth is not configured
+func newJWTVerifier() *jwtVerifier {
+	issuer := os.Getenv("JWT_ISSUER")
+	audience := os.Getenv("JWT_AUDIENCE")
+	if issuer == "" && audience == "" {
+		return nil
+	}
+	if issuer == "" || audience == "" {
+		log.Printf("JWT auth is disabled: set both JWT_ISSUER and JWT_AUDIENCE")
+		return nil
+	}
+	return &jwtVerifier{
+		issuer:   issuer,
+		audience: audience,
+		jwksURL:  os.Getenv("JWT_JWKS_URL"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// jwtClaims are the registered claims we check
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// hasAudience reports whether aud, a string or a list of strings, names
+// the audience
+func (claims jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(claims.Audience, &single) == nil {
+		return single == audience
+	}
+	var list []string
+	if json.Unmarshal(claims.Audience, &list) == nil {
+		return containsString(list, audience)
+	}
+	return false
+}
+
+// looksLikeJWT tells tokens apart from opaque API keys sent as bearer
+// tokens
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verify checks a token's signature and claims and returns its subject
+func (v *jwtVerifier) verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("token is not a JWT")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", fmt.Errorf("invalid header: %v", err)
+	}
+	hash, ok := jwtAlgorithms[header.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm %q", header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	key, err := v.key(header.KeyID)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyJWTSignature(header.Algorithm, hash, key, parts[0]+"."+parts[1], signature); err != nil {
+		return "", err
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("invalid claims: %v", err)
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != v.issuer:
+		return "", fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case !claims.hasAudience(v.audience):
+		return "", errors.New("token is not for this audience")
+	case claims.ExpiresAt == nil:
+		return "", errors.New("token has no expiry")
+	case now.Add(-jwtLeeway).After(jwtTime(*claims.ExpiresAt)):
+		return "", errors.New("token has expired")
+	case claims.NotBefore != nil && now.Add(jwtLeeway).Before(jwtTime(*claims.NotBefore)):
+		return "", errors.New("token is not valid yet")
+	case claims.Subject == "":
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// jwtTime converts a NumericDate claim
+func jwtTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// decodeJWTPart decodes a base64url JSON segment of a token
+func decodeJWTPart(part string, into interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+// verifyJWTSignature checks an RS* or ES* signature over the signed part
+// of a token
+func verifyJWTSignature(algorithm string, hash crypto.Hash, key crypto.PublicKey, signed string, signature []byte) error {
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") {
+			return fmt.Errorf("key cannot verify %s", algorithm)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "ES") {
+			return fmt.Errorf("key cannot verify %s", algorithm)
+		}
+		// JWS signatures are r and s as fixed-size big-endian integers
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported key type")
+}
+
+// key returns the signing key with the given ID, fetching the key set when
+// it is stale or doesn't have the key yet (providers rotate keys)
+func (v *jwtVerifier) key(id string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[id]
+	age := time.Since(v.fetchedAt)
+	if (ok && age < jwksRefresh) || (!ok && age < jwksMinRefresh) {
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", id)
+		}
+		return key, nil
+	}
+
+	keys, err := v.fetchKeys()
+	v.fetchedAt = time.Now()
+	if err != nil {
+		// Keep using the keys we have until the provider is back
+		log.Printf("Error fetching JWT signing keys: %v", err)
+	} else {
+		v.keys = keys
+	}
+	if key, ok := v.keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", id)
+}
+
+// check fetches the signing keys now, for the startup self-check
+func (v *jwtVerifier) check() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return err
+	}
+	v.keys, v.fetchedAt = keys, time.Now()
+	return nil
+}
+
+// fetchKeys downloads the provider's JSON Web Key Set; callers must hold
+// the lock
+func (v *jwtVerifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(strings.TrimSuffix(v.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("failed to discover the key set, set JWT_JWKS_URL: %v", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("the OpenID configuration has no jwks_uri, set JWT_JWKS_URL")
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []struct {
+			Type  string `json:"kty"`
+			ID    string `json:"kid"`
+			Use   string `json:"use"`
+			N     string `json:"n"`
+			E     string `json:"e"`
+			Curve string `json:"crv"`
+			X     string `json:"x"`
+			Y     string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(v.jwksURL, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Type {
+		case "RSA":
+			key, err = rsaKey(jwk.N, jwk.E)
+		case "EC":
+			key, err = ecKey(jwk.Curve, jwk.X, jwk.Y)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Skipping JWT signing key %s: %v", jwk.ID, err)
+			continue
+		}
+		keys[jwk.ID] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the key set has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (v *jwtVerifier) getJSON(url string, into interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	if len(modulus) < 256 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	exp := new(big.Int).SetBytes(exponent)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(exp.Int64())}, nil
+}
+
+func ecKey(curve, x, y string) (*ecdsa.PublicKey, error) {
+	var c elliptic.Curve
+	switch curve {
+	case "P-256":
+		c = elliptic.P256()
+	case "P-384":
+		c = elliptic.P384()
+	case "P-521":
+		c = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", curve)
+	}
+	xBytes, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: c, X: new(big.Int).SetBytes(xBytes), Y: new(big.Int).SetBytes(yBytes)}
+	if !c.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return key, nil
+}
+
+// identifyUser is a middleware that checks a bearer JWT and records its
+// subject. A token that fails the checks is rejected with a 401 rather
+// than served as anonymous, so clients notice expired sessions
+func (ns *NewsService) identifyUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ns.jwt == nil || token == c.GetHeader("Authorization") || !looksLikeJWT(token) {
+			c.Next()
+			return
+		}
+		subject, err := ns.jwt.verify(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_token",
+				Message: fmt.Sprintf("The bearer token was rejected: %v", err),
+			})
+			return
+		}
+		c.Set(userContextKey, subject)
+		c.Next()
+	}
+}
+
+// currentUser returns the subject of the request's token, "" when there
+// is none
+func currentUser(c *gin.Context) string {
+	return c.GetString(userContextKey)
+}
+
+// userStore keeps the bookmarks of signed-in users, persisted under
+// USERS_DIR when it is set
+type userStore struct {
+	mu        sync.Mutex
+	dir       string
+	bookmarks map[string]*bookmarkStore // subject -> bookmarks
+}
+
+func newUserStore() *userStore {
+	return &userStore{dir: os.Getenv("USERS_DIR"), bookmarks: map[string]*bookmarkStore{}}
+}
+
+// bookmarksOf returns a user's bookmarks, loading them on first use
+func (u *userStore) bookmarksOf(subject string) *bookmarkStore {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if store, ok := u.bookmarks[subject]; ok {
+		return store
+	}
+	store := &bookmarkStore{bookmarks: map[string]models.Bookmark{}}
+	if u.dir != "" {
+		// Subjects are chosen by the provider, so they never become paths
+		hash := sha256.Sum256([]byte(subject))
+		store.path = filepath.Join(u.dir, hex.EncodeToString(hash[:]), "bookmarks.json")
+		store.load()
+	}
+	u.bookmarks[subject] = store
+	return store
+}
+
+// remove deletes a user's bookmarks, on disk too, and returns how many
+// there were
+func (u *userStore) remove(subject string) (int, error) {
+	store := u.bookmarksOf(subject)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	removed := len(store.bookmarks)
+	store.bookmarks = map[string]models.Bookmark{}
+	delete(u.bookmarks, subject)
+	if store.path != "" {
+		if err := os.RemoveAll(filepath.Dir(store.path)); err != nil {
+			return removed, fmt.Errorf("failed to remove bookmarks: %v", err)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lane is the priority of work on the scrape worker pool. Background work
+// and bulk callers are batch; people waiting on a response are interactive
+type lane int
+
+const (
+	laneBatch lane = iota
+	laneInteractive
+)
+
+// laneHeader lets a caller say which lane its request belongs in
+const laneHeader = "X-Request-Lane"
+
+var laneNames = map[string]lane{
+	"batch":       laneBatch,
+	"interactive": laneInteractive,
+}
+
+// checkLane reports an error for a lane name that is not known. Keys
+// without a lane are interactive
+func checkLane(name string) error {
+	if _, ok := laneNames[name]; name != "" && !ok {
+		return fmt.Errorf("unknown lane %q, want interactive or batch", name)
+	}
+	return nil
+}
+
+// requestLane picks the lane of a request. Requests are interactive unless
+// their key or tenant is configured as batch or they ask for batch with
+// X-Request-Lane; a batch key can not ask its way into the interactive
+// lane
+func requestLane(c *gin.Context) lane {
+	configured := c.GetString(keyLaneContextKey)
+	if t := currentTenant(c); t != nil {
+		configured = t.lane
+	}
+	if configured == "batch" || strings.EqualFold(c.GetHeader(laneHeader), "batch") {
+		return laneBatch
+	}
+	return laneInteractive
+}
+
+// scrapePool caps how many scrapes run at once. Interactive work is let in
+// first whenever a worker frees up, and batch work may only hold some of
+// the workers, so a bulk caller never takes them all
+type scrapePool struct {
+	mu       sync.Mutex
+	workers  int
+	batchMax int
+	running  [2]int
+	waiting  [2][]chan struct{}
+}
+
+// newScrapePool reads SCRAPE_WORKERS, the scrapes that may run at once,
+// and SCRAPE_BATCH_WORKERS, how many of them batch work may hold, half by
+// default. Without SCRAPE_WORKERS scrapes are not limited
+func newScrapePool() *scrapePool {
+	value := os.Getenv("SCRAPE_WORKERS")
+	if value == "" {
+		return nil
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		log.Printf("Invalid SCRAPE_WORKERS %q, scrapes are not limited", value)
+		return nil
+	}
+	p := &scrapePool{workers: workers, batchMax: (workers + 1) / 2}
+	if value := os.Getenv("SCRAPE_BATCH_WORKERS"); value != "" {
+		batchMax, err := strconv.Atoi(value)
+		if err != nil || batchMax < 1 || batchMax > workers {
+			log.Printf("Invalid SCRAPE_BATCH_WORKERS %q, using %d", value, p.batchMax)
+		} else {
+			p.batchMax = batchMax
+		}
+	}
+	return p
+}
+
+// acquire waits for a worker in a lane and returns the function that
+// gives it back
+func (p *scrapePool) acquire(l lane) func() {
+	if p == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	if len(p.waiting[laneInteractive]) == 0 && (l == laneInteractive || len(p.waiting[laneBatch]) == 0) && p.free(l) {
+		p.running[l]++
+		p.mu.Unlock()
+		return func() { p.release(l) }
+	}
+	ready := make(chan struct{})
+	p.waiting[l] = append(p.waiting[l], ready)
+	p.mu.Unlock()
+	<-ready
+	return func() { p.release(l) }
+}
+
+// free reports whether a lane may start another scrape; callers must hold
+// the lock
+func (p *scrapePool) free(l lane) bool {
+	if p.running[laneInteractive]+p.running[laneBatch] >= p.workers {
+		return false
+	}
+	return l == laneInteractive || p.running[laneBatch] < p.batchMax
+}
+
+// release gives a worker back and hands free workers to the longest
+// waiting interactive work, then batch work
+func (p *scrapePool) release(l lane) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running[l]--
+	for _, next := range []lane{laneInteractive, laneBatch} {
+		for len(p.waiting[next]) > 0 && p.free(next) {
+			p.running[next]++
+			close(p.waiting[next][0])
+			p.waiting[next] = p.waiting[next][1:]
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLatestArticle returns just the ID and timestamps of a source's newest
+// stored article. It never scrapes, so polling it is cheap; fetch the full
+// list when the ID changes
+func (ns *NewsService) GetLatestArticle(c *gin.Context) {
+	sourceName := c.Param("source")
+	if _, exists := ns.sources[sourceName]; !exists || !currentTenant(c).allows(sourceName) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "source_not_found",
+			Message: "News source not found",
+		})
+		return
+	}
+
+	latest := ns.store.List(store.Filter{Source: sourceName, Limit: 1})
+	if len(latest) == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "no_articles",
+			Message: "No articles from this source are stored yet",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.LatestArticleResponse{
+		Success:     true,
+		Source:      sourceName,
+		ID:          latest[0].ID,
+		Key:         articleKey(latest[0].URL),
+		PublishedAt: latest[0].PublishedAt,
+		FetchedAt:   ns.health.lastSuccess(sourceName),
+	})
+}
+
+// setFreshnessHeaders adds X-Article-Count, the number of articles in the
+// response, and X-Last-Fetched, when the source was last scraped
+// successfully, to a source's news response. GET and HEAD both get them,
+// so dashboards can poll with HEAD and skip the articles
+func setFreshnessHeaders(c *gin.Context, count int, fetched *time.Time) {
+	c.Header("X-Article-Count", strconv.Itoa(count))
+	if fetched != nil {
+		c.Header("X-Last-Fetched", fetched.UTC().Format(http.TimeFormat))
+	}
+}
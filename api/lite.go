@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liteDescriptionMax is the longest description kept in lite responses
+const liteDescriptionMax = 140
+
+// writeNews sends a news response with editorial overrides and the
+// tenant's filters applied, in its minimal form when the request asks for
+// lite=true, or as GeoJSON for format=geojson. Source responses also get the
+// freshness headers
+func (ns *NewsService) writeNews(c *gin.Context, response models.NewsResponse) {
+	data, ok := servedFilter(c, currentTenant(c).filter(ns.overrides.apply(response.Data, response.Source)))
+	if !ok {
+		return
+	}
+	response.Data = data
+	response.Count = len(response.Data)
+	if response.Source != "" {
+		setFreshnessHeaders(c, response.Count, response.FetchedAt)
+	}
+	setSurrogateKeys(c, surrogateKeys(response.Data, response.Source))
+	if c.Query("format") == "geojson" {
+		writeGeoJSON(c, response.Data)
+		return
+	}
+	if c.Query("lite") != "true" {
+		if ns.fastJSON {
+			writeNewsJSON(c, response)
+		} else {
+			c.JSON(http.StatusOK, response)
+		}
+		return
+	}
+
+	lite := models.LiteNewsResponse{
+		Success: true,
+		Data:    make([]models.LiteArticle, 0, len(response.Data)),
+		Count:   response.Count,
+	}
+	for _, article := range response.Data {
+		lite.Data = append(lite.Data, liteArticle(article))
+	}
+	c.JSON(http.StatusOK, lite)
+}
+
+// liteArticle drops everything but the headline, link, publish time and
+// sports score; short descriptions are kept, long ones dropped
+func liteArticle(article models.NewsArticle) models.LiteArticle {
+	lite := models.LiteArticle{
+		Key:         article.Key,
+		Title:       article.Title,
+		URL:         article.URL,
+		Source:      article.Source,
+		PublishedAt: article.PublishedAt,
+		Score:       article.Score,
+	}
+	if utf8.RuneCountInString(article.Description) <= liteDescriptionMax {
+		lite.Description = article.Description
+	}
+	return lite
+}
+
+var liteTemplate = template.Must(template.New("lite").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Top News (lite)</title>
+</head>
+<body>
+<h1>Top News</h1>
+{{range .Sections}}<h2>{{.Name}}</h2>
+<ul>
+{{range .Articles}}<li><a href="{{if $.Compliance}}{{.URL}}{{else}}/api/v1/article/{{.Key}}/view{{end}}">{{.Title}}</a></li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// liteSection is one source's headlines on the lite page
+type liteSection struct {
+	Name     string
+	Articles []models.NewsArticle
+}
+
+// LitePage serves a text-only HTML page of headlines from every active
+// source, with no images, scripts or styles, for very slow connections.
+// Articles link to their reader view, or to the source in compliance mode
+func (ns *NewsService) LitePage(c *gin.Context) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sections := []liteSection{}
+
+	for name, source := range ns.sources {
+		if !source.Active {
+			continue
+		}
+		wg.Add(1)
+		go func(sourceName string, source models.Source) {
+			defer wg.Done()
+			// Only headlines are shown, so skip enrichment
+			news, err := ns.fetchNewsFromSource(sourceName, source.URL, scrapeOptions{enrich: []string{}, lane: requestLane(c), fresh: c.Query("fresh") == "true"})
+			if err != nil {
+				log.Printf("Error fetching from %s: %v", sourceName, err)
+				return
+			}
+			mu.Lock()
+			sections = append(sections, liteSection{Name: source.DisplayName, Articles: ns.overrides.apply(news, sourceName)})
+			mu.Unlock()
+		}(name, source)
+	}
+	wg.Wait()
+
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Name < sections[j].Name
+	})
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := liteTemplate.Execute(c.Writer, map[string]interface{}{
+		"Sections":   sections,
+		"Compliance": complianceMode(),
+	}); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+)
+
+// maxLiveUpdates caps how many updates are read from one live blog
+const maxLiveUpdates = 200
+
+// liveUpdateSelectors locate the posts of live blogs that carry no
+// JSON-LD updates: CNN's live stories and The Daily Star's live blogs
+var liveUpdateSelectors = []string{
+	".live-story-post",
+	"article.live-blog-post",
+	".live-blog__item",
+	".liveblog-entry",
+	".live-update",
+}
+
+// GetArticleUpdates splits a stored live-blog article into its timestamped
+// updates, newest first. since=<RFC 3339 time> returns only newer updates
+func (ns *NewsService) GetArticleUpdates(c *gin.Context) {
+	article, found := ns.articleByKey(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "article_not_found",
+			Message: "No stored article has this key",
+		})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_since",
+				Message: "since must be an RFC 3339 time, e.g. 2026-01-02T15:04:05Z",
+			})
+			return
+		}
+		since = parsed
+	}
+
+	release := ns.scrapes.acquire(requestLane(c))
+	page, article, err := ns.fetchStoredArticlePage(article)
+	release()
+	if errors.Is(err, errPageGone) {
+		articleGone(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "fetch_failed",
+			Message: fmt.Sprintf("Failed to fetch the article: %v", err),
+		})
+		return
+	}
+	ns.chargeEnrichment(c, 1)
+	updates := liveUpdates(page)
+	if updates == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "not_live_blog",
+			Message: "The article is not a live blog",
+		})
+		return
+	}
+
+	if !since.IsZero() {
+		newer := []models.LiveUpdate{}
+		for _, update := range updates {
+			if update.PublishedAt != nil && update.PublishedAt.After(since) {
+				newer = append(newer, update)
+			}
+		}
+		updates = newer
+	}
+	if limit > 0 && len(updates) > limit {
+		updates = updates[:limit]
+	}
+	c.JSON(http.StatusOK, models.LiveUpdatesResponse{
+		Success: true,
+		Article: article,
+		Data:    updates,
+		Count:   len(updates),
+	})
+}
+
+// liveUpdates reads the updates of a live-blog page, from its JSON-LD
+// LiveBlogPosting when it has one and its markup otherwise. It returns nil
+// when the page is not a live blog
+func liveUpdates(page *articlePage) []models.LiveUpdate {
+	updates := jsonLDLiveUpdates(page)
+	if updates == nil {
+		updates = markupLiveUpdates(page)
+	}
+	if updates == nil {
+		return nil
+	}
+	if len(updates) > maxLiveUpdates {
+		updates = updates[:maxLiveUpdates]
+	}
+	// Without a time on every update, the page's own order, newest first,
+	// is kept
+	for _, update := range updates {
+		if update.PublishedAt == nil {
+			return updates
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].PublishedAt.After(*updates[j].PublishedAt)
+	})
+	return updates
+}
+
+// jsonLDLiveUpdates reads the liveBlogUpdate list of the page's
+// LiveBlogPosting, nil when there is none
+func jsonLDLiveUpdates(page *articlePage) []models.LiveUpdate {
+	posting := jsonLDObject(page.Doc, "LiveBlogPosting")
+	if posting == nil {
+		return nil
+	}
+
+	items, ok := posting["liveBlogUpdate"].([]interface{})
+	if !ok {
+		if item, isObject := posting["liveBlogUpdate"].(map[string]interface{}); isObject {
+			items = []interface{}{item}
+		}
+	}
+	base, _ := url.Parse(page.URL)
+	updates := []models.LiveUpdate{}
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		update := models.LiveUpdate{
+			Title:  jsonLDString(object, "headline"),
+			Body:   strings.Join(strings.Fields(jsonLDString(object, "articleBody")), " "),
+			Author: jsonLDString(object, "author", "name"),
+		}
+		if link := jsonLDString(object, "url"); link != "" {
+			update.URL = resolveURL(base, link)
+			update.ID = fragmentOf(update.URL)
+		}
+		if update.ID == "" {
+			update.ID = jsonLDString(object, "@id")
+		}
+		update.PublishedAt = liveUpdateTime(jsonLDString(object, "datePublished"), page.Source)
+		if update.Title != "" || update.Body != "" {
+			updates = append(updates, update)
+		}
+	}
+	return updates
+}
+
+// markupLiveUpdates reads the posts of a live blog from its markup, nil
+// when no live-blog selector matches
+func markupLiveUpdates(page *articlePage) []models.LiveUpdate {
+	base, _ := url.Parse(page.URL)
+	for _, selector := range liveUpdateSelectors {
+		posts := page.Doc.Find(selector)
+		if posts.Length() == 0 {
+			continue
+		}
+		updates := []models.LiveUpdate{}
+		posts.Each(func(i int, s *goquery.Selection) {
+			update := models.LiveUpdate{
+				ID:    s.AttrOr("id", s.AttrOr("data-post-id", "")),
+				Title: strings.Join(strings.Fields(s.Find("h2, h3, [class*='headline']").First().Text()), " "),
+			}
+			raw := s.Find("time[datetime]").First().AttrOr("datetime", s.AttrOr("data-timestamp", ""))
+			if raw == "" {
+				raw = strings.TrimSpace(s.Find("time, [class*='timestamp'], [class*='date']").First().Text())
+			}
+			update.PublishedAt = liveUpdateTime(raw, page.Source)
+			paragraphs := []string{}
+			s.Find("p").Each(func(i int, p *goquery.Selection) {
+				if text := strings.Join(strings.Fields(p.Text()), " "); text != "" {
+					paragraphs = append(paragraphs, text)
+				}
+			})
+			update.Body = strings.Join(paragraphs, "\n\n")
+			update.Author = strings.Join(strings.Fields(s.Find("[class*='byline'], [rel='author'], [class*='author']").First().Text()), " ")
+			if link := s.Find("a[href^='#'], a[href*='#']").First().AttrOr("href", ""); link != "" {
+				update.URL = resolveURL(base, link)
+			} else if update.ID != "" {
+				update.URL = resolveURL(base, "#"+update.ID)
+			}
+			if update.Title != "" || update.Body != "" {
+				updates = append(updates, update)
+			}
+		})
+		return updates
+	}
+	return nil
+}
+
+// liveUpdateTime parses an update's time, nil when it has none. Unix
+// timestamps, as some live blogs put in data attributes, are accepted too
+func liveUpdateTime(raw string, source models.Source) *time.Time {
+	if raw == "" {
+		return nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if seconds > 1e12 {
+			seconds /= 1000
+		}
+		t := time.Unix(seconds, 0).UTC()
+		return &t
+	}
+	if t, ok := parsePublishedAt(raw, source); ok {
+		return &t
+	}
+	return nil
+}
+
+// fragmentOf returns the fragment of a URL, "" when it has none
+func fragmentOf(link string) string {
+	if i := strings.Index(link, "#"); i >= 0 {
+		return link[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultLocalArticles is how many articles a local feed has when the
+	// request sets no limit
+	defaultLocalArticles = 20
+	// localWindow is how far back local feeds look in the store
+	localWindow = 7 * 24 * time.Hour
+)
+
+// GetLocalNews serves a hyperlocal feed for a Bangladeshi district: the
+// district pages of sources that have them (The Daily Star's), fetched
+// live, merged with stored articles from the last week that mention the
+// district. Any spelling of the district works, e.g. chittagong or
+// coxs-bazar
+func (ns *NewsService) GetLocalNews(c *gin.Context) {
+	district, ok := lookupDistrict(c.Param("district"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "district_not_found",
+			Message: "Unknown district, use one of the 64 districts of Bangladesh",
+		})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultLocalArticles
+	}
+	enrich, ok := parseEnrich(c)
+	if !ok {
+		return
+	}
+
+	tenant := currentTenant(c)
+	articles := []models.NewsArticle{}
+	for name, source := range ns.sources {
+		if source.DistrictURL == "" || !source.Active || !tenant.allows(name) {
+			continue
+		}
+		url := strings.ReplaceAll(source.DistrictURL, "{district}", districtSlug(district.Name))
+		news, err := ns.fetchNewsFromSource(name, url, scrapeOptions{limit: limit, enrich: enrich, lane: requestLane(c), fresh: c.Query("fresh") == "true"})
+		if err != nil {
+			// The stored articles still make a feed
+			log.Printf("Error fetching the %s page of %s: %v", district.Name, name, err)
+		}
+		articles = append(articles, news...)
+	}
+	for _, article := range ns.store.List(store.Filter{Since: time.Now().Add(-localWindow)}) {
+		if article = withLocations(article); tenant.allows(article.Source) && mentions(article, district.Name) {
+			articles = append(articles, article)
+		}
+	}
+	ns.writeNews(c, models.NewsResponse{Success: true, Data: newestUnique(articles, limit)})
+}
+
+// GetDivisionNews serves stored articles from the last week that mention
+// any district of a division
+func (ns *NewsService) GetDivisionNews(c *gin.Context) {
+	division, ok := lookupDistrict(c.Param("division"))
+	if !ok || division.Division != division.Name {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "division_not_found",
+			Message: "Unknown division, use one of the 8 divisions of Bangladesh",
+		})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultLocalArticles
+	}
+
+	tenant := currentTenant(c)
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{Since: time.Now().Add(-localWindow)}) {
+		if !tenant.allows(article.Source) {
+			continue
+		}
+		article = withLocations(article)
+		for _, location := range article.Locations {
+			if location.Type == "district" && location.Division == division.Name {
+				articles = append(articles, article)
+				break
+			}
+		}
+	}
+	ns.writeNews(c, models.NewsResponse{Success: true, Data: newestUnique(articles, limit)})
+}
+
+// withLocations geotags articles stored before geotagging existed
+func withLocations(article models.NewsArticle) models.NewsArticle {
+	if article.Locations == nil {
+		article.Locations = geotag(article)
+	}
+	return article
+}
+
+// newestUnique drops repeated URLs and keeps the newest limit articles
+func newestUnique(articles []models.NewsArticle, limit int) []models.NewsArticle {
+	seen := map[string]bool{}
+	unique := []models.NewsArticle{}
+	for _, article := range articles {
+		if !seen[article.URL] {
+			seen[article.URL] = true
+			unique = append(unique, article)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return unique[i].PublishedAt.After(unique[j].PublishedAt)
+	})
+	if len(unique) > limit {
+		unique = unique[:limit]
+	}
+	return unique
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTimeout bounds a whole lock operation, connecting included
+const redisTimeout = 5 * time.Second
+
+// releaseScript deletes a lock only if we still hold it, so a lock that
+// expired and was taken by another instance is left alone
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// redisLock coordinates instances through Redis, so only one of them runs
+// each scheduled scrape. Each operation uses a fresh connection; locks are
+// taken minutes apart
+type redisLock struct {
+	addr     string
+	host     string
+	tls      bool
+	username string
+	password string
+	db       int
+	prefix   string
+	// instance names this process in lock values, for debugging
+	instance string
+}
+
+// newRedisLock connects to REDIS_URL, e.g. redis://:password@host:6379/0
+// or rediss:// for TLS. Lock keys start with LOCK_PREFIX (default
+// "top-news:"). It returns nil when REDIS_URL is unset or invalid
+func newRedisLock() *redisLock {
+	raw := os.Getenv("REDIS_URL")
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss") || parsed.Host == "" {
+		log.Printf("Invalid REDIS_URL, scrapes are not coordinated between instances")
+		return nil
+	}
+	l := &redisLock{addr: parsed.Host, host: parsed.Hostname(), tls: parsed.Scheme == "rediss", prefix: "top-news:"}
+	if parsed.Port() == "" {
+		l.addr = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	if parsed.User != nil {
+		l.username = parsed.User.Username()
+		l.password, _ = parsed.User.Password()
+	}
+	if db := strings.Trim(parsed.Path, "/"); db != "" {
+		if l.db, err = strconv.Atoi(db); err != nil {
+			log.Printf("Invalid REDIS_URL database %q, scrapes are not coordinated between instances", db)
+			return nil
+		}
+	}
+	if prefix, ok := os.LookupEnv("LOCK_PREFIX"); ok {
+		l.prefix = prefix
+	}
+	hostname, _ := os.Hostname()
+	l.instance = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	return l
+}
+
+// tryLock takes the named lock for ttl. It returns the token needed to
+// release it, or ok false when another instance holds the lock
+func (l *redisLock) tryLock(name string, ttl time.Duration) (token string, ok bool, err error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	token = l.instance + ":" + hex.EncodeToString(id)
+	reply, err := l.do("SET", l.prefix+name, token, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return "", false, err
+	}
+	// SET ... NX answers OK when it set the key and nil when it existed
+	return token, reply == "OK", nil
+}
+
+// unlock releases a lock taken with tryLock, if it is still ours
+func (l *redisLock) unlock(name, token string) error {
+	_, err := l.do("EVAL", releaseScript, "1", l.prefix+name, token)
+	return err
+}
+
+// ping checks that Redis answers, for the startup self-check
+func (l *redisLock) ping() error {
+	_, err := l.do("PING")
+	return err
+}
+
+// do runs one command on a new connection, after authenticating and
+// selecting the database
+func (l *redisLock) do(args ...string) (interface{}, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if l.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", l.addr, &tls.Config{ServerName: l.host})
+	} else {
+		conn, err = dialer.Dial("tcp", l.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	commands := [][]string{}
+	if l.password != "" {
+		if l.username != "" {
+			commands = append(commands, []string{"AUTH", l.username, l.password})
+		} else {
+			commands = append(commands, []string{"AUTH", l.password})
+		}
+	}
+	if l.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(l.db)})
+	}
+	commands = append(commands, args)
+
+	// Pipeline everything, then read the replies in order
+	var request strings.Builder
+	for _, command := range commands {
+		fmt.Fprintf(&request, "*%d\r\n", len(command))
+		for _, arg := range command {
+			fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := conn.Write([]byte(request.String())); err != nil {
+		return nil, fmt.Errorf("failed to write to redis: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	var reply interface{}
+	for _, command := range commands {
+		if reply, err = readRESP(reader); err != nil {
+			return nil, fmt.Errorf("redis %s: %v", command[0], err)
+		}
+	}
+	return reply, nil
+}
+
+// readRESP reads one reply of the Redis protocol. Simple and bulk strings
+// become strings, integers int64, arrays []interface{} and nil replies nil;
+// error replies are returned as errors
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readRESP(reader); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
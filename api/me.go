@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestSubject returns the signed-in user's subject. It writes a 401 and
+// returns "" when the request has no user token
+func requestSubject(c *gin.Context) string {
+	subject := currentUser(c)
+	if subject == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Success: false,
+			Error:   "auth_required",
+			Message: "A signed-in user's bearer token is required",
+		})
+	}
+	return subject
+}
+
+// ExportMe returns everything kept about the signed-in user: their
+// bookmarks and saved searches with feed URLs
+func (ns *NewsService) ExportMe(c *gin.Context) {
+	subject := requestSubject(c)
+	if subject == "" {
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="top-news-export.json"`)
+	c.JSON(http.StatusOK, models.UserExport{
+		Success:       true,
+		Subject:       subject,
+		ExportedAt:    time.Now().UTC(),
+		Bookmarks:     ns.users.bookmarksOf(subject).list(),
+		SavedSearches: withFeedURLs(c, ns.searches.list(userOwner(subject))...),
+	})
+}
+
+// DeleteMe erases the signed-in user's bookmarks and saved searches, whose
+// feed URLs stop working
+func (ns *NewsService) DeleteMe(c *gin.Context) {
+	subject := requestSubject(c)
+	if subject == "" {
+		return
+	}
+	bookmarks, err := ns.users.remove(subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "delete_failed",
+			Message: fmt.Sprintf("Failed to delete your data: %v", err),
+		})
+		return
+	}
+	searches, err := ns.searches.removeOwner(userOwner(subject))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "delete_failed",
+			Message: fmt.Sprintf("Failed to delete your data: %v", err),
+		})
+		return
+	}
+	log.Printf("Deleted a user's data: %d bookmarks, %d saved searches", bookmarks, searches)
+	c.JSON(http.StatusOK, models.UserDeletionResponse{Success: true, Bookmarks: bookmarks, SavedSearches: searches})
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metering event kinds
+const (
+	meterRequests   = "requests"
+	meterEnrichment = "enrichment"
+)
+
+// maxMeteringBacklog caps how many events a failing sink holds for retry;
+// the oldest are dropped past it
+const maxMeteringBacklog = 10000
+
+// stripeMeterEventsURL is Stripe's meter event endpoint, a variable so it
+// can point elsewhere
+var stripeMeterEventsURL = "https://api.stripe.com/v1/billing/meter_events"
+
+// meteringSink receives metering events
+type meteringSink interface {
+	export(events []models.MeteringEvent) error
+}
+
+// meteringCount identifies what a pending count is for
+type meteringCount struct {
+	key  string
+	tier string
+	kind string
+}
+
+// meteringExporter adds up each API key's requests and enrichment units
+// and exports them to its sinks every interval. Events a sink fails to take
+// are retried with the next export
+type meteringExporter struct {
+	mu       sync.Mutex
+	sinks    []meteringSink
+	interval time.Duration
+	counts   map[meteringCount]int64
+	since    time.Time
+	backlog  [][]models.MeteringEvent // per sink
+}
+
+// newMeteringExporter loads sinks from the JSON file at METERING_PATH, or
+// inline JSON in METERING. Invalid configuration is logged and metering
+// events are not exported
+func newMeteringExporter() *meteringExporter {
+	data := []byte(os.Getenv("METERING"))
+	if path := os.Getenv("METERING_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading metering config, metering events are not exported: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	data, err := openSecrets(data)
+	if err != nil {
+		log.Printf("Error decrypting metering config, metering events are not exported: %v", err)
+		return nil
+	}
+
+	var config models.MeteringConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding metering config, metering events are not exported: %v", err)
+		return nil
+	}
+	m, err := compileMeteringConfig(config)
+	if err != nil {
+		log.Printf("Invalid metering config, metering events are not exported: %v", err)
+		return nil
+	}
+	go m.run()
+	return m
+}
+
+// compileMeteringConfig builds the sinks and checks each has what its type
+// needs
+func compileMeteringConfig(config models.MeteringConfig) (*meteringExporter, error) {
+	m := &meteringExporter{interval: time.Minute, counts: map[meteringCount]int64{}, since: time.Now().UTC()}
+	if config.Interval != "" {
+		interval, err := time.ParseDuration(config.Interval)
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("invalid interval %q", config.Interval)
+		}
+		m.interval = interval
+	}
+	if len(config.Sinks) == 0 {
+		return nil, fmt.Errorf("no sinks configured")
+	}
+	client := &http.Client{Timeout: 20 * time.Second}
+	for i, sc := range config.Sinks {
+		switch sc.Type {
+		case "file":
+			if sc.Path == "" {
+				return nil, fmt.Errorf("sink %d: file needs a path", i+1)
+			}
+			m.sinks = append(m.sinks, &fileMeteringSink{path: sc.Path})
+		case "kafka":
+			if parsed, err := url.Parse(sc.URL); err != nil || parsed.Host == "" || sc.Topic == "" {
+				return nil, fmt.Errorf("sink %d: kafka needs a REST proxy url and a topic", i+1)
+			}
+			m.sinks = append(m.sinks, &kafkaMeteringSink{url: strings.TrimRight(sc.URL, "/"), topic: sc.Topic, client: client})
+		case "stripe":
+			if sc.APIKey == "" || len(sc.EventNames) == 0 || len(sc.Customers) == 0 {
+				return nil, fmt.Errorf("sink %d: stripe needs api_key, event_names and customers", i+1)
+			}
+			m.sinks = append(m.sinks, &stripeMeteringSink{apiKey: sc.APIKey, eventNames: sc.EventNames, customers: sc.Customers, client: client})
+		default:
+			return nil, fmt.Errorf("sink %d: unknown type %q", i+1, sc.Type)
+		}
+	}
+	m.backlog = make([][]models.MeteringEvent, len(m.sinks))
+	return m, nil
+}
+
+// count adds units of a kind to a key's pending usage
+func (m *meteringExporter) count(key, tier, kind string, units int64) {
+	if m == nil || key == "" || units == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[meteringCount{key: key, tier: tier, kind: kind}] += units
+}
+
+// run exports the pending usage every interval
+func (m *meteringExporter) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.flush()
+	}
+}
+
+// flush turns the pending counts into events and hands them, after any
+// backlog, to every sink
+func (m *meteringExporter) flush() {
+	m.mu.Lock()
+	now := time.Now().UTC()
+	events := make([]models.MeteringEvent, 0, len(m.counts))
+	for count, quantity := range m.counts {
+		id := make([]byte, 8)
+		rand.Read(id)
+		events = append(events, models.MeteringEvent{
+			ID:          hex.EncodeToString(id),
+			Key:         count.key,
+			Tier:        count.tier,
+			Kind:        count.kind,
+			Quantity:    quantity,
+			PeriodStart: m.since,
+			PeriodEnd:   now,
+		})
+	}
+	m.counts = map[meteringCount]int64{}
+	m.since = now
+	m.mu.Unlock()
+
+	// Only the run goroutine touches the backlog
+	for i, sink := range m.sinks {
+		pending := append(m.backlog[i], events...)
+		if len(pending) == 0 {
+			continue
+		}
+		if err := sink.export(pending); err != nil {
+			log.Printf("Error exporting %d metering events, retrying with the next export: %v", len(pending), err)
+			if len(pending) > maxMeteringBacklog {
+				pending = pending[len(pending)-maxMeteringBacklog:]
+			}
+			m.backlog[i] = pending
+			continue
+		}
+		m.backlog[i] = nil
+	}
+}
+
+// chargeEnrichment records enrichment units used on behalf of the request's
+// API key, such as article pages fetched and processed on demand
+func (ns *NewsService) chargeEnrichment(c *gin.Context, units int64) {
+	ns.metering.count(requestKeyName(c), ns.requestTier(c), meterEnrichment, units)
+}
+
+// fileMeteringSink appends events to a file as JSON lines
+type fileMeteringSink struct {
+	path string
+}
+
+func (s *fileMeteringSink) export(events []models.MeteringEvent) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create metering directory: %v", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open metering file: %v", err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to write metering event: %v", err)
+		}
+	}
+	return nil
+}
+
+// kafkaMeteringSink produces events to a Kafka topic through a REST proxy,
+// keyed by API key so one key's events stay in order
+type kafkaMeteringSink struct {
+	url    string
+	topic  string
+	client *http.Client
+}
+
+func (s *kafkaMeteringSink) export(events []models.MeteringEvent) error {
+	type record struct {
+		Key   string               `json:"key"`
+		Value models.MeteringEvent `json:"value"`
+	}
+	records := make([]record, len(events))
+	for i, event := range events {
+		records[i] = record{Key: event.Key, Value: event}
+	}
+	return postJSON(s.client, s.url+"/topics/"+url.PathEscape(s.topic), map[string]interface{}{"records": records}, map[string]string{
+		"Content-Type": "application/vnd.kafka.json.v2+json",
+	})
+}
+
+// stripeMeteringSink reports events to Stripe metered billing as meter
+// events. Events whose key has no customer, or whose kind has no event
+// name, are skipped
+type stripeMeteringSink struct {
+	apiKey     string
+	eventNames map[string]string
+	customers  map[string]string
+	client     *http.Client
+}
+
+func (s *stripeMeteringSink) export(events []models.MeteringEvent) error {
+	for i, event := range events {
+		customer, name := s.customers[event.Key], s.eventNames[event.Kind]
+		if customer == "" || name == "" {
+			continue
+		}
+		form := url.Values{
+			"event_name":                  {name},
+			"identifier":                  {event.ID},
+			"timestamp":                   {strconv.FormatInt(event.PeriodEnd.Unix(), 10)},
+			"payload[stripe_customer_id]": {customer},
+			"payload[value]":              {strconv.FormatInt(event.Quantity, 10)},
+		}
+		req, err := http.NewRequest("POST", stripeMeterEventsURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+		// Stripe ignores a repeated identifier, so a retried batch does not
+		// bill twice for the events that went through
+		req.Header.Set("Idempotency-Key", event.ID)
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("event %d of %d: request failed: %v", i+1, len(events), err)
+		}
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("event %d of %d: unexpected status code %d: %s", i+1, len(events), resp.StatusCode, strings.TrimSpace(string(detail)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"top-news/store"
+)
+
+// storeMigrations are the changes made to stored articles over time, in
+// order. Add new ones at the end; never change one that has shipped
+var storeMigrations = []store.Migration{
+	{Version: 1, Name: "article_keys", Apply: migrateArticleKeys},
+}
+
+// migrateArticleKeys gives articles stored before keys existed the key of
+// their URL
+func migrateArticleKeys(records []map[string]interface{}) error {
+	for _, record := range records {
+		link, _ := record["url"].(string)
+		if key, _ := record["key"].(string); key == "" && link != "" {
+			record["key"] = articleKey(link)
+		}
+	}
+	return nil
+}
+
+// openStore opens the article store at path, first running any pending
+// migrations unless STORE_MIGRATE is off. Without migrating, a store
+// behind the latest schema is still opened, with a warning
+func openStore(path string) (*store.Store, error) {
+	if path == "" {
+		return store.Open(path)
+	}
+	dryRun := os.Getenv("STORE_MIGRATE") == "off"
+	from, pending, err := store.Migrate(path, storeMigrations, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 && dryRun {
+		log.Printf("Store schema is at version %d with %d migrations pending, run newsctl migrate", from, len(pending))
+	} else if len(pending) > 0 {
+		log.Printf("Migrated the store from schema version %d to %d", from, pending[len(pending)-1].Version)
+	}
+	return store.Open(path)
+}
+
+// MigrateStore runs the pending migrations of the store at path, or with
+// dryRun only lists them, for newsctl migrate. It returns the version the
+// store was at and the names of the migrations, in order
+func MigrateStore(path string, dryRun bool) (int, []string, error) {
+	from, pending, err := store.Migrate(path, storeMigrations, dryRun)
+	if err != nil {
+		return from, nil, err
+	}
+	names := []string{}
+	for _, migration := range pending {
+		names = append(names, fmt.Sprintf("%d %s", migration.Version, migration.Name))
+	}
+	return from, names, nil
+}
+
+// StoreSchemaVersion is the schema version this build migrates stores to
+func StoreSchemaVersion() int {
+	return len(storeMigrations)
+}
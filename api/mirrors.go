@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// textMirror describes a text-only edition of a news site. Their plain
+// markup parses in milliseconds and rarely changes
+type textMirror struct {
+	// links selects the headline links on the mirror's front page
+	links string
+	// articlePath matches the paths of article links, nil for any path
+	articlePath *regexp.Regexp
+	// canonical is the site that mirror paths are rewritten onto, so
+	// articles keep the URLs homepage scrapes give them. Empty keeps the
+	// mirror's URLs
+	canonical string
+}
+
+// textMirrors are the text-only mirrors that lite fallbacks can point at,
+// by host
+var textMirrors = map[string]textMirror{
+	"lite.cnn.com": {
+		links:       "li.card--lite a[href], main li a[href]",
+		articlePath: regexp.MustCompile(`^/\d{4}/\d{2}/\d{2}/`),
+		canonical:   "https://edition.cnn.com",
+	},
+	"text.npr.org": {
+		links:       "main li a[href], .topic-title[href]",
+		articlePath: regexp.MustCompile(`^/[a-z]+-s1-\d+`),
+	},
+}
+
+// fetchMirrorArticles reads a source's headlines from the front page of a
+// text-only mirror and enriches them like scraped ones
+func (ns *NewsService) fetchMirrorArticles(sourceName, mirrorURL string, opts scrapeOptions) ([]models.NewsArticle, error) {
+	base, err := url.Parse(mirrorURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror url %q: %v", mirrorURL, err)
+	}
+	mirror, ok := textMirrors[base.Hostname()]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a known text-only mirror", base.Hostname())
+	}
+	page, _, err := ns.fetchArticlePage(mirrorURL, ns.sources[sourceName], pageValidators{})
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.limit
+	if limit == 0 {
+		limit = defaultSourceLimit
+	}
+
+	articles := make([]models.NewsArticle, 0, limit)
+	seen := map[string]bool{}
+	page.Doc.Find(mirror.links).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		title := strings.Join(strings.Fields(s.Text()), " ")
+		link, err := base.Parse(s.AttrOr("href", ""))
+		if title == "" || err != nil || link.Host != base.Host {
+			return true
+		}
+		if mirror.articlePath != nil && !mirror.articlePath.MatchString(link.Path) {
+			return true
+		}
+		articleURL := link.String()
+		if mirror.canonical != "" {
+			articleURL = mirror.canonical + link.EscapedPath()
+		}
+		if seen[articleURL] {
+			return true
+		}
+		seen[articleURL] = true
+		articles = append(articles, models.NewsArticle{
+			ID:          fmt.Sprintf("%s_lite_%d", sourceName, len(articles)),
+			Title:       title,
+			URL:         articleURL,
+			Source:      sourceName,
+			PublishedAt: time.Now(),
+		})
+		return len(articles) < limit
+	})
+
+	ns.updateArticleDetails(&articles, opts)
+	return articles, nil
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// newsCache keeps the result of each scrape for a while, so repeated news
+// requests are answered without scraping again. Entries are deep-copied in
+// and out, as callers filter and rewrite the articles they get
+type newsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[newsCacheKey]newsCacheEntry
+	// scrapes are the scrapes in flight, which requests for the same key
+	// wait for instead of scraping too
+	scrapes map[newsCacheKey]*newsScrape
+}
+
+// newsCacheKey is what makes two scrapes of a source differ
+type newsCacheKey struct {
+	source string
+	url    string
+	limit  int
+	enrich string
+}
+
+type newsCacheEntry struct {
+	articles []models.NewsArticle
+	expires  time.Time
+}
+
+// newsScrape is a scrape in flight; done is closed once it has finished
+type newsScrape struct {
+	done     chan struct{}
+	articles []models.NewsArticle
+	err      error
+}
+
+// newNewsCache reads NEWS_CACHE_TTL, how long a scrape is reused (default
+// 10m, 0 turns the cache off)
+func newNewsCache() *newsCache {
+	c := &newsCache{ttl: 10 * time.Minute, entries: map[newsCacheKey]newsCacheEntry{}, scrapes: map[newsCacheKey]*newsScrape{}}
+	if value := os.Getenv("NEWS_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			log.Printf("Invalid NEWS_CACHE_TTL %q, using %s", value, c.ttl)
+		} else {
+			c.ttl = ttl
+		}
+	}
+	return c
+}
+
+// newsCacheKeyFor identifies a scrape; nil enrich, the source default, differs
+// from an empty list, which skips enrichment
+func newsCacheKeyFor(source, url string, opts scrapeOptions) newsCacheKey {
+	enrich := "default"
+	if opts.enrich != nil {
+		enrich = strings.Join(opts.enrich, ",")
+	}
+	return newsCacheKey{source: source, url: url, limit: opts.limit, enrich: enrich}
+}
+
+// get returns a copy of the articles of an unexpired scrape
+func (c *newsCache) get(key newsCacheKey) ([]models.NewsArticle, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return cloneArticles(entry.articles), true
+}
+
+// put keeps a copy of a scrape's articles, dropping expired entries
+func (c *newsCache) put(key newsCacheKey, articles []models.NewsArticle) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = newsCacheEntry{articles: cloneArticles(articles), expires: now.Add(c.ttl)}
+}
+
+// do runs scrape, unless one for the same key is already running, in
+// which case it waits for that one and returns a copy of its result
+func (c *newsCache) do(key newsCacheKey, scrape func() ([]models.NewsArticle, error)) ([]models.NewsArticle, error) {
+	c.mu.Lock()
+	if running, ok := c.scrapes[key]; ok {
+		c.mu.Unlock()
+		<-running.done
+		return cloneArticles(running.articles), running.err
+	}
+	call := &newsScrape{done: make(chan struct{})}
+	c.scrapes[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.scrapes, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.articles, call.err = scrape()
+	// The waiters copy the result once done is closed, while this caller
+	// may already be changing its articles
+	return cloneArticles(call.articles), call.err
+}
+
+// cloneArticles deep-copies articles, so no slice or pointer in them is
+// shared with the originals
+func cloneArticles(articles []models.NewsArticle) []models.NewsArticle {
+	if articles == nil {
+		return nil
+	}
+	clones := make([]models.NewsArticle, len(articles))
+	for i, article := range articles {
+		article.Images = append([]models.ArticleImage(nil), article.Images...)
+		article.Tags = append([]string(nil), article.Tags...)
+		article.Robots = append([]string(nil), article.Robots...)
+		article.Locations = append([]models.Location(nil), article.Locations...)
+		article.Figures = append([]models.Figure(nil), article.Figures...)
+		if article.Score != nil {
+			score := *article.Score
+			score.Teams = append([]models.TeamScore(nil), score.Teams...)
+			article.Score = &score
+		}
+		clones[i] = article
+	}
+	return clones
+}
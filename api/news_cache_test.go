@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"top-news/models"
+)
+
+// countingTransport counts the requests it passes on
+type countingTransport struct {
+	next  http.RoundTripper
+	count atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count.Add(1)
+	return t.next.RoundTrip(req)
+}
+
+// replayUpstream answers every request to the news sites with a source's
+// bench homepage and counts the requests, for tests that scrape
+func replayUpstream(t *testing.T, source string) *countingTransport {
+	t.Helper()
+	home, err := os.ReadFile("fixtures/bench/" + source + "_home.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POLITENESS_RATE", "off")
+	t.Setenv("ACCESS_LOG", "off")
+	upstream := &countingTransport{next: replayTransport(home)}
+	original := http.DefaultTransport
+	http.DefaultTransport = upstream
+	t.Cleanup(func() { http.DefaultTransport = original })
+	return upstream
+}
+
+func TestNewsCacheCopiesArticles(t *testing.T) {
+	cache := newNewsCache()
+	key := newsCacheKey{source: "cnn"}
+	articles := []models.NewsArticle{{
+		Tags:  []string{"politics"},
+		Score: &models.Score{Teams: []models.TeamScore{{Team: "Bangladesh"}}},
+	}}
+	cache.put(key, articles)
+	articles[0].Tags[0] = "changed"
+	articles[0].Score.Teams[0].Team = "changed"
+
+	got, ok := cache.get(key)
+	if !ok {
+		t.Fatal("cached articles not found")
+	}
+	got[0].Tags[0] = "changed again"
+	got[0].Score.Teams[0].Team = "changed again"
+
+	again, _ := cache.get(key)
+	if tag := again[0].Tags[0]; tag != "politics" {
+		t.Errorf("cached tag = %q, want politics", tag)
+	}
+	if team := again[0].Score.Teams[0].Team; team != "Bangladesh" {
+		t.Errorf("cached team = %q, want Bangladesh", team)
+	}
+}
+
+func TestNewsCacheSharesScrape(t *testing.T) {
+	cache := newNewsCache()
+	key := newsCacheKey{source: "cnn"}
+	var scrapes atomic.Int32
+	release := make(chan struct{})
+	scrape := func() ([]models.NewsArticle, error) {
+		scrapes.Add(1)
+		<-release
+		return []models.NewsArticle{{Tags: []string{"politics"}}}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]models.NewsArticle, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = cache.do(key, scrape)
+		}()
+	}
+	// Let every request reach the cache before the scrape finishes
+	for scrapes.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := scrapes.Load(); n != 1 {
+		t.Errorf("scraped %d times, want 1", n)
+	}
+	results[0][0].Tags[0] = "changed"
+	for i, articles := range results[1:] {
+		if len(articles) != 1 || articles[0].Tags[0] != "politics" {
+			t.Errorf("request %d got %+v, want its own copy of the scrape", i+1, articles)
+		}
+	}
+}
+
+func TestNewsCacheFreshScrapesAgain(t *testing.T) {
+	upstream := replayUpstream(t, "cnn")
+	ns := NewNewsService()
+	url := ns.sources["cnn"].URL
+	fetch := func(fresh bool) int32 {
+		t.Helper()
+		before := upstream.count.Load()
+		if _, err := ns.fetchNewsFromSource("cnn", url, scrapeOptions{enrich: []string{}, lane: laneInteractive, fresh: fresh}); err != nil {
+			t.Fatal(err)
+		}
+		return upstream.count.Load() - before
+	}
+
+	if n := fetch(false); n == 0 {
+		t.Fatal("first request did not scrape")
+	}
+	if n := fetch(false); n != 0 {
+		t.Errorf("cached request made %d upstream requests, want 0", n)
+	}
+	if n := fetch(true); n == 0 {
+		t.Error("fresh=true was answered from the cache")
+	}
+	if n := fetch(false); n != 0 {
+		t.Errorf("request after fresh=true made %d upstream requests, want 0", n)
+	}
+}
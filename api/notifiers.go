@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/pkg/webhook"
+)
+
+// notifierArticles caps how many headlines one message lists
+const notifierArticles = 10
+
+// notifier delivers events to people or systems outside the API
+type notifier interface {
+	notify(event models.Event) error
+}
+
+// notificationRoute is a compiled models.NotificationRoute
+type notificationRoute struct {
+	notifier string
+	events   []string
+	sources  []string
+	keywords []string
+}
+
+// match returns the part of event the route lets through, false when
+// nothing is left
+func (r notificationRoute) match(event models.Event) (models.Event, bool) {
+	if len(r.events) > 0 && !containsString(r.events, event.Type) {
+		return event, false
+	}
+	if len(r.sources) > 0 && !containsString(r.sources, event.Source) {
+		return event, false
+	}
+	if len(r.keywords) == 0 || len(event.Articles) == 0 {
+		return event, true
+	}
+	matched := []models.NewsArticle{}
+	for _, article := range event.Articles {
+		text := articleText(article)
+		for _, keyword := range r.keywords {
+			if strings.Contains(text, keyword) {
+				matched = append(matched, article)
+				break
+			}
+		}
+	}
+	event.Articles = matched
+	return event, len(matched) > 0
+}
+
+// notificationDispatcher routes bus events to notifiers. Failed deliveries
+// go to the delivery queue to be retried
+type notificationDispatcher struct {
+	notifiers  map[string]notifier
+	routes     []notificationRoute
+	deliveries *deliveryQueue
+}
+
+// newNotificationDispatcher loads notifiers and routes from the JSON file
+// at NOTIFIERS_PATH, or inline JSON in NOTIFIERS. Invalid configuration is
+// logged and notifications are disabled
+func newNotificationDispatcher() *notificationDispatcher {
+	data := []byte(os.Getenv("NOTIFIERS"))
+	if path := os.Getenv("NOTIFIERS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading notifier config, notifications are disabled: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	data, err := openSecrets(data)
+	if err != nil {
+		log.Printf("Error decrypting notifier config, notifications are disabled: %v", err)
+		return nil
+	}
+
+	var config models.NotificationConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding notifier config, notifications are disabled: %v", err)
+		return nil
+	}
+	d, err := compileNotificationConfig(config)
+	if err != nil {
+		log.Printf("Invalid notifier config, notifications are disabled: %v", err)
+		return nil
+	}
+	d.deliveries = newDeliveryQueue(d, os.Getenv("DELIVERIES_PATH"))
+	go d.deliveries.run()
+	return d
+}
+
+// compileNotificationConfig builds the notifiers and checks every route
+// points at one
+func compileNotificationConfig(config models.NotificationConfig) (*notificationDispatcher, error) {
+	d := &notificationDispatcher{notifiers: map[string]notifier{}}
+	// Webhook URLs may come from tenants, so deliveries can't reach
+	// private addresses unless the outbound policy allows them
+	client := outboundClient(20 * time.Second)
+	for name, nc := range config.Notifiers {
+		switch nc.Type {
+		case "slack":
+			if _, err := outbound().CheckURL(nc.URL); err != nil {
+				return nil, fmt.Errorf("notifier %s: slack needs a valid webhook url: %v", name, err)
+			}
+			d.notifiers[name] = &slackNotifier{webhookURL: nc.URL, client: client}
+		case "telegram":
+			if nc.BotToken == "" || nc.ChatID == "" {
+				return nil, fmt.Errorf("notifier %s: telegram needs bot_token and chat_id", name)
+			}
+			d.notifiers[name] = &telegramNotifier{botToken: nc.BotToken, chatID: nc.ChatID, client: client}
+		case "email":
+			if nc.SMTPHost == "" || nc.From == "" || len(nc.To) == 0 {
+				return nil, fmt.Errorf("notifier %s: email needs smtp_host, from and to", name)
+			}
+			port := nc.SMTPPort
+			if port == 0 {
+				port = 587
+			}
+			d.notifiers[name] = &emailNotifier{host: nc.SMTPHost, port: port, username: nc.Username, password: nc.Password, from: nc.From, to: nc.To}
+		case "webhook":
+			if _, err := outbound().CheckURL(nc.URL); err != nil {
+				return nil, fmt.Errorf("notifier %s: webhook needs a valid url: %v", name, err)
+			}
+			d.notifiers[name] = &webhookNotifier{url: nc.URL, secret: []byte(nc.Secret), client: client}
+		default:
+			return nil, fmt.Errorf("notifier %s: unknown type %q", name, nc.Type)
+		}
+	}
+	for i, route := range config.Routes {
+		if _, ok := d.notifiers[route.Notifier]; !ok {
+			return nil, fmt.Errorf("route %d: unknown notifier %q", i+1, route.Notifier)
+		}
+		for _, eventType := range route.Events {
+			if !containsString(eventTypes, eventType) {
+				return nil, fmt.Errorf("route %d: unknown event %q, use any of: %s", i+1, eventType, strings.Join(eventTypes, ","))
+			}
+		}
+		d.routes = append(d.routes, notificationRoute{
+			notifier: route.Notifier,
+			events:   route.Events,
+			sources:  route.Sources,
+			keywords: foldKeywords(route.Keywords),
+		})
+	}
+	return d, nil
+}
+
+// subscribe routes every bus event through the dispatcher
+func (d *notificationDispatcher) subscribe(bus *eventBus) {
+	bus.subscribe("notifications", d.dispatch)
+}
+
+// dispatch sends an event to the notifier of every matching route
+func (d *notificationDispatcher) dispatch(event models.Event) {
+	for _, route := range d.routes {
+		if routed, ok := route.match(event); ok {
+			go d.deliver(route.notifier, routed)
+		}
+	}
+}
+
+// deliver sends an event to a notifier, queueing it for retries on failure
+func (d *notificationDispatcher) deliver(name string, event models.Event) {
+	if err := d.notifiers[name].notify(event); err != nil {
+		log.Printf("Notifier %s failed on %s event %s, queueing a retry: %v", name, event.Type, event.ID, err)
+		d.deliveries.add(name, event, err)
+	}
+}
+
+// eventSubject is a one-line summary of an event
+func eventSubject(event models.Event) string {
+	switch event.Type {
+	case eventArticleDiscovered:
+		return fmt.Sprintf("%d new from %s", len(event.Articles), event.Source)
+	case eventArticleUpdated:
+		return fmt.Sprintf("%d updated on %s", len(event.Articles), event.Source)
+	case eventSourceFailed:
+		return fmt.Sprintf("Scraping %s failed", event.Source)
+	case eventArticleBreaking:
+		if len(event.Articles) > 0 {
+			return "Breaking: " + event.Articles[0].Title
+		}
+	}
+	return event.Type
+}
+
+// eventText renders an event as plain text for chat and email
+func eventText(event models.Event) string {
+	var text strings.Builder
+	text.WriteString(eventSubject(event))
+	if event.Error != "" {
+		text.WriteString(": " + event.Error)
+	}
+	for i, article := range event.Articles {
+		if i == notifierArticles {
+			fmt.Fprintf(&text, "\n…and %d more", len(event.Articles)-i)
+			break
+		}
+		fmt.Fprintf(&text, "\n• %s\n  %s", strings.TrimSpace(article.Title), article.URL)
+	}
+	return text.String()
+}
+
+// postJSON sends a JSON body and treats any non-2xx status as a failure
+func postJSON(client *http.Client, target string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func (s *slackNotifier) notify(event models.Event) error {
+	return postJSON(s.client, s.webhookURL, map[string]string{"text": eventText(event)}, nil)
+}
+
+// telegramNotifier sends messages from a Telegram bot to a chat or channel
+type telegramNotifier struct {
+	botToken string
+	chatID   string
+	client   *http.Client
+}
+
+func (t *telegramNotifier) notify(event models.Event) error {
+	return postJSON(t.client, "https://api.telegram.org/bot"+t.botToken+"/sendMessage", map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     eventText(event),
+		"disable_web_page_preview": len(event.Articles) != 1,
+	}, nil)
+}
+
+// emailNotifier sends plain-text mail over SMTP
+type emailNotifier struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (e *emailNotifier) notify(event models.Event) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		auth = smtp.PlainAuth("", e.username, e.password, e.host)
+	}
+	message := "From: " + e.from + "\r\n" +
+		"To: " + strings.Join(e.to, ", ") + "\r\n" +
+		"Subject: [Top News] " + eventSubject(event) + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		strings.ReplaceAll(eventText(event), "\n", "\r\n") + "\r\n"
+	if err := smtp.SendMail(e.host+":"+strconv.Itoa(e.port), auth, e.from, e.to, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	return nil
+}
+
+// webhookNotifier POSTs the event as JSON. With a secret, every attempt
+// is signed so subscribers can check it came from us (see pkg/webhook)
+type webhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func (w *webhookNotifier) notify(event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	headers := map[string]string{
+		webhook.EventTypeHeader: event.Type,
+		webhook.EventIDHeader:   event.ID,
+	}
+	if len(w.secret) > 0 {
+		headers[webhook.SignatureHeader] = webhook.Sign(w.secret, time.Now(), body)
+	}
+	return postJSON(w.client, w.url, json.RawMessage(body), headers)
+}
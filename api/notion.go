@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"top-news/models"
+)
+
+// Notion API settings. Notion allows about three requests a second per
+// integration, so pages are created one at a time with a short pause
+const (
+	notionAPI      = "https://api.notion.com/v1/pages"
+	notionVersion  = "2022-06-28"
+	notionInterval = 350 * time.Millisecond
+)
+
+// notionIntegration adds a page to a Notion database for every new article.
+// The database needs a title property "Name", a URL property "URL", select
+// properties "Source" and "Category" and a date property "Published"
+type notionIntegration struct {
+	token      string
+	databaseID string
+	filter     integrationFilter
+	client     *http.Client
+}
+
+// newNotionIntegration configures the Notion sync from NOTION_TOKEN,
+// NOTION_DATABASE_ID and NOTION_FILTER, returning nil when it is not
+// configured
+func newNotionIntegration() *notionIntegration {
+	token := secretEnv("NOTION_TOKEN")
+	databaseID := os.Getenv("NOTION_DATABASE_ID")
+	if token == "" && databaseID == "" {
+		return nil
+	}
+	if token == "" || databaseID == "" {
+		log.Printf("Notion sync is disabled: set both NOTION_TOKEN and NOTION_DATABASE_ID")
+		return nil
+	}
+	return &notionIntegration{
+		token:      token,
+		databaseID: databaseID,
+		filter:     parseIntegrationFilter(os.Getenv("NOTION_FILTER")),
+		client:     &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+func (n *notionIntegration) name() string {
+	return "notion"
+}
+
+// publish creates a database page per matching article, stopping at the
+// first error
+func (n *notionIntegration) publish(articles []models.NewsArticle) error {
+	sent := 0
+	for _, article := range articles {
+		if !n.filter.matches(article) {
+			continue
+		}
+		if sent > 0 {
+			time.Sleep(notionInterval)
+		}
+		if err := n.createPage(article); err != nil {
+			return fmt.Errorf("after %d pages: %v", sent, err)
+		}
+		sent++
+	}
+	return nil
+}
+
+// notionSelect builds a select property value; Notion rejects commas in
+// option names
+func notionSelect(value string) interface{} {
+	if value == "" {
+		return map[string]interface{}{"select": nil}
+	}
+	return map[string]interface{}{"select": map[string]string{"name": strings.ReplaceAll(value, ",", " ")}}
+}
+
+// createPage adds one article to the database
+func (n *notionIntegration) createPage(article models.NewsArticle) error {
+	published := map[string]interface{}{"date": nil}
+	if !article.PublishedAt.IsZero() {
+		published = map[string]interface{}{"date": map[string]string{"start": article.PublishedAt.Format(time.RFC3339)}}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"parent": map[string]string{"database_id": n.databaseID},
+		"properties": map[string]interface{}{
+			"Name":      map[string]interface{}{"title": []map[string]interface{}{{"text": map[string]string{"content": article.Title}}}},
+			"URL":       map[string]interface{}{"url": article.URL},
+			"Source":    notionSelect(article.Source),
+			"Category":  notionSelect(article.Category),
+			"Published": published,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode page: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", notionAPI, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	req.Header.Set("Notion-Version", notionVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("page request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("notion API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oEmbed card size; consumers may ask for smaller with maxwidth/maxheight
+const (
+	oembedWidth     = 600
+	oembedHeight    = 400
+	oembedThumbSize = 400
+)
+
+// GetOEmbed returns oEmbed JSON for an aggregated article, so CMSes and
+// chat apps can unfurl news links without hitting the news sites
+func (ns *NewsService) GetOEmbed(c *gin.Context) {
+	articleURL := c.Query("url")
+	if articleURL == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "missing_url",
+			Message: "The url query parameter is required",
+		})
+		return
+	}
+	if format := c.Query("format"); format != "" && format != "json" {
+		// The oEmbed spec asks for a 501 on unsupported formats
+		c.JSON(http.StatusNotImplemented, models.ErrorResponse{
+			Success: false,
+			Error:   "unsupported_format",
+			Message: "Only the json format is supported",
+		})
+		return
+	}
+
+	article, found := ns.findArticle(articleURL)
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "article_not_found",
+			Message: "The url is not an article aggregated by this service",
+		})
+		return
+	}
+
+	width := boundedQueryInt(c, "maxwidth", oembedWidth)
+	height := boundedQueryInt(c, "maxheight", oembedHeight)
+
+	response := models.OEmbedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        article.Title,
+		AuthorName:   article.Author,
+		ProviderName: article.Source,
+		CacheAge:     3600,
+		HTML:         oembedHTML(article),
+		Width:        width,
+		Height:       height,
+	}
+	if source, exists := ns.sources[article.Source]; exists {
+		response.ProviderName = source.DisplayName
+		response.ProviderURL = source.URL
+	}
+	if article.ImageURL != "" {
+		// We don't know the image's real size, so advertise a square bound
+		// that fits the requested box
+		size := oembedThumbSize
+		if width < size {
+			size = width
+		}
+		if height < size {
+			size = height
+		}
+		response.ThumbnailURL = article.ImageURL
+		response.ThumbnailWidth = size
+		response.ThumbnailHeight = size
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findArticle looks an article up in the store by URL, falling back to
+// comparing canonical URLs so tracking parameters don't matter
+func (ns *NewsService) findArticle(articleURL string) (models.NewsArticle, bool) {
+	if article, ok := ns.store.Get(articleURL); ok {
+		return article, true
+	}
+	key := canonicalURL(articleURL)
+	for _, article := range ns.store.List(store.Filter{}) {
+		if canonicalURL(article.URL) == key {
+			return article, true
+		}
+	}
+	return models.NewsArticle{}, false
+}
+
+// oembedHTML renders a small link card for the article
+func oembedHTML(article models.NewsArticle) string {
+	card := `<blockquote class="top-news-embed">`
+	if article.ImageURL != "" {
+		card += fmt.Sprintf(`<img src="%s" alt="" style="max-width:100%%">`, html.EscapeString(article.ImageURL))
+	}
+	card += fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(article.URL), html.EscapeString(article.Title))
+	if article.Description != "" {
+		card += fmt.Sprintf(`<p>%s</p>`, html.EscapeString(article.Description))
+	}
+	return card + `</blockquote>`
+}
+
+// boundedQueryInt reads a positive integer query parameter, capped at max
+func boundedQueryInt(c *gin.Context, name string, max int) int {
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil || value <= 0 || value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"top-news/safefetch"
+)
+
+var (
+	outboundOnce         sync.Once
+	outboundPolicy       *safefetch.Policy
+	outboundRoundTripper http.RoundTripper
+)
+
+// outbound is the policy for fetching URLs that callers, tenants and
+// remote servers supplied. Invalid settings are logged and the strict
+// default is used
+func outbound() *safefetch.Policy {
+	outboundOnce.Do(func() {
+		policy, err := safefetch.FromEnv()
+		if err != nil {
+			log.Printf("Error loading the outbound policy, allowing public addresses only: %v", err)
+			policy = &safefetch.Policy{}
+		}
+		outboundPolicy = policy
+		outboundRoundTripper = policy.Transport()
+	})
+	return outboundPolicy
+}
+
+// outboundTransport is the policy's transport, shared so connections are
+// reused
+func outboundTransport() http.RoundTripper {
+	outbound()
+	return outboundRoundTripper
+}
+
+// outboundClient returns a client for user-supplied URLs
+func outboundClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: outboundTransport(), CheckRedirect: outbound().CheckRedirect}
+}
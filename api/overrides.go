@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// overrideStore keeps editorial overrides apart from scraped articles, so
+// re-scraping never undoes them. When OVERRIDES_PATH is set they are
+// persisted to that JSON file
+type overrideStore struct {
+	mu        sync.RWMutex
+	path      string
+	overrides map[string]models.ArticleOverride
+}
+
+// newOverrideStore loads overrides from OVERRIDES_PATH
+func newOverrideStore() *overrideStore {
+	s := &overrideStore{
+		path:      os.Getenv("OVERRIDES_PATH"),
+		overrides: map[string]models.ArticleOverride{},
+	}
+	if s.path == "" {
+		return s
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading overrides, starting empty: %v", err)
+		}
+		return s
+	}
+	var overrides []models.ArticleOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		log.Printf("Error decoding overrides, starting empty: %v", err)
+		return s
+	}
+	for _, override := range overrides {
+		s.overrides[override.Key] = override
+	}
+	return s
+}
+
+// list returns every override, most recently updated first
+func (s *overrideStore) list() []models.ArticleOverride {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	overrides := make([]models.ArticleOverride, 0, len(s.overrides))
+	for _, override := range s.overrides {
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].UpdatedAt.After(overrides[j].UpdatedAt)
+	})
+	return overrides
+}
+
+// set stores an override, replacing and returning any earlier one for the
+// same key
+func (s *overrideStore) set(override models.ArticleOverride) (*models.ArticleOverride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var previous *models.ArticleOverride
+	if existing, exists := s.overrides[override.Key]; exists {
+		previous = &existing
+	}
+	s.overrides[override.Key] = override
+	return previous, s.persist()
+}
+
+// remove deletes the override for key and returns it, nil when there was
+// none
+func (s *overrideStore) remove(key string) (*models.ArticleOverride, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, exists := s.overrides[key]
+	if !exists {
+		return nil, nil
+	}
+	delete(s.overrides, key)
+	return &existing, s.persist()
+}
+
+// persist writes the overrides to disk; callers must hold the lock
+func (s *overrideStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	overrides := make([]models.ArticleOverride, 0, len(s.overrides))
+	for _, override := range s.overrides {
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Key < overrides[j].Key
+	})
+
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode overrides: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create overrides dir: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write overrides: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace overrides: %v", err)
+	}
+	return nil
+}
+
+// apply curates a served list of articles: hidden ones are dropped,
+// re-titled ones renamed and pinned ones moved to the top. Pinned articles
+// the scrape missed are added when they belong to source ("" for all)
+func (s *overrideStore) apply(articles []models.NewsArticle, source string) []models.NewsArticle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.overrides) == 0 {
+		return articles
+	}
+
+	pinned := []models.NewsArticle{}
+	rest := []models.NewsArticle{}
+	seen := map[string]bool{}
+	for _, article := range articles {
+		key := article.Key
+		if key == "" {
+			key = articleKey(article.URL)
+		}
+		seen[key] = true
+		override, exists := s.overrides[key]
+		switch {
+		case !exists:
+			rest = append(rest, article)
+		case override.Hidden:
+			continue
+		case override.Pinned:
+			pinned = append(pinned, retitle(article, override))
+		default:
+			rest = append(rest, retitle(article, override))
+		}
+	}
+
+	for key, override := range s.overrides {
+		if seen[key] || !override.Pinned || override.Hidden || override.Article == nil {
+			continue
+		}
+		if source == "" || override.Article.Source == source {
+			pinned = append(pinned, retitle(*override.Article, override))
+		}
+	}
+	sort.SliceStable(pinned, func(i, j int) bool {
+		return s.overrides[pinned[i].Key].UpdatedAt.After(s.overrides[pinned[j].Key].UpdatedAt)
+	})
+
+	return append(pinned, rest...)
+}
+
+// retitle applies an override's replacement title, if it has one
+func retitle(article models.NewsArticle, override models.ArticleOverride) models.NewsArticle {
+	article.Key = override.Key
+	if override.Title != "" {
+		article.Title = override.Title
+	}
+	return article
+}
+
+// ListOverrides returns every editorial override
+func (ns *NewsService) ListOverrides(c *gin.Context) {
+	overrides := ns.overrides.list()
+	c.JSON(http.StatusOK, models.OverridesResponse{
+		Success:   true,
+		Overrides: overrides,
+		Count:     len(overrides),
+	})
+}
+
+// SetOverride pins, hides or re-titles the article with the given key. A
+// pinned article the scrapers never found can be supplied in full, its key
+// is then derived from its URL
+func (ns *NewsService) SetOverride(c *gin.Context) {
+	var override models.ArticleOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Invalid override: %v", err),
+		})
+		return
+	}
+	override.Key = c.Param("key")
+	override.Title = strings.TrimSpace(override.Title)
+
+	if override.Article != nil {
+		if override.Article.URL == "" || override.Article.Title == "" || override.Article.Source == "" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_request",
+				Message: "A supplied article needs a url, title and source",
+			})
+			return
+		}
+		override.Key = articleKey(override.Article.URL)
+		override.Article.Key = override.Key
+		if override.Article.ID == "" {
+			override.Article.ID = override.Article.Source + "_pinned"
+		}
+	} else if _, found := ns.articleByKey(override.Key); !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "article_not_found",
+			Message: "No stored article has this key, supply the article to pin it",
+		})
+		return
+	}
+	if !override.Pinned && !override.Hidden && override.Title == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: "An override must pin, hide or re-title the article",
+		})
+		return
+	}
+
+	override.UpdatedAt = time.Now().UTC()
+	previous, err := ns.overrides.set(override)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "override_failed",
+			Message: fmt.Sprintf("Failed to save override: %v", err),
+		})
+		return
+	}
+	ns.audit.record(c, "override.set", override.Key, previous, override)
+	ns.purgeAllNews()
+	c.JSON(http.StatusOK, gin.H{"success": true, "override": override})
+}
+
+// DeleteOverride removes an override, restoring the scraped article
+func (ns *NewsService) DeleteOverride(c *gin.Context) {
+	removed, err := ns.overrides.remove(c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "override_failed",
+			Message: fmt.Sprintf("Failed to remove override: %v", err),
+		})
+		return
+	}
+	if removed == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "override_not_found",
+			Message: "No override exists for this key",
+		})
+		return
+	}
+	ns.audit.record(c, "override.delete", removed.Key, removed, nil)
+	ns.purgeAllNews()
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// politenessBudget limits how fast we fetch from each site. Homepage
+// scraping, enrichment, backfill, fact-checks and the image proxy all draw
+// from the same token bucket per site, so together they never go faster
+// than one subsystem alone was allowed to
+type politenessBudget struct {
+	// rate is requests per second per site, burst how many may go at once
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*hostBucket
+}
+
+// hostBucket is one site's tokens as of last
+type hostBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newPolitenessBudget reads POLITENESS_RATE (requests per second per site,
+// default 1) and POLITENESS_BURST (default 3). POLITENESS_RATE=off turns
+// the limit off and returns nil
+func newPolitenessBudget() *politenessBudget {
+	b := &politenessBudget{rate: 1, burst: 3, buckets: map[string]*hostBucket{}}
+	if raw := os.Getenv("POLITENESS_RATE"); raw == "off" {
+		return nil
+	} else if raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate <= 0 {
+			log.Printf("Invalid POLITENESS_RATE %q, using %g requests per second", raw, b.rate)
+		} else {
+			b.rate = rate
+		}
+	}
+	if raw := os.Getenv("POLITENESS_BURST"); raw != "" {
+		burst, err := strconv.Atoi(raw)
+		if err != nil || burst < 1 {
+			log.Printf("Invalid POLITENESS_BURST %q, using %g", raw, b.burst)
+		} else {
+			b.burst = float64(burst)
+		}
+	}
+	return b
+}
+
+// transport wraps next so every request waits for its site's budget. A nil
+// budget passes requests straight through
+func (b *politenessBudget) transport(next http.RoundTripper) http.RoundTripper {
+	if b == nil {
+		return next
+	}
+	return &politeTransport{next: next, budget: b}
+}
+
+// politeTransport holds each upstream request until its site has a token
+type politeTransport struct {
+	next   http.RoundTripper
+	budget *politenessBudget
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.budget.wait(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// wait takes a token for host, sleeping until one is free. It fails right
+// away, without using a token, when the request's deadline would pass first
+func (b *politenessBudget) wait(ctx context.Context, host string) error {
+	site := politenessKey(host)
+	now := time.Now()
+
+	b.mu.Lock()
+	bucket, ok := b.buckets[site]
+	if !ok {
+		bucket = &hostBucket{tokens: b.burst, last: now}
+		b.buckets[site] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * b.rate
+	if bucket.tokens > b.burst {
+		bucket.tokens = b.burst
+	}
+	bucket.last = now
+	bucket.tokens--
+	// A negative balance is a reservation: this request goes once the
+	// requests queued ahead of it have had their turn
+	delay := time.Duration(-bucket.tokens / b.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && delay > 0 && now.Add(delay).After(deadline) {
+		bucket.tokens++
+		b.mu.Unlock()
+		return fmt.Errorf("politeness budget for %s exhausted, next request in %s", site, delay.Round(time.Millisecond))
+	}
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// politenessKey groups the hosts of one site, so www.thedailystar.net and
+// thedailystar.net, or edition.cnn.com and media.cnn.com, share a budget.
+// It keeps the last two labels of a name; IP addresses are kept whole
+func politenessKey(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// upstreamTransport is the transport for fetches from news sites: the
+// scrape window, the politeness budget, then chaos
+func (ns *NewsService) upstreamTransport() http.RoundTripper {
+	return ns.windows.transport(ns.politeness.transport(ns.chaos.transport(nil)))
+}
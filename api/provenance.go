@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"top-news/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Extraction methods in the order the fallback chain prefers them
+const (
+	methodJSONLD    = "json-ld"
+	methodOpenGraph = "og"
+	methodMeta      = "meta"
+	methodCSS       = "css"
+	methodHeuristic = "heuristic"
+)
+
+// methodConfidence is how much we trust a value produced by each method.
+// Structured data is written by the publisher for machines; CSS selectors
+// break with redesigns; heuristics guess
+var methodConfidence = map[string]float64{
+	methodJSONLD:    0.95,
+	methodOpenGraph: 0.9,
+	methodMeta:      0.8,
+	methodCSS:       0.7,
+	methodHeuristic: 0.4,
+}
+
+// provenanceOf classifies the method string recorded for a field, e.g.
+// "article page: meta[property='og:image'][content]", into a method and a
+// confidence score
+func provenanceOf(recorded string) models.FieldProvenance {
+	provenance := models.FieldProvenance{Selector: recorded}
+	selector := recorded
+	for _, prefix := range []string{"article page: ", "enrichment cache: ", "enrichment cache (unchanged page): "} {
+		selector = strings.TrimPrefix(selector, prefix)
+	}
+
+	switch {
+	case strings.HasPrefix(selector, methodJSONLD):
+		provenance.Method = methodJSONLD
+	case strings.Contains(selector, "og:") || strings.Contains(selector, "twitter:"):
+		provenance.Method = methodOpenGraph
+	case strings.HasPrefix(selector, "meta[") || strings.HasSuffix(selector, "[datetime]"):
+		provenance.Method = methodMeta
+	case strings.HasPrefix(selector, methodHeuristic):
+		provenance.Method = methodHeuristic
+	default:
+		provenance.Method = methodCSS
+	}
+	provenance.Confidence = methodConfidence[provenance.Method]
+	if strings.HasPrefix(recorded, "enrichment cache") {
+		provenance.Cached = true
+	}
+	return provenance
+}
+
+// articleJSONLD returns the first Article-like object in the page's JSON-LD
+// blocks, looking inside @graph lists too, or nil when there is none
+func articleJSONLD(doc *goquery.Document) map[string]interface{} {
+	var found map[string]interface{}
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		found = findArticleObject(data)
+		return found == nil
+	})
+	return found
+}
+
+// findArticleObject walks decoded JSON-LD for an object whose @type is an
+// article type
+func findArticleObject(data interface{}) map[string]interface{} {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if object := findArticleObject(item); object != nil {
+				return object
+			}
+		}
+	case map[string]interface{}:
+		if isArticleType(value["@type"]) {
+			return value
+		}
+		if graph, ok := value["@graph"]; ok {
+			return findArticleObject(graph)
+		}
+	}
+	return nil
+}
+
+// jsonLDObject returns the page's first JSON-LD object of the given @type,
+// e.g. LiveBlogPosting or ImageGallery, nil when it has none
+func jsonLDObject(doc *goquery.Document, objectType string) map[string]interface{} {
+	var found map[string]interface{}
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		found = findJSONLDType(data, objectType)
+		return found == nil
+	})
+	return found
+}
+
+// findJSONLDType walks decoded JSON-LD for an object of the given @type
+func findJSONLDType(data interface{}, objectType string) map[string]interface{} {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if object := findJSONLDType(item, objectType); object != nil {
+				return object
+			}
+		}
+	case map[string]interface{}:
+		if jsonLDValue(value["@type"], nil) == objectType {
+			return value
+		}
+		if graph, ok := value["@graph"]; ok {
+			return findJSONLDType(graph, objectType)
+		}
+	}
+	return nil
+}
+
+func isArticleType(value interface{}) bool {
+	switch t := value.(type) {
+	case string:
+		return strings.HasSuffix(t, "Article") || t == "LiveBlogPosting"
+	case []interface{}:
+		for _, item := range t {
+			if isArticleType(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonLDString reads a JSON-LD property that may be a string, an object
+// or a list of either, and returns the first value. For objects the keys
+// are tried in order, e.g. "url" for images and "name" for authors
+func jsonLDString(object map[string]interface{}, key string, objectKeys ...string) string {
+	return jsonLDValue(object[key], objectKeys)
+}
+
+func jsonLDValue(value interface{}, objectKeys []string) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		for _, item := range v {
+			if s := jsonLDValue(item, objectKeys); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range objectKeys {
+			if s, ok := v[key].(string); ok && strings.TrimSpace(s) != "" {
+				return strings.TrimSpace(s)
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDStrings reads a JSON-LD property holding a list or a comma-separated
+// string
+func jsonLDStrings(object map[string]interface{}, key string) []string {
+	values := []string{}
+	switch v := object[key].(type) {
+	case string:
+		values = append(values, strings.Split(v, ",")...)
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, jsonLDValue(item, []string{"name"}))
+		}
+	}
+	return values
+}
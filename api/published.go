@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/textnorm"
+)
+
+// isoLayouts are machine-readable timestamps that carry their own offset
+var isoLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+}
+
+// localLayouts are tried against human-readable dates once they have been
+// normalized to English month names, ASCII digits and no commas
+var localLayouts = []string{
+	"Jan 2 2006 3:04 PM",
+	"January 2 2006 3:04 PM",
+	"2 Jan 2006 3:04 PM",
+	"2 January 2006 3:04 PM",
+	"3:04 PM Jan 2 2006",
+	"3:04 PM January 2 2006",
+	"Jan 2 2006 15:04",
+	"January 2 2006 15:04",
+	"2 Jan 2006 15:04",
+	"2 January 2006 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"Jan 2 2006",
+	"January 2 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"2006-01-02",
+}
+
+// Numeric dates are ambiguous, so their order depends on the source locale
+var (
+	dayFirstLayouts   = []string{"02/01/2006 15:04", "02/01/2006 3:04 PM", "02/01/2006", "02-01-2006", "02.01.2006"}
+	monthFirstLayouts = []string{"01/02/2006 15:04", "01/02/2006 3:04 PM", "01/02/2006", "01-02-2006"}
+)
+
+var (
+	datePrefixPattern = regexp.MustCompile(`(?i)^(published|updated|last update(d)? on|posted|publish|প্রকাশিত|প্রকাশ|আপডেট)\s*(on|at)?\s*:?\s*`)
+	weekdayPattern    = regexp.MustCompile(`(?i)\b(mon|tue|wed|thu|fri|sat|sun)[a-z]*\b`)
+	zoneAbbrevPattern = regexp.MustCompile(`\b(UTC|GMT|BST|IST|EDT|EST|ET|CDT|CST|MDT|MST|PDT|PST|PT)\b`)
+	meridiemPattern   = regexp.MustCompile(`(?i)(\d)\s*([ap])\.?m\b\.?`)
+	dateWordPattern   = regexp.MustCompile(`(?i)\s(at|on)\s`)
+	multiSpacePattern = regexp.MustCompile(`\s+`)
+)
+
+// parsePublishedAt parses a scraped publish time using the source's locale
+// and timezone, returning it in UTC
+func parsePublishedAt(raw string, source models.Source) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	text := normalizeDateText(raw)
+	layouts := append([]string{}, localLayouts...)
+	if strings.HasPrefix(source.Locale, "en-US") {
+		layouts = append(layouts, monthFirstLayouts...)
+	} else {
+		layouts = append(layouts, dayFirstLayouts...)
+	}
+
+	location := sourceLocation(source)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeDateText turns a human-readable date into a form the layouts
+// understand: Bangla digits and months become English, labels, weekdays,
+// timezone abbreviations and commas are dropped
+func normalizeDateText(text string) string {
+	text = textnorm.Date(text)
+	text = strings.ReplaceAll(text, ",", " ")
+	text = strings.ReplaceAll(text, "|", " ")
+	text = multiSpacePattern.ReplaceAllString(strings.TrimSpace(text), " ")
+	text = datePrefixPattern.ReplaceAllString(text, "")
+	text = weekdayPattern.ReplaceAllString(text, "")
+	text = meridiemPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := meridiemPattern.FindStringSubmatch(m)
+		return parts[1] + " " + strings.ToUpper(parts[2]) + "M"
+	})
+	text = zoneAbbrevPattern.ReplaceAllString(text, "")
+	text = dateWordPattern.ReplaceAllString(text, " ")
+	return multiSpacePattern.ReplaceAllString(strings.TrimSpace(text), " ")
+}
+
+// sourceLocation loads the source's timezone, falling back to UTC
+func sourceLocation(source models.Source) *time.Location {
+	if source.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(source.Timezone)
+	if err != nil {
+		log.Printf("Unknown timezone %q for %s, using UTC: %v", source.Timezone, source.Name, err)
+		return time.UTC
+	}
+	return location
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaTiers are the configured quota tiers, nil when there are none
+type quotaTiers struct {
+	defaultTier string
+	tiers       map[string]models.QuotaTier
+}
+
+// newQuotaTiers loads tiers from the JSON file at QUOTA_TIERS_PATH, or
+// inline JSON in QUOTA_TIERS. Invalid configuration is logged and keys are
+// left without quotas
+func newQuotaTiers() *quotaTiers {
+	data := []byte(os.Getenv("QUOTA_TIERS"))
+	if path := os.Getenv("QUOTA_TIERS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading quota tiers, keys have no quotas: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var config models.QuotaTiersConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding quota tiers, keys have no quotas: %v", err)
+		return nil
+	}
+	q, err := compileQuotaTiers(config)
+	if err != nil {
+		log.Printf("Invalid quota tiers, keys have no quotas: %v", err)
+		return nil
+	}
+	return q
+}
+
+// compileQuotaTiers checks that limits are not negative and the default
+// tier exists
+func compileQuotaTiers(config models.QuotaTiersConfig) (*quotaTiers, error) {
+	for name, tier := range config.Tiers {
+		if tier.RequestsPerMonth < 0 || tier.RequestsPerMinute < 0 || tier.MaxWebhooks < 0 || tier.MaxAlerts < 0 {
+			return nil, fmt.Errorf("tier %s: limits must not be negative", name)
+		}
+	}
+	if _, ok := config.Tiers[config.Default]; config.Default != "" && !ok {
+		return nil, fmt.Errorf("unknown default tier %q", config.Default)
+	}
+	return &quotaTiers{defaultTier: config.Default, tiers: config.Tiers}, nil
+}
+
+// check reports an error for a tier name that is not configured. Keys
+// without a tier are always fine
+func (q *quotaTiers) check(name string) error {
+	if name == "" {
+		return nil
+	}
+	if q == nil {
+		return fmt.Errorf("tier %q is set but no quota tiers are configured", name)
+	}
+	if _, ok := q.tiers[name]; !ok {
+		return fmt.Errorf("unknown tier %q", name)
+	}
+	return nil
+}
+
+// tier returns the limits of a tier, the default tier's for "". The zero
+// tier, without limits, is returned when there is neither
+func (q *quotaTiers) tier(name string) models.QuotaTier {
+	if q == nil {
+		return models.QuotaTier{}
+	}
+	if name == "" {
+		name = q.defaultTier
+	}
+	return q.tiers[name]
+}
+
+// requestTier names the quota tier of the API key behind a request: the
+// tier of its tenant or key, else the default tier. It is "" when the key
+// has no tier
+func (ns *NewsService) requestTier(c *gin.Context) string {
+	name := c.GetString(keyTierContextKey)
+	if t := currentTenant(c); t != nil {
+		name = t.tier
+	}
+	if name == "" && ns.tiers != nil {
+		name = ns.tiers.defaultTier
+	}
+	return name
+}
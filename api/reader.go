@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// articleBodySelectors locate the story text on article pages, most
+// specific first
+var articleBodySelectors = []string{
+	".article-body",
+	".article__content",
+	".section-content",
+	".field-body",
+	"article",
+	"main",
+}
+
+// maxProxiedImageBytes caps images served through the image proxy
+const maxProxiedImageBytes = 5 << 20
+
+// readerPolicy keeps the markup of a story's text and drops everything
+// else: scripts, styles, iframes, forms and event handlers
+var readerPolicy = func() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowRelativeURLs(true)
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}()
+
+var readerTemplate = template.Must(template.New("reader").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Article.Title}}</title>
+<style>
+body{max-width:40em;margin:0 auto;padding:1em;font:1.05em/1.6 Georgia,serif;color:#222}
+img{max-width:100%;height:auto}
+.meta{color:#666;font-size:.9em}
+</style>
+</head>
+<body>
+<p class="meta">{{.SourceName}}{{if .Article.Author}} &middot; {{.Article.Author}}{{end}}{{if not .Article.PublishedAt.IsZero}} &middot; {{.Article.PublishedAt.Format "2 Jan 2006 15:04 MST"}}{{end}}</p>
+<h1>{{.Article.Title}}</h1>
+{{if .Image}}<img src="{{.Image}}" alt="{{.Article.ImageCaption}}">{{end}}
+{{if or .Article.ImageCaption .Article.ImageCredit}}<p class="meta">{{.Article.ImageCaption}}{{if .Article.ImageCredit}} Photo: {{.Article.ImageCredit}}{{end}}</p>{{end}}
+{{.Body}}
+{{if .Article.ArchiveURL}}<p class="meta">This story is no longer on {{.SourceName}}. <a href="{{.Article.ArchiveURL}}" rel="nofollow">Read the archived copy</a></p>
+{{else}}<p class="meta"><a href="{{.Article.URL}}" rel="nofollow">Read the original on {{.SourceName}}</a></p>
+{{end}}
+</body>
+</html>
+`))
+
+// ViewArticle serves a reader-mode HTML page for a stored article: the
+// story text sanitized, images routed through the image proxy and scripts
+// stripped, for lightweight clients and slow connections
+func (ns *NewsService) ViewArticle(c *gin.Context) {
+	article, found := ns.articleByKey(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "article_not_found",
+			Message: "No stored article has this key",
+		})
+		return
+	}
+
+	source := ns.sources[article.Source]
+	release := ns.scrapes.acquire(requestLane(c))
+	page, article, err := ns.fetchStoredArticlePage(article)
+	release()
+	if errors.Is(err, errPageGone) {
+		articleGone(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "fetch_failed",
+			Message: fmt.Sprintf("Failed to fetch the article: %v", err),
+		})
+		return
+	}
+	ns.chargeEnrichment(c, 1)
+
+	lang := "en"
+	if strings.HasPrefix(source.Locale, "bn") {
+		lang = "bn"
+	}
+	sourceName := source.DisplayName
+	if sourceName == "" {
+		sourceName = article.Source
+	}
+	image := ""
+	if article.ImageURL != "" {
+		image = proxiedImageURL(article.ImageURL)
+	}
+
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := readerTemplate.Execute(c.Writer, map[string]interface{}{
+		"Lang":       lang,
+		"Article":    article,
+		"SourceName": sourceName,
+		"Image":      image,
+		"Body":       template.HTML(readerBody(page)),
+	}); err != nil {
+		c.Error(err)
+	}
+}
+
+// articleGone answers for an article removed from its source that the
+// Wayback Machine has no copy of
+func articleGone(c *gin.Context) {
+	c.JSON(http.StatusGone, models.ErrorResponse{
+		Success: false,
+		Error:   "article_gone",
+		Message: "The article was removed from its source and no archived copy was found",
+	})
+}
+
+// articleByKey finds a stored article by its stable key
+func (ns *NewsService) articleByKey(key string) (models.NewsArticle, bool) {
+	for _, article := range ns.store.List(store.Filter{}) {
+		if article.Key == key || articleKey(article.URL) == key {
+			return article, true
+		}
+	}
+	return models.NewsArticle{}, false
+}
+
+// readerBody extracts the story text of an article page as sanitized HTML,
+// with links made absolute and images pointed at the image proxy
+func readerBody(page *articlePage) string {
+	base, _ := url.Parse(page.URL)
+	body := page.Doc.Find("body")
+	for _, selector := range articleBodySelectors {
+		if match := page.Doc.Find(selector).First(); len(strings.TrimSpace(match.Text())) > 200 {
+			body = match
+			break
+		}
+	}
+
+	body.Find("script, style, noscript, iframe, form, nav, aside, footer, .ad, .advertisement").Remove()
+	body.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		s.SetAttr("href", resolveURL(base, s.AttrOr("href", "")))
+	})
+	body.Find("img").Each(func(i int, s *goquery.Selection) {
+		src := s.AttrOr("data-src", s.AttrOr("src", ""))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			s.Remove()
+			return
+		}
+		s.SetAttr("src", proxiedImageURL(resolveURL(base, src)))
+		s.RemoveAttr("srcset")
+		s.RemoveAttr("data-srcset")
+	})
+
+	content, err := body.Html()
+	if err != nil {
+		return ""
+	}
+	return readerPolicy.Sanitize(content)
+}
+
+// resolveURL makes ref absolute against base
+func resolveURL(base *url.URL, ref string) string {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || base == nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// proxiedImageURL points an image at the image proxy
+func proxiedImageURL(imageURL string) string {
+	return "/api/v1/image?url=" + url.QueryEscape(imageURL)
+}
+
+// ProxyImage streams an image hosted by one of the configured sources, so
+// reader pages never make the browser contact the news sites directly
+func (ns *NewsService) ProxyImage(c *gin.Context) {
+	imageURL, err := url.Parse(c.Query("url"))
+	if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") || !ns.isSourceHost(imageURL.Hostname()) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_image_url",
+			Message: "url must be an http(s) image hosted by a configured source",
+		})
+		return
+	}
+
+	req, err := http.NewRequest("GET", imageURL.String(), nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Success: false, Error: "invalid_image_url", Message: err.Error()})
+		return
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	// Source hosts are checked above, but their addresses and redirects
+	// are still checked, so a source's DNS can't point the proxy inward
+	client := outboundClient(10 * time.Second)
+	client.Transport = ns.windows.transport(ns.politeness.transport(ns.chaos.transport(client.Transport)))
+	resp, err := client.Do(req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Success: false, Error: "fetch_failed", Message: err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, "image/") {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "fetch_failed",
+			Message: fmt.Sprintf("Upstream returned %d %s", resp.StatusCode, contentType),
+		})
+		return
+	}
+	if resp.ContentLength > maxProxiedImageBytes {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{Success: false, Error: "image_too_large", Message: "The image is larger than 5MB"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.DataFromReader(http.StatusOK, -1, contentType, io.LimitReader(resp.Body, maxProxiedImageBytes), nil)
+}
+
+// isSourceHost reports whether host belongs to one of the configured
+// sources, including subdomains such as image CDNs
+func (ns *NewsService) isSourceHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, source := range ns.sources {
+		parsed, err := url.Parse(source.URL)
+		if err != nil {
+			continue
+		}
+		base := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+		if base = registrableDomain(base); host == base || strings.HasSuffix(host, "."+base) {
+			return true
+		}
+	}
+	return false
+}
+
+// registrableDomain trims a host to its last two labels, e.g.
+// edition.cnn.com becomes cnn.com
+func registrableDomain(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storePruner drops articles past the retention policy in the background
+type storePruner struct {
+	retention store.Retention
+	interval  time.Duration
+
+	mu     sync.Mutex
+	status models.StoreRetention
+}
+
+// newStorePruner reads STORE_RETENTION_DAYS, how many days of articles to
+// keep, and STORE_MAX_PER_SOURCE, how many of each source's newest articles
+// to keep. Pruning runs every STORE_PRUNE_INTERVAL (default 1h) and forgets
+// tombstones older than STORE_TOMBSTONE_DAYS (default 30). It returns nil
+// when neither article limit is set
+func newStorePruner() *storePruner {
+	p := &storePruner{interval: time.Hour}
+	p.retention.TombstoneAge = 30 * 24 * time.Hour
+	if value := os.Getenv("STORE_TOMBSTONE_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			log.Printf("Invalid STORE_TOMBSTONE_DAYS %q, using 30", value)
+		} else {
+			p.retention.TombstoneAge = time.Duration(days) * 24 * time.Hour
+		}
+	}
+	if value := os.Getenv("STORE_RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			log.Printf("Invalid STORE_RETENTION_DAYS %q, articles are kept however old", value)
+		} else {
+			p.retention.MaxAge = time.Duration(days) * 24 * time.Hour
+			p.status.MaxAgeDays = days
+		}
+	}
+	if value := os.Getenv("STORE_MAX_PER_SOURCE"); value != "" {
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 1 {
+			log.Printf("Invalid STORE_MAX_PER_SOURCE %q, sources keep every article", value)
+		} else {
+			p.retention.MaxPerSource = max
+			p.status.MaxPerSource = max
+		}
+	}
+	if value := os.Getenv("STORE_PRUNE_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < time.Minute {
+			log.Printf("Invalid STORE_PRUNE_INTERVAL %q, using %s", value, p.interval)
+		} else {
+			p.interval = interval
+		}
+	}
+	if p.retention.MaxAge == 0 && p.retention.MaxPerSource == 0 {
+		return nil
+	}
+	return p
+}
+
+// run prunes the store right away and then every interval
+func (p *storePruner) run(articles *store.Store) {
+	for {
+		p.prune(articles)
+		time.Sleep(p.interval)
+	}
+}
+
+// prune drops the articles past the policy once and records the outcome
+func (p *storePruner) prune(articles *store.Store) {
+	removed, err := articles.Prune(p.retention, time.Now().UTC())
+	now := time.Now().UTC()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.LastRunAt = &now
+	p.status.LastPruned = removed
+	p.status.TotalPruned += removed
+	p.status.Error = ""
+	if err != nil {
+		p.status.Error = err.Error()
+		log.Printf("Error saving the store after pruning %d articles: %v", removed, err)
+	} else if removed > 0 {
+		log.Printf("Pruned %d articles past the retention policy", removed)
+	}
+}
+
+// report returns the policy and what pruning has done, nil without a
+// policy
+func (p *storePruner) report() *models.StoreRetention {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := p.status
+	return &status
+}
+
+// GetStoreStats reports how many articles the store holds, per source, how
+// big its file is and what the retention policy pruned
+func (ns *NewsService) GetStoreStats(c *gin.Context) {
+	stats := ns.store.Stats()
+	response := models.StoreStatsResponse{
+		Success:   true,
+		Articles:  stats.Articles,
+		Sources:   stats.Sources,
+		Bytes:     stats.Bytes,
+		Retention: ns.pruner.report(),
+	}
+	if !stats.Oldest.IsZero() {
+		response.Oldest, response.Newest = &stats.Oldest, &stats.Newest
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,454 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minScheduleInterval keeps a typo like "5s" from hammering a site
+const minScheduleInterval = time.Minute
+
+// sharedRecheck is how often a request looks for a source's shared
+// articles in Redis while this instance has none
+const sharedRecheck = 30 * time.Second
+
+// scheduler scrapes sources in the background, each on its own timer, so
+// their articles reach the store and the event bus without a client asking
+type scheduler struct {
+	ns     *NewsService
+	jitter float64
+	// prefetched answers news requests from the last scheduled scrape
+	prefetched bool
+	// lock makes sure only one instance runs each scrape, nil when this
+	// instance is on its own
+	lock *redisLock
+	// shards splits the sources between workers, nil when every instance
+	// runs every source
+	shards *shardRing
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+}
+
+// scheduledJob is one source's timer and the outcome of its last run
+type scheduledJob struct {
+	source   string
+	interval time.Duration
+	// cron is set instead of interval for sources run on a cron
+	// expression
+	cron   *cronSchedule
+	status models.ScheduledSource
+	// articles are the last successful run's, in homepage order, or the
+	// ones another instance's run shared
+	articles []models.NewsArticle
+	// sharedAt is when the shared articles were last looked up
+	sharedAt time.Time
+}
+
+// newScheduler loads the schedule from the JSON file at SCHEDULE_PATH, or
+// inline JSON in SCHEDULE. It returns nil, and nothing is scraped in the
+// background, when no schedule is configured or it is invalid
+func newScheduler(ns *NewsService) *scheduler {
+	data := []byte(os.Getenv("SCHEDULE"))
+	if path := os.Getenv("SCHEDULE_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading schedule, background scraping is disabled: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var config models.ScheduleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding schedule, background scraping is disabled: %v", err)
+		return nil
+	}
+	s, err := compileSchedule(ns, config)
+	if err != nil {
+		log.Printf("Invalid schedule, background scraping is disabled: %v", err)
+		return nil
+	}
+	return s
+}
+
+// compileSchedule checks the intervals and cron expressions and sets up a
+// job per source
+func compileSchedule(ns *NewsService, config models.ScheduleConfig) (*scheduler, error) {
+	s := &scheduler{ns: ns, jitter: 0.1, prefetched: true, lock: newRedisLock(), jobs: map[string]*scheduledJob{}}
+	if config.ServePrefetched != nil {
+		s.prefetched = *config.ServePrefetched
+	}
+	if config.Jitter != nil {
+		if *config.Jitter < 0 || *config.Jitter > 0.5 {
+			return nil, fmt.Errorf("jitter must be between 0 and 0.5")
+		}
+		s.jitter = *config.Jitter
+	}
+	loc := time.UTC
+	if config.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(config.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", config.Timezone)
+		}
+	}
+
+	// Values with spaces, or starting with @, are cron expressions and
+	// the rest intervals
+	parse := func(name, value string) (*scheduledJob, error) {
+		if strings.HasPrefix(value, "@") || strings.Contains(value, " ") {
+			cron, err := parseCron(value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			if cron.next(time.Now()).IsZero() {
+				return nil, fmt.Errorf("%s: cron expression %q never runs", name, value)
+			}
+			return &scheduledJob{cron: cron}, nil
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid interval %q", name, value)
+		}
+		if interval < minScheduleInterval {
+			return nil, fmt.Errorf("%s: interval %s is shorter than %s", name, interval, minScheduleInterval)
+		}
+		return &scheduledJob{interval: interval}, nil
+	}
+
+	for name, value := range config.Sources {
+		if _, ok := ns.sources[name]; !ok {
+			return nil, fmt.Errorf("unknown source %q", name)
+		}
+		job, err := parse(name, value)
+		if err != nil {
+			return nil, err
+		}
+		s.jobs[name] = job
+	}
+	if config.Default != "" {
+		if _, err := parse("default", config.Default); err != nil {
+			return nil, err
+		}
+		for name, source := range ns.sources {
+			if _, listed := s.jobs[name]; !listed && source.Active {
+				s.jobs[name], _ = parse(name, config.Default)
+			}
+		}
+	}
+	for name, job := range s.jobs {
+		job.source = name
+		job.status = models.ScheduledSource{Source: name}
+		if job.cron != nil {
+			job.status.Cron = job.cron.expr
+		} else {
+			job.status.Interval = job.interval.String()
+		}
+	}
+	s.shards = newShardRing(s.lock)
+	return s, nil
+}
+
+// start runs every job on its own timer. The first runs of intervals are
+// spread over each interval so sources don't all start together
+func (s *scheduler) start() {
+	if s.shards != nil {
+		go s.shards.run()
+	}
+	for _, job := range s.jobs {
+		if job.cron != nil {
+			go s.loop(job, s.nextWait(job))
+			continue
+		}
+		go s.loop(job, time.Duration(rand.Int63n(int64(job.interval))))
+	}
+}
+
+// loop scrapes a source forever. The next run is planned once the current
+// one has finished, so slow scrapes never overlap
+func (s *scheduler) loop(job *scheduledJob, wait time.Duration) {
+	for {
+		next := time.Now().Add(wait)
+		s.mu.Lock()
+		job.status.NextRun = &next
+		s.mu.Unlock()
+
+		time.Sleep(wait)
+		s.run(job)
+		wait = s.nextWait(job)
+	}
+}
+
+// nextWait is the time until a cron job's next run, or the interval
+// shifted by up to the jitter either way
+func (s *scheduler) nextWait(job *scheduledJob) time.Duration {
+	if job.cron != nil {
+		return time.Until(job.cron.next(time.Now()))
+	}
+	spread := float64(job.interval) * s.jitter
+	return job.interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// period is about how long a job waits between runs: its interval, or the
+// gap between a cron job's next two runs
+func (job *scheduledJob) period() time.Duration {
+	if job.cron == nil {
+		return job.interval
+	}
+	next := job.cron.next(time.Now())
+	return job.cron.next(next).Sub(next)
+}
+
+// run scrapes one source now, unless another instance already has or its
+// scrape window is closed
+func (s *scheduler) run(job *scheduledJob) {
+	if s.shards != nil {
+		owner := s.shards.owner(job.source)
+		s.mu.Lock()
+		job.status.Owner = owner
+		s.mu.Unlock()
+		// The owner's run shares the articles for the others to serve
+		if !s.shards.owns(job.source) {
+			s.loadShared(job)
+			return
+		}
+	}
+
+	// A closed window queues one scrape for when it reopens; the timer
+	// carries on as usual
+	if until := s.ns.windows.until(job.source); !until.IsZero() {
+		s.ns.deferScrape(job.source, until)
+		s.mu.Lock()
+		job.status.DeferredUntil = &until
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Lock()
+	job.status.DeferredUntil = nil
+	s.mu.Unlock()
+
+	lockName := "scrape:" + job.source
+	token := ""
+	if s.lock != nil {
+		// Hold the lock until just before the next run is due, so every
+		// instance's timer for this interval finds it taken
+		ttl := time.Duration(float64(job.period()) * (1 - s.jitter) * 0.9)
+		var ok bool
+		var err error
+		token, ok, err = s.lock.tryLock(lockName, ttl)
+		switch {
+		case err != nil:
+			// Scraping twice beats not scraping while Redis is down
+			log.Printf("Error taking the scrape lock for %s, scraping anyway: %v", job.source, err)
+		case !ok:
+			now := time.Now().UTC()
+			s.mu.Lock()
+			job.status.SkippedAt = &now
+			s.mu.Unlock()
+			s.loadShared(job)
+			return
+		}
+	}
+
+	source := s.ns.sources[job.source]
+	articles, err := s.ns.fetchNewsFromSource(job.source, source.URL, scrapeOptions{limit: defaultSourceLimit, fresh: true})
+	if err != nil {
+		log.Printf("Scheduled scrape of %s failed: %v", job.source, err)
+		// Let another instance retry at its next run
+		if token != "" {
+			if err := s.lock.unlock(lockName, token); err != nil {
+				log.Printf("Error releasing the scrape lock for %s: %v", job.source, err)
+			}
+		}
+	}
+
+	if s.shards != nil {
+		s.shards.record(err)
+	}
+
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.status.LastRun = &now
+	job.status.Articles = len(articles)
+	job.status.LastError = ""
+	if err != nil {
+		job.status.LastError = err.Error()
+		return
+	}
+	job.articles = cloneArticles(articles)
+	if s.lock != nil {
+		go s.share(job.source, articles, job.period())
+	}
+}
+
+// sharedKey is where the articles of a source's last scheduled run are
+// kept in Redis
+func (s *scheduler) sharedKey(source string) string {
+	return s.lock.prefix + "prefetched:" + source
+}
+
+// share puts the articles of a scheduled run in Redis next to its lock,
+// for the instances that didn't run it to serve. They are kept for three
+// runs, so a missed run or two doesn't empty every instance
+func (s *scheduler) share(source string, articles []models.NewsArticle, period time.Duration) {
+	data, err := json.Marshal(articles)
+	if err != nil {
+		log.Printf("Error encoding the %s articles to share: %v", source, err)
+		return
+	}
+	ttl := strconv.FormatInt((3 * period).Milliseconds(), 10)
+	if _, err := s.lock.do("SET", s.sharedKey(source), string(data), "PX", ttl); err != nil {
+		log.Printf("Error sharing the %s articles: %v", source, err)
+	}
+}
+
+// loadShared takes the articles another instance's scheduled run of a
+// source shared, for serving. They were announced by that instance, so
+// they are stored here without announcing them again
+func (s *scheduler) loadShared(job *scheduledJob) {
+	reply, err := s.lock.do("GET", s.sharedKey(job.source))
+	if err != nil {
+		log.Printf("Error reading the shared %s articles: %v", job.source, err)
+		return
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return
+	}
+	var articles []models.NewsArticle
+	if err := json.Unmarshal([]byte(data), &articles); err != nil {
+		log.Printf("Error decoding the shared %s articles: %v", job.source, err)
+		return
+	}
+	if _, err := s.ns.store.Save(articles...); err != nil {
+		log.Printf("Error saving the shared %s articles to the store: %v", job.source, err)
+	}
+	s.mu.Lock()
+	job.articles = articles
+	s.mu.Unlock()
+}
+
+// coordinated reports whether a source's scheduled runs are shared
+// between instances through Redis. Only those runs then store and
+// announce its articles; scrapes for requests just serve them
+func (s *scheduler) coordinated(source string) bool {
+	return s.job(source) != nil && s.lock != nil
+}
+
+// job returns a source's job, nil when it is not scheduled
+func (s *scheduler) job(source string) *scheduledJob {
+	if s == nil {
+		return nil
+	}
+	return s.jobs[source]
+}
+
+// prefetchedNews returns a copy of a scheduled source's articles from its
+// last successful run, when the schedule serves prefetched news and there
+// has been one
+func (s *scheduler) prefetchedNews(source string, limit int) ([]models.NewsArticle, bool) {
+	job := s.job(source)
+	if job == nil || !s.prefetched {
+		return nil, false
+	}
+	// Until this instance has run the source, another one may have
+	s.mu.Lock()
+	lookup := job.articles == nil && s.lock != nil && time.Since(job.sharedAt) >= sharedRecheck
+	if lookup {
+		job.sharedAt = time.Now()
+	}
+	s.mu.Unlock()
+	if lookup {
+		s.loadShared(job)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.articles == nil {
+		return nil, false
+	}
+	articles := job.articles
+	if limit > 0 && len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return cloneArticles(articles), true
+}
+
+// status returns a copy of a scheduled source's status
+func (s *scheduler) status(source string) (models.ScheduledSource, bool) {
+	job := s.job(source)
+	if job == nil {
+		return models.ScheduledSource{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return job.status, true
+}
+
+// list returns the status of every job, by source name
+func (s *scheduler) list() []models.ScheduledSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sources := make([]models.ScheduledSource, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		sources = append(sources, job.status)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Source < sources[j].Source
+	})
+	return sources
+}
+
+// GetSchedule shows when each source was and will next be scraped in the
+// background
+func (ns *NewsService) GetSchedule(c *gin.Context) {
+	if ns.scheduler == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "schedule_disabled",
+			Message: "No schedule is configured, set SCHEDULE or SCHEDULE_PATH",
+		})
+		return
+	}
+	response := models.ScheduleResponse{Success: true, Sources: ns.scheduler.list()}
+	if shards := ns.scheduler.shards; shards != nil {
+		response.Worker = shards.id
+		shards.mu.Lock()
+		response.Workers = append([]string{}, shards.members...)
+		shards.mu.Unlock()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// prefetchedNews serves a scheduled source from its last scheduled scrape
+// when the schedule serves prefetched news, or from the store before the
+// first one has finished
+func (ns *NewsService) prefetchedNews(source string, limit int) ([]models.NewsArticle, bool) {
+	if ns.scheduler == nil || !ns.scheduler.prefetched || ns.scheduler.job(source) == nil {
+		return nil, false
+	}
+	if articles, ok := ns.scheduler.prefetchedNews(source, limit); ok {
+		return articles, true
+	}
+	if limit == 0 {
+		limit = defaultSourceLimit
+	}
+	articles := ns.store.List(store.Filter{Source: source, Limit: limit})
+	return articles, len(articles) > 0
+}
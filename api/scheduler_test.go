@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"testing"
+
+	"top-news/models"
+)
+
+func TestFreshSkipsPrefetched(t *testing.T) {
+	upstream := replayUpstream(t, "cnn")
+	ns := NewNewsService()
+	s, err := compileSchedule(ns, models.ScheduleConfig{Sources: map[string]string{"cnn": "5m"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.scheduler = s
+	s.jobs["cnn"].articles = []models.NewsArticle{{Title: "Prefetched", URL: "https://edition.cnn.com/prefetched", Source: "cnn"}}
+	url := ns.sources["cnn"].URL
+
+	articles, err := ns.fetchNewsFromSource("cnn", url, scrapeOptions{lane: laneInteractive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 || articles[0].Title != "Prefetched" || upstream.count.Load() != 0 {
+		t.Fatalf("got %d articles and %d upstream requests, want the prefetched article only", len(articles), upstream.count.Load())
+	}
+
+	articles, err = ns.fetchNewsFromSource("cnn", url, scrapeOptions{lane: laneInteractive, fresh: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upstream.count.Load() == 0 {
+		t.Error("fresh=true was answered with the prefetched articles")
+	}
+	for _, article := range articles {
+		if article.Title == "Prefetched" {
+			t.Error("fresh=true returned the prefetched article")
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"top-news/models"
+	"top-news/textnorm"
+)
+
+// team is a national side with the short code tickers show for it
+type team struct {
+	name, code string
+	aliases    []string
+}
+
+// teams are the sides whose scores get a ticker code. Other sides, such as
+// football clubs, are shown by name
+var teams = []team{
+	{"Bangladesh", "BAN", []string{"Bangladesh", "Tigers", "BAN"}},
+	{"India", "IND", []string{"India", "IND"}},
+	{"Pakistan", "PAK", []string{"Pakistan", "PAK"}},
+	{"Sri Lanka", "SL", []string{"Sri Lanka", "SL"}},
+	{"Afghanistan", "AFG", []string{"Afghanistan", "AFG"}},
+	{"Australia", "AUS", []string{"Australia", "AUS"}},
+	{"England", "ENG", []string{"England", "ENG"}},
+	{"New Zealand", "NZ", []string{"New Zealand", "Black Caps", "NZ"}},
+	{"South Africa", "SA", []string{"South Africa", "Proteas", "SA"}},
+	{"West Indies", "WI", []string{"West Indies", "Windies", "WI"}},
+	{"Zimbabwe", "ZIM", []string{"Zimbabwe", "ZIM"}},
+	{"Ireland", "IRE", []string{"Ireland", "IRE"}},
+	{"Netherlands", "NED", []string{"Netherlands", "NED"}},
+	{"Scotland", "SCO", []string{"Scotland", "SCO"}},
+	{"Nepal", "NEP", []string{"Nepal", "NEP"}},
+	{"UAE", "UAE", []string{"UAE"}},
+	{"Argentina", "ARG", []string{"Argentina", "ARG"}},
+	{"Brazil", "BRA", []string{"Brazil", "BRA"}},
+	{"France", "FRA", []string{"France", "FRA"}},
+	{"Germany", "GER", []string{"Germany", "GER"}},
+	{"Spain", "ESP", []string{"Spain", "ESP"}},
+	{"Portugal", "POR", []string{"Portugal", "POR"}},
+	{"Italy", "ITA", []string{"Italy", "ITA"}},
+	{"Croatia", "CRO", []string{"Croatia", "CRO"}},
+	{"Belgium", "BEL", []string{"Belgium", "BEL"}},
+	{"Morocco", "MAR", []string{"Morocco", "MAR"}},
+	{"Japan", "JPN", []string{"Japan", "JPN"}},
+	{"Saudi Arabia", "KSA", []string{"Saudi Arabia", "KSA"}},
+	{"Qatar", "QAT", []string{"Qatar", "QAT"}},
+	{"Bhutan", "BHU", []string{"Bhutan", "BHU"}},
+	{"Maldives", "MDV", []string{"Maldives", "MDV"}},
+}
+
+// Cues for which sport an article covers, matched as folded words
+var (
+	sportSections  = []string{"sport", "sports", "cricket", "football", "খেলা", "খেলাধুলা", "ক্রিকেট", "ফুটবল"}
+	cricketCues    = []string{"cricket", "odi", "t20", "t20i", "wicket", "wickets", "innings", "overs", "bcb", "ক্রিকেট"}
+	footballCues   = []string{"football", "soccer", "fifa", "uefa", "goal", "goals", "league", "bff", "ফুটবল"}
+	footballVerbs  = `beat|beats|thrash|thrashes|thrashed|rout|routs|routed|edge|edges|edged|down|downs|downed|stun|stuns|stunned|hold|holds|held|draw with|drew with`
+	footballTeam   = `[A-Z][\p{L}.'&]*(?:\s+[A-Z][\p{L}.'&]*){0,2}`
+	cricketScore   = compileCricketScore()
+	footballResult = regexp.MustCompile(`(` + footballTeam + `)\s+(\d{1,2})\s*[-–]\s*(\d{1,2})\s+(` + footballTeam + `)`)
+	footballWin    = regexp.MustCompile(`(` + footballTeam + `)\s+(?:` + footballVerbs + `)\s+(` + footballTeam + `)\s+(\d{1,2})\s*[-–]\s*(\d{1,2})`)
+)
+
+// compileCricketScore matches innings such as "Bangladesh 245/6 (48.2
+// overs)", "Tigers 245 for 6", "BAN 245-6" or "Zimbabwe all out for 180"
+func compileCricketScore() *regexp.Regexp {
+	aliases := []string{}
+	for _, t := range teams {
+		for _, alias := range t.aliases {
+			aliases = append(aliases, regexp.QuoteMeta(alias))
+		}
+	}
+	// Longest first, so New Zealand is not read as a team called New
+	sort.Slice(aliases, func(i, j int) bool { return len(aliases[i]) > len(aliases[j]) })
+	return regexp.MustCompile(`\b(` + strings.Join(aliases, "|") + `)\s+(?:(?:were|are|make|makes|made|score|scores|scored|post|posts|posted|reach|reaches|reached|finish on|finished on|slump to|slumped to|(?:were )?(?:bowled|all) out for)\s+)?` +
+		`(\d{1,3})(?:\s*(/|-|for)\s*(\d{1,2})|\s+all\s+out)?(?:\s*(?:\(|in\s+)(\d{1,2}(?:\.\d)?)\s*(?:overs|over|ov)\)?)?`)
+}
+
+// parseScore reads the match score out of a sports article's headline or
+// description, nil when it has none
+func parseScore(article models.NewsArticle) *models.Score {
+	if !isSports(article) {
+		return nil
+	}
+	sport := sportOf(article)
+	// The innings of a match are often split between headline and
+	// description
+	if sport != "football" {
+		if score := cricketScoreIn(article.Title + "\n" + article.Description); score != nil {
+			return score
+		}
+	}
+	for _, text := range []string{article.Title, article.Description} {
+		if sport != "cricket" {
+			if score := footballScoreIn(text); score != nil {
+				return score
+			}
+		}
+	}
+	return nil
+}
+
+// isSports reports whether an article is sports coverage, by its category
+// or URL section
+func isSports(article models.NewsArticle) bool {
+	category := textnorm.ForSearch(article.Category)
+	for _, section := range sportSections {
+		if category == section || strings.Contains(article.URL, "/"+section+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sportOf guesses whether a sports article is about cricket or football,
+// "" when it cannot tell
+func sportOf(article models.NewsArticle) string {
+	text := articleText(article) + textnorm.ForSearch(article.Category) + " "
+	for _, cue := range cricketCues {
+		if strings.Contains(text, " "+cue+" ") || strings.Contains(article.URL, "/"+cue+"/") {
+			return "cricket"
+		}
+	}
+	for _, cue := range footballCues {
+		if strings.Contains(text, " "+cue+" ") || strings.Contains(article.URL, "/"+cue+"/") {
+			return "football"
+		}
+	}
+	return ""
+}
+
+// cricketScoreIn finds up to two innings of different sides in text
+func cricketScoreIn(text string) *models.Score {
+	score := &models.Score{Sport: "cricket"}
+	for _, match := range cricketScore.FindAllStringSubmatch(text, -1) {
+		side, runs, separator, wickets, overs := lookupTeam(match[1]), match[2], match[3], match[4], match[5]
+		allOut := separator == "" && (strings.Contains(match[0], "all out") || strings.Contains(match[0], "bowled out"))
+		if separator == "" && !allOut && overs == "" {
+			// A bare number after a team name is not a score
+			continue
+		}
+		if n, _ := strconv.Atoi(runs); separator == "-" && n <= 10 {
+			// 2-1 is a series scoreline, not an innings
+			continue
+		}
+		if n, _ := strconv.Atoi(wickets); n > 10 {
+			continue
+		}
+		if len(score.Teams) == 1 && score.Teams[0].Team == side.name {
+			continue
+		}
+		line := runs
+		switch {
+		case allOut:
+		case wickets != "" && wickets != "10":
+			line += "/" + wickets
+		}
+		score.Teams = append(score.Teams, models.TeamScore{Team: side.name, Code: side.code, Score: line, Overs: overs})
+		if len(score.Teams) == 2 {
+			break
+		}
+	}
+	if len(score.Teams) == 0 {
+		return nil
+	}
+	lines := []string{}
+	for _, t := range score.Teams {
+		line := tickerName(t) + " " + t.Score
+		if t.Overs != "" {
+			line += " (" + t.Overs + " ov)"
+		}
+		lines = append(lines, line)
+	}
+	score.Summary = strings.Join(lines, " v ")
+	return score
+}
+
+// footballScoreIn finds a result such as "Argentina 2-1 Brazil" or
+// "Argentina beat Brazil 2-1" in text
+func footballScoreIn(text string) *models.Score {
+	var home, away, homeGoals, awayGoals string
+	if match := footballResult.FindStringSubmatch(text); match != nil {
+		home, homeGoals, awayGoals, away = match[1], match[2], match[3], match[4]
+	} else if match := footballWin.FindStringSubmatch(text); match != nil {
+		home, away, homeGoals, awayGoals = match[1], match[2], match[3], match[4]
+	} else {
+		return nil
+	}
+	first, second := lookupTeam(home), lookupTeam(away)
+	score := &models.Score{Sport: "football", Teams: []models.TeamScore{
+		{Team: first.name, Code: first.code, Score: homeGoals},
+		{Team: second.name, Code: second.code, Score: awayGoals},
+	}}
+	score.Summary = tickerName(score.Teams[0]) + " " + homeGoals + "-" + awayGoals + " " + tickerName(score.Teams[1])
+	return score
+}
+
+// lookupTeam finds a known side by any of its names. A capitalised word in
+// front of it, as in "Holders Argentina", is dropped; unknown sides keep
+// the name as written
+func lookupTeam(name string) team {
+	for _, t := range teams {
+		for _, alias := range t.aliases {
+			if name == alias || strings.HasSuffix(name, " "+alias) {
+				return t
+			}
+		}
+	}
+	return team{name: name}
+}
+
+// tickerName is a side's code, or its name when it has none
+func tickerName(t models.TeamScore) string {
+	if t.Code != "" {
+		return t.Code
+	}
+	return t.Team
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"top-news/models"
+	"top-news/store"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSuggestions is how many titles and keywords are suggested
+	// when the request sets no limit
+	defaultSuggestions = 8
+	// trendingWindow is how far back keyword completions look
+	trendingWindow = 48 * time.Hour
+	// defaultSearchResults is how many results a search returns when the
+	// request sets no limit
+	defaultSearchResults = 20
+	// snippetLength is roughly how many characters of a long description
+	// a highlight shows
+	snippetLength = 160
+)
+
+// GetSearchSuggestions completes a partial query for type-ahead search:
+// stored headlines containing it at the start of a word, and completions of
+// its last word with the words most used in recent headlines
+func (ns *NewsService) GetSearchSuggestions(c *gin.Context) {
+	query := c.Query("q")
+	words := textnorm.Words(query)
+	if len(words) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "missing_query",
+			Message: "The q query parameter is required",
+		})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultSuggestions
+	}
+
+	tenant := currentTenant(c)
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{}) {
+		if tenant.allows(article.Source) {
+			articles = append(articles, article)
+		}
+	}
+	articles = tenant.filter(articles)
+
+	// The last word may be half typed; the ones before it are complete
+	phrase := " " + strings.Join(words, " ")
+	prefix, partial := words[:len(words)-1], words[len(words)-1]
+	preceding := " " + strings.Join(prefix, " ") + " "
+
+	response := models.SearchSuggestResponse{
+		Success:  true,
+		Query:    query,
+		Titles:   []models.TitleSuggestion{},
+		Keywords: []models.KeywordSuggestion{},
+	}
+	seen := map[string]bool{}
+	counts := map[string]int{}
+	recent := time.Now().Add(-trendingWindow)
+	for _, article := range articles {
+		title := " " + strings.Join(textnorm.Words(article.Title), " ")
+		if len(response.Titles) < limit && strings.Contains(title, phrase) && !seen[title] {
+			seen[title] = true
+			response.Titles = append(response.Titles, models.TitleSuggestion{
+				Title:  article.Title,
+				Key:    articleKey(article.URL),
+				Source: article.Source,
+			})
+		}
+
+		if article.PublishedAt.Before(recent) || (len(prefix) > 0 && !strings.Contains(title+" ", preceding)) {
+			continue
+		}
+		for word := range significantWords(article.Title) {
+			if strings.HasPrefix(word, partial) {
+				counts[word]++
+			}
+		}
+	}
+
+	for word, count := range counts {
+		response.Keywords = append(response.Keywords, models.KeywordSuggestion{
+			Text:  strings.Join(append(append([]string{}, prefix...), word), " "),
+			Count: count,
+		})
+	}
+	sort.Slice(response.Keywords, func(i, j int) bool {
+		a, b := response.Keywords[i], response.Keywords[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Text < b.Text
+	})
+	if len(response.Keywords) > limit {
+		response.Keywords = response.Keywords[:limit]
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Search finds stored articles containing every word of the query in their
+// title, description or tags, over a date range (default the last 30
+// days). Title matches rank first, then newer articles. Each result shows
+// where it matched as <em> highlighted snippets and character offsets, and
+// facets count the matches per source, category, language and date bucket
+// (granularity=hour|day|week|month, default day)
+func (ns *NewsService) Search(c *gin.Context) {
+	query := c.Query("q")
+	search := newSearchQuery(query, c.Query("source"), c.Query("category"), c.Query("language"))
+	if len(search.words) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "missing_query",
+			Message: "The q query parameter is required",
+		})
+		return
+	}
+	since, until, ok := parseDateRange(c, 30*24*time.Hour)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultSearchResults
+	}
+	granularity := c.DefaultQuery("granularity", "day")
+	truncate, known := statsGranularities[granularity]
+	if !known {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_granularity",
+			Message: "granularity must be hour, day, week or month",
+		})
+		return
+	}
+
+	tenant := currentTenant(c)
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{Source: search.source, Since: since, Until: until}) {
+		if tenant.allows(article.Source) {
+			articles = append(articles, article)
+		}
+	}
+	articles = tenant.filter(articles)
+
+	type scored struct {
+		result models.SearchResult
+		score  int
+	}
+	matches := []scored{}
+	facets := models.SearchFacets{BySource: map[string]int{}, ByCategory: map[string]int{}, ByLanguage: map[string]int{}, ByDate: map[string]int{}}
+	for _, article := range articles {
+		if !search.matches(article) {
+			continue
+		}
+
+		result := models.SearchResult{
+			Key:         articleKey(article.URL),
+			Title:       article.Title,
+			Description: article.Description,
+			URL:         article.URL,
+			Source:      article.Source,
+			PublishedAt: article.PublishedAt,
+			Highlights:  []models.SearchHighlight{},
+		}
+		score := 0
+		for _, field := range []struct {
+			name, text string
+			weight     int
+		}{{"title", article.Title, 3}, {"description", article.Description, 1}} {
+			if highlight, ok := highlightField(field.name, field.text, search.words); ok {
+				result.Highlights = append(result.Highlights, highlight)
+				score += field.weight * len(highlight.Ranges)
+			}
+		}
+		matches = append(matches, scored{result: result, score: score})
+
+		facets.BySource[article.Source]++
+		facets.ByCategory[categoryOf(article)]++
+		facets.ByLanguage[languageOf(article)]++
+		facets.ByDate[truncate(article.PublishedAt.UTC()).Format(time.RFC3339)]++
+	}
+	// List returns newest first and the sort is stable, so ties stay newest
+	// first
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	response := models.SearchResponse{
+		Success: true,
+		Query:   query,
+		Since:   since,
+		Until:   until,
+		Total:   len(matches),
+		Results: []models.SearchResult{},
+		Facets:  facets,
+	}
+	for i := 0; i < len(matches) && i < limit; i++ {
+		response.Results = append(response.Results, matches[i].result)
+	}
+	response.Count = len(response.Results)
+	c.JSON(http.StatusOK, response)
+}
+
+// searchQuery is what a search matches articles on
+type searchQuery struct {
+	// words are the folded query words, all of which must appear
+	words                      map[string]bool
+	source, category, language string
+}
+
+func newSearchQuery(query, source, category, language string) searchQuery {
+	search := searchQuery{words: map[string]bool{}, source: source, category: category, language: language}
+	for _, word := range textnorm.Words(query) {
+		search.words[word] = true
+	}
+	return search
+}
+
+// matches reports whether an article has every word of the query in its
+// title, description or tags and passes the filters
+func (q searchQuery) matches(article models.NewsArticle) bool {
+	if (q.source != "" && article.Source != q.source) || (q.category != "" && categoryOf(article) != q.category) ||
+		(q.language != "" && languageOf(article) != q.language) {
+		return false
+	}
+	text := articleText(article)
+	for word := range q.words {
+		if !strings.Contains(text, " "+word+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// wordSpan is a word of a field: its character offsets and folded form
+type wordSpan struct {
+	start, end int
+	folded     string
+}
+
+// wordSpans splits text into words the way textnorm.Words does, keeping
+// where each word is in the original text
+func wordSpans(text string) []wordSpan {
+	runes := []rune(text)
+	spans := []wordSpan{}
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		inWord := i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || unicode.IsMark(runes[i]) ||
+			runes[i] == '\u200c' || runes[i] == '\u200d')
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			if folded := textnorm.ForSearch(string(runes[start:i])); folded != "" {
+				spans = append(spans, wordSpan{start: start, end: i, folded: folded})
+			}
+			start = -1
+		}
+	}
+	return spans
+}
+
+// highlightField finds the query's words in a field. Long fields get a
+// snippet around the first match instead of the whole text
+func highlightField(name, text string, wanted map[string]bool) (models.SearchHighlight, bool) {
+	ranges := []models.TextRange{}
+	for _, span := range wordSpans(text) {
+		if wanted[span.folded] {
+			ranges = append(ranges, models.TextRange{Start: span.start, End: span.end})
+		}
+	}
+	if len(ranges) == 0 {
+		return models.SearchHighlight{}, false
+	}
+
+	runes := []rune(text)
+	from, to := 0, len(runes)
+	if len(runes) > snippetLength {
+		// Start a little before the first match, on a word boundary
+		from = ranges[0].Start - snippetLength/3
+		if from < 0 {
+			from = 0
+		}
+		for from > 0 && from < ranges[0].Start && !unicode.IsSpace(runes[from-1]) {
+			from++
+		}
+		to = from + snippetLength
+		if to > len(runes) {
+			to = len(runes)
+		}
+		for to < len(runes) && to > ranges[0].End && !unicode.IsSpace(runes[to]) {
+			to--
+		}
+	}
+
+	var snippet strings.Builder
+	if from > 0 {
+		snippet.WriteString("…")
+	}
+	at := from
+	for _, r := range ranges {
+		if r.Start < from || r.End > to {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(string(runes[at:r.Start])))
+		snippet.WriteString("<em>" + html.EscapeString(string(runes[r.Start:r.End])) + "</em>")
+		at = r.End
+	}
+	snippet.WriteString(html.EscapeString(strings.TrimRightFunc(string(runes[at:to]), unicode.IsSpace)))
+	if to < len(runes) {
+		snippet.WriteString("…")
+	}
+	return models.SearchHighlight{Field: name, Snippet: strings.TrimLeftFunc(snippet.String(), unicode.IsSpace), Ranges: ranges}, true
+}
@@ -0,0 +1,443 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// savedSearchesMax caps the saved searches of one user or tenant
+	savedSearchesMax = 50
+	// searchFeedItems is how many articles a saved search feed lists
+	searchFeedItems = 50
+	// searchFeedWindow is how far back a saved search feed looks
+	searchFeedWindow = 30 * 24 * time.Hour
+)
+
+// savedSearchStore keeps every user's and tenant's saved searches. When
+// SAVED_SEARCHES_PATH is set they are persisted to that JSON file
+type savedSearchStore struct {
+	mu       sync.Mutex
+	path     string
+	searches map[string]savedSearch // id -> search
+}
+
+// savedSearch is a saved search and who owns it: "user:" and the hash of a
+// signed-in user's subject, or "tenant:" and a tenant name
+type savedSearch struct {
+	models.SavedSearch
+	Owner string `json:"owner"`
+}
+
+// newSavedSearchStore loads saved searches from SAVED_SEARCHES_PATH
+func newSavedSearchStore() *savedSearchStore {
+	s := &savedSearchStore{path: os.Getenv("SAVED_SEARCHES_PATH"), searches: map[string]savedSearch{}}
+	if s.path == "" {
+		return s
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading saved searches, starting empty: %v", err)
+		}
+		return s
+	}
+	var searches []savedSearch
+	if err := json.Unmarshal(data, &searches); err != nil {
+		log.Printf("Error decoding saved searches, starting empty: %v", err)
+		return s
+	}
+	for _, search := range searches {
+		s.searches[search.ID] = search
+	}
+	return s
+}
+
+// list returns an owner's saved searches, newest first
+func (s *savedSearchStore) list(owner string) []models.SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	searches := []models.SavedSearch{}
+	for _, search := range s.searches {
+		if search.Owner == owner {
+			searches = append(searches, search.SavedSearch)
+		}
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].CreatedAt.After(searches[j].CreatedAt)
+	})
+	return searches
+}
+
+// add saves a search for owner, unless it already has too many
+func (s *savedSearchStore) add(owner string, search models.SavedSearch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, existing := range s.searches {
+		if existing.Owner == owner {
+			count++
+		}
+	}
+	if count >= savedSearchesMax {
+		return fmt.Errorf("at most %d searches can be saved", savedSearchesMax)
+	}
+	s.searches[search.ID] = savedSearch{SavedSearch: search, Owner: owner}
+	return s.persist()
+}
+
+// remove deletes one of owner's saved searches, reporting whether it
+// existed
+func (s *savedSearchStore) remove(owner, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if search, ok := s.searches[id]; !ok || search.Owner != owner {
+		return false, nil
+	}
+	delete(s.searches, id)
+	return true, s.persist()
+}
+
+// removeOwner deletes every saved search of owner and returns how many
+// there were
+func (s *savedSearchStore) removeOwner(owner string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, search := range s.searches {
+		if search.Owner == owner {
+			delete(s.searches, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.persist()
+}
+
+// byToken finds the saved search a feed token belongs to
+func (s *savedSearchStore) byToken(token string) (savedSearch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, search := range s.searches {
+		if subtle.ConstantTimeCompare([]byte(search.Token), []byte(token)) == 1 {
+			return search, true
+		}
+	}
+	return savedSearch{}, false
+}
+
+// persist writes the saved searches to disk; callers must hold the lock
+func (s *savedSearchStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	searches := make([]savedSearch, 0, len(s.searches))
+	for _, search := range s.searches {
+		searches = append(searches, search)
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].ID < searches[j].ID
+	})
+	data, err := json.MarshalIndent(searches, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode saved searches: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create saved searches dir: %v", err)
+	}
+	// Feed tokens are secrets, so only the owner of the file may read it
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write saved searches: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace saved searches: %v", err)
+	}
+	return nil
+}
+
+// requestOwner names who the request's saved searches belong to: the
+// signed-in user, or else the request's tenant. It writes a 401 and returns
+// "" when there is neither
+func requestOwner(c *gin.Context) string {
+	if subject := currentUser(c); subject != "" {
+		return userOwner(subject)
+	}
+	if t := currentTenant(c); t != nil {
+		return "tenant:" + t.name
+	}
+	c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+		Success: false,
+		Error:   "auth_required",
+		Message: "A tenant API key or a signed-in user's bearer token is required",
+	})
+	return ""
+}
+
+// userOwner names a signed-in user as the owner of saved searches
+func userOwner(subject string) string {
+	hash := sha256.Sum256([]byte(subject))
+	return "user:" + hex.EncodeToString(hash[:])
+}
+
+// withFeedURLs fills in the feed URLs of saved searches
+func withFeedURLs(c *gin.Context, searches ...models.SavedSearch) []models.SavedSearch {
+	base := requestBaseURL(c) + "/api/v1/feeds/"
+	for i := range searches {
+		searches[i].RSSURL = base + searches[i].Token + ".rss"
+		searches[i].JSONURL = base + searches[i].Token + ".json"
+	}
+	return searches
+}
+
+// ListSavedSearches returns the user's or tenant's saved searches with
+// their feed URLs
+func (ns *NewsService) ListSavedSearches(c *gin.Context) {
+	owner := requestOwner(c)
+	if owner == "" {
+		return
+	}
+	searches := withFeedURLs(c, ns.searches.list(owner)...)
+	c.JSON(http.StatusOK, models.SavedSearchesResponse{Success: true, Data: searches, Count: len(searches)})
+}
+
+// SaveSearch saves a search for the user or tenant and returns the RSS and
+// JSON Feed URLs that follow it
+func (ns *NewsService) SaveSearch(c *gin.Context) {
+	owner := requestOwner(c)
+	if owner == "" {
+		return
+	}
+	var request struct {
+		Name     string `json:"name"`
+		Query    string `json:"query"`
+		Source   string `json:"source"`
+		Category string `json:"category"`
+		Language string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(newSearchQuery(request.Query, "", "", "").words) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: `Send {"name": "...", "query": "..."} and optionally a source, category and language`,
+		})
+		return
+	}
+	if _, exists := ns.sources[request.Source]; request.Source != "" && (!exists || !currentTenant(c).allows(request.Source)) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "source_not_found",
+			Message: "News source not found",
+		})
+		return
+	}
+	if max := ns.tiers.tier(ns.requestTier(c)).MaxAlerts; max > 0 && currentUser(c) == "" && len(ns.searches.list(owner)) >= max {
+		c.JSON(http.StatusPaymentRequired, models.ErrorResponse{
+			Success: false,
+			Error:   quotaExceeded,
+			Message: fmt.Sprintf("This tenant's tier allows at most %d saved searches; delete one or move to a higher tier", max),
+		})
+		return
+	}
+
+	id := make([]byte, 8)
+	token := make([]byte, 24)
+	rand.Read(id)
+	rand.Read(token)
+	search := models.SavedSearch{
+		ID:        hex.EncodeToString(id),
+		Name:      strings.TrimSpace(request.Name),
+		Query:     request.Query,
+		Source:    request.Source,
+		Category:  request.Category,
+		Language:  request.Language,
+		Token:     hex.EncodeToString(token),
+		CreatedAt: time.Now().UTC(),
+	}
+	if search.Name == "" {
+		search.Name = search.Query
+	}
+	if err := ns.searches.add(owner, search); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "save_failed",
+			Message: fmt.Sprintf("Failed to save search: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "search": withFeedURLs(c, search)[0]})
+}
+
+// DeleteSavedSearch removes a saved search; its feed URLs stop working
+func (ns *NewsService) DeleteSavedSearch(c *gin.Context) {
+	owner := requestOwner(c)
+	if owner == "" {
+		return
+	}
+	removed, err := ns.searches.remove(owner, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "delete_failed",
+			Message: fmt.Sprintf("Failed to delete saved search: %v", err),
+		})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "search_not_found",
+			Message: "No saved search has this ID",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// searchRSS is an RSS 2.0 feed of a saved search
+type searchRSS struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Atom    string   `xml:"xmlns:atom,attr"`
+	Channel struct {
+		Links       []atomLink      `xml:"atom:link"`
+		Title       string          `xml:"title"`
+		Link        string          `xml:"link"`
+		Description string          `xml:"description"`
+		Items       []searchRSSItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type searchRSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate,omitempty"`
+	Description string `xml:"description,omitempty"`
+	Source      string `xml:"category,omitempty"`
+}
+
+// jsonFeed is a JSON Feed 1.1 of a saved search
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title"`
+	Summary       string     `json:"summary,omitempty"`
+	Image         string     `json:"image,omitempty"`
+	DatePublished *time.Time `json:"date_published,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+}
+
+// GetSearchFeed serves a saved search as RSS (<token>.rss) or JSON Feed
+// (<token>.json): the newest matching stored articles from the last 30
+// days. The token is the only credential, so feed readers need no API key
+func (ns *NewsService) GetSearchFeed(c *gin.Context) {
+	file := c.Param("file")
+	token, format := file, ""
+	if dot := strings.LastIndex(file, "."); dot >= 0 {
+		token, format = file[:dot], file[dot+1:]
+	}
+	search, ok := ns.searches.byToken(token)
+	if !ok || (format != "rss" && format != "json") {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "feed_not_found",
+			Message: "No saved search feed has this URL",
+		})
+		return
+	}
+
+	// A tenant's feed sees only what the tenant sees; when the tenant is
+	// gone, so is the feed
+	var owner *tenant
+	if name, isTenant := strings.CutPrefix(search.Owner, "tenant:"); isTenant {
+		if owner = ns.tenants.named(name); owner == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Success: false,
+				Error:   "feed_not_found",
+				Message: "No saved search feed has this URL",
+			})
+			return
+		}
+	}
+
+	query := newSearchQuery(search.Query, search.Source, search.Category, search.Language)
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(store.Filter{Source: search.Source, Since: time.Now().Add(-searchFeedWindow)}) {
+		if owner.allows(article.Source) {
+			articles = append(articles, article)
+		}
+	}
+	matches := []models.NewsArticle{}
+	for _, article := range owner.filter(articles) {
+		if query.matches(article) {
+			matches = append(matches, article)
+			if len(matches) == searchFeedItems {
+				break
+			}
+		}
+	}
+
+	base := requestBaseURL(c)
+	self := base + c.Request.URL.Path
+	title := "Top News: " + search.Name
+	if format == "json" {
+		feed := jsonFeed{Version: "https://jsonfeed.org/version/1.1", Title: title, HomePageURL: base, FeedURL: self, Items: []jsonFeedItem{}}
+		for _, article := range matches {
+			item := jsonFeedItem{ID: article.URL, URL: article.URL, Title: article.Title, Summary: article.Description, Image: article.ImageURL, Tags: article.Tags}
+			if !article.PublishedAt.IsZero() {
+				published := article.PublishedAt
+				item.DatePublished = &published
+			}
+			feed.Items = append(feed.Items, item)
+		}
+		c.Header("Content-Type", "application/feed+json; charset=utf-8")
+		c.JSON(http.StatusOK, feed)
+		return
+	}
+
+	feed := searchRSS{Version: "2.0", Atom: "http://www.w3.org/2005/Atom"}
+	feed.Channel.Links = ns.websub.advertise(c, self)
+	if feed.Channel.Links == nil {
+		feed.Channel.Links = []atomLink{{Rel: "self", Href: self}}
+	}
+	feed.Channel.Title = title
+	feed.Channel.Link = base
+	feed.Channel.Description = fmt.Sprintf("Articles matching %q", search.Query)
+	for _, article := range matches {
+		item := searchRSSItem{Title: article.Title, Link: article.URL, GUID: article.URL, Description: article.Description, Source: article.Source}
+		if !article.PublishedAt.IsZero() {
+			item.PubDate = article.PublishedAt.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	c.XML(http.StatusOK, feed)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"top-news/secrets"
+)
+
+var (
+	secretKeysOnce sync.Once
+	secretKeyring  *secrets.Keyring
+)
+
+// secretKeys is the keyring integration credentials are encrypted with,
+// nil when SECRETS_KEY is not set
+func secretKeys() *secrets.Keyring {
+	secretKeysOnce.Do(func() {
+		keys, err := secrets.FromEnv()
+		if err != nil {
+			log.Printf("Error loading the secrets key, encrypted credentials can not be read: %v", err)
+		}
+		secretKeyring = keys
+	})
+	return secretKeyring
+}
+
+// secretEnv reads a credential from an env var, which may hold it
+// encrypted. One that can not be decrypted is logged and left unset
+func secretEnv(name string) string {
+	value, err := secretKeys().Open(os.Getenv(name))
+	if err != nil {
+		log.Printf("Error decrypting %s, leaving it unset: %v", name, err)
+		return ""
+	}
+	return value
+}
+
+// openSecrets decrypts the encrypted credentials in a JSON config
+func openSecrets(data []byte) ([]byte, error) {
+	return secretKeys().OpenJSON(data)
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// selfCheckTimeout bounds the homepage fetch of each source
+const selfCheckTimeout = 15 * time.Second
+
+// startup runs the self-check once for the service the router was built
+// with
+var startup struct {
+	once sync.Once
+	ns   *NewsService
+	err  error
+}
+
+// Startup runs the configuration self-check as STARTUP_CHECK says:
+// "degrade" (the default) checks in the background and marks failing
+// sources degraded, "strict" checks before returning and fails when
+// anything is wrong, and "off" skips it. Only the first call does anything
+func Startup() error {
+	startup.once.Do(func() {
+		startup.err = startup.ns.startupCheck(os.Getenv("STARTUP_CHECK"))
+	})
+	return startup.err
+}
+
+// CheckConfig runs the self-check right away and returns the report
+func CheckConfig() models.SelfCheckReport {
+	return startup.ns.selfCheck()
+}
+
+func (ns *NewsService) startupCheck(mode string) error {
+	switch mode {
+	case "off":
+		return nil
+	case "strict":
+		report := ns.selfCheck()
+		logSelfCheck(report)
+		if !report.OK {
+			return fmt.Errorf("startup self-check failed: %s", failedChecks(report))
+		}
+		return nil
+	case "", "degrade":
+	default:
+		log.Printf("Unknown STARTUP_CHECK %q, use degrade, strict or off; checking in the background", mode)
+	}
+	go func() {
+		logSelfCheck(ns.selfCheck())
+	}()
+	return nil
+}
+
+// selfCheck validates the configuration: every active source must be
+// reachable and its selectors must find articles, the store must be
+// writable, and configured features must have loaded. Failing sources are
+// marked degraded until a scrape succeeds
+func (ns *NewsService) selfCheck() models.SelfCheckReport {
+	checks := []models.ReadinessCheck{}
+	add := func(name string, err error, ok string) {
+		check := models.ReadinessCheck{Name: name, OK: err == nil, Message: ok}
+		if err != nil {
+			check.Message = err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	add("store", ns.store.Check(), "")
+
+	names := make([]string, 0, len(ns.sources))
+	for name, source := range ns.sources {
+		if source.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if mockMode() != nil {
+			add("source "+name, nil, "mock mode, not checked")
+			continue
+		}
+		found, err := ns.checkSource(name)
+		ns.health.record(name, found, err)
+		add("source "+name, err, fmt.Sprintf("%d articles found", found))
+	}
+
+	// Features that are configured but failed to load were logged and
+	// turned off; a deployment that relies on them should know now
+	features := []struct {
+		name   string
+		env    []string
+		loaded bool
+	}{
+		{"notifiers", []string{"NOTIFIERS", "NOTIFIERS_PATH"}, ns.notifications != nil},
+		{"tenants", []string{"TENANTS", "TENANTS_PATH"}, ns.tenants != nil},
+		{"api_keys", []string{"API_KEYS", "API_KEYS_PATH"}, len(ns.apiKeys) > 0},
+		{"jwt", []string{"JWT_ISSUER", "JWT_AUDIENCE"}, ns.jwt != nil},
+		{"schedule", []string{"SCHEDULE", "SCHEDULE_PATH"}, ns.scheduler != nil},
+		{"scrape_windows", []string{"SCRAPE_WINDOWS", "SCRAPE_WINDOWS_PATH"}, ns.windows != nil},
+	}
+	for _, feature := range features {
+		set := []string{}
+		for _, env := range feature.env {
+			if os.Getenv(env) != "" {
+				set = append(set, env)
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+		var err error
+		if !feature.loaded {
+			err = fmt.Errorf("%s is set but the configuration was rejected, see the error logged above", strings.Join(set, " and "))
+		}
+		add(feature.name, err, "")
+	}
+	if ns.jwt != nil {
+		add("jwt_keys", ns.jwt.check(), "")
+	}
+	if ns.scheduler != nil && ns.scheduler.lock != nil {
+		add("redis", ns.scheduler.lock.ping(), "")
+	}
+
+	report := models.SelfCheckReport{OK: true, Checks: checks}
+	for _, check := range checks {
+		report.OK = report.OK && check.OK
+	}
+	return report
+}
+
+// checkSource fetches a source's homepage and runs the scraper's selectors
+// over it, returning how many articles they found
+func (ns *NewsService) checkSource(name string) (int, error) {
+	source := ns.sources[name]
+	if parsed, err := url.Parse(source.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return 0, fmt.Errorf("invalid url %q", source.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	resp, err := ns.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("unreachable: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read homepage: %v", err)
+	}
+
+	// Replaying the page runs the real selectors without storing anything
+	articles, err := ns.fetchNewsFromSource(name, source.URL, scrapeOptions{limit: 5, replay: body})
+	if err != nil {
+		return 0, fmt.Errorf("selectors failed: %v", err)
+	}
+	if len(articles) == 0 {
+		return 0, fmt.Errorf("selectors found no articles on the homepage")
+	}
+	return len(articles), nil
+}
+
+// logSelfCheck writes the report, one line per check
+func logSelfCheck(report models.SelfCheckReport) {
+	for _, check := range report.Checks {
+		status := "ok"
+		if !check.OK {
+			status = "FAILED"
+		}
+		if check.Message != "" {
+			log.Printf("Self-check %s: %s (%s)", check.Name, status, check.Message)
+		} else {
+			log.Printf("Self-check %s: %s", check.Name, status)
+		}
+	}
+	if !report.OK {
+		log.Printf("Self-check found problems: %s", failedChecks(report))
+	}
+}
+
+// failedChecks names the failed checks of a report
+func failedChecks(report models.SelfCheckReport) string {
+	failed := []string{}
+	for _, check := range report.Checks {
+		if !check.OK {
+			failed = append(failed, check.Name)
+		}
+	}
+	return strings.Join(failed, ", ")
+}
@@ -1,24 +1,91 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"top-news/models"
+	"top-news/scraper"
+	"top-news/store"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gin-gonic/gin"
-	"github.com/gocolly/colly/v2"
 )
 
 // NewsService handles news fetching operations
 type NewsService struct {
-	sources map[string]models.Source
-	client  *http.Client
+	sources       map[string]models.Source
+	client        *http.Client
+	snapshots     *snapshotStore
+	store         *store.Store
+	enrichMetrics *enrichmentMetrics
+	enrichCache   *enrichmentCache
+	briefings     *briefingStore
+	digests       *digestStore
+	overrides     *overrideStore
+	filters       *filterRules
+	// factCheckFeeds holds the fact-checkers, kept apart from news sources
+	factCheckFeeds *factCheckCache
+	exports        *exportManager
+	events         *eventBus
+	notifications  *notificationDispatcher
+	chaos          *chaosConfig
+	websub         *websubPublisher
+	activityPub    *activityPubActor
+	// maxArticles caps the articles in one response
+	maxArticles int
+	// fastJSON writes news responses without encoding/json
+	fastJSON bool
+	cdn      *cdnPurger
+	tenants  *tenantRegistry
+	apiKeys  apiKeys
+	audit    *auditLog
+	jwt      *jwtVerifier
+	users    *userStore
+	searches *savedSearchStore
+	health   *sourceHealth
+	// scheduler is nil unless background scraping is configured
+	scheduler *scheduler
+	// politeness is the per-site request budget every upstream fetch
+	// shares, nil when POLITENESS_RATE is off
+	politeness *politenessBudget
+	// windows keeps fragile sites quiet at night or under an hourly cap,
+	// nil when no scrape windows are configured
+	windows *scrapeWindows
+	// special is the election or crisis mode, off until an operator
+	// starts an event
+	special *specialEvents
+	// endpointFailures keeps broken homepages and fallback feeds from
+	// being tried first
+	endpointFailures *endpointFailures
+	// terms keeps the robots directives sources put on their pages
+	terms *sourceTerms
+	// usage counts each API key's requests and rate limits them
+	usage *usageMeter
+	// tiers are the quota tiers keys and tenants can be put on, nil when
+	// none are configured
+	tiers *quotaTiers
+	// metering exports each key's usage to billing sinks, nil when none
+	// are configured
+	metering *meteringExporter
+	// scrapes caps concurrent live scrapes, nil when they are not limited
+	scrapes *scrapePool
+	// blobs keeps each source's latest response in blob storage, nil when
+	// it is not set up
+	blobs *blobSnapshots
+	// warm lists the sources served from the store after a restart until
+	// their first scrape
+	warm *warmStart
+	// pruner drops stored articles past the retention policy, nil when
+	// articles are kept forever
+	pruner *storePruner
+	// newsCache reuses recent scrapes for repeated requests
+	newsCache *newsCache
 }
 
 // NewNewsService creates a new news service instance
@@ -30,14 +97,31 @@ func NewNewsService() *NewsService {
 			DisplayName: "The Daily Star",
 			URL:         "https://www.thedailystar.net/",
 			Active:      true,
+			Timezone:    "Asia/Dhaka",
+			Locale:      "en-BD",
+			MaxPages:    3,
+			SitemapURL:  "https://www.thedailystar.net/sitemap.xml",
+			DistrictURL: "https://www.thedailystar.net/tags/{district}",
+			Fallbacks: []models.SourceFallback{
+				{Kind: "rss", URL: "https://www.thedailystar.net/frontpage/rss.xml"},
+			},
 		},
 		"cnn": {
 			Name:        "cnn",
 			DisplayName: "CNN",
 			URL:         "https://edition.cnn.com/",
 			Active:      true,
+			Timezone:    "America/New_York",
+			Locale:      "en-US",
+			MaxPages:    1,
+			Fallbacks: []models.SourceFallback{
+				{Kind: "lite", URL: "https://lite.cnn.com/"},
+				{Kind: "rss", URL: "http://rss.cnn.com/rss/edition.rss"},
+			},
 		},
 	}
+	// SOURCE_FALLBACKS can replace the feeds tried when a homepage fails
+	applySourceFallbacks(sources)
 
 	// Create HTTP client with timeout and redirect handling
 	client := &http.Client{
@@ -47,63 +131,196 @@ func NewNewsService() *NewsService {
 		},
 	}
 
-	return &NewsService{
-		sources: sources,
-		client:  client,
+	// Every upstream fetch must fall in its site's scrape window and waits
+	// for its politeness budget, then goes through the chaos transport,
+	// which does nothing until an admin turns fault injection on
+	chaos := newChaosConfig()
+	politeness := newPolitenessBudget()
+	windows := newScrapeWindows(sources)
+	client.Transport = windows.transport(politeness.transport(chaos.transport(nil)))
+
+	// Open the article store, persisted to STORE_PATH when it is set and
+	// migrated to the latest schema
+	articleStore, err := openStore(os.Getenv("STORE_PATH"))
+	if err != nil {
+		log.Printf("Error opening article store, using an empty one: %v", err)
+		articleStore, _ = store.Open("")
+	}
+
+	// Integrations react to scrape events rather than being called by the
+	// scraper. The ActivityPub actor also serves its own endpoints
+	events := newEventBus()
+	for _, target := range newIntegrations() {
+		events.subscribeIntegration(target)
+	}
+	fediverse := newActivityPubActor()
+	if fediverse != nil {
+		events.subscribeIntegration(fediverse)
+	}
+	notifications := newNotificationDispatcher()
+	if notifications != nil {
+		notifications.subscribe(events)
+	}
+	cdn := newCDNPurger()
+	if cdn != nil {
+		cdn.subscribe(events)
+	}
+	if archiver := newArchiveSubmitter(); archiver != nil {
+		archiver.subscribe(events)
 	}
+	tiers := newQuotaTiers()
+
+	ns := &NewsService{
+		sources:          sources,
+		client:           client,
+		snapshots:        newSnapshotStore(),
+		store:            articleStore,
+		enrichMetrics:    newEnrichmentMetrics(),
+		enrichCache:      newEnrichmentCache(),
+		briefings:        newBriefingStore(),
+		digests:          newDigestStore(),
+		overrides:        newOverrideStore(),
+		filters:          newFilterRules(),
+		factCheckFeeds:   newFactCheckCache(),
+		exports:          newExportManager(),
+		events:           events,
+		notifications:    notifications,
+		chaos:            chaos,
+		politeness:       politeness,
+		windows:          windows,
+		activityPub:      fediverse,
+		websub:           newWebSubPublisher(),
+		maxArticles:      maxResponseArticles(),
+		fastJSON:         fastJSONEnabled(),
+		cdn:              cdn,
+		tenants:          newTenantRegistry(sources, events, tiers),
+		apiKeys:          newAPIKeys(tiers),
+		audit:            newAuditLog(),
+		jwt:              newJWTVerifier(),
+		users:            newUserStore(),
+		searches:         newSavedSearchStore(),
+		health:           newSourceHealth(),
+		endpointFailures: newEndpointFailures(),
+		terms:            newSourceTerms(),
+		usage:            newUsageMeter(),
+		tiers:            tiers,
+		metering:         newMeteringExporter(),
+		scrapes:          newScrapePool(),
+		blobs:            newBlobSnapshots(),
+		warm:             newWarmStart(),
+		pruner:           newStorePruner(),
+		newsCache:        newNewsCache(),
+	}
+
+	// Scrape in the background on each source's schedule, if one is set
+	ns.scheduler = newScheduler(ns)
+	if ns.scheduler != nil {
+		ns.scheduler.start()
+	}
+	ns.special = newSpecialEvents(ns)
+	if complianceMode() {
+		ns.stripFullText()
+	}
+	if ns.pruner != nil {
+		go ns.pruner.run(ns.store)
+	}
+	ns.primeFromStore()
+	return ns
 }
 
 // GetAllNews fetches news from all active sources
 func (ns *NewsService) GetAllNews(c *gin.Context) {
-	var wg sync.WaitGroup
-	allNews := make(chan []models.NewsArticle, len(ns.sources))
-	
-	// Fetch news from all sources concurrently
+	inactive, ok := includeInactive(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	enrich, ok := parseEnrich(c)
+	if !ok {
+		return
+	}
+	grouped, ok := groupBySource(c)
+	if !ok {
+		return
+	}
+
+	tenant := currentTenant(c)
+	selected := map[string]models.Source{}
 	for name, source := range ns.sources {
-		if !source.Active {
-			continue
+		if (source.Active || inactive) && tenant.allows(name) {
+			selected[name] = source
 		}
-		
+	}
+
+	// Merge straight into one pre-sized buffer as sources finish, so a
+	// request never holds more than maxArticles articles
+	perSource := limit
+	if perSource == 0 {
+		perSource = defaultSourceLimit
+	}
+	buffer := newArticleBuffer(perSource*len(selected), ns.maxArticles)
+	// With group_by=source each source's result is kept apart, failures
+	// included
+	var groupsMu sync.Mutex
+	groups := map[string]models.SourceNews{}
+
+	// Fetch news from all sources concurrently
+	var wg sync.WaitGroup
+	for name, source := range selected {
 		wg.Add(1)
 		go func(sourceName string, source models.Source) {
 			defer wg.Done()
-			news, err := ns.fetchNewsFromSource(sourceName, source.URL)
+			news, err := ns.fetchNewsFromSource(sourceName, source.URL, scrapeOptions{limit: limit, enrich: enrich, lane: requestLane(c), fresh: c.Query("fresh") == "true"})
 			if err != nil {
 				log.Printf("Error fetching from %s: %v", sourceName, err)
-				allNews <- []models.NewsArticle{}
+			}
+			if grouped {
+				group := models.SourceNews{Articles: news, FetchedAt: time.Now().UTC()}
+				// Cached news was fetched by the last successful scrape
+				if last := ns.health.lastSuccess(sourceName); last != nil && err == nil {
+					group.FetchedAt = *last
+				}
+				if err != nil {
+					group.Articles, group.Error = []models.NewsArticle{}, err.Error()
+				}
+				groupsMu.Lock()
+				groups[sourceName] = group
+				groupsMu.Unlock()
 				return
 			}
-			allNews <- news
+			if err == nil {
+				buffer.add(news)
+			}
 		}(name, source)
 	}
+	wg.Wait()
 
-	// Wait for all goroutines to complete
-	go func() {
-		wg.Wait()
-		close(allNews)
-	}()
-
-	// Collect all news
-	var allArticles []models.NewsArticle
-	for news := range allNews {
-		allArticles = append(allArticles, news...)
+	if grouped {
+		ns.writeGroupedNews(c, groups)
+		return
 	}
 
+	if buffer.dropped > 0 {
+		log.Printf("Dropped %d articles over the response cap of %d", buffer.dropped, ns.maxArticles)
+	}
 	response := models.NewsResponse{
 		Success: true,
-		Data:    allArticles,
-		Count:   len(allArticles),
+		Data:    buffer.articles,
+		Count:   len(buffer.articles),
 	}
 
-	c.JSON(http.StatusOK, response)
+	ns.writeNews(c, response)
 }
 
 // GetNewsBySource fetches news from a specific source
 func (ns *NewsService) GetNewsBySource(c *gin.Context) {
 	sourceName := c.Param("source")
-	
+
 	source, exists := ns.sources[sourceName]
-	if !exists {
+	if !exists || !currentTenant(c).allows(sourceName) {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Success: false,
 			Error:   "source_not_found",
@@ -112,7 +329,12 @@ func (ns *NewsService) GetNewsBySource(c *gin.Context) {
 		return
 	}
 
-	if !source.Active {
+	inactive, ok := includeInactive(c)
+	if !ok {
+		return
+	}
+
+	if !source.Active && !inactive {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
 			Error:   "source_inactive",
@@ -120,11 +342,37 @@ func (ns *NewsService) GetNewsBySource(c *gin.Context) {
 		})
 		return
 	}
+	// Plain requests are answered from the stored snapshot when there is
+	// a fresh one
+	if ns.blobs.serve(c, sourceName) {
+		return
+	}
+
+	diag, ok := selectorDebug(c)
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	enrich, ok := parseEnrich(c)
+	if !ok {
+		return
+	}
 
-	news, err := ns.fetchNewsFromSource(sourceName, source.URL)
+	if limit > ns.maxArticles {
+		limit = ns.maxArticles
+	}
+
+	news, err := ns.fetchNewsFromSource(sourceName, source.URL, scrapeOptions{diag: diag, limit: limit, enrich: enrich, lane: requestLane(c), fresh: c.Query("fresh") == "true"})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		// Still a news response, so clients always find data and count
+		c.JSON(http.StatusInternalServerError, models.NewsResponse{
 			Success: false,
+			Data:    []models.NewsArticle{},
+			Count:   0,
+			Source:  sourceName,
 			Error:   "fetch_error",
 			Message: fmt.Sprintf("Failed to fetch news: %v", err),
 		})
@@ -132,395 +380,319 @@ func (ns *NewsService) GetNewsBySource(c *gin.Context) {
 	}
 
 	response := models.NewsResponse{
-		Success: true,
-		Data:    news,
-		Count:   len(news),
-		Source:  sourceName,
+		Success:   true,
+		Data:      news,
+		Count:     len(news),
+		Source:    sourceName,
+		FetchedAt: ns.health.lastSuccess(sourceName),
+		Debug:     diag.report(),
 	}
 
-	c.JSON(http.StatusOK, response)
+	ns.writeNews(c, response)
 }
 
-// GetAvailableSources returns all available news sources
+// GetAvailableSources returns all active news sources, plus inactive ones
+// for admins passing include_inactive=true
 func (ns *NewsService) GetAvailableSources(c *gin.Context) {
-	var sources []models.Source
-	for _, source := range ns.sources {
+	inactive, ok := includeInactive(c)
+	if !ok {
+		return
+	}
+
+	tenant := currentTenant(c)
+	sources := []models.Source{}
+	for name, source := range ns.sources {
+		if !source.Active && !inactive || !tenant.allows(name) {
+			continue
+		}
+		source.Degraded = ns.health.degraded(name)
+		if until := ns.windows.until(name); !until.IsZero() {
+			source.PausedUntil = &until
+		}
+		source.Robots = ns.terms.directives(name)
+		source.Restrictive = ns.terms.restrictive(name)
+		source.FetchedAt = ns.health.lastSuccess(name)
+		if status, ok := ns.scheduler.status(name); ok {
+			source.Schedule = status.Interval + status.Cron
+			source.NextFetchAt = status.NextRun
+		}
 		sources = append(sources, source)
 	}
 
 	response := models.SourcesResponse{
 		Success: true,
 		Sources: sources,
+		Count:   len(sources),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// fetchNewsFromSource fetches news from a specific source
-func (ns *NewsService) fetchNewsFromSource(sourceName, url string) ([]models.NewsArticle, error) {
-	// Only handle The Daily Star
-	if sourceName == "thedailystar" {
-		return ns.fetchTheDailyStarWithColly(url)
-	}
-	if sourceName == "cnn" {
-		return ns.fetchCNNWithColly(url)
-	}
-
-	return nil, fmt.Errorf("unsupported source: %s", sourceName)
+// scrapeOptions tweaks how a single scrape runs
+type scrapeOptions struct {
+	// diag records selector usage when it is not nil
+	diag *selectorDiagnostics
+	// replay is served instead of fetching the homepage; replays skip
+	// article page enrichment, pagination and rate limiting and never save
+	// snapshots
+	replay []byte
+	// limit caps the number of articles, 0 means the source default
+	limit int
+	// maxPages and pagination control crawling past the first page; an
+	// empty pagination is the scraper's default
+	maxPages   int
+	pagination string
+	// enrich picks the enrichment stages, nil means the source default and
+	// an empty list skips enrichment
+	enrich []string
+	// lane is the priority of the scrape on the worker pool; background
+	// work is batch
+	lane lane
+	// fresh skips the news cache and prefetched articles, for fresh=true
+	// and background refreshes
+	fresh bool
+	// quiet scrapes are served only, never stored or announced
+	quiet bool
 }
 
-// fetchTheDailyStarWithColly fetches news from The Daily Star using Colly
-func (ns *NewsService) fetchTheDailyStarWithColly(url string) ([]models.NewsArticle, error) {
-	// Initialize a slice to store articles
-	articles := []models.NewsArticle{}
-
-	// Create a new Colly collector
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.thedailystar.net", "thedailystar.net"),
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
-		colly.MaxDepth(1),
-	)
-
-	// Add rate limiting to avoid server blocks
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*.thedailystar.net",
-		Delay:       2 * time.Second,
-		RandomDelay: 1 * time.Second,
-	})
-
-	// Counter for article IDs
-	articleID := 0
-
-	// OnHTML callback for article containers
-	c.OnHTML(".story, .article, .news-item, .card, .pane-content, .teaser, .post, .news-block", func(e *colly.HTMLElement) {
-		if len(articles) >= 10 { // Limit to 10 articles for testing
-			return
-		}
-
-		// Extract title - get only the first/main title
-		var title string
-		titleSelectors := []string{"h1", "h2", "h3", "h4", ".title", ".headline"}
-		for _, selector := range titleSelectors {
-			title = strings.TrimSpace(e.ChildText(selector))
-			if title != "" && len(title) >= 10 {
-				break
-			}
-		}
-		
-		if title == "" {
-			return
-		}
-
-		// Clean up title - remove extra whitespace and newlines
-		title = strings.ReplaceAll(title, "\n", " ")
-		title = strings.ReplaceAll(title, "\r", " ")
-		title = strings.ReplaceAll(title, "\t", " ")
-		title = strings.Join(strings.Fields(title), " ") // Normalize whitespace
-		
-		// Truncate at first comma or period to get only the main headline
-		if idx := strings.Index(title, ","); idx != -1 {
-			title = strings.TrimSpace(title[:idx])
-		}
-		if idx := strings.Index(title, "."); idx != -1 {
-			title = strings.TrimSpace(title[:idx])
-		}
-
-		// Extract link
-		link := e.ChildAttr("a", "href")
-		if link == "" {
-			return
-		}
-		link = e.Request.AbsoluteURL(link)
-
-		// Filter out category links (e.g., /news/bangladesh)
-		pathSegments := strings.Split(strings.TrimPrefix(link, "https://www.thedailystar.net"), "/")
-		if len(pathSegments) <= 3 || pathSegments[len(pathSegments)-1] == "" {
-			return
-		}
-
-		// Skip if not a news article link
-		if !strings.Contains(link, "/news/") &&
-			!strings.Contains(link, "/bangladesh/") &&
-			!strings.Contains(link, "/world/") &&
-			!strings.Contains(link, "/business/") &&
-			!strings.Contains(link, "/sports/") &&
-			!strings.Contains(link, "/entertainment/") {
-			return
-		}
-
-		// Skip duplicates
-		for _, article := range articles {
-			if article.URL == link {
-				return
-			}
-		}
-
-		// Extract image URL
-		imageURL := ""
-		for _, attr := range []string{"src", "data-src", "data-lazy-src", "data-srcset", "data-original", "data-image", "data-lazy"} {
-			imageURL = e.ChildAttr("img", attr)
-			if imageURL != "" {
-				break
-			}
-		}
-		if imageURL == "" {
-			imageURL = e.ChildAttr("picture source", "srcset")
+// fetchNewsFromSource fetches news from a specific source
+func (ns *NewsService) fetchNewsFromSource(sourceName, url string, opts scrapeOptions) ([]models.NewsArticle, error) {
+	source := ns.sources[sourceName]
+	opts.maxPages = source.MaxPages
+	if opts.maxPages < 1 || opts.replay != nil {
+		opts.maxPages = 1
+	}
+	opts.pagination = source.PaginationSelector
+
+	mock := mockMode()
+	// Repeated requests get the last scrape until it is NEWS_CACHE_TTL old.
+	// Replays, mock data and selector debugging always scrape
+	cacheKey := newsCacheKeyFor(sourceName, url, opts)
+	cacheable := opts.replay == nil && mock == nil && opts.diag == nil
+	if cacheable && !opts.fresh {
+		if cached, ok := ns.newsCache.get(cacheKey); ok {
+			return cached, nil
 		}
-		if imageURL != "" {
-			imageURL = e.Request.AbsoluteURL(imageURL)
-			if strings.Contains(imageURL, ",") {
-				imageURL = strings.Split(imageURL, ",")[0]
-				imageURL = strings.TrimSpace(strings.Split(imageURL, " ")[0])
-			}
+	}
+	// With serve_prefetched, client requests for a scheduled source get its
+	// last scheduled scrape, or the store before that has run, and only
+	// scrape when there is neither or they ask for fresh news
+	if cacheable && !opts.fresh && opts.lane == laneInteractive && url == source.URL && opts.enrich == nil {
+		if articles, ok := ns.prefetchedNews(sourceName, opts.limit); ok {
+			return articles, nil
 		}
-
-		// Extract description
-		description := strings.TrimSpace(e.ChildText("p, .summary, .intro, .teaser-text, .excerpt, .description"))
-		if description != "" && len(description) > 200 {
-			description = description[:200] + "..."
+	}
+	// Outside its scrape window a source is served from the store and
+	// scraped once the window reopens
+	if until := ns.windows.until(sourceName); opts.replay == nil && mock == nil && !until.IsZero() {
+		ns.deferScrape(sourceName, until)
+		return ns.storedNews(sourceName, opts.limit, until)
+	}
+	// After a restart, interactive requests get the stored articles until
+	// the source's first scrape is back
+	if opts.replay == nil && mock == nil && opts.lane == laneInteractive && url == source.URL {
+		if articles, ok := ns.warmNews(sourceName, opts.limit); ok {
+			return articles, nil
 		}
-
-		// Create NewsArticle struct
-		article := models.NewsArticle{
-			ID:          fmt.Sprintf("dailystar_%d", articleID),
-			Title:       title,
-			Description: description,
-			ImageURL:    imageURL,
-			URL:         link,
-			Source:      "thedailystar",
-			PublishedAt: time.Now(),
+	}
+	// Another instance may run this source's schedule, and announces
+	// what it finds
+	if opts.lane == laneInteractive && ns.scheduler.coordinated(sourceName) {
+		opts.quiet = true
+	}
+	if !cacheable {
+		return ns.scrapeSource(sourceName, url, opts)
+	}
+	// Requests that miss the cache together share one scrape
+	return ns.newsCache.do(cacheKey, func() ([]models.NewsArticle, error) {
+		articles, err := ns.scrapeSource(sourceName, url, opts)
+		if err == nil {
+			ns.newsCache.put(cacheKey, articles)
 		}
-
-		articles = append(articles, article)
-		articleID++
-	})
-
-	// OnError callback to handle errors
-	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Error: %v, Status Code: %d", err, r.StatusCode)
+		return articles, err
 	})
+}
 
-	// Start scraping the homepage
-	err := c.Visit(url)
+// scrapeSource scrapes a source live, then stores and announces the
+// articles
+func (ns *NewsService) scrapeSource(sourceName, url string, opts scrapeOptions) ([]models.NewsArticle, error) {
+	source := ns.sources[sourceName]
+	mock := mockMode()
+	var articles []models.NewsArticle
+	var err error
+	// Sources with fallbacks go through their text-only mirrors, homepage
+	// and feeds until one works; replays and district pages have none
+	// Live scrapes wait for a worker, interactive requests first
+	release := func() {}
+	if mock == nil && opts.replay == nil {
+		release = ns.scrapes.acquire(opts.lane)
+	}
+	if mock != nil && opts.replay == nil {
+		articles, err = mockArticles(sourceName, opts.limit, mock)
+	} else if opts.replay == nil && url == source.URL && len(source.Fallbacks) > 0 {
+		articles, err = ns.scrapeWithFallbacks(source, opts)
+	} else {
+		articles, err = ns.scrapeHomepage(sourceName, url, opts)
+	}
+	release()
+	if articles == nil {
+		articles = []models.NewsArticle{}
+	}
+	// Drop horoscopes, advertorials and anything else the operator excluded
+	articles = ns.filters.filter(articles, opts.diag)
+	classify(articles)
+	for i := range articles {
+		articles[i].Key = articleKey(articles[i].URL)
+	}
+	if opts.replay == nil {
+		ns.health.record(sourceName, len(articles), err)
+	}
+	// Replays and mock articles are never stored or announced
+	if opts.replay != nil || mock != nil {
+		return articles, err
+	}
+	if opts.quiet {
+		return articles, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to visit: %v", err)
+		ns.events.publish(models.Event{Type: eventSourceFailed, Source: sourceName, Error: err.Error()})
+		return articles, err
 	}
 
-	// Wait for all requests to complete
-	c.Wait()
-
-	// Update missing image URLs by scraping individual article pages
-	//ns.updateMissingImageURLs(&articles)
-	//ns.updateMissingImageURLs(&articles)
-	ns.updateArticleDetails(&articles)
-
+	// Keep everything we scrape in the article store, and announce articles
+	// we had not seen before or whose headline or summary changed
+	discovered := []models.NewsArticle{}
+	updated := []models.NewsArticle{}
+	for _, article := range articles {
+		stored, seen := ns.store.Get(article.URL)
+		switch {
+		case !seen:
+			discovered = append(discovered, article)
+		case article.Title != "" && article.Title != stored.Title,
+			article.Description != "" && stored.Description != "" && article.Description != stored.Description:
+			updated = append(updated, article)
+		}
+	}
+	if _, err := ns.store.Save(articles...); err != nil {
+		log.Printf("Error saving %s articles to the store: %v", sourceName, err)
+	}
+	ns.events.publishArticles(eventArticleDiscovered, sourceName, discovered)
+	ns.events.publishArticles(eventArticleUpdated, sourceName, updated)
+	// Default scrapes of the homepage refresh the source's snapshot
+	if url == source.URL && opts.diag == nil && opts.enrich == nil && (opts.limit == 0 || opts.limit == defaultSourceLimit) {
+		ns.blobs.publish(sourceName, ns.overrides.apply(articles, sourceName))
+	}
 	return articles, nil
 }
 
-// fetchCNNWithColly fetches news from CNN using Colly
-func (ns *NewsService) fetchCNNWithColly(url string) ([]models.NewsArticle, error) {
-	// Initialize a slice to store articles
-	articles := []models.NewsArticle{}
-
-	// Create a new Colly collector
-	c := colly.NewCollector(
-		colly.AllowedDomains("edition.cnn.com", "cnn.com"),
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
-		colly.MaxDepth(1),
-	)
-
-	// Add rate limiting
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*.cnn.com",
-		Delay:       2 * time.Second,
-		RandomDelay: 1 * time.Second,
+// scrapeHomepage scrapes a source's homepage, or another of its listing
+// pages, with the scraper written for it, then enriches the articles from
+// their own pages
+func (ns *NewsService) scrapeHomepage(sourceName, url string, opts scrapeOptions) ([]models.NewsArticle, error) {
+	// The politeness budget spaces out requests to avoid server blocks
+	transport := ns.upstreamTransport()
+	if opts.replay != nil {
+		transport = replayTransport(opts.replay)
+	}
+	articles, page, err := scraper.Homepage(context.Background(), sourceName, url, scraper.Options{
+		Limit:      opts.limit,
+		MaxPages:   opts.maxPages,
+		Pagination: opts.pagination,
+		Transport:  transport,
+		Recorder:   diagRecorder{opts.diag},
 	})
-
-	// Counter for article IDs
-	articleID := 0
-
-	// OnHTML callback for article containers
-	c.OnHTML("a[data-link-type='article']", func(e *colly.HTMLElement) {
-		if len(articles) >= 15 { // Limit to 15 articles for CNN
-			return
-		}
-
-		link := e.Request.AbsoluteURL(e.Attr("href"))
-
-		// Skip duplicates by URL
-		for _, article := range articles {
-			if article.URL == link {
-				return
-			}
-		}
-
-		var title string
-		// CNN uses spans with data-editable="headline" for many titles
-		title = e.ChildText("span[data-editable='headline']")
-		if title == "" {
-			// Fallback for different card styles
-			title = e.ChildText(".container__headline-text")
-		}
-		title = strings.TrimSpace(title)
-
-		if title == "" || len(title) < 10 {
-			return
-		}
-
-		// Skip duplicates by Title
-		for _, article := range articles {
-			if article.Title == title {
-				return
+	if err != nil {
+		if opts.replay == nil {
+			if id := ns.snapshotFailure(sourceName, page, err); id != "" {
+				return nil, fmt.Errorf("%v (snapshot %s)", err, id)
 			}
 		}
-
-		article := models.NewsArticle{
-			ID:          fmt.Sprintf("cnn_%d", articleID),
-			Title:       title,
-			Description: "", // Description is not easily available on the homepage
-			ImageURL:    "", // Will be fetched by updateMissingImageURLs
-			URL:         link,
-			Source:      "cnn",
-			PublishedAt: time.Now(),
-		}
-
-		articles = append(articles, article)
-		articleID++
-	})
-
-	// OnError callback to handle errors
-	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Error scraping CNN: %v, Status Code: %d", err, r.StatusCode)
-	})
-
-	// Start scraping the homepage
-	err := c.Visit(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to visit CNN: %v", err)
+		return nil, err
+	}
+	for i := range articles {
+		articles[i].Description = truncateText(articles[i].Description, descriptionLength())
+	}
+	if opts.replay != nil {
+		return articles, nil
 	}
 
-	// Wait for all requests to complete
-	c.Wait()
-
-	// Update missing image URLs by scraping individual article pages
-	ns.updateArticleDetails(&articles)
-
+	if len(articles) == 0 {
+		ns.snapshotFailure(sourceName, page, nil)
+	}
+	ns.updateArticleDetails(&articles, opts)
 	return articles, nil
 }
 
-// updateArticleDetails updates empty image_url and description fields by scraping from the article URL
-func (ns *NewsService) updateArticleDetails(articles *[]models.NewsArticle) {
-	for i := range *articles {
-		article := &(*articles)[i]
-		if article.ImageURL == "" || article.Description == "" {
-			imageURL, description, err := ns.scrapeArticleDetailsFromURL(article.URL)
-			if err != nil {
-				log.Printf("Error scraping details for %s: %v", article.URL, err)
-				continue
-			}
-			if article.ImageURL == "" && imageURL != "" {
-				article.ImageURL = imageURL
-			}
-			if article.Description == "" && description != "" {
-				article.Description = description
-			}
-			// Add delay to avoid overwhelming the server
-			time.Sleep(1 * time.Second)
-		}
+// parseLimit reads the optional limit query parameter, writing a 400 and
+// returning false for ok when it is invalid
+func parseLimit(c *gin.Context) (limit int, ok bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return 0, true
 	}
-}
-
-// scrapeArticleDetailsFromURL fetches an image URL and description from the given webpage
-func (ns *NewsService) scrapeArticleDetailsFromURL(url string) (string, string, error) {
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_limit",
+			Message: "limit must be a number between 1 and 100",
+		})
+		return 0, false
 	}
+	return limit, true
+}
 
-	// Make HTTP GET request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to create request: %v", err)
+// updateArticleDetails fetches each article's own page and runs the
+// enrichment stages enabled for its source and the request over it
+func (ns *NewsService) updateArticleDetails(articles *[]models.NewsArticle, opts scrapeOptions) {
+	if opts.enrich != nil && len(opts.enrich) == 0 {
+		return
 	}
 
-	// Set User-Agent to avoid being blocked
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	for i := range *articles {
+		article := &(*articles)[i]
+		source := ns.sources[article.Source]
+		stages := ns.terms.allowedStages(source.Name, enabledStages(source, opts.enrich))
+		if len(stages) == 0 {
+			continue
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to fetch URL %s: %v", url, err)
-	}
-	defer resp.Body.Close()
+		// Reuse earlier results for pages we enriched recently
+		if cached, ok := ns.enrichCache.get(article.URL, stages); ok {
+			useCached(article, cached, stages, opts.diag, "enrichment cache")
+			continue
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+		// Revalidate pages we enriched before instead of re-enriching them
+		stale, hasStale := ns.enrichCache.stale(article.URL, stages)
 
-	// Parse HTML using goquery
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to parse HTML: %v", err)
-	}
-
-	// --- Scrape Image URL ---
-	imageURL := ""
-	doc.Find("picture img").Each(func(i int, s *goquery.Selection) {
-		if src, exists := s.Attr("data-srcset"); exists && imageURL == "" {
-			imageURL = src
+		page, validators, err := ns.fetchArticlePage(article.URL, source, stale.validators)
+		if err != nil {
+			log.Printf("Error scraping details for %s: %v", article.URL, err)
+			continue
 		}
-	})
-	if imageURL == "" {
-		doc.Find("span.lg-gallery").Each(func(i int, s *goquery.Selection) {
-			if src, exists := s.Attr("data-src"); exists && imageURL == "" {
-				imageURL = src
-			}
-		})
-	}
-	if imageURL == "" {
-		doc.Find("meta[property='og:image']").Each(func(i int, s *goquery.Selection) {
-			if content, exists := s.Attr("content"); exists && imageURL == "" {
-				imageURL = content
-			}
-		})
-	}
-	if imageURL == "" {
-		doc.Find("article img, div.section-media img").Each(func(i int, s *goquery.Selection) {
-			if src, exists := s.Attr("src"); exists && imageURL == "" {
-				imageURL = src
-			}
-		})
-	}
-
-	// --- Scrape Description ---
-	description := ""
-	doc.Find("meta[property='og:description']").Each(func(i int, s *goquery.Selection) {
-		if content, exists := s.Attr("content"); exists && description == "" {
-			description = strings.TrimSpace(content)
+		if page == nil && hasStale {
+			ns.enrichCache.refresh(article.URL, validators)
+			useCached(article, stale, stages, opts.diag, "enrichment cache (unchanged page)")
+		} else if page != nil {
+			methods := ns.enrichArticle(page, article, stages, opts.diag)
+			ns.enrichCache.put(*article, stages, methods, validators)
 		}
-	})
-	if description == "" {
-		doc.Find("meta[name='description']").Each(func(i int, s *goquery.Selection) {
-			if content, exists := s.Attr("content"); exists && description == "" {
-				description = strings.TrimSpace(content)
-			}
-		})
-	}
-	if description == "" {
-		doc.Find(".article__content p, .article-body p, .paragraph, .zn-body__paragraph").Each(func(i int, s *goquery.Selection) {
-			if pText := strings.TrimSpace(s.Text()); len(pText) > 50 && description == "" {
-				description = pText
-			}
-		})
 	}
+}
 
-	if description != "" && len(description) > 200 {
-		description = description[:200] + "..."
+// useCached copies the stages' fields from a cache entry, recording the
+// method that originally filled each one
+func useCached(article *models.NewsArticle, cached enrichmentCacheEntry, stages []enrichmentStage, diag *selectorDiagnostics, label string) {
+	for _, stage := range stages {
+		stage.copy(article, cached.article)
+		if method, ok := cached.methods[stage.field]; ok {
+			diag.field(article.ID, stage.field, label+": "+method)
+		}
 	}
-
-	return imageURL, description, nil
 }
 
 // ServiceHealth is a simple exported function to satisfy Vercel's requirement
 func ServiceHealth() string {
 	return "News service is healthy"
-} 
\ No newline at end of file
+}
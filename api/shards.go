@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// shardHeartbeat is how often a worker renews its place in the ring
+	shardHeartbeat = 10 * time.Second
+	// shardExpiry drops workers that stopped renewing
+	shardExpiry = 30 * time.Second
+	// shardMaxFailures is how many scheduled scrapes in a row may fail
+	// before a worker hands its sources to the others
+	shardMaxFailures = 3
+	// shardSitOut is how long an unhealthy worker stays out of the ring
+	shardSitOut = 5 * time.Minute
+)
+
+// shardRing splits scheduled sources between workers with rendezvous
+// hashing: each source goes to the worker with the highest hash of
+// worker and source. When a worker joins or leaves, only its own sources
+// move. Membership is kept in a Redis sorted set scored by expiry time
+type shardRing struct {
+	redis *redisLock
+	id    string
+	key   string
+
+	mu       sync.Mutex
+	members  []string
+	failures int
+	// sitOutUntil is when a worker that left for being unhealthy rejoins
+	sitOutUntil time.Time
+}
+
+// newShardRing turns on sharding when SHARDING is true. It needs REDIS_URL
+func newShardRing(redis *redisLock) *shardRing {
+	if os.Getenv("SHARDING") != "true" {
+		return nil
+	}
+	if redis == nil {
+		log.Printf("SHARDING needs REDIS_URL, every instance runs every scheduled scrape")
+		return nil
+	}
+	return &shardRing{redis: redis, id: redis.instance, key: redis.prefix + "workers"}
+}
+
+// run keeps this worker's membership fresh and reloads the ring
+func (r *shardRing) run() {
+	for {
+		r.refresh()
+		time.Sleep(shardHeartbeat)
+	}
+}
+
+// refresh renews (or withdraws) this worker and reads the live members
+func (r *shardRing) refresh() {
+	now := time.Now()
+	r.mu.Lock()
+	healthy := now.After(r.sitOutUntil)
+	r.mu.Unlock()
+
+	var err error
+	if healthy {
+		expires := strconv.FormatInt(now.Add(shardExpiry).UnixMilli(), 10)
+		_, err = r.redis.do("ZADD", r.key, expires, r.id)
+	} else {
+		_, err = r.redis.do("ZREM", r.key, r.id)
+	}
+	if err == nil {
+		_, err = r.redis.do("ZREMRANGEBYSCORE", r.key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	}
+	var reply interface{}
+	if err == nil {
+		reply, err = r.redis.do("ZRANGE", r.key, "0", "-1")
+	}
+	if err != nil {
+		// Keep the last known ring; the scrape lock still stops duplicates
+		log.Printf("Error refreshing the shard ring: %v", err)
+		return
+	}
+
+	members := []string{}
+	if items, ok := reply.([]interface{}); ok {
+		for _, item := range items {
+			if member, ok := item.(string); ok {
+				members = append(members, member)
+			}
+		}
+	}
+	sort.Strings(members)
+	r.mu.Lock()
+	r.members = members
+	r.mu.Unlock()
+}
+
+// owner returns the worker a source belongs to, "" while the ring is
+// unknown
+func (r *shardRing) owner(source string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	best, bestScore := "", uint64(0)
+	for _, member := range r.members {
+		sum := sha256.Sum256([]byte(member + "/" + source))
+		if score := binary.BigEndian.Uint64(sum[:8]); best == "" || score > bestScore {
+			best, bestScore = member, score
+		}
+	}
+	return best
+}
+
+// owns reports whether this worker should scrape a source. Until the ring
+// is known every worker does
+func (r *shardRing) owns(source string) bool {
+	owner := r.owner(source)
+	return owner == "" || owner == r.id
+}
+
+// record counts failed scheduled scrapes. After too many in a row the
+// worker leaves the ring for a while so its sources move to healthy
+// workers, unless it is the only worker
+func (r *shardRing) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.failures >= shardMaxFailures && len(r.members) > 1 {
+		log.Printf("Worker %s failed %d scrapes in a row, handing its sources to the others for %s", r.id, r.failures, shardSitOut)
+		r.failures = 0
+		r.sitOutUntil = time.Now().Add(shardSitOut)
+		go r.refresh()
+	}
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// googleServiceAccount is the part of a service account key file we use
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleTokenSource exchanges a signed service account JWT for OAuth
+// access tokens, reusing each token until shortly before it expires
+type googleTokenSource struct {
+	mu      sync.Mutex
+	account googleServiceAccount
+	key     *rsa.PrivateKey
+	scope   string
+	client  *http.Client
+	token   string
+	expires time.Time
+}
+
+// loadServiceAccount reads service account credentials from
+// GOOGLE_SERVICE_ACCOUNT_JSON (the key file's contents, optionally base64
+// encoded) or the file at GOOGLE_APPLICATION_CREDENTIALS
+func loadServiceAccount(scope string) (*googleTokenSource, error) {
+	data := []byte(strings.TrimSpace(secretEnv("GOOGLE_SERVICE_ACCOUNT_JSON")))
+	if len(data) > 0 && data[0] != '{' {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("GOOGLE_SERVICE_ACCOUNT_JSON is neither JSON nor base64: %v", err)
+		}
+		data = decoded
+	}
+	if len(data) == 0 {
+		path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		if path == "" {
+			return nil, fmt.Errorf("set GOOGLE_SERVICE_ACCOUNT_JSON or GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read credentials: %v", err)
+		}
+	}
+
+	data, err := openSecrets(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %v", err)
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials: %v", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("credentials have no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+
+	return &googleTokenSource{
+		account: account,
+		key:     key,
+		scope:   scope,
+		client:  &http.Client{Timeout: 20 * time.Second},
+	}, nil
+}
+
+// accessToken returns a valid OAuth access token
+func (s *googleTokenSource) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + encode(map[string]interface{}{
+		"iss":   s.account.ClientEmail,
+		"scope": s.scope,
+		"aud":   s.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %v", err)
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	resp, err := s.client.PostForm(s.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %v", err)
+	}
+	s.token = token.AccessToken
+	s.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+// sheetsIntegration appends new articles as rows of a Google Sheet
+type sheetsIntegration struct {
+	tokens        *googleTokenSource
+	spreadsheetID string
+	sheetRange    string
+	filter        integrationFilter
+	client        *http.Client
+}
+
+// newSheetsIntegration configures the Google Sheets exporter from
+// GOOGLE_SHEETS_ID, GOOGLE_SHEETS_RANGE (default Sheet1!A1) and
+// GOOGLE_SHEETS_FILTER, returning nil when it is not configured
+func newSheetsIntegration() *sheetsIntegration {
+	spreadsheetID := os.Getenv("GOOGLE_SHEETS_ID")
+	if spreadsheetID == "" {
+		return nil
+	}
+	tokens, err := loadServiceAccount("https://www.googleapis.com/auth/spreadsheets")
+	if err != nil {
+		log.Printf("Google Sheets export is disabled: %v", err)
+		return nil
+	}
+	sheetRange := os.Getenv("GOOGLE_SHEETS_RANGE")
+	if sheetRange == "" {
+		sheetRange = "Sheet1!A1"
+	}
+	return &sheetsIntegration{
+		tokens:        tokens,
+		spreadsheetID: spreadsheetID,
+		sheetRange:    sheetRange,
+		filter:        parseIntegrationFilter(os.Getenv("GOOGLE_SHEETS_FILTER")),
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *sheetsIntegration) name() string {
+	return "google-sheets"
+}
+
+// publish appends one row per matching article, in exportColumns order
+func (s *sheetsIntegration) publish(articles []models.NewsArticle) error {
+	rows := [][]string{}
+	for _, article := range articles {
+		if s.filter.matches(article) {
+			rows = append(rows, exportRow(article))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	token, err := s.tokens.accessToken()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]interface{}{"values": rows})
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %v", err)
+	}
+	endpoint := fmt.Sprintf("https://sheets.googleapis.com/v4/spreadsheets/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		url.PathEscape(s.spreadsheetID), url.PathEscape(s.sheetRange))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("append request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("sheets API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
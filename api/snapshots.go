@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/scraper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotStore keeps the raw HTML of failed scrapes on disk so selector
+// problems can be reproduced offline
+type snapshotStore struct {
+	dir       string
+	retention time.Duration
+}
+
+// newSnapshotStore configures snapshot storage from SNAPSHOT_DIR and
+// SNAPSHOT_RETENTION. Setting SNAPSHOT_DIR to "off" disables capturing
+func newSnapshotStore() *snapshotStore {
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "off" {
+		return nil
+	}
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "top-news-snapshots")
+	}
+
+	retention := 7 * 24 * time.Hour
+	if value := os.Getenv("SNAPSHOT_RETENTION"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Invalid SNAPSHOT_RETENTION %q, using %v: %v", value, retention, err)
+		} else {
+			retention = parsed
+		}
+	}
+
+	return &snapshotStore{dir: dir, retention: retention}
+}
+
+// save writes the page body and its metadata, returning the snapshot ID
+func (s *snapshotStore) save(meta models.Snapshot, body []byte) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot dir: %v", err)
+	}
+	s.prune()
+
+	meta.ID = meta.Source + "-" + strconv.FormatInt(meta.CapturedAt.UnixNano(), 36)
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot metadata: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, meta.ID+".html"), body, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, meta.ID+".json"), metaJSON, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write snapshot metadata: %v", err)
+	}
+
+	return meta.ID, nil
+}
+
+// prune removes snapshots older than the retention period
+func (s *snapshotStore) prune() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-s.retention)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".html") && !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, name))
+	}
+}
+
+// snapshotFailure saves the captured page of a scrape that errored or found
+// no articles and returns the snapshot ID, or "" when nothing was saved
+func (ns *NewsService) snapshotFailure(sourceName string, page *scraper.Page, scrapeErr error) string {
+	if ns.snapshots == nil || len(page.Body) == 0 {
+		return ""
+	}
+
+	reason := "no articles found"
+	if scrapeErr != nil {
+		reason = scrapeErr.Error()
+	}
+
+	id, err := ns.snapshots.save(models.Snapshot{
+		Source:     sourceName,
+		URL:        page.URL,
+		StatusCode: page.Status,
+		Reason:     reason,
+		CapturedAt: time.Now().UTC(),
+	}, page.Body)
+	if err != nil {
+		log.Printf("Error saving snapshot for %s: %v", sourceName, err)
+		return ""
+	}
+
+	log.Printf("Saved snapshot %s for %s: %s", id, sourceName, reason)
+	return id
+}
+
+// list returns the metadata of every stored snapshot, newest first
+func (s *snapshotStore) list() ([]models.Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []models.Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot dir: %v", err)
+	}
+
+	snapshots := []models.Snapshot{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		meta, _, err := s.load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, meta)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CapturedAt.After(snapshots[j].CapturedAt)
+	})
+	return snapshots, nil
+}
+
+// load reads a snapshot's metadata and HTML body
+func (s *snapshotStore) load(id string) (models.Snapshot, []byte, error) {
+	var meta models.Snapshot
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return meta, nil, os.ErrNotExist
+	}
+
+	metaJSON, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return meta, nil, err
+	}
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return meta, nil, fmt.Errorf("failed to decode snapshot metadata: %v", err)
+	}
+
+	body, err := os.ReadFile(filepath.Join(s.dir, id+".html"))
+	if err != nil {
+		return meta, nil, err
+	}
+	return meta, body, nil
+}
+
+// replayTransport answers every request with the same stored HTML page
+type replayTransport []byte
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewReader(t)),
+		ContentLength: int64(len(t)),
+		Request:       req,
+	}, nil
+}
+
+// ListSnapshots returns the stored snapshots of failed scrapes
+func (ns *NewsService) ListSnapshots(c *gin.Context) {
+	if ns.snapshots == nil {
+		c.JSON(http.StatusOK, models.SnapshotsResponse{Success: true, Snapshots: []models.Snapshot{}, Count: 0})
+		return
+	}
+
+	snapshots, err := ns.snapshots.list()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "snapshot_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SnapshotsResponse{Success: true, Snapshots: snapshots, Count: len(snapshots)})
+}
+
+// ReplaySnapshot re-runs the current selector logic of the snapshot's
+// source against its stored HTML, without touching the network
+func (ns *NewsService) ReplaySnapshot(c *gin.Context) {
+	if ns.snapshots == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "snapshot_not_found",
+			Message: "Snapshot capturing is disabled",
+		})
+		return
+	}
+
+	meta, body, err := ns.snapshots.load(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "snapshot_not_found",
+			Message: "Snapshot not found",
+		})
+		return
+	}
+
+	diag := newSelectorDiagnostics()
+	news, err := ns.fetchNewsFromSource(meta.Source, meta.URL, scrapeOptions{diag: diag, replay: body})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "replay_error",
+			Message: fmt.Sprintf("Failed to replay snapshot: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReplayResponse{
+		Success:  true,
+		Snapshot: meta,
+		Data:     news,
+		Count:    len(news),
+		Debug:    diag.report(),
+	})
+}
@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// socialDedupWindow is how long a posted article is remembered, so a story
+// that drops off and reappears on a homepage is not posted twice
+const socialDedupWindow = 7 * 24 * time.Hour
+
+// socialAccount posts a headline to one social network account
+type socialAccount interface {
+	name() string
+	post(article models.NewsArticle) error
+}
+
+// socialPoster sends the top new articles of each scrape to a social
+// account, skipping ones it already posted and staying under an hourly cap
+type socialPoster struct {
+	mu        sync.Mutex
+	account   socialAccount
+	filter    integrationFilter
+	perScrape int
+	perHour   int
+	statePath string
+	posted    map[string]time.Time // article key -> when it was posted
+}
+
+// newSocialPosters configures posting to X and a Facebook page. Shared
+// settings are SOCIAL_FILTER, SOCIAL_PER_SCRAPE (default 1),
+// SOCIAL_MAX_PER_HOUR (default 5) and SOCIAL_STATE_DIR, where posted
+// articles are remembered across restarts
+func newSocialPosters() []integration {
+	accounts := []socialAccount{}
+	if x := newXAccount(); x != nil {
+		accounts = append(accounts, x)
+	}
+	if facebook := newFacebookPage(); facebook != nil {
+		accounts = append(accounts, facebook)
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	perScrape := envInt("SOCIAL_PER_SCRAPE", 1)
+	perHour := envInt("SOCIAL_MAX_PER_HOUR", 5)
+	filter := parseIntegrationFilter(os.Getenv("SOCIAL_FILTER"))
+	dir := os.Getenv("SOCIAL_STATE_DIR")
+
+	posters := []integration{}
+	for _, account := range accounts {
+		poster := &socialPoster{
+			account:   account,
+			filter:    filter,
+			perScrape: perScrape,
+			perHour:   perHour,
+			posted:    map[string]time.Time{},
+		}
+		if dir != "" {
+			poster.statePath = filepath.Join(dir, account.name()+".json")
+			poster.load()
+		}
+		posters = append(posters, poster)
+	}
+	return posters
+}
+
+// envInt reads a non-negative integer env var, logging and using fallback
+// when it is invalid
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// load reads the posted articles from statePath
+func (p *socialPoster) load() {
+	data, err := os.ReadFile(p.statePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s posting history, starting empty: %v", p.account.name(), err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &p.posted); err != nil {
+		log.Printf("Error decoding %s posting history, starting empty: %v", p.account.name(), err)
+		p.posted = map[string]time.Time{}
+	}
+}
+
+// persist writes the posted articles to statePath; callers must hold the lock
+func (p *socialPoster) persist() error {
+	if p.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(p.posted)
+	if err != nil {
+		return fmt.Errorf("failed to encode posting history: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.statePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create posting history dir: %v", err)
+	}
+	tmp := p.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write posting history: %v", err)
+	}
+	if err := os.Rename(tmp, p.statePath); err != nil {
+		return fmt.Errorf("failed to replace posting history: %v", err)
+	}
+	return nil
+}
+
+func (p *socialPoster) name() string {
+	return p.account.name()
+}
+
+// publish posts the first perScrape matching articles that were not posted
+// before, as long as the hourly cap allows
+func (p *socialPoster) publish(articles []models.NewsArticle) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	lastHour := 0
+	for key, postedAt := range p.posted {
+		if now.Sub(postedAt) > socialDedupWindow {
+			delete(p.posted, key)
+		} else if now.Sub(postedAt) < time.Hour {
+			lastHour++
+		}
+	}
+
+	sent := 0
+	var firstErr error
+	for _, article := range articles {
+		if sent == p.perScrape {
+			break
+		}
+		key := articleKey(article.URL)
+		if _, done := p.posted[key]; done || !p.filter.matches(article) || article.IsSponsored {
+			continue
+		}
+		if lastHour >= p.perHour {
+			log.Printf("Skipping %s posts, the cap of %d an hour is reached", p.account.name(), p.perHour)
+			break
+		}
+		if err := p.account.post(article); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		p.posted[key] = now
+		lastHour++
+		sent++
+	}
+	if err := p.persist(); err != nil {
+		log.Printf("Error saving %s posting history: %v", p.account.name(), err)
+	}
+	return firstErr
+}
+
+// X counts every link as 23 characters, whatever its length
+const (
+	xPostLength = 280
+	xLinkLength = 23
+)
+
+// xAccount posts to X through the v2 API with OAuth 1.0a user credentials
+type xAccount struct {
+	apiKey, apiSecret        string
+	accessToken, tokenSecret string
+	client                   *http.Client
+}
+
+// newXAccount configures X from X_API_KEY, X_API_SECRET, X_ACCESS_TOKEN
+// and X_ACCESS_SECRET, returning nil when they are not all set
+func newXAccount() *xAccount {
+	account := &xAccount{
+		apiKey:      secretEnv("X_API_KEY"),
+		apiSecret:   secretEnv("X_API_SECRET"),
+		accessToken: secretEnv("X_ACCESS_TOKEN"),
+		tokenSecret: secretEnv("X_ACCESS_SECRET"),
+		client:      &http.Client{Timeout: 20 * time.Second},
+	}
+	set := 0
+	for _, value := range []string{account.apiKey, account.apiSecret, account.accessToken, account.tokenSecret} {
+		if value != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return nil
+	}
+	if set < 4 {
+		log.Printf("Posting to X is disabled: set X_API_KEY, X_API_SECRET, X_ACCESS_TOKEN and X_ACCESS_SECRET")
+		return nil
+	}
+	return account
+}
+
+func (x *xAccount) name() string {
+	return "x"
+}
+
+// xPostText is the headline, shortened to leave room for the link
+func xPostText(article models.NewsArticle) string {
+	title := []rune(strings.TrimSpace(article.Title))
+	if room := xPostLength - xLinkLength - 1; len(title) > room {
+		title = append(title[:room-1], '…')
+	}
+	return string(title) + " " + article.URL
+}
+
+func (x *xAccount) post(article models.NewsArticle) error {
+	const endpoint = "https://api.twitter.com/2/tweets"
+	payload, err := json.Marshal(map[string]string{"text": xPostText(article)})
+	if err != nil {
+		return fmt.Errorf("failed to encode post: %v", err)
+	}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(string(payload)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", x.authorization("POST", endpoint))
+	return readSocialResponse("X", x.client, req)
+}
+
+// authorization builds an OAuth 1.0a header. JSON bodies are not part of
+// the signature, only the oauth_ parameters are
+func (x *xAccount) authorization(method, endpoint string) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	params := map[string]string{
+		"oauth_consumer_key":     x.apiKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_token":            x.accessToken,
+		"oauth_version":          "1.0",
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, oauthEscape(name)+"="+oauthEscape(params[name]))
+	}
+	base := method + "&" + oauthEscape(endpoint) + "&" + oauthEscape(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(oauthEscape(x.apiSecret)+"&"+oauthEscape(x.tokenSecret)))
+	mac.Write([]byte(base))
+	params["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	header := []string{}
+	for _, name := range append(names, "oauth_signature") {
+		header = append(header, fmt.Sprintf(`%s="%s"`, name, oauthEscape(params[name])))
+	}
+	return "OAuth " + strings.Join(header, ", ")
+}
+
+// oauthEscape percent-encodes per RFC 3986, as OAuth 1.0a requires
+func oauthEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// facebookPage posts links to a Facebook page through the Graph API
+type facebookPage struct {
+	pageID string
+	token  string
+	client *http.Client
+}
+
+// newFacebookPage configures Facebook from FACEBOOK_PAGE_ID and
+// FACEBOOK_PAGE_TOKEN, a page access token with pages_manage_posts
+func newFacebookPage() *facebookPage {
+	pageID := os.Getenv("FACEBOOK_PAGE_ID")
+	token := secretEnv("FACEBOOK_PAGE_TOKEN")
+	if pageID == "" && token == "" {
+		return nil
+	}
+	if pageID == "" || token == "" {
+		log.Printf("Posting to Facebook is disabled: set both FACEBOOK_PAGE_ID and FACEBOOK_PAGE_TOKEN")
+		return nil
+	}
+	return &facebookPage{pageID: pageID, token: token, client: &http.Client{Timeout: 20 * time.Second}}
+}
+
+func (f *facebookPage) name() string {
+	return "facebook"
+}
+
+func (f *facebookPage) post(article models.NewsArticle) error {
+	endpoint := "https://graph.facebook.com/v19.0/" + url.PathEscape(f.pageID) + "/feed"
+	form := url.Values{
+		"message":      {strings.TrimSpace(article.Title)},
+		"link":         {article.URL},
+		"access_token": {f.token},
+	}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return readSocialResponse("Facebook", f.client, req)
+}
+
+// readSocialResponse sends a post request and turns failures into errors
+func readSocialResponse(network string, client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %v", network, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %d: %s", network, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gocolly/colly/v2"
+)
+
+// TestSource scrapes a candidate source config once and reports what its
+// selectors matched, without adding the source to the service
+func (ns *NewsService) TestSource(c *gin.Context) {
+	var req models.SourceTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Invalid source config: %v", err),
+		})
+		return
+	}
+
+	if _, err := outbound().CheckURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_url",
+			Message: fmt.Sprintf("url must be an absolute http or https URL on a public address: %v", err),
+		})
+		return
+	}
+
+	if strings.TrimSpace(req.Selectors.Article) == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_selectors",
+			Message: "selectors.article is required",
+		})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+
+	articles, hits, err := ns.scrapeWithSelectors(req.URL, req.Selectors, limit)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Success: false,
+			Error:   "fetch_error",
+			Message: fmt.Sprintf("Failed to scrape candidate source: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SourceTestResponse{
+		Success:      true,
+		Data:         articles,
+		Count:        len(articles),
+		SelectorHits: hits,
+	})
+}
+
+// scrapeWithSelectors runs a single-page scrape driven entirely by the given
+// selectors and counts how many article elements each selector matched in
+func (ns *NewsService) scrapeWithSelectors(pageURL string, selectors models.Selectors, limit int) ([]models.NewsArticle, map[string]int, error) {
+	articles := []models.NewsArticle{}
+	hits := map[string]int{
+		"article":     0,
+		"title":       0,
+		"link":        0,
+		"image":       0,
+		"description": 0,
+	}
+
+	c := colly.NewCollector(
+		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
+		colly.MaxDepth(1),
+	)
+	c.SetRequestTimeout(15 * time.Second)
+	// Candidate URLs come from the caller, so they go through the
+	// outbound policy like every user-supplied URL
+	c.WithTransport(outboundTransport())
+	c.SetRedirectHandler(outbound().CheckRedirect)
+
+	c.OnHTML(selectors.Article, func(e *colly.HTMLElement) {
+		hits["article"]++
+
+		// Title defaults to the element's own text
+		title := strings.TrimSpace(e.Text)
+		if selectors.Title != "" {
+			title = strings.TrimSpace(e.ChildText(selectors.Title))
+		}
+		title = strings.Join(strings.Fields(title), " ")
+		if title != "" {
+			hits["title"]++
+		}
+
+		// Link defaults to the element itself, then to its first anchor
+		link := ""
+		if selectors.Link != "" {
+			link = e.ChildAttr(selectors.Link, "href")
+		} else if link = e.Attr("href"); link == "" {
+			link = e.ChildAttr("a", "href")
+		}
+		if link != "" {
+			hits["link"]++
+			link = e.Request.AbsoluteURL(link)
+		}
+
+		imageURL := ""
+		if selectors.Image != "" {
+			for _, attr := range []string{"src", "data-src", "data-srcset", "srcset", "content"} {
+				imageURL = e.ChildAttr(selectors.Image, attr)
+				if imageURL != "" {
+					break
+				}
+			}
+			if imageURL != "" {
+				hits["image"]++
+				imageURL = e.Request.AbsoluteURL(strings.Fields(imageURL)[0])
+			}
+		}
+
+		description := ""
+		if selectors.Description != "" {
+			description = strings.TrimSpace(e.ChildText(selectors.Description))
+			if description != "" {
+				hits["description"]++
+			}
+			description = truncateText(description, descriptionLength())
+		}
+
+		if title == "" || link == "" || len(articles) >= limit {
+			return
+		}
+
+		// Skip duplicates
+		for _, article := range articles {
+			if article.URL == link {
+				return
+			}
+		}
+
+		articles = append(articles, models.NewsArticle{
+			ID:          fmt.Sprintf("preview_%d", len(articles)),
+			Title:       title,
+			Description: description,
+			ImageURL:    imageURL,
+			URL:         link,
+			Source:      "preview",
+			PublishedAt: time.Now(),
+		})
+	})
+
+	c.OnError(func(r *colly.Response, err error) {
+		log.Printf("Error scraping candidate source %s: %v, Status Code: %d", pageURL, err, r.StatusCode)
+	})
+
+	if err := c.Visit(pageURL); err != nil {
+		return nil, nil, fmt.Errorf("failed to visit: %v", err)
+	}
+	c.Wait()
+
+	return articles, hits, nil
+}
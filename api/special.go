@@ -0,0 +1,415 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSpecialInterval is how often a special event's sources are
+	// scraped when the operator sets no interval
+	defaultSpecialInterval = 2 * time.Minute
+	// liveLookback is how long before an event started its live page
+	// reaches back, so it opens with the build-up
+	liveLookback = 24 * time.Hour
+	// defaultLiveArticles is how many articles the live page lists when
+	// the request sets no limit
+	defaultLiveArticles = 50
+)
+
+var eventSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// specialEvents runs the election or crisis mode: while an event is on,
+// its sources are scraped every few minutes, each new article matching its
+// keywords is pushed on its own as breaking news, and the matches make up
+// the event's live page. There is one event at a time; the last one stays
+// readable after it ends. With SPECIAL_EVENT_PATH set it survives restarts
+type specialEvents struct {
+	ns   *NewsService
+	path string
+
+	mu    sync.Mutex
+	event *models.SpecialEvent
+	// keywords are the event's keywords folded like article text
+	keywords []string
+	// stop ends the refresh loop of the running event
+	stop chan struct{}
+}
+
+// newSpecialEvents restores the event saved at SPECIAL_EVENT_PATH and
+// resumes it if it has not ended
+func newSpecialEvents(ns *NewsService) *specialEvents {
+	s := &specialEvents{ns: ns, path: os.Getenv("SPECIAL_EVENT_PATH")}
+	ns.events.subscribe("special_event", s.pushBreaking, eventArticleDiscovered)
+	if s.path == "" {
+		return s
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading the special event, starting without one: %v", err)
+		}
+		return s
+	}
+	var event models.SpecialEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		log.Printf("Error decoding the special event, starting without one: %v", err)
+		return s
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.event = &event
+	s.keywords = foldKeywords(event.Keywords)
+	if event.Active {
+		s.run(event)
+	}
+	return s
+}
+
+// start replaces any running event with event
+func (s *specialEvents) start(event models.SpecialEvent) (*models.SpecialEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.endLocked(time.Now().UTC())
+	s.event = &event
+	s.keywords = foldKeywords(event.Keywords)
+	s.run(event)
+	return previous, s.persist()
+}
+
+// end stops the running event and returns it, nil when none is running
+func (s *specialEvents) end() (*models.SpecialEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ended := s.endLocked(time.Now().UTC())
+	if ended == nil {
+		return nil, nil
+	}
+	return ended, s.persist()
+}
+
+// endLocked marks the running event ended at the given time and stops its
+// refresh loop. The caller holds the lock
+func (s *specialEvents) endLocked(at time.Time) *models.SpecialEvent {
+	if s.event == nil || !s.event.Active {
+		return nil
+	}
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.event.Active = false
+	s.event.EndedAt = &at
+	ended := *s.event
+	return &ended
+}
+
+// current returns a copy of the running or last event
+func (s *specialEvents) current() *models.SpecialEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.event == nil {
+		return nil
+	}
+	event := *s.event
+	return &event
+}
+
+// run starts the refresh loop of event. The caller holds the lock
+func (s *specialEvents) run(event models.SpecialEvent) {
+	interval, _ := time.ParseDuration(event.Interval)
+	stop := make(chan struct{})
+	s.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if event.Until != nil && time.Now().After(*event.Until) {
+				s.mu.Lock()
+				if s.stop == stop {
+					s.endLocked(*event.Until)
+					if err := s.persist(); err != nil {
+						log.Printf("Error saving the special event: %v", err)
+					}
+				}
+				s.mu.Unlock()
+				log.Printf("Special event %s ended as scheduled", event.Slug)
+				return
+			}
+			s.refresh(event)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refresh scrapes the event's sources. With sharded scheduling each
+// worker only scrapes the sources it owns
+func (s *specialEvents) refresh(event models.SpecialEvent) {
+	for _, name := range s.sourcesOf(event) {
+		if sched := s.ns.scheduler; sched != nil && sched.shards != nil && !sched.shards.owns(name) {
+			continue
+		}
+		if _, err := s.ns.fetchNewsFromSource(name, s.ns.sources[name].URL, scrapeOptions{limit: defaultSourceLimit, fresh: true}); err != nil {
+			log.Printf("Special event scrape of %s failed: %v", name, err)
+		}
+	}
+	now := time.Now().UTC()
+	s.mu.Lock()
+	if s.event != nil && s.event.Slug == event.Slug && s.event.Active {
+		s.event.LastRefresh = &now
+	}
+	s.mu.Unlock()
+}
+
+// sourcesOf returns the event's sources, every active source when it names
+// none
+func (s *specialEvents) sourcesOf(event models.SpecialEvent) []string {
+	if len(event.Sources) > 0 {
+		return event.Sources
+	}
+	names := []string{}
+	for name, source := range s.ns.sources {
+		if source.Active && source.Kind == "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// matches reports whether an article belongs to the event: from one of its
+// sources and mentioning one of its keywords
+func (s *specialEvents) matches(event models.SpecialEvent, keywords []string, article models.NewsArticle) bool {
+	if len(event.Sources) > 0 && !containsString(event.Sources, article.Source) {
+		return false
+	}
+	if len(keywords) == 0 {
+		return true
+	}
+	text := articleText(article)
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushBreaking republishes each new article of the running event as its own
+// breaking news event, so notifiers and integrations send it right away
+// instead of in a batch
+func (s *specialEvents) pushBreaking(discovered models.Event) {
+	s.mu.Lock()
+	if s.event == nil || !s.event.Active {
+		s.mu.Unlock()
+		return
+	}
+	event, keywords := *s.event, s.keywords
+	s.mu.Unlock()
+
+	for _, article := range discovered.Articles {
+		if s.matches(event, keywords, article) {
+			s.ns.events.publish(models.Event{Type: eventArticleBreaking, Source: discovered.Source, Articles: []models.NewsArticle{article}})
+		}
+	}
+}
+
+// persist writes the event to disk; callers must hold the lock
+func (s *specialEvents) persist() error {
+	if s.path == "" || s.event == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.event, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode special event: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create special event dir: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write special event: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace special event: %v", err)
+	}
+	return nil
+}
+
+// GetSpecialEvent shows the running or last special event
+func (ns *NewsService) GetSpecialEvent(c *gin.Context) {
+	event := ns.special.current()
+	if event == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "no_special_event",
+			Message: "No special event has been started",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "event": event})
+}
+
+// StartSpecialEvent turns special event mode on for an election or crisis,
+// replacing any event already running
+func (ns *NewsService) StartSpecialEvent(c *gin.Context) {
+	var event models.SpecialEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("Invalid special event: %v", err),
+		})
+		return
+	}
+	invalid := func(code, message string) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Success: false, Error: code, Message: message})
+	}
+	event.Title = strings.TrimSpace(event.Title)
+	if !eventSlugPattern.MatchString(event.Slug) {
+		invalid("invalid_slug", "slug must be 1 to 64 lowercase letters, digits and dashes, e.g. election-2026")
+		return
+	}
+	if event.Title == "" {
+		invalid("invalid_request", "A special event needs a title")
+		return
+	}
+	for _, name := range event.Sources {
+		if source, ok := ns.sources[name]; !ok || source.Kind != "" {
+			invalid("unknown_source", fmt.Sprintf("Unknown news source %q", name))
+			return
+		}
+	}
+	if len(event.Sources) == 0 && len(foldKeywords(event.Keywords)) == 0 {
+		invalid("invalid_request", "A special event needs sources, keywords or both")
+		return
+	}
+	if event.Interval == "" {
+		event.Interval = defaultSpecialInterval.String()
+	}
+	if interval, err := time.ParseDuration(event.Interval); err != nil || interval < minScheduleInterval {
+		invalid("invalid_interval", fmt.Sprintf("interval must be a duration of at least %s, e.g. 2m", minScheduleInterval))
+		return
+	}
+	now := time.Now().UTC()
+	if event.Until != nil && !event.Until.After(now) {
+		invalid("invalid_until", "until must be in the future")
+		return
+	}
+	event.Active = true
+	event.StartedAt = now
+	event.EndedAt = nil
+	event.LastRefresh = nil
+
+	previous, err := ns.special.start(event)
+	if err != nil {
+		log.Printf("Error saving the special event: %v", err)
+	}
+	ns.audit.record(c, "special_event.start", event.Slug, previous, event)
+	c.JSON(http.StatusOK, gin.H{"success": true, "event": event})
+}
+
+// StopSpecialEvent turns special event mode off. The event's live page
+// stays up, frozen at the time it ended
+func (ns *NewsService) StopSpecialEvent(c *gin.Context) {
+	ended, err := ns.special.end()
+	if err != nil {
+		log.Printf("Error saving the special event: %v", err)
+	}
+	if ended == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "no_special_event",
+			Message: "No special event is running",
+		})
+		return
+	}
+	ns.audit.record(c, "special_event.stop", ended.Slug, nil, ended)
+	c.JSON(http.StatusOK, gin.H{"success": true, "event": ended})
+}
+
+// GetLiveEvent serves a special event's live page: stored articles from its
+// sources that mention its keywords, newest first, from a day before it
+// started until it ended. since=<RFC 3339 time> returns only articles
+// published after that, for clients polling for updates
+func (ns *NewsService) GetLiveEvent(c *gin.Context) {
+	ns.special.mu.Lock()
+	var event models.SpecialEvent
+	found := ns.special.event != nil && ns.special.event.Slug == c.Param("slug")
+	if found {
+		event = *ns.special.event
+	}
+	keywords := ns.special.keywords
+	ns.special.mu.Unlock()
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "event_not_found",
+			Message: "No special event has this slug",
+		})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultLiveArticles
+	}
+	filter := store.Filter{Since: event.StartedAt.Add(-liveLookback)}
+	if event.EndedAt != nil {
+		filter.Until = *event.EndedAt
+	}
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_since",
+				Message: "since must be an RFC 3339 time, e.g. 2026-01-02T15:04:05Z",
+			})
+			return
+		}
+		if since.After(filter.Since) {
+			// Since is inclusive; pollers pass the newest time they have
+			filter.Since = since.Add(time.Nanosecond)
+		}
+	}
+
+	tenant := currentTenant(c)
+	articles := []models.NewsArticle{}
+	for _, article := range ns.store.List(filter) {
+		if tenant.allows(article.Source) && ns.special.matches(event, keywords, article) {
+			articles = append(articles, article)
+		}
+	}
+	articles, ok = servedFilter(c, tenant.filter(ns.overrides.apply(articles, "")))
+	if !ok {
+		return
+	}
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	c.JSON(http.StatusOK, models.LiveEventResponse{
+		Success: true,
+		Event:   event,
+		Data:    articles,
+		Count:   len(articles),
+	})
+}
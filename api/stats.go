@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+	"top-news/textnorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statsGranularities maps granularity names to how a time is truncated
+var statsGranularities = map[string]func(time.Time) time.Time{
+	"hour": func(t time.Time) time.Time { return t.Truncate(time.Hour) },
+	"day": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	},
+	"week": func(t time.Time) time.Time {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	},
+	"month": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	},
+}
+
+// GetStats counts stored articles per source, category and language over
+// time. Supports granularity=hour|day|week|month (default day) and a
+// since/until range (default the last 30 days)
+func (ns *NewsService) GetStats(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", "day")
+	truncate, known := statsGranularities[granularity]
+	if !known {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_granularity",
+			Message: "granularity must be hour, day, week or month",
+		})
+		return
+	}
+	since, until, ok := parseDateRange(c, 30*24*time.Hour)
+	if !ok {
+		return
+	}
+
+	buckets := map[time.Time]*models.StatsBucket{}
+	totals := models.StatsBucket{BySource: map[string]int{}, ByCategory: map[string]int{}, ByLanguage: map[string]int{}}
+	undated := 0
+	for _, article := range ns.store.List(store.Filter{}) {
+		if article.PublishedAt.IsZero() {
+			undated++
+			continue
+		}
+		if article.PublishedAt.Before(since) || !article.PublishedAt.Before(until) {
+			continue
+		}
+		start := truncate(article.PublishedAt.UTC())
+		bucket, exists := buckets[start]
+		if !exists {
+			bucket = &models.StatsBucket{Start: start, BySource: map[string]int{}, ByCategory: map[string]int{}, ByLanguage: map[string]int{}}
+			buckets[start] = bucket
+		}
+		for _, b := range []*models.StatsBucket{bucket, &totals} {
+			b.Total++
+			b.BySource[article.Source]++
+			b.ByCategory[categoryOf(article)]++
+			b.ByLanguage[languageOf(article)]++
+		}
+	}
+
+	series := make([]models.StatsBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		series = append(series, *bucket)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Start.Before(series[j].Start)
+	})
+
+	c.JSON(http.StatusOK, models.StatsResponse{
+		Success:     true,
+		Granularity: granularity,
+		Since:       since,
+		Until:       until,
+		Buckets:     series,
+		Totals:      totals,
+		Undated:     undated,
+	})
+}
+
+// parseDateRange reads since and until (RFC 3339 or YYYY-MM-DD), defaulting
+// to the window before now. It writes a 400 and returns false for ok when
+// either is invalid
+func parseDateRange(c *gin.Context, window time.Duration) (since, until time.Time, ok bool) {
+	until = time.Now().UTC()
+	since = until.Add(-window)
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if parsed, err = time.Parse("2006-01-02", raw); err == nil && param.name == "until" {
+				// Include the whole last day
+				parsed = parsed.Add(24 * time.Hour)
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Success: false,
+				Error:   "invalid_" + param.name,
+				Message: param.name + " must be an RFC 3339 time or a YYYY-MM-DD date",
+			})
+			return time.Time{}, time.Time{}, false
+		}
+		*param.value = parsed.UTC()
+	}
+	return since, until, true
+}
+
+// categoryOf returns an article's category for grouping
+func categoryOf(article models.NewsArticle) string {
+	if article.Category == "" {
+		return "uncategorized"
+	}
+	return article.Category
+}
+
+// languageOf guesses an article's language from its headline
+func languageOf(article models.NewsArticle) string {
+	if language := textnorm.Language(article.Title + " " + article.Description); language != "" {
+		return language
+	}
+	return "unknown"
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenantContextKey is where identifyTenant stores the request's tenant
+const tenantContextKey = "tenant"
+
+// tenant is one consumer of a shared deployment, such as an app, with its
+// own source set, filters, bookmarks and webhooks
+type tenant struct {
+	name      string
+	tier      string
+	lane      string
+	sources   []string
+	filters   *filterRules
+	bookmarks *bookmarkStore
+}
+
+// allows reports whether the tenant may see a source. A nil tenant, the
+// anonymous public, sees every source
+func (t *tenant) allows(source string) bool {
+	return t == nil || len(t.sources) == 0 || containsString(t.sources, source)
+}
+
+// filter applies the tenant's filter rules to a copy of articles, leaving
+// the caller's slice alone
+func (t *tenant) filter(articles []models.NewsArticle) []models.NewsArticle {
+	if t == nil {
+		return articles
+	}
+	return t.filters.filter(append([]models.NewsArticle{}, articles...), nil)
+}
+
+// tenantRegistry finds tenants by API key or name. Keys are kept hashed
+type tenantRegistry struct {
+	byKey  map[[32]byte]*tenant
+	byName map[string]*tenant
+}
+
+// newTenantRegistry loads tenants from the JSON file at TENANTS_PATH, or
+// inline JSON in TENANTS. Bookmarks and failed webhook deliveries are
+// persisted under TENANTS_DIR, one directory per tenant. Invalid
+// configuration is logged and tenants are disabled
+func newTenantRegistry(sources map[string]models.Source, bus *eventBus, tiers *quotaTiers) *tenantRegistry {
+	data := []byte(os.Getenv("TENANTS"))
+	if path := os.Getenv("TENANTS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading tenants, tenants are disabled: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	data, err := openSecrets(data)
+	if err != nil {
+		log.Printf("Error decrypting tenants, tenants are disabled: %v", err)
+		return nil
+	}
+
+	var config models.TenantsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding tenants, tenants are disabled: %v", err)
+		return nil
+	}
+	registry, err := compileTenants(config, sources, bus, os.Getenv("TENANTS_DIR"), tiers)
+	if err != nil {
+		log.Printf("Invalid tenants, tenants are disabled: %v", err)
+		return nil
+	}
+	return registry
+}
+
+// compileTenants checks the configuration and sets up each tenant's
+// filters, bookmarks and webhooks. A tenant may not have more webhooks
+// than its quota tier allows
+func compileTenants(config models.TenantsConfig, sources map[string]models.Source, bus *eventBus, dir string, tiers *quotaTiers) (*tenantRegistry, error) {
+	registry := &tenantRegistry{byKey: map[[32]byte]*tenant{}, byName: map[string]*tenant{}}
+	names := make([]string, 0, len(config.Tenants))
+	for name := range config.Tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dispatchers := []*notificationDispatcher{}
+	for _, name := range names {
+		tc := config.Tenants[name]
+		if len(tc.APIKeys) == 0 {
+			return nil, fmt.Errorf("tenant %s: at least one api key is required", name)
+		}
+		for _, source := range tc.Sources {
+			if _, ok := sources[source]; !ok {
+				return nil, fmt.Errorf("tenant %s: unknown source %q", name, source)
+			}
+		}
+		filters, err := compileFilterRules(tc.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		if err := tiers.check(tc.Tier); err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		if err := checkLane(tc.Lane); err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", name, err)
+		}
+		if max := tiers.tier(tc.Tier).MaxWebhooks; max > 0 && len(tc.Webhooks) > max {
+			return nil, fmt.Errorf("tenant %s: its tier allows at most %d webhooks", name, max)
+		}
+
+		t := &tenant{name: name, tier: tc.Tier, lane: tc.Lane, sources: tc.Sources, filters: filters, bookmarks: &bookmarkStore{bookmarks: map[string]models.Bookmark{}}}
+		tenantDir := ""
+		if dir != "" {
+			tenantDir = filepath.Join(dir, name)
+			t.bookmarks.path = filepath.Join(tenantDir, "bookmarks.json")
+			t.bookmarks.load()
+		}
+		registry.byName[name] = t
+
+		for _, key := range tc.APIKeys {
+			hash := sha256.Sum256([]byte(key))
+			if key == "" || registry.byKey[hash] != nil {
+				return nil, fmt.Errorf("tenant %s: api keys must be non-empty and unique", name)
+			}
+			registry.byKey[hash] = t
+		}
+
+		if len(tc.Webhooks) > 0 {
+			d, err := compileTenantWebhooks(name, tc)
+			if err != nil {
+				return nil, err
+			}
+			deliveries := ""
+			if tenantDir != "" {
+				deliveries = filepath.Join(tenantDir, "deliveries.json")
+			}
+			d.deliveries = newDeliveryQueue(d, deliveries)
+			dispatchers = append(dispatchers, d)
+		}
+	}
+
+	// Only start delivering once every tenant is valid
+	for _, d := range dispatchers {
+		go d.deliveries.run()
+		d.subscribe(bus)
+	}
+	return registry, nil
+}
+
+// compileTenantWebhooks turns a tenant's webhooks into notifier routes
+// limited to its sources
+func compileTenantWebhooks(name string, tc models.TenantConfig) (*notificationDispatcher, error) {
+	config := models.NotificationConfig{Notifiers: map[string]models.NotifierConfig{}}
+	for i, webhook := range tc.Webhooks {
+		notifier := name + "/webhook-" + strconv.Itoa(i+1)
+		config.Notifiers[notifier] = models.NotifierConfig{Type: "webhook", URL: webhook.URL, Secret: webhook.Secret}
+		config.Routes = append(config.Routes, models.NotificationRoute{
+			Notifier: notifier,
+			Events:   webhook.Events,
+			Sources:  tc.Sources,
+			Keywords: webhook.Keywords,
+		})
+	}
+	d, err := compileNotificationConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %v", name, err)
+	}
+	return d, nil
+}
+
+// lookup returns the tenant owning an API key, or nil
+func (r *tenantRegistry) lookup(key string) *tenant {
+	if r == nil || key == "" {
+		return nil
+	}
+	return r.byKey[sha256.Sum256([]byte(key))]
+}
+
+// named returns the tenant called name, or nil
+func (r *tenantRegistry) named(name string) *tenant {
+	if r == nil {
+		return nil
+	}
+	return r.byName[name]
+}
+
+// identifyTenant is a middleware that attaches the tenant of the request's
+// API key. Requests without a tenant key are served as the public, who see
+// every source
+func (ns *NewsService) identifyTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ns.tenants != nil {
+			// Responses differ per key, so shared caches must not mix them
+			c.Header("Vary", "X-API-Key, Authorization")
+			if t := ns.tenants.lookup(requestAPIKey(c)); t != nil {
+				c.Set(tenantContextKey, t)
+			}
+		}
+		c.Next()
+	}
+}
+
+// currentTenant returns the request's tenant, nil for the public
+func currentTenant(c *gin.Context) *tenant {
+	if value, ok := c.Get(tenantContextKey); ok {
+		return value.(*tenant)
+	}
+	return nil
+}
+
+// requestBookmarks returns the bookmarks of the signed-in user, or else of
+// the request's tenant. It writes a 401 and returns nil when there is
+// neither
+func (ns *NewsService) requestBookmarks(c *gin.Context) *bookmarkStore {
+	if subject := currentUser(c); subject != "" {
+		return ns.users.bookmarksOf(subject)
+	}
+	if t := currentTenant(c); t != nil {
+		return t.bookmarks
+	}
+	c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+		Success: false,
+		Error:   "auth_required",
+		Message: "A tenant API key or a signed-in user's bearer token is required",
+	})
+	return nil
+}
+
+// bookmarkStore keeps a tenant's bookmarks, persisted to path when set
+type bookmarkStore struct {
+	mu        sync.Mutex
+	path      string
+	bookmarks map[string]models.Bookmark // article key -> bookmark
+}
+
+// load reads the bookmarks from path
+func (s *bookmarkStore) load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading bookmarks, starting empty: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &s.bookmarks); err != nil {
+		log.Printf("Error decoding bookmarks, starting empty: %v", err)
+		s.bookmarks = map[string]models.Bookmark{}
+	}
+}
+
+// persist writes the bookmarks to path; callers must hold the lock
+func (s *bookmarkStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.bookmarks)
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create bookmarks dir: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write bookmarks: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace bookmarks: %v", err)
+	}
+	return nil
+}
+
+// list returns the bookmarks, newest first
+func (s *bookmarkStore) list() []models.Bookmark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bookmarks := make([]models.Bookmark, 0, len(s.bookmarks))
+	for _, bookmark := range s.bookmarks {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].CreatedAt.After(bookmarks[j].CreatedAt)
+	})
+	return bookmarks
+}
+
+// add saves or replaces a bookmark
+func (s *bookmarkStore) add(bookmark models.Bookmark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bookmarks[bookmark.Key] = bookmark
+	return s.persist()
+}
+
+// remove deletes a bookmark, reporting whether it existed
+func (s *bookmarkStore) remove(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bookmarks[key]; !ok {
+		return false, nil
+	}
+	delete(s.bookmarks, key)
+	return true, s.persist()
+}
+
+// ListBookmarks returns the user's or tenant's bookmarks
+func (ns *NewsService) ListBookmarks(c *gin.Context) {
+	store := ns.requestBookmarks(c)
+	if store == nil {
+		return
+	}
+	bookmarks := store.list()
+	c.JSON(http.StatusOK, models.BookmarksResponse{Success: true, Data: bookmarks, Count: len(bookmarks)})
+}
+
+// AddBookmark saves a stored article to the user's or tenant's bookmarks
+func (ns *NewsService) AddBookmark(c *gin.Context) {
+	store := ns.requestBookmarks(c)
+	if store == nil {
+		return
+	}
+	var request struct {
+		URL  string `json:"url"`
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.URL == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_request",
+			Message: `Send {"url": "...", "note": "..."} with the URL of a scraped article`,
+		})
+		return
+	}
+	article, ok := ns.store.Get(request.URL)
+	if !ok || !currentTenant(c).allows(article.Source) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "article_not_found",
+			Message: "No scraped article has this URL",
+		})
+		return
+	}
+
+	bookmark := models.Bookmark{
+		Key:       articleKey(article.URL),
+		Article:   article,
+		Note:      request.Note,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.add(bookmark); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "bookmark_failed",
+			Message: fmt.Sprintf("Failed to save bookmark: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "bookmark": bookmark})
+}
+
+// DeleteBookmark removes a bookmark by article key
+func (ns *NewsService) DeleteBookmark(c *gin.Context) {
+	store := ns.requestBookmarks(c)
+	if store == nil {
+		return
+	}
+	removed, err := store.remove(c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error:   "bookmark_failed",
+			Message: fmt.Sprintf("Failed to remove bookmark: %v", err),
+		})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Success: false,
+			Error:   "bookmark_not_found",
+			Message: "No bookmark exists for this key",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
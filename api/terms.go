@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"top-news/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// restrictiveDirectives are the robots directives that ask not to reuse a
+// page's content: for AI training, in archives, or as snippets
+var restrictiveDirectives = map[string]bool{
+	"noai":            true,
+	"noimageai":       true,
+	"noarchive":       true,
+	"nosnippet":       true,
+	"tdm-reservation": true,
+}
+
+// robotsMetaSelector matches the meta tags that carry robots directives
+const robotsMetaSelector = "meta[name='robots'], meta[name='googlebot'], meta[name='bingbot']"
+
+// enrichRobots records the robots directives an article page carries, from
+// its X-Robots-Tag headers, robots meta tags and TDMRep reservation
+func enrichRobots(ctx context.Context, page *articlePage, article *models.NewsArticle) (string, error) {
+	seen := map[string]bool{}
+	methods := []string{}
+	add := func(value, method string) {
+		added := false
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			// X-Robots-Tag may name a bot, e.g. "googlebot: noarchive"
+			if bot, rest, found := strings.Cut(directive, ":"); found && strings.HasSuffix(bot, "bot") {
+				directive = strings.TrimSpace(rest)
+			}
+			if directive != "" && !seen[directive] {
+				seen[directive] = true
+				added = true
+			}
+		}
+		if added {
+			methods = append(methods, method)
+		}
+	}
+	for _, header := range page.RobotsHeaders {
+		add(header, "X-Robots-Tag")
+	}
+	page.Doc.Find(robotsMetaSelector).Each(func(i int, s *goquery.Selection) {
+		add(s.AttrOr("content", ""), "meta[name='"+s.AttrOr("name", "")+"']")
+	})
+	if strings.TrimSpace(page.Doc.Find("meta[name='tdm-reservation']").AttrOr("content", "")) == "1" {
+		add("tdm-reservation", "meta[name='tdm-reservation']")
+	}
+	if len(seen) == 0 {
+		return "", nil
+	}
+
+	directives := make([]string, 0, len(seen))
+	for directive := range seen {
+		directives = append(directives, directive)
+	}
+	sort.Strings(directives)
+	article.Robots = directives
+	return strings.Join(methods, ", "), nil
+}
+
+// sourceTerms keeps the robots directives of each source's most recently
+// enriched page
+type sourceTerms struct {
+	mu      sync.Mutex
+	sources map[string][]string
+	// respect skips enrichment for sources whose pages carry a restrictive
+	// directive
+	respect bool
+}
+
+// newSourceTerms tracks directives. RESPECT_ROBOTS=true stops enriching
+// sources that ask not to have their content reused
+func newSourceTerms() *sourceTerms {
+	return &sourceTerms{sources: map[string][]string{}, respect: os.Getenv("RESPECT_ROBOTS") == "true"}
+}
+
+// record notes the directives of a source's latest page, none included
+func (t *sourceTerms) record(source string, directives []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sources[source] = directives
+}
+
+// directives lists the directives of a source's latest page, sorted
+func (t *sourceTerms) directives(source string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sources[source]
+}
+
+// restrictive reports whether a source's latest page asks not to be reused
+func (t *sourceTerms) restrictive(source string) bool {
+	for _, directive := range t.directives(source) {
+		if restrictiveDirectives[directive] {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedStages drops every stage but robots for restrictive sources when
+// RESPECT_ROBOTS is on. The directives are still read, so a source that
+// lifts its restriction is enriched again
+func (t *sourceTerms) allowedStages(source string, stages []enrichmentStage) []enrichmentStage {
+	if !t.respect || !t.restrictive(source) {
+		return stages
+	}
+	allowed := []enrichmentStage{}
+	for _, stage := range stages {
+		if stage.name == "robots" {
+			allowed = append(allowed, stage)
+		}
+	}
+	return allowed
+}
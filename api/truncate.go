@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDescLen is the longest desc_len a request may ask for
+const maxDescLen = 1000
+
+// descriptionLength reads DESCRIPTION_LENGTH, the most characters of a
+// description kept when scraping, defaulting to 200
+func descriptionLength() int {
+	length := 200
+	if value := os.Getenv("DESCRIPTION_LENGTH"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid DESCRIPTION_LENGTH %q, using %d", value, length)
+		} else {
+			length = parsed
+		}
+	}
+	return length
+}
+
+// truncateText shortens text to at most max characters before a "..."
+// marking the cut. It ends at the last full sentence when that keeps at
+// least half of max and at the last word boundary otherwise, cutting mid
+// word only when the text is one long word, and never splits a multibyte
+// character
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max])
+	// The character after the cut tells whether the cut itself ends a
+	// sentence or a word
+	next := runes[max]
+	if bounds := sentenceEnd.FindAllStringIndex(cut+string(next), -1); len(bounds) > 0 {
+		end := bounds[len(bounds)-1][1]
+		if end > len(cut) {
+			end = len(cut)
+		}
+		if utf8.RuneCountInString(cut[:end]) >= max/2 {
+			return strings.TrimSpace(cut[:end])
+		}
+	}
+	if !unicode.IsSpace(next) {
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " ,;:–—-") + "..."
+}
+
+// parseDescLen reads the optional desc_len query parameter. It writes a 400
+// and returns false for ok when it is not a number between 1 and
+// maxDescLen
+func parseDescLen(c *gin.Context) (length int, ok bool) {
+	value := c.Query("desc_len")
+	if value == "" {
+		return 0, true
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 1 || length > maxDescLen {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error:   "invalid_desc_len",
+			Message: fmt.Sprintf("desc_len must be a number between 1 and %d", maxDescLen),
+		})
+		return 0, false
+	}
+	return length, true
+}
+
+// shortenDescriptions returns copies of the articles with descriptions cut
+// to length characters
+func shortenDescriptions(articles []models.NewsArticle, length int) []models.NewsArticle {
+	shortened := make([]models.NewsArticle, len(articles))
+	for i, article := range articles {
+		article.Description = truncateText(article.Description, length)
+		shortened[i] = article
+	}
+	return shortened
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"short text is kept", "Short text.", 20, "Short text."},
+		{"cut ends a sentence", "First sentence here. Second one is longer than the rest", 20, "First sentence here."},
+		{"cut ends a question", "Is this the end? No", 16, "Is this the end?"},
+		{"cut ends a sentence before a quote", `He said "stop." Then left`, 15, `He said "stop."`},
+		{"earlier sentence is kept", "One two three. Four five six seven", 24, "One two three."},
+		{"decimal point is not a sentence", "Prices rose 3.5 percent", 13, "Prices rose..."},
+		{"word boundary past half", "alpha beta gamma delta", 14, "alpha beta..."},
+		{"word boundary before half", "tiny supercalifragilisticexpialidocious word", 20, "tiny..."},
+		{"cut on a space keeps the whole cut", "alpha beta gamma", 10, "alpha beta..."},
+		{"one long word is hard cut", "supercalifragilisticexpialidocious", 10, "supercalif..."},
+		{"trailing punctuation is trimmed", "alpha, beta gamma", 8, "alpha..."},
+		{"multibyte characters are not split", "বাংলাদেশের রাজধানী ঢাকা", 12, "বাংলাদেশের..."},
+		{"dari ends a sentence", "ঢাকায় বৃষ্টি। আজ সারাদিন", 14, "ঢাকায় বৃষ্টি।"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateText(tt.text, tt.max); got != tt.want {
+				t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateTextSentenceAtCut(t *testing.T) {
+	// A cut that ends in sentence punctuation used to slice past the end
+	text := strings.Repeat("word ", 19) + "end. " + strings.Repeat("more ", 10)
+	for max := 90; max <= 110; max++ {
+		got := truncateText(text, max)
+		if len([]rune(strings.TrimSuffix(got, "..."))) > max {
+			t.Errorf("truncateText(text, %d) = %q, longer than %d", max, got, max)
+		}
+	}
+	if got, want := truncateText(text, 99), strings.Repeat("word ", 19)+"end."; got != want {
+		t.Errorf("truncateText(text, 99) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// usageMeter counts each API key's requests and enforces the per-minute
+// rate limit and monthly quota. Counts live in memory, per instance
+type usageMeter struct {
+	mu        sync.Mutex
+	keys      map[string]*keyUsage
+	perMinute int64
+}
+
+// keyUsage is one key's request counts. Each window count is reset when
+// its window has passed
+type keyUsage struct {
+	total       int64
+	minute      time.Time
+	minuteCount int64
+	day         time.Time
+	dayCount    int64
+	month       time.Time
+	monthCount  int64
+}
+
+// newUsageMeter reads RATE_LIMIT_PER_MINUTE, the requests each API key may
+// make in a minute; 0, the default, leaves keys unlimited
+func newUsageMeter() *usageMeter {
+	m := &usageMeter{keys: map[string]*keyUsage{}}
+	if value := os.Getenv("RATE_LIMIT_PER_MINUTE"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			log.Printf("Invalid RATE_LIMIT_PER_MINUTE %q, keys are not rate limited", value)
+		} else {
+			m.perMinute = parsed
+		}
+	}
+	return m
+}
+
+// requestKeyName names the API key behind a request for metering: its
+// tenant's name for tenant keys, "" for requests without a known key
+func requestKeyName(c *gin.Context) string {
+	if t := currentTenant(c); t != nil {
+		return "tenant:" + t.name
+	}
+	if name := c.GetString(keyNameContextKey); name != "" {
+		return "key:" + name
+	}
+	return ""
+}
+
+// usage returns a key's counts with expired windows reset; callers must
+// hold the lock
+func (m *usageMeter) usage(name string, now time.Time) *keyUsage {
+	u, ok := m.keys[name]
+	if !ok {
+		u = &keyUsage{}
+		m.keys[name] = u
+	}
+	if minute := now.Truncate(time.Minute); !u.minute.Equal(minute) {
+		u.minute, u.minuteCount = minute, 0
+	}
+	if day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !u.day.Equal(day) {
+		u.day, u.dayCount = day, 0
+	}
+	if month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC); !u.month.Equal(month) {
+		u.month, u.monthCount = month, 0
+	}
+	return u
+}
+
+// Reasons allow turns a request away
+const (
+	rateLimited   = "rate_limited"
+	quotaExceeded = "quota_exceeded"
+)
+
+// allow counts a request by a key on a tier, unless the key is out of
+// requests for this minute or month; it then returns rateLimited or
+// quotaExceeded. The key's rate limit and quota status are returned either
+// way
+func (m *usageMeter) allow(name string, tier models.QuotaTier) (string, *models.RateLimitStatus, *models.QuotaStatus) {
+	now := time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.usage(name, now)
+	if tier.RequestsPerMonth > 0 && u.monthCount >= tier.RequestsPerMonth {
+		return quotaExceeded, m.status(u, tier), quotaStatus(u, tier)
+	}
+	if limit := m.limit(tier); limit > 0 && u.minuteCount >= limit {
+		return rateLimited, m.status(u, tier), quotaStatus(u, tier)
+	}
+	u.total++
+	u.minuteCount++
+	u.dayCount++
+	u.monthCount++
+	return "", m.status(u, tier), quotaStatus(u, tier)
+}
+
+// limit is the requests a key on a tier may make in a minute: the tier's
+// own limit when it sets one and RATE_LIMIT_PER_MINUTE otherwise
+func (m *usageMeter) limit(tier models.QuotaTier) int64 {
+	if tier.RequestsPerMinute > 0 {
+		return tier.RequestsPerMinute
+	}
+	return m.perMinute
+}
+
+// status reports a key's rate limit, nil when there is none; callers must
+// hold the lock
+func (m *usageMeter) status(u *keyUsage, tier models.QuotaTier) *models.RateLimitStatus {
+	limit := m.limit(tier)
+	if limit == 0 {
+		return nil
+	}
+	return &models.RateLimitStatus{
+		Limit:     limit,
+		Remaining: limit - u.minuteCount,
+		ResetAt:   u.minute.Add(time.Minute),
+	}
+}
+
+// quotaStatus reports a key's monthly quota, nil when its tier has none
+func quotaStatus(u *keyUsage, tier models.QuotaTier) *models.QuotaStatus {
+	if tier.RequestsPerMonth == 0 {
+		return nil
+	}
+	return &models.QuotaStatus{
+		Limit:     tier.RequestsPerMonth,
+		Used:      u.monthCount,
+		Remaining: tier.RequestsPerMonth - u.monthCount,
+		ResetAt:   u.month.AddDate(0, 1, 0),
+	}
+}
+
+// report returns a key's counts, rate limit and quota status
+func (m *usageMeter) report(name string, tier models.QuotaTier) (models.UsageCounts, *models.RateLimitStatus, *models.QuotaStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.usage(name, time.Now().UTC())
+	return models.UsageCounts{
+		Total:      u.total,
+		ThisMonth:  u.monthCount,
+		Today:      u.dayCount,
+		ThisMinute: u.minuteCount,
+	}, m.status(u, tier), quotaStatus(u, tier)
+}
+
+// meterUsage is a middleware that counts the requests of every known API
+// key. It answers 429 once a key has used up its requests for the minute
+// and 402 once it has used up its tier's requests for the month. Requests
+// without a key are not metered, and neither are usage reports, so a
+// limited key can still check when it may continue
+func (ns *NewsService) meterUsage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := requestKeyName(c)
+		if name == "" || c.FullPath() == "/api/v1/me/usage" {
+			c.Next()
+			return
+		}
+		tier := ns.requestTier(c)
+		refused, limit, quota := ns.usage.allow(name, ns.tiers.tier(tier))
+		if limit != nil {
+			c.Header("X-RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(limit.Remaining, 10))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(limit.ResetAt.Unix(), 10))
+		}
+		if quota != nil {
+			c.Header("X-Quota-Limit", strconv.FormatInt(quota.Limit, 10))
+			c.Header("X-Quota-Remaining", strconv.FormatInt(quota.Remaining, 10))
+			c.Header("X-Quota-Reset", strconv.FormatInt(quota.ResetAt.Unix(), 10))
+		}
+		switch refused {
+		case quotaExceeded:
+			c.AbortWithStatusJSON(http.StatusPaymentRequired, models.ErrorResponse{
+				Success: false,
+				Error:   quotaExceeded,
+				Message: fmt.Sprintf("This API key has used its %d requests for the month; the quota resets at %s or with a higher tier", quota.Limit, quota.ResetAt.Format(time.RFC3339)),
+			})
+			return
+		case rateLimited:
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(limit.ResetAt).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+				Success: false,
+				Error:   rateLimited,
+				Message: "This API key has made too many requests this minute, retry after the time in Retry-After",
+			})
+			return
+		}
+		ns.metering.count(name, tier, meterRequests, 1)
+		c.Next()
+	}
+}
+
+// GetUsage reports the caller's API key's request counts, rate limit and
+// quota
+func (ns *NewsService) GetUsage(c *gin.Context) {
+	name := requestKeyName(c)
+	if name == "" {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Success: false,
+			Error:   "auth_required",
+			Message: "An API key is required",
+		})
+		return
+	}
+	requests, limit, quota := ns.usage.report(name, ns.tiers.tier(ns.requestTier(c)))
+	_, key, _ := strings.Cut(name, ":")
+	c.JSON(http.StatusOK, models.UsageResponse{
+		Success:   true,
+		Key:       key,
+		Tier:      ns.requestTier(c),
+		Requests:  requests,
+		RateLimit: limit,
+		Quota:     quota,
+	})
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"top-news/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X top-news/api.Version=1.4.0 -X top-news/api.Commit=$(git rev-parse HEAD) -X top-news/api.BuildDate=$(date -u +%FT%TZ)"
+//
+// Commit and BuildDate fall back to the VCS details Go embeds in binaries
+// built from a git checkout
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// buildInfo fills in what -ldflags didn't set
+func buildInfo() models.VersionResponse {
+	info := models.VersionResponse{
+		Success:   true,
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			case setting.Key == "vcs.modified" && setting.Value == "true":
+				info.Dirty = true
+			}
+		}
+	}
+	return info
+}
+
+// GetVersion reports what is deployed: the build and which optional
+// features this instance has turned on
+func (ns *NewsService) GetVersion(c *gin.Context) {
+	info := buildInfo()
+	info.Features = map[string]bool{
+		"mock":              mockMode() != nil,
+		"fast_json":         ns.fastJSON,
+		"store":             os.Getenv("STORE_PATH") != "",
+		"cdn_purge":         ns.cdn != nil,
+		"tenants":           ns.tenants != nil,
+		"api_key_roles":     len(ns.apiKeys) > 0,
+		"jwt_auth":          ns.jwt != nil,
+		"notifications":     ns.notifications != nil,
+		"activitypub":       ns.activityPub != nil,
+		"websub":            len(ns.websub.hubs) > 0,
+		"audit_file":        ns.audit.path != "",
+		"scheduler":         ns.scheduler != nil,
+		"scrape_lock":       ns.scheduler != nil && ns.scheduler.lock != nil,
+		"sharding":          ns.scheduler != nil && ns.scheduler.shards != nil,
+		"politeness":        ns.politeness != nil,
+		"scrape_windows":    ns.windows != nil,
+		"special_event":     ns.special.current() != nil && ns.special.current().Active,
+		"rate_limit":        ns.usage.perMinute > 0,
+		"quota_tiers":       ns.tiers != nil,
+		"metering":          ns.metering != nil,
+		"scrape_lanes":      ns.scrapes != nil,
+		"blob_snapshots":    ns.blobs != nil,
+		"store_retention":   ns.pruner != nil,
+		"encrypted_secrets": secretKeys() != nil,
+		"news_cache":        ns.newsCache.ttl > 0,
+		"serve_prefetched":  ns.scheduler != nil && ns.scheduler.prefetched,
+	}
+	c.JSON(http.StatusOK, info)
+}
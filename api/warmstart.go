@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"top-news/models"
+	"top-news/store"
+)
+
+// warmStartArticles is how many of each source's newest stored articles
+// are loaded on boot
+const warmStartArticles = 50
+
+// warmStart tracks the sources served from the store after a restart,
+// until their first scrape comes back
+type warmStart struct {
+	mu         sync.Mutex
+	primed     map[string]bool
+	refreshing map[string]bool
+}
+
+func newWarmStart() *warmStart {
+	return &warmStart{primed: map[string]bool{}, refreshing: map[string]bool{}}
+}
+
+// primeFromStore loads every active source's newest stored articles into
+// the enrichment cache, and serves them to interactive requests until the
+// source has been scraped, so the first requests after a deploy don't wait
+// on live scrapes. WARM_START=off turns it off
+func (ns *NewsService) primeFromStore() {
+	if os.Getenv("WARM_START") == "off" {
+		return
+	}
+	primed, sources := 0, 0
+	for name, source := range ns.sources {
+		if !source.Active {
+			continue
+		}
+		articles := ns.store.List(store.Filter{Source: name, Limit: warmStartArticles})
+		if len(articles) == 0 {
+			continue
+		}
+		stages := ns.terms.allowedStages(name, enabledStages(source, nil))
+		for _, article := range articles {
+			ns.enrichCache.prime(article, stages)
+		}
+		ns.warm.mu.Lock()
+		ns.warm.primed[name] = true
+		ns.warm.mu.Unlock()
+		primed += len(articles)
+		sources++
+	}
+	if sources > 0 {
+		log.Printf("Warm start: loaded %d stored articles of %d sources", primed, sources)
+	}
+}
+
+// warmNews returns a source's stored articles while it is still primed,
+// and starts its first scrape in the background. It returns false once the
+// source has been scraped
+func (ns *NewsService) warmNews(name string, limit int) ([]models.NewsArticle, bool) {
+	w := ns.warm
+	w.mu.Lock()
+	if !w.primed[name] {
+		w.mu.Unlock()
+		return nil, false
+	}
+	refresh := !w.refreshing[name]
+	w.refreshing[name] = true
+	w.mu.Unlock()
+
+	if refresh {
+		go func() {
+			_, err := ns.fetchNewsFromSource(name, ns.sources[name].URL, scrapeOptions{limit: defaultSourceLimit})
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.refreshing, name)
+			if err != nil {
+				log.Printf("First scrape of %s after the warm start failed, still serving stored articles: %v", name, err)
+				return
+			}
+			delete(w.primed, name)
+		}()
+	}
+	if limit == 0 {
+		limit = defaultSourceLimit
+	}
+	return ns.store.List(store.Filter{Source: name, Limit: limit}), true
+}
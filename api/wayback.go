@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+)
+
+// errPageGone is returned for article pages that answer 404 or 410
+var errPageGone = errors.New("page is gone")
+
+// A page has to be found gone goneChecks times, at least goneInterval
+// apart, before its article is removed, so an outage or a page that is
+// briefly unpublished doesn't lose it. The checks are kept in the store,
+// so they add up across restarts
+const (
+	goneChecks   = 3
+	goneInterval = time.Hour
+)
+
+// waybackAvailabilityURL is the Wayback Machine's snapshot lookup API
+var waybackAvailabilityURL = "https://archive.org/wayback/available"
+
+// waybackSnapshotPattern splits a snapshot URL into its prefix and the
+// archived URL, so the raw page can be asked for
+var waybackSnapshotPattern = regexp.MustCompile(`^https?://web\.archive\.org/web/(\d+)[a-z_]*/(.+)$`)
+
+// waybackEnabled reports whether dead links are looked up in the Wayback
+// Machine. WAYBACK=off turns the lookups off
+func waybackEnabled() bool {
+	return os.Getenv("WAYBACK") != "off"
+}
+
+// waybackSnapshot finds the Wayback Machine snapshot of a URL closest to
+// when it was published, "" when there is none
+func (ns *NewsService) waybackSnapshot(articleURL string, published time.Time) (string, error) {
+	query := url.Values{"url": {articleURL}}
+	if !published.IsZero() {
+		query.Set("timestamp", published.UTC().Format("20060102150405"))
+	}
+	req, err := http.NewRequest("GET", waybackAvailabilityURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := ns.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query the Wayback Machine: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code from the Wayback Machine: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode the Wayback Machine's reply: %v", err)
+	}
+	closest := result.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Status != "200" || closest.URL == "" {
+		return "", nil
+	}
+	return strings.Replace(closest.URL, "http://web.archive.org/", "https://web.archive.org/", 1), nil
+}
+
+// rawSnapshotURL points at a snapshot's page as it was archived, without
+// the Wayback Machine's toolbar and rewritten links
+func rawSnapshotURL(snapshot string) string {
+	match := waybackSnapshotPattern.FindStringSubmatch(snapshot)
+	if match == nil {
+		return snapshot
+	}
+	return "https://web.archive.org/web/" + match[1] + "id_/" + match[2]
+}
+
+// fetchStoredArticlePage fetches a stored article's page. When the page is
+// gone it is read from the Wayback Machine instead, and the snapshot is
+// kept as the article's archive_url. The article is returned with it set
+func (ns *NewsService) fetchStoredArticlePage(article models.NewsArticle) (*articlePage, models.NewsArticle, error) {
+	source := ns.sources[article.Source]
+	page, _, err := ns.fetchArticlePage(article.URL, source, pageValidators{})
+	if !errors.Is(err, errPageGone) {
+		if err == nil {
+			if clearErr := ns.store.ClearGone(article.URL); clearErr != nil {
+				log.Printf("Error saving the store after fetching %s: %v", article.URL, clearErr)
+			}
+		}
+		return page, article, err
+	}
+	if !waybackEnabled() {
+		ns.removeGone(article, time.Now())
+		return nil, article, err
+	}
+
+	if article.ArchiveURL == "" {
+		snapshot, lookupErr := ns.waybackSnapshot(article.URL, article.PublishedAt)
+		if lookupErr != nil {
+			log.Printf("Error looking up %s in the Wayback Machine: %v", article.URL, lookupErr)
+			return nil, article, err
+		}
+		if snapshot == "" {
+			ns.removeGone(article, time.Now())
+			return nil, article, err
+		}
+		article.ArchiveURL = snapshot
+		if _, saveErr := ns.store.Save(article); saveErr != nil {
+			log.Printf("Error saving the archive URL of %s: %v", article.URL, saveErr)
+		}
+	}
+
+	page, _, err = ns.fetchArticlePage(rawSnapshotURL(article.ArchiveURL), source, pageValidators{})
+	if err != nil {
+		return nil, article, fmt.Errorf("failed to fetch the archived copy: %v", err)
+	}
+	// Links on the archived page resolve against the original URL
+	page.URL = article.URL
+	return page, article, nil
+}
+
+// removeGone drops an article whose page is gone and has no archived copy
+// from the store, leaving a tombstone for syncing clients, once enough
+// separate checks have found the page gone
+func (ns *NewsService) removeGone(article models.NewsArticle, now time.Time) {
+	checks, err := ns.store.MarkGone(article.URL, now, goneInterval)
+	if err != nil {
+		log.Printf("Error saving the store after checking %s: %v", article.URL, err)
+	}
+	if checks < goneChecks {
+		log.Printf("%s is gone, keeping it until it has been gone for %d checks", article.URL, goneChecks)
+		return
+	}
+	removed, err := ns.store.Remove(article.URL, store.RemovedUpstream)
+	if err != nil {
+		log.Printf("Error saving the store after removing %s: %v", article.URL, err)
+	} else if removed {
+		log.Printf("Removed %s from the store, its page is gone", article.URL)
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+)
+
+// openGoneStore opens the store at path with article saved in it
+func openGoneStore(t *testing.T, path string, article models.NewsArticle) *NewsService {
+	t.Helper()
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	if _, ok := st.Get(article.URL); !ok {
+		if _, err := st.Save(article); err != nil {
+			t.Fatalf("failed to save article: %v", err)
+		}
+	}
+	return &NewsService{store: st}
+}
+
+func TestGoneArticleRemovedAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "articles.json")
+	article := models.NewsArticle{Key: "1", URL: "https://www.thedailystar.net/news/1", Source: "thedailystar"}
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	checks := []struct {
+		after   time.Duration
+		removed bool
+	}{
+		{0, false},
+		// Retries within the hour are the same check
+		{time.Minute, false},
+		{30 * time.Minute, false},
+		{time.Hour, false},
+		{2 * time.Hour, true},
+	}
+	for _, check := range checks {
+		// Every check runs on a freshly opened store, as on a recycled
+		// instance
+		ns := openGoneStore(t, path, article)
+		ns.removeGone(article, start.Add(check.after))
+
+		reopened, err := store.Open(path)
+		if err != nil {
+			t.Fatalf("failed to reopen store: %v", err)
+		}
+		if _, stored := reopened.Get(article.URL); stored == check.removed {
+			t.Errorf("check after %s: stored = %v, want %v", check.after, stored, !check.removed)
+		}
+	}
+
+	reopened, _ := store.Open(path)
+	tombstones := reopened.Tombstones(time.Time{}, "")
+	if len(tombstones) != 1 || tombstones[0].Reason != store.RemovedUpstream {
+		t.Errorf("tombstones = %+v, want one for the removed page", tombstones)
+	}
+}
+
+func TestGoneChecksClearedByFetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "articles.json")
+	article := models.NewsArticle{Key: "2", URL: "https://edition.cnn.com/2024/05/01/world/story", Source: "cnn"}
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	ns := openGoneStore(t, path, article)
+	ns.removeGone(article, start)
+	ns.removeGone(article, start.Add(time.Hour))
+	if err := ns.store.ClearGone(article.URL); err != nil {
+		t.Fatalf("failed to clear gone checks: %v", err)
+	}
+
+	ns = openGoneStore(t, path, article)
+	ns.removeGone(article, start.Add(2*time.Hour))
+	if _, stored := ns.store.Get(article.URL); !stored {
+		t.Error("page removed after a successful fetch started the count over")
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// websubPublisher advertises WebSub hubs on the generated feeds and pings
+// them when a feed changes, so feed readers subscribed through a hub get
+// updates pushed instead of polling
+type websubPublisher struct {
+	hubs    []string
+	baseURL string
+	client  *http.Client
+}
+
+// newWebSubPublisher configures WebSub from WEBSUB_HUBS, a comma-separated
+// list of hub URLs, and PUBLIC_BASE_URL, the public address of this API
+// used to name feeds when pinging hubs
+func newWebSubPublisher() *websubPublisher {
+	publisher := &websubPublisher{
+		baseURL: strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, hub := range strings.Split(os.Getenv("WEBSUB_HUBS"), ",") {
+		hub = strings.TrimSpace(hub)
+		if hub == "" {
+			continue
+		}
+		if parsed, err := url.Parse(hub); err != nil || parsed.Host == "" {
+			log.Printf("Ignoring invalid WEBSUB_HUBS entry %q", hub)
+			continue
+		}
+		publisher.hubs = append(publisher.hubs, hub)
+	}
+	if len(publisher.hubs) > 0 && publisher.baseURL == "" {
+		log.Printf("WEBSUB_HUBS is set without PUBLIC_BASE_URL, hubs are advertised but never notified")
+	}
+	return publisher
+}
+
+// advertise adds the hub and self Link headers to a feed response and
+// returns the links for embedding in the feed itself
+func (p *websubPublisher) advertise(c *gin.Context, self string) []atomLink {
+	if len(p.hubs) == 0 {
+		return nil
+	}
+	links := []atomLink{{Rel: "self", Href: self}}
+	headers := []string{fmt.Sprintf(`<%s>; rel="self"`, self)}
+	for _, hub := range p.hubs {
+		links = append(links, atomLink{Rel: "hub", Href: hub})
+		headers = append(headers, fmt.Sprintf(`<%s>; rel="hub"`, hub))
+	}
+	c.Header("Link", strings.Join(headers, ", "))
+	return links
+}
+
+// notify tells every hub that the feed at path has new content. Hubs then
+// fetch the feed and push it to subscribers
+func (p *websubPublisher) notify(path string) {
+	if len(p.hubs) == 0 || p.baseURL == "" {
+		return
+	}
+	topic := p.baseURL + path
+	for _, hub := range p.hubs {
+		go func(hub string) {
+			resp, err := p.client.PostForm(hub, url.Values{"hub.mode": {"publish"}, "hub.url": {topic}})
+			if err != nil {
+				log.Printf("Error notifying WebSub hub %s about %s: %v", hub, topic, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Printf("WebSub hub %s rejected the update of %s: %d", hub, topic, resp.StatusCode)
+			}
+		}(hub)
+	}
+}
+
+// atomLink is an <atom:link> element, used in RSS feeds for WebSub
+// discovery
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// requestBaseURL returns the scheme and host the request was made to
+func requestBaseURL(c *gin.Context) string {
+	scheme := "https"
+	if c.Request.TLS == nil && c.GetHeader("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return scheme + "://" + c.Request.Host
+}
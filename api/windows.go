@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"top-news/models"
+	"top-news/store"
+)
+
+// scrapeWindows keeps fragile sites from being fetched during their quiet
+// hours or more often than they can take. Every upstream fetch is checked
+// against its site's window, whichever subsystem makes it
+type scrapeWindows struct {
+	// sites holds the windows by site (see politenessKey), sources by
+	// source name; both point at the same windows
+	sites   map[string]*siteWindow
+	sources map[string]*siteWindow
+
+	mu sync.Mutex
+	// deferred is when each source that was asked for while its window
+	// was closed will be scraped
+	deferred map[string]time.Time
+}
+
+// siteWindow is one source's window and its recent fetches
+type siteWindow struct {
+	source   string
+	location *time.Location
+	quiet    []quietRange
+	max      int
+	hits     []time.Time
+}
+
+// quietRange is a span of the day in minutes after midnight. It wraps past
+// midnight when end is before start
+type quietRange struct {
+	start, end int
+}
+
+// newScrapeWindows loads the windows from the JSON file at
+// SCRAPE_WINDOWS_PATH, or inline JSON in SCRAPE_WINDOWS, keyed by source
+// name. It returns nil when none are configured or they are invalid
+func newScrapeWindows(sources map[string]models.Source) *scrapeWindows {
+	data := []byte(os.Getenv("SCRAPE_WINDOWS"))
+	if path := os.Getenv("SCRAPE_WINDOWS_PATH"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("Error reading scrape windows, sources are fetched at any time: %v", err)
+			return nil
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	var config map[string]models.ScrapeWindow
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error decoding scrape windows, sources are fetched at any time: %v", err)
+		return nil
+	}
+	w, err := compileScrapeWindows(sources, config)
+	if err != nil {
+		log.Printf("Invalid scrape windows, sources are fetched at any time: %v", err)
+		return nil
+	}
+	return w
+}
+
+// compileScrapeWindows checks the windows and parses their quiet hours
+func compileScrapeWindows(sources map[string]models.Source, config map[string]models.ScrapeWindow) (*scrapeWindows, error) {
+	w := &scrapeWindows{sites: map[string]*siteWindow{}, sources: map[string]*siteWindow{}, deferred: map[string]time.Time{}}
+	for name, window := range config {
+		source, ok := sources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q", name)
+		}
+		if window.MaxRequestsPerHour < 0 {
+			return nil, fmt.Errorf("%s: max_requests_per_hour must not be negative", name)
+		}
+		parsed, err := url.Parse(source.URL)
+		if err != nil || parsed.Hostname() == "" {
+			return nil, fmt.Errorf("%s: invalid url %q", name, source.URL)
+		}
+		site := &siteWindow{source: name, location: sourceLocation(source), max: window.MaxRequestsPerHour}
+		for _, hours := range window.QuietHours {
+			var startHour, startMinute, endHour, endMinute int
+			if _, err := fmt.Sscanf(hours, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute); err != nil ||
+				startHour < 0 || startHour > 23 || endHour < 0 || endHour > 24 || startMinute < 0 || startMinute > 59 || endMinute < 0 || endMinute > 59 {
+				return nil, fmt.Errorf("%s: invalid quiet hours %q, use HH:MM-HH:MM", name, hours)
+			}
+			site.quiet = append(site.quiet, quietRange{start: startHour*60 + startMinute, end: endHour*60 + endMinute})
+		}
+		key := politenessKey(parsed.Hostname())
+		if other, ok := w.sites[key]; ok {
+			return nil, fmt.Errorf("%s and %s share the site %s, give only one of them a window", other.source, name, key)
+		}
+		w.sites[key] = site
+		w.sources[name] = site
+	}
+	return w, nil
+}
+
+// until returns when a source's window reopens, or the zero time while it
+// is open
+func (w *scrapeWindows) until(source string) time.Time {
+	if w == nil || w.sources[source] == nil {
+		return time.Time{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sources[source].reopens(time.Now())
+}
+
+// take records a fetch from host, or fails while its site's window is
+// closed
+func (w *scrapeWindows) take(host string) error {
+	site := w.sites[politenessKey(host)]
+	if site == nil {
+		return nil
+	}
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if until := site.reopens(now); !until.IsZero() {
+		return fmt.Errorf("scrape window for %s is closed until %s", site.source, until.Format(time.RFC3339))
+	}
+	site.hits = append(site.hits, now)
+	return nil
+}
+
+// reopens returns when the window is next open from now on, the zero time
+// when it is open now. The caller holds the lock
+func (s *siteWindow) reopens(now time.Time) time.Time {
+	// Forget fetches that no longer count against the hourly cap
+	kept := s.hits[:0]
+	for _, hit := range s.hits {
+		if now.Sub(hit) < time.Hour {
+			kept = append(kept, hit)
+		}
+	}
+	s.hits = kept
+
+	// Waiting out quiet hours may run into the cap and the other way
+	// round, so repeat until neither moves the time
+	at := now
+	for i := 0; i < 4; i++ {
+		moved := false
+		if end, quiet := s.quietUntil(at); quiet {
+			at, moved = end, true
+		}
+		if s.max > 0 {
+			recent := 0
+			for _, hit := range s.hits {
+				if at.Sub(hit) < time.Hour {
+					recent++
+				}
+			}
+			if recent >= s.max {
+				at, moved = s.hits[len(s.hits)-s.max].Add(time.Hour), true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	if at.Equal(now) {
+		return time.Time{}
+	}
+	return at
+}
+
+// quietUntil reports whether t falls in quiet hours, and when they end
+func (s *siteWindow) quietUntil(t time.Time) (time.Time, bool) {
+	local := t.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+	for _, quiet := range s.quiet {
+		var inside bool
+		if quiet.start <= quiet.end {
+			inside = minute >= quiet.start && minute < quiet.end
+		} else {
+			inside = minute >= quiet.start || minute < quiet.end
+		}
+		if !inside {
+			continue
+		}
+		end := midnight.Add(time.Duration(quiet.end) * time.Minute)
+		if quiet.end <= minute {
+			end = end.AddDate(0, 0, 1)
+		}
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+// transport wraps next so fetches from sites with a closed window fail
+// before reaching them. A nil set of windows passes requests straight
+// through
+func (w *scrapeWindows) transport(next http.RoundTripper) http.RoundTripper {
+	if w == nil {
+		return next
+	}
+	return &windowTransport{next: next, windows: w}
+}
+
+// windowTransport refuses fetches outside a site's scrape window
+type windowTransport struct {
+	next    http.RoundTripper
+	windows *scrapeWindows
+}
+
+func (t *windowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.windows.take(req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// deferScrape queues a scrape of a source for when its window reopens.
+// Asking again before then does not queue another
+func (ns *NewsService) deferScrape(name string, until time.Time) {
+	w := ns.windows
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, queued := w.deferred[name]; queued {
+		return
+	}
+	w.deferred[name] = until
+	time.AfterFunc(time.Until(until), func() {
+		w.mu.Lock()
+		delete(w.deferred, name)
+		w.mu.Unlock()
+		log.Printf("Scrape window for %s reopened, running the deferred scrape", name)
+		if _, err := ns.fetchNewsFromSource(name, ns.sources[name].URL, scrapeOptions{limit: defaultSourceLimit, fresh: true}); err != nil {
+			log.Printf("Deferred scrape of %s failed: %v", name, err)
+		}
+	})
+}
+
+// storedNews serves a source's newest stored articles while its window is
+// closed
+func (ns *NewsService) storedNews(name string, limit int, until time.Time) ([]models.NewsArticle, error) {
+	if limit == 0 {
+		limit = defaultSourceLimit
+	}
+	articles := ns.store.List(store.Filter{Source: name, Limit: limit})
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("scrape window for %s is closed until %s and nothing is stored yet", name, until.Format(time.RFC3339))
+	}
+	return articles, nil
+}
@@ -0,0 +1,255 @@
+// Command loadtest drives a steady request rate against a running
+// instance and reports latency percentiles and error rates, e.g.
+//
+//	go run ./cmd/loadtest -url http://localhost:8080 -rps 20 -duration 1m
+//
+// By default requests are spread over /api/v1/news and the news of every
+// active source; -mix sets other paths and their weights
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// target is a path requested with a relative weight
+type target struct {
+	path   string
+	weight int
+}
+
+// result is the outcome of one request
+type result struct {
+	path    string
+	status  int
+	latency time.Duration
+	err     error
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "base URL of the instance")
+	rps := flag.Float64("rps", 10, "requests per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to run")
+	mix := flag.String("mix", "", `comma-separated path=weight pairs, e.g. "/api/v1/news=3,/api/v1/news/cnn=1"`)
+	maxInFlight := flag.Int("max-inflight", 200, "requests allowed in flight before new ones are dropped")
+	timeout := flag.Duration("timeout", 60*time.Second, "per-request timeout")
+	flag.Parse()
+
+	if *rps <= 0 {
+		fail(fmt.Errorf("-rps must be positive"))
+	}
+	base := strings.TrimRight(*baseURL, "/")
+	client := &http.Client{Timeout: *timeout}
+
+	targets, err := parseMix(*mix)
+	if err == nil && len(targets) == 0 {
+		targets, err = defaultMix(client, base)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Printf("Running %.1f req/s for %s against %s\n", *rps, *duration, base)
+	for _, t := range targets {
+		fmt.Printf("  %-40s weight %d\n", t.path, t.weight)
+	}
+
+	results := run(client, base, targets, *rps, *duration, *maxInFlight)
+	report(results, *duration)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
+
+// parseMix reads the -mix flag
+func parseMix(mix string) ([]target, error) {
+	targets := []target{}
+	for _, pair := range strings.Split(mix, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		// the weight follows the last "=", so paths can carry a query
+		t := target{path: pair, weight: 1}
+		if i := strings.LastIndex(pair, "="); i >= 0 {
+			n, err := strconv.Atoi(pair[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid weight in -mix entry %q", pair)
+			}
+			t.path, t.weight = pair[:i], n
+		}
+		if !strings.HasPrefix(t.path, "/") {
+			return nil, fmt.Errorf("-mix paths must start with /: %q", pair)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// defaultMix requests all news twice as often as each source's news
+func defaultMix(client *http.Client, base string) ([]target, error) {
+	resp, err := client.Get(base + "/api/v1/sources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %v", err)
+	}
+	defer resp.Body.Close()
+	var sources models.SourcesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sources); err != nil {
+		return nil, fmt.Errorf("failed to decode sources: %v", err)
+	}
+
+	targets := []target{{path: "/api/v1/news", weight: 2}}
+	for _, source := range sources.Sources {
+		if source.Active {
+			targets = append(targets, target{path: "/api/v1/news/" + source.Name, weight: 1})
+		}
+	}
+	return targets, nil
+}
+
+// pick chooses a target by weight
+func pick(targets []target, total int) string {
+	n := rand.Intn(total)
+	for _, t := range targets {
+		if n < t.weight {
+			return t.path
+		}
+		n -= t.weight
+	}
+	return targets[len(targets)-1].path
+}
+
+// run fires requests at a fixed rate, whether or not earlier ones have
+// finished, so slow responses show up as latency rather than lower load
+func run(client *http.Client, base string, targets []target, rps float64, duration time.Duration, maxInFlight int) []result {
+	total := 0
+	for _, t := range targets {
+		total += t.weight
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := []result{}
+	inFlight := make(chan struct{}, maxInFlight)
+	dropped := 0
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+	defer ticker.Stop()
+	deadline := time.After(duration)
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			dropped++
+			continue
+		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			r := result{path: path}
+			start := time.Now()
+			resp, err := client.Get(base + path)
+			if err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				r.status = resp.StatusCode
+			}
+			r.latency = time.Since(start)
+			r.err = err
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}(pick(targets, total))
+	}
+	wg.Wait()
+
+	if dropped > 0 {
+		fmt.Printf("\n%d requests were not sent because %d were already in flight\n", dropped, maxInFlight)
+	}
+	return results
+}
+
+// report prints overall and per-path latency percentiles and errors
+func report(results []result, duration time.Duration) {
+	if len(results) == 0 {
+		fmt.Println("\nNo requests completed")
+		return
+	}
+	byPath := map[string][]result{}
+	for _, r := range results {
+		byPath[r.path] = append(byPath[r.path], r)
+	}
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Printf("\n%d requests in %s (%.1f req/s)\n\n", len(results), duration, float64(len(results))/duration.Seconds())
+	fmt.Printf("%-40s %7s %9s %9s %9s %9s %8s\n", "path", "count", "p50", "p90", "p99", "max", "errors")
+	for _, path := range paths {
+		printRow(path, byPath[path])
+	}
+	printRow("all", results)
+
+	statuses := map[string]int{}
+	for _, r := range results {
+		key := strconv.Itoa(r.status)
+		if r.err != nil {
+			key = "network error"
+		}
+		statuses[key]++
+	}
+	keys := make([]string, 0, len(statuses))
+	for key := range statuses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Println("\nResponses:")
+	for _, key := range keys {
+		fmt.Printf("  %-14s %d\n", key, statuses[key])
+	}
+}
+
+func printRow(label string, results []result) {
+	latencies := make([]time.Duration, len(results))
+	errors := 0
+	for i, r := range results {
+		latencies[i] = r.latency
+		if r.err != nil || r.status >= 500 {
+			errors++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Printf("%-40s %7d %9s %9s %9s %9s %7.1f%%\n", label, len(results),
+		percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), latencies[len(latencies)-1].Round(time.Millisecond),
+		100*float64(errors)/float64(len(results)))
+}
+
+// percentile reads a percentile from sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index].Round(time.Millisecond)
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	handler "top-news/api"
+	"top-news/models"
+	"top-news/secrets"
+	"top-news/store"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: newsctl <command> [flags]
+
+Commands:
+  backfill   Populate the article store from a source's sitemap
+  bench      Time parsing, enrichment and JSON encoding on fixture pages
+  check      Validate the configuration and check every source is reachable
+  migrate    Bring the article store up to the latest schema
+  prune      Drop stored articles past a retention policy
+  secrets    Create a secrets key, encrypt a credential or rotate config files
+  serve      Run the API locally, optionally with mock data
+  store      Export the article store to a file, or import one into it
+
+Run "newsctl <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "backfill":
+		err = backfill(os.Args[2:])
+	case "serve":
+		err = serve(os.Args[2:])
+	case "bench":
+		err = bench(os.Args[2:])
+	case "check":
+		err = check(os.Args[2:])
+	case "prune":
+		err = prune(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	case "store":
+		err = storeCommand(os.Args[2:])
+	case "secrets":
+		err = secretsCommand(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// backfill walks the sitemap of a source and stores past articles
+func backfill(args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	source := flags.String("source", "", "source to backfill, e.g. thedailystar")
+	from := flags.String("from", "", "earliest publish date to include (YYYY-MM-DD)")
+	to := flags.String("to", "", "latest publish date to include (YYYY-MM-DD, default today)")
+	max := flags.Int("max", 500, "maximum number of article pages to fetch")
+	storePath := flags.String("store", os.Getenv("STORE_PATH"), "article store file (defaults to $STORE_PATH)")
+	flags.Parse(args)
+
+	if *source == "" || *from == "" {
+		flags.Usage()
+		return fmt.Errorf("--source and --from are required")
+	}
+	if *storePath == "" {
+		return fmt.Errorf("--store or STORE_PATH is required, otherwise backfilled articles would be lost")
+	}
+
+	opts := handler.BackfillOptions{MaxArticles: *max}
+	var err error
+	if opts.From, err = time.Parse("2006-01-02", *from); err != nil {
+		return fmt.Errorf("invalid --from date: %v", err)
+	}
+	if *to != "" {
+		if opts.To, err = time.Parse("2006-01-02", *to); err != nil {
+			return fmt.Errorf("invalid --to date: %v", err)
+		}
+		// Include the whole last day
+		opts.To = opts.To.Add(24*time.Hour - time.Nanosecond)
+	}
+	opts.Progress = func(done, total int, article models.NewsArticle, err error) {
+		if err != nil {
+			fmt.Printf("[%d/%d] skipped %s: %v\n", done, total, article.URL, err)
+			return
+		}
+		fmt.Printf("[%d/%d] %s\n", done, total, article.Title)
+	}
+
+	os.Setenv("STORE_PATH", *storePath)
+	added, err := handler.NewNewsService().Backfill(*source, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backfill complete: %d new articles stored in %s\n", added, *storePath)
+	return nil
+}
+
+// prune drops stored articles past a retention policy once, e.g. before
+// turning retention on for a large store
+func prune(args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	days := flags.Int("days", 0, "keep articles published in the last this many days")
+	maxPerSource := flags.Int("max-per-source", 0, "keep only each source's newest this many articles")
+	storePath := flags.String("store", os.Getenv("STORE_PATH"), "article store file (defaults to $STORE_PATH)")
+	flags.Parse(args)
+
+	if *days <= 0 && *maxPerSource <= 0 {
+		flags.Usage()
+		return fmt.Errorf("--days or --max-per-source is required")
+	}
+	if *storePath == "" {
+		return fmt.Errorf("--store or STORE_PATH is required")
+	}
+	articles, err := store.Open(*storePath)
+	if err != nil {
+		return err
+	}
+	before := articles.Len()
+	removed, err := articles.Prune(store.Retention{MaxAge: time.Duration(*days) * 24 * time.Hour, MaxPerSource: *maxPerSource}, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d of %d articles from %s\n", removed, before, *storePath)
+	return nil
+}
+
+// migrate runs the store's pending schema migrations, for deployments
+// that set STORE_MIGRATE=off and migrate before rolling out
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the pending migrations without running them")
+	storePath := flags.String("store", os.Getenv("STORE_PATH"), "article store file (defaults to $STORE_PATH)")
+	flags.Parse(args)
+
+	if *storePath == "" {
+		return fmt.Errorf("--store or STORE_PATH is required")
+	}
+	from, pending, err := handler.MigrateStore(*storePath, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Printf("%s is at the latest schema version, %d\n", *storePath, handler.StoreSchemaVersion())
+		return nil
+	}
+	verb := "Ran"
+	if *dryRun {
+		verb = "Pending"
+	}
+	for _, name := range pending {
+		fmt.Printf("%s: %s\n", verb, name)
+	}
+	if !*dryRun {
+		fmt.Printf("Migrated %s from schema version %d to %d\n", *storePath, from, handler.StoreSchemaVersion())
+	}
+	return nil
+}
+
+// storeFormats are the formats the store is exported and imported in:
+// one article per line, or a JSON array like the store file itself
+var storeFormats = map[string]bool{"ndjson": true, "json": true}
+
+// storeCommand runs store export or store import, for moving articles
+// between deployments or seeding a staging store with production ones
+func storeCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: newsctl store export|import [flags]")
+	}
+	switch args[0] {
+	case "export":
+		return storeExport(args[1:])
+	case "import":
+		return storeImport(args[1:])
+	}
+	return fmt.Errorf("unknown store command %q, want export or import", args[0])
+}
+
+// storeExport writes the stored articles, newest first
+func storeExport(args []string) error {
+	flags := flag.NewFlagSet("store export", flag.ExitOnError)
+	format := flags.String("format", "ndjson", "output format, ndjson or json")
+	source := flags.String("source", "", "export only this source's articles")
+	out := flags.String("out", "", "file to write (defaults to stdout)")
+	storePath := flags.String("store", os.Getenv("STORE_PATH"), "article store file (defaults to $STORE_PATH)")
+	flags.Parse(args)
+
+	if !storeFormats[*format] {
+		return fmt.Errorf("unknown --format %q, want ndjson or json", *format)
+	}
+	if *storePath == "" {
+		return fmt.Errorf("--store or STORE_PATH is required")
+	}
+	articles, err := store.Open(*storePath)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	buffered := bufio.NewWriter(w)
+	list := articles.List(store.Filter{Source: *source})
+	encoder := json.NewEncoder(buffered)
+	if *format == "json" {
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(list)
+	} else {
+		for _, article := range list {
+			if err = encoder.Encode(article); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write articles: %v", err)
+	}
+	// The summary goes to stderr so it never ends up in a piped export
+	fmt.Fprintf(os.Stderr, "Exported %d articles from %s\n", len(list), *storePath)
+	return nil
+}
+
+// storeImport adds the articles of an export to the store. Articles already
+// stored are updated, keeping the earliest publish time seen
+func storeImport(args []string) error {
+	flags := flag.NewFlagSet("store import", flag.ExitOnError)
+	format := flags.String("format", "ndjson", "input format, ndjson or json")
+	in := flags.String("in", "", "file to read (defaults to stdin)")
+	storePath := flags.String("store", os.Getenv("STORE_PATH"), "article store file (defaults to $STORE_PATH)")
+	flags.Parse(args)
+
+	if !storeFormats[*format] {
+		return fmt.Errorf("unknown --format %q, want ndjson or json", *format)
+	}
+	if *storePath == "" {
+		return fmt.Errorf("--store or STORE_PATH is required, otherwise imported articles would be lost")
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", *in, err)
+		}
+		defer file.Close()
+		r = file
+	}
+	imported := []models.NewsArticle{}
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	if *format == "json" {
+		if err := decoder.Decode(&imported); err != nil {
+			return fmt.Errorf("failed to decode articles: %v", err)
+		}
+	} else {
+		for line := 1; ; line++ {
+			var article models.NewsArticle
+			err := decoder.Decode(&article)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to decode article %d: %v", line, err)
+			}
+			imported = append(imported, article)
+		}
+	}
+	skipped := 0
+	for _, article := range imported {
+		if article.URL == "" {
+			skipped++
+		}
+	}
+
+	articles, err := store.Open(*storePath)
+	if err != nil {
+		return err
+	}
+	// One save writes the store once, however many articles there are
+	added, err := articles.Save(imported...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d articles into %s: %d new, %d updated, %d skipped without a url\n",
+		len(imported)-skipped, *storePath, added, len(imported)-skipped-added, skipped)
+	return nil
+}
+
+// secretsCommand manages encrypted integration credentials: keygen prints
+// a new SECRETS_KEY, encrypt seals a credential read from stdin, and
+// rotate seals a config file's credentials again with the current key
+func secretsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: newsctl secrets keygen|encrypt|rotate [flags]")
+	}
+	switch args[0] {
+	case "keygen":
+		fmt.Println(secrets.GenerateKey())
+		return nil
+	case "encrypt":
+		return secretsEncrypt(args[1:])
+	case "rotate":
+		return secretsRotate(args[1:])
+	}
+	return fmt.Errorf("unknown secrets command %q, want keygen, encrypt or rotate", args[0])
+}
+
+// secretsEncrypt seals the credential on stdin with SECRETS_KEY, so it can
+// go in a config file or env var in place of the plain value
+func secretsEncrypt(args []string) error {
+	flags := flag.NewFlagSet("secrets encrypt", flag.ExitOnError)
+	flags.Parse(args)
+
+	keys, err := secrets.FromEnv()
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("SECRETS_KEY or SECRETS_KEY_PATH is required")
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %v", err)
+	}
+	sealed, err := keys.Seal(strings.TrimRight(string(data), "\r\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(sealed)
+	return nil
+}
+
+// secretsRotate rewrites config files with their credentials sealed with
+// the current SECRETS_KEY. The keys they were sealed with go in
+// SECRETS_OLD_KEYS while rotating
+func secretsRotate(args []string) error {
+	flags := flag.NewFlagSet("secrets rotate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "count the credentials to rotate without writing the files")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: newsctl secrets rotate [--dry-run] <config file>...")
+	}
+	keys, err := secrets.FromEnv()
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("SECRETS_KEY or SECRETS_KEY_PATH is required")
+	}
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		rotated, count, err := keys.RotateJSON(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if count > 0 && !*dryRun {
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %v", path, err)
+			}
+			// Through a temporary file, so a failed write never loses the
+			// only copy of the credentials
+			if err := os.WriteFile(path+".tmp", rotated, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write %s: %v", path, err)
+			}
+			if err := os.Rename(path+".tmp", path); err != nil {
+				return fmt.Errorf("failed to replace %s: %v", path, err)
+			}
+		}
+		if *dryRun {
+			fmt.Printf("%s: %d credentials to rotate\n", path, count)
+		} else {
+			fmt.Printf("%s: %d credentials rotated\n", path, count)
+		}
+	}
+	return nil
+}
+
+// serve runs the API on a local port. With --mock it serves fixture
+// articles instead of scraping, for building frontends offline
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	addr := flags.String("addr", ":"+port, "address to listen on")
+	mock := flags.Bool("mock", false, "serve fixture articles instead of scraping live sites")
+	latency := flags.Duration("latency", 0, "delay added to every mock scrape, e.g. 300ms")
+	errorRate := flags.Float64("error-rate", 0, "share of mock scrapes that fail, from 0 to 1")
+	flags.Parse(args)
+
+	if *errorRate < 0 || *errorRate > 1 {
+		return fmt.Errorf("--error-rate must be between 0 and 1")
+	}
+	if *mock {
+		handler.EnableMock(handler.MockOptions{Latency: *latency, ErrorRate: *errorRate})
+		log.Printf("Mock mode: serving fixture articles (latency %s, error rate %.2f)", *latency, *errorRate)
+	}
+
+	// Check the configuration before taking traffic, so STARTUP_CHECK=strict
+	// can stop a misconfigured server here
+	if err := handler.Startup(); err != nil {
+		return err
+	}
+	log.Printf("Listening on %s", *addr)
+	return http.ListenAndServe(*addr, http.HandlerFunc(handler.Handler))
+}
+
+// check runs the configuration self-check and prints the report
+func check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	report := handler.CheckConfig()
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range report.Checks {
+			status := "ok  "
+			if !c.OK {
+				status = "FAIL"
+			}
+			fmt.Printf("%s  %-22s %s\n", status, c.Name, c.Message)
+		}
+	}
+	if !report.OK {
+		return fmt.Errorf("the configuration has problems")
+	}
+	return nil
+}
+
+// benchResult is one benchmark's timing. PerOp counts the articles handled
+// in each operation, for reporting throughput
+type benchResult struct {
+	Name   string                  `json:"name"`
+	PerOp  int                     `json:"articles_per_op"`
+	Result testing.BenchmarkResult `json:"result"`
+}
+
+// articlesPerSecond is the throughput of the benchmark
+func (r benchResult) articlesPerSecond() float64 {
+	if r.Result.NsPerOp() == 0 {
+		return 0
+	}
+	return float64(r.PerOp) * 1e9 / float64(r.Result.NsPerOp())
+}
+
+// bench runs the benchmark suite and, given a baseline from an earlier
+// --save, fails when a benchmark got slower than the tolerance allows
+func bench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory with <source>_home.html and <source>_article.html pages to use instead of the built-in fixtures")
+	articles := flags.Int("articles", 100, "number of articles in the JSON encoding benchmarks")
+	filter := flags.String("run", "", "regular expression selecting benchmarks by name, e.g. ^parse/")
+	save := flags.String("save", "", "write the results as JSON to this file")
+	baseline := flags.String("baseline", "", "compare against results saved earlier with --save")
+	tolerance := flags.Float64("tolerance", 0.2, "allowed slowdown against the baseline, 0.2 is 20%")
+	flags.Parse(args)
+
+	// Benchmarks would otherwise log every enrichment and scrape
+	log.SetOutput(io.Discard)
+	cases, err := handler.BenchCases(handler.BenchOptions{Dir: *dir, Articles: *articles, Filter: *filter})
+	results := []benchResult{}
+	for _, bench := range cases {
+		var failed error
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bench.Run(); err != nil {
+					failed = err
+					b.FailNow()
+				}
+			}
+		})
+		if failed != nil {
+			err = fmt.Errorf("%s failed: %v", bench.Name, failed)
+			break
+		}
+		results = append(results, benchResult{Name: bench.Name, PerOp: bench.PerOp, Result: result})
+	}
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]benchResult{}
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err != nil {
+			return fmt.Errorf("failed to read baseline: %v", err)
+		}
+		var saved []benchResult
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to decode baseline: %v", err)
+		}
+		for _, result := range saved {
+			previous[result.Name] = result
+		}
+	}
+
+	fmt.Printf("%-22s %10s %14s %12s %12s %14s %9s\n", "benchmark", "runs", "ns/op", "B/op", "allocs/op", "articles/s", "change")
+	regressions := []string{}
+	for _, result := range results {
+		change := ""
+		if before, ok := previous[result.Name]; ok && before.Result.NsPerOp() > 0 {
+			ratio := float64(result.Result.NsPerOp())/float64(before.Result.NsPerOp()) - 1
+			change = fmt.Sprintf("%+.1f%%", ratio*100)
+			if ratio > *tolerance {
+				regressions = append(regressions, result.Name)
+			}
+		}
+		fmt.Printf("%-22s %10d %14d %12d %12d %14.0f %9s\n", result.Name, result.Result.N, result.Result.NsPerOp(),
+			result.Result.AllocedBytesPerOp(), result.Result.AllocsPerOp(), result.articlesPerSecond(), change)
+	}
+
+	if *save != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*save, data, 0o644); err != nil {
+			return fmt.Errorf("failed to save results: %v", err)
+		}
+	}
+	if len(regressions) > 0 {
+		return fmt.Errorf("slower than the baseline by more than %.0f%%: %s", *tolerance*100, strings.Join(regressions, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+package models
+
+// The TypeScript client mirrors these models
+//go:generate go run ../cmd/tsgen -o ../clients/ts/top-news.ts
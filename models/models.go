@@ -1,17 +1,108 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // NewsArticle represents a single news article
 type NewsArticle struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	ImageURL    string    `json:"image_url"`
-	URL         string    `json:"url"`
+	ID string `json:"id"`
+	// Key is stable across scrapes, derived from the canonical URL
+	Key         string `json:"key,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	// ImageCaption and ImageCredit describe the image and name its
+	// photographer or agency, for attribution
+	ImageCaption string `json:"image_caption,omitempty"`
+	ImageCredit  string `json:"image_credit,omitempty"`
+	// Images are every photo of a gallery, in page order
+	Images []ArticleImage `json:"images,omitempty"`
+	URL    string         `json:"url"`
+	// ArchiveURL is a Wayback Machine copy of the article, found once its
+	// URL stopped working
+	ArchiveURL  string    `json:"archive_url,omitempty"`
 	Source      string    `json:"source"`
 	PublishedAt time.Time `json:"published_at"`
 	Category    string    `json:"category,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Summary     string    `json:"summary,omitempty"`
+	// Robots are the robots directives of the article's page, e.g. noai,
+	// noarchive or max-snippet:50, for deciding how it may be reused
+	Robots []string `json:"robots,omitempty"`
+	// ContentWarning flags graphic or distressing stories, e.g. violence
+	ContentWarning string `json:"content_warning,omitempty"`
+	// Type is news, opinion, analysis, gallery or announcement
+	Type string `json:"type,omitempty"`
+	// Announcement is the kind of announcement: obituary, notice,
+	// horoscope or prayer_times
+	Announcement string `json:"announcement,omitempty"`
+	// IsSponsored marks press releases, advertorials and paid content
+	IsSponsored bool `json:"is_sponsored"`
+	// IsWire marks stories syndicated from news agencies such as UNB or BSS
+	IsWire bool `json:"is_wire"`
+	// Locations are the Bangladeshi districts and world capitals the
+	// article mentions, in the order they first appear
+	Locations []Location `json:"locations,omitempty"`
+	// Score is the match score of sports stories that report one
+	Score *Score `json:"score,omitempty"`
+	// Figures are the money amounts, exchange rates and commodity prices
+	// business stories mention
+	Figures []Figure `json:"figures,omitempty"`
+}
+
+// ArticleImage is one photo of a gallery
+type ArticleImage struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption,omitempty"`
+	Credit  string `json:"credit,omitempty"`
+}
+
+// Figure is a monetary figure found in an article
+type Figure struct {
+	// Kind is amount, exchange_rate or price
+	Kind string `json:"kind"`
+	// Text is the figure as written, e.g. "Tk 7.97 lakh crore"
+	Text string `json:"text"`
+	// Amount is in units of Currency, scales such as crore applied
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	// Per is the currency an exchange rate is quoted against or the unit
+	// a price is for, e.g. USD or kg
+	Per       string `json:"per,omitempty"`
+	Commodity string `json:"commodity,omitempty"`
+}
+
+// Score is a cricket or football score for tickers
+type Score struct {
+	Sport string `json:"sport"`
+	// Summary is a ticker line such as "BAN 245/6 (48.2 ov)" or
+	// "ARG 2-1 BRA"
+	Summary string      `json:"summary"`
+	Teams   []TeamScore `json:"teams"`
+}
+
+// TeamScore is one side's part of a score
+type TeamScore struct {
+	Team string `json:"team"`
+	// Code is the ticker code of national sides, e.g. BAN
+	Code string `json:"code,omitempty"`
+	// Score is runs/wickets for cricket, e.g. 245/6, and goals for football
+	Score string `json:"score"`
+	Overs string `json:"overs,omitempty"`
+}
+
+// Location is a place an article mentions
+type Location struct {
+	Name string `json:"name"`
+	// Type is district or capital
+	Type     string  `json:"type"`
+	Division string  `json:"division,omitempty"`
+	Country  string  `json:"country"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
 }
 
 // NewsResponse represents the API response for news
@@ -20,12 +111,64 @@ type NewsResponse struct {
 	Data    []NewsArticle `json:"data"`
 	Count   int           `json:"count"`
 	Source  string        `json:"source,omitempty"`
+	// FetchedAt is when the source was last scraped successfully
+	FetchedAt *time.Time     `json:"fetched_at,omitempty"`
+	Debug     *SelectorDebug `json:"debug,omitempty"`
+	// Error and Message are set instead of data when the scrape failed,
+	// as in ErrorResponse
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GroupedNewsResponse is the news of every source, keyed by source name,
+// returned for group_by=source
+type GroupedNewsResponse struct {
+	Success bool                  `json:"success"`
+	Sources map[string]SourceNews `json:"sources"`
+	// Count is the number of articles across all sources
+	Count int `json:"count"`
+}
+
+// SourceNews is one source's part of a grouped news response. Error is set
+// when its scrape failed
+type SourceNews struct {
+	Articles  []NewsArticle `json:"articles"`
+	Count     int           `json:"count"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// SelectorDebug describes how a scrape's selectors behaved
+type SelectorDebug struct {
+	Articles  []ArticleSelectors `json:"articles"`
+	Hits      map[string]int     `json:"selector_hits"`
+	Unmatched []string           `json:"unmatched_selectors"`
+	Skipped   map[string]int     `json:"skipped"`
+}
+
+// ArticleSelectors records which selector filled each field of an article
+type ArticleSelectors struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+	// Provenance is only filled for debug=provenance
+	Provenance map[string]FieldProvenance `json:"provenance,omitempty"`
+}
+
+// FieldProvenance describes how a field was extracted and how much the
+// value can be trusted
+type FieldProvenance struct {
+	// Method is json-ld, og, meta, css or heuristic
+	Method     string  `json:"method"`
+	Selector   string  `json:"selector"`
+	Confidence float64 `json:"confidence"`
+	Cached     bool    `json:"cached,omitempty"`
 }
 
 // SourcesResponse represents the API response for available sources
 type SourcesResponse struct {
 	Success bool     `json:"success"`
 	Sources []Source `json:"sources"`
+	Count   int      `json:"count"`
 }
 
 // Source represents a news source configuration
@@ -34,6 +177,453 @@ type Source struct {
 	DisplayName string `json:"display_name"`
 	URL         string `json:"url"`
 	Active      bool   `json:"active"`
+	// Timezone is the IANA zone publish times are written in, e.g. Asia/Dhaka
+	Timezone string `json:"timezone,omitempty"`
+	// Locale decides how ambiguous dates are read, e.g. en-US is month-first
+	// while en-BD and bn-BD are day-first and may use Bangla digits and months
+	Locale string `json:"locale,omitempty"`
+	// Enrichment lists the article page enrichment stages to run, nil
+	// means all of them
+	Enrichment []string `json:"enrichment,omitempty"`
+	// MaxPages is how many homepage/pagination pages may be crawled when
+	// the first page has fewer articles than requested
+	MaxPages int `json:"max_pages"`
+	// PaginationSelector matches "next page" or "load more" links
+	PaginationSelector string `json:"-"`
+	// SitemapURL points at the sitemap (or sitemap index) used for backfills
+	SitemapURL string `json:"-"`
+	// DistrictURL is the source's page for a Bangladeshi district, with
+	// {district} standing for the district's slug, e.g. coxs-bazar
+	DistrictURL string `json:"-"`
+	// Kind is empty for news sources and "factcheck" for fact-checkers
+	Kind string `json:"kind,omitempty"`
+	// FeedURL is the RSS feed of sources read from a feed
+	FeedURL string `json:"feed_url,omitempty"`
+	// Degraded is set while the last scrape or self-check of the source
+	// failed
+	Degraded bool `json:"degraded,omitempty"`
+	// PausedUntil is set while the source's scrape window is closed;
+	// stored articles are served until it reopens
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	// Schedule is the source's background scrape interval or cron
+	// expression, with when it was last scraped and runs next
+	Schedule    string     `json:"schedule,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	NextFetchAt *time.Time `json:"next_fetch_at,omitempty"`
+	// Fallbacks are tried in order when a homepage scrape fails or finds
+	// nothing, except text-only mirrors, which are tried first
+	Fallbacks []SourceFallback `json:"fallbacks,omitempty"`
+	// Robots are the robots directives on the source's latest enriched
+	// page, e.g. noai or noarchive
+	Robots []string `json:"robots,omitempty"`
+	// Restrictive is set when those directives ask not to reuse the
+	// source's content
+	Restrictive bool `json:"restrictive,omitempty"`
+}
+
+// SourceFallback is another place to get a source's headlines from
+type SourceFallback struct {
+	// Kind is rss or lite, a text-only mirror tried before the homepage
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+// Selectors describes where a source keeps article data on its homepage.
+// Title, Link, Image and Description are looked up inside each Article match
+type Selectors struct {
+	Article     string `json:"article"`
+	Title       string `json:"title,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SourceTestRequest represents a candidate source to scrape once without saving it
+type SourceTestRequest struct {
+	URL       string    `json:"url"`
+	Selectors Selectors `json:"selectors"`
+	Limit     int       `json:"limit,omitempty"`
+}
+
+// SourceTestResponse represents the result of a one-off candidate source scrape
+type SourceTestResponse struct {
+	Success      bool           `json:"success"`
+	Data         []NewsArticle  `json:"data"`
+	Count        int            `json:"count"`
+	SelectorHits map[string]int `json:"selector_hits"`
+}
+
+// Snapshot describes the raw HTML captured from a failed scrape
+type Snapshot struct {
+	ID         string    `json:"id"`
+	Source     string    `json:"source"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"status_code"`
+	Reason     string    `json:"reason"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// SnapshotsResponse represents the API response for stored snapshots
+type SnapshotsResponse struct {
+	Success   bool       `json:"success"`
+	Snapshots []Snapshot `json:"snapshots"`
+	Count     int        `json:"count"`
+}
+
+// ReplayResponse represents the result of re-scraping a stored snapshot
+type ReplayResponse struct {
+	Success  bool           `json:"success"`
+	Snapshot Snapshot       `json:"snapshot"`
+	Data     []NewsArticle  `json:"data"`
+	Count    int            `json:"count"`
+	Debug    *SelectorDebug `json:"debug,omitempty"`
+}
+
+// LiteArticle is the minimal form of an article returned with lite=true
+type LiteArticle struct {
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Source      string    `json:"source"`
+	PublishedAt time.Time `json:"published_at"`
+	Description string    `json:"description,omitempty"`
+	Score       *Score    `json:"score,omitempty"`
+}
+
+// LiteNewsResponse represents the API response for news with lite=true
+type LiteNewsResponse struct {
+	Success bool          `json:"success"`
+	Data    []LiteArticle `json:"data"`
+	Count   int           `json:"count"`
+}
+
+// DigestResponse represents a day's curated top stories
+type DigestResponse struct {
+	Success  bool          `json:"success"`
+	Date     string        `json:"date"`
+	CutoffAt time.Time     `json:"cutoff_at"`
+	Frozen   bool          `json:"frozen"`
+	Data     []NewsArticle `json:"data"`
+	Count    int           `json:"count"`
+}
+
+// ArticleOverride is an editorial change to how an article is served
+type ArticleOverride struct {
+	Key    string `json:"key"`
+	Pinned bool   `json:"pinned"`
+	Hidden bool   `json:"hidden"`
+	// Title replaces the scraped headline when set
+	Title string `json:"title,omitempty"`
+	// Article is a full article to pin when the scrapers missed it
+	Article   *NewsArticle `json:"article,omitempty"`
+	Note      string       `json:"note,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// OverridesResponse represents the API response for editorial overrides
+type OverridesResponse struct {
+	Success   bool              `json:"success"`
+	Overrides []ArticleOverride `json:"overrides"`
+	Count     int               `json:"count"`
+}
+
+// FilterRules configure which scraped articles are dropped. URL patterns
+// are regular expressions; keywords match whole words in the title,
+// description and tags
+type FilterRules struct {
+	BlockKeywords     []string                     `json:"block_keywords,omitempty"`
+	ExcludeURLs       []string                     `json:"exclude_urls,omitempty"`
+	ExcludeCategories []string                     `json:"exclude_categories,omitempty"`
+	Sources           map[string]SourceFilterRules `json:"sources,omitempty"`
+}
+
+// SourceFilterRules are filtering rules for a single source. When
+// AllowURLs is set, only matching URLs are kept
+type SourceFilterRules struct {
+	AllowURLs     []string `json:"allow_urls,omitempty"`
+	ExcludeURLs   []string `json:"exclude_urls,omitempty"`
+	BlockKeywords []string `json:"block_keywords,omitempty"`
+}
+
+// NotificationConfig declares notifiers and routes events to them
+type NotificationConfig struct {
+	Notifiers map[string]NotifierConfig `json:"notifiers"`
+	Routes    []NotificationRoute       `json:"routes"`
+}
+
+// NotifierConfig configures one notifier. Type is slack, telegram, email
+// or webhook; the other fields apply depending on the type
+type NotifierConfig struct {
+	Type     string   `json:"type"`
+	URL      string   `json:"url,omitempty"`
+	BotToken string   `json:"bot_token,omitempty"`
+	ChatID   string   `json:"chat_id,omitempty"`
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+}
+
+// NotificationRoute sends events to a notifier. Empty Events, Sources or
+// Keywords match everything; keywords match whole words of the articles
+type NotificationRoute struct {
+	Notifier string   `json:"notifier"`
+	Events   []string `json:"events,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Delivery is a notifier delivery that failed and is waiting to be
+// retried, or that ran out of retries (status "dead")
+type Delivery struct {
+	ID            string     `json:"id"`
+	Notifier      string     `json:"notifier"`
+	Event         Event      `json:"event"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DeliveriesResponse represents the API response for failed deliveries
+type DeliveriesResponse struct {
+	Success    bool       `json:"success"`
+	Deliveries []Delivery `json:"deliveries"`
+	Count      int        `json:"count"`
+}
+
+// APIKeysConfig lists API keys and their roles
+type APIKeysConfig struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// APIKey is a named key with a role: reader, editor or admin
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role string `json:"role"`
+	// Tier is the key's quota tier
+	Tier string `json:"tier,omitempty"`
+	// Lane is interactive, the default, or batch for bulk callers
+	Lane string `json:"lane,omitempty"`
+}
+
+// AuditEntry records one change made through the admin API. Previous and
+// Current hold the changed value before and after, when there is one
+type AuditEntry struct {
+	ID       string          `json:"id"`
+	Actor    string          `json:"actor"`
+	Role     string          `json:"role"`
+	Action   string          `json:"action"`
+	Target   string          `json:"target,omitempty"`
+	Previous json.RawMessage `json:"previous,omitempty"`
+	Current  json.RawMessage `json:"current,omitempty"`
+	At       time.Time       `json:"at"`
+}
+
+// AuditResponse represents the API response for the audit log
+type AuditResponse struct {
+	Success bool         `json:"success"`
+	Entries []AuditEntry `json:"entries"`
+	Count   int          `json:"count"`
+}
+
+// TenantsConfig lists the tenants sharing a deployment, by name
+type TenantsConfig struct {
+	Tenants map[string]TenantConfig `json:"tenants"`
+}
+
+// TenantConfig scopes what a tenant's API keys see. Empty Sources means
+// every source; Filters apply on top of the deployment's own rules
+type TenantConfig struct {
+	APIKeys  []string        `json:"api_keys"`
+	Sources  []string        `json:"sources,omitempty"`
+	Filters  FilterRules     `json:"filters,omitempty"`
+	Webhooks []TenantWebhook `json:"webhooks,omitempty"`
+	// Tier is the quota tier shared by the tenant's keys
+	Tier string `json:"tier,omitempty"`
+	// Lane is interactive, the default, or batch for bulk callers
+	Lane string `json:"lane,omitempty"`
+}
+
+// TenantWebhook receives the tenant's events, signed with Secret when set.
+// Empty Events or Keywords match everything
+type TenantWebhook struct {
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`
+	Events   []string `json:"events,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Bookmark is an article a tenant saved
+type Bookmark struct {
+	Key       string      `json:"key"`
+	Article   NewsArticle `json:"article"`
+	Note      string      `json:"note,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// BookmarksResponse represents the API response for bookmarks
+type BookmarksResponse struct {
+	Success bool       `json:"success"`
+	Data    []Bookmark `json:"data"`
+	Count   int        `json:"count"`
+}
+
+// ChaosSettings control fault injection on upstream fetches. Rates are
+// probabilities from 0 to 1; Hosts limits injection to those hosts
+type ChaosSettings struct {
+	Enabled      bool     `json:"enabled"`
+	ErrorRate    float64  `json:"error_rate"`
+	LatencyRate  float64  `json:"latency_rate"`
+	LatencyMS    int      `json:"latency_ms"`
+	TruncateRate float64  `json:"truncate_rate"`
+	Hosts        []string `json:"hosts,omitempty"`
+}
+
+// ChaosStats count the faults injected since chaos was last configured
+type ChaosStats struct {
+	Requests    int64 `json:"requests"`
+	Errors      int64 `json:"errors"`
+	Delays      int64 `json:"delays"`
+	Truncations int64 `json:"truncations"`
+}
+
+// FactCheck is a verdict published by a fact-checking organisation
+type FactCheck struct {
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Source      string    `json:"source"`
+	PublishedAt time.Time `json:"published_at"`
+	Summary     string    `json:"summary,omitempty"`
+	// Verdict is the rating found in the title or categories, e.g. false
+	Verdict string           `json:"verdict,omitempty"`
+	Related []RelatedArticle `json:"related"`
+}
+
+// RelatedArticle links to a stored news article
+type RelatedArticle struct {
+	Key         string     `json:"key"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Source      string     `json:"source"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// FactChecksResponse represents the API response for fact-checks
+type FactChecksResponse struct {
+	Success bool        `json:"success"`
+	Data    []FactCheck `json:"data"`
+	Count   int         `json:"count"`
+	Sources []Source    `json:"sources"`
+}
+
+// StatsBucket counts articles published in one time bucket
+type StatsBucket struct {
+	Start      time.Time      `json:"start,omitempty"`
+	Total      int            `json:"total"`
+	BySource   map[string]int `json:"by_source"`
+	ByCategory map[string]int `json:"by_category"`
+	ByLanguage map[string]int `json:"by_language"`
+}
+
+// StatsResponse represents the API response for article statistics
+type StatsResponse struct {
+	Success     bool          `json:"success"`
+	Granularity string        `json:"granularity"`
+	Since       time.Time     `json:"since"`
+	Until       time.Time     `json:"until"`
+	Buckets     []StatsBucket `json:"buckets"`
+	Totals      StatsBucket   `json:"totals"`
+	// Undated counts stored articles without a publish time
+	Undated int `json:"undated"`
+}
+
+// SourceCoverage describes how one source covered a keyword
+type SourceCoverage struct {
+	Source           string           `json:"source"`
+	DisplayName      string           `json:"display_name"`
+	Count            int              `json:"count"`
+	FirstPublishedAt *time.Time       `json:"first_published_at,omitempty"`
+	LastPublishedAt  *time.Time       `json:"last_published_at,omitempty"`
+	Articles         []RelatedArticle `json:"articles"`
+}
+
+// CoverageResponse represents the API response for coverage comparisons
+type CoverageResponse struct {
+	Success bool      `json:"success"`
+	Query   string    `json:"query"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	// Total counts the matching articles, Count the sources
+	Total   int              `json:"total"`
+	Sources []SourceCoverage `json:"sources"`
+	Count   int              `json:"count"`
+}
+
+// ExportFilters select the stored articles to export
+type ExportFilters struct {
+	Source string    `json:"source,omitempty"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Query  string    `json:"q,omitempty"`
+	Type   string    `json:"type,omitempty"`
+}
+
+// ExportRequest asks for a background export of stored articles
+type ExportRequest struct {
+	Filters ExportFilters `json:"filters"`
+	// Format is csv, ndjson or xlsx
+	Format string `json:"format"`
+}
+
+// ExportJob is the status of a background export
+type ExportJob struct {
+	ID string `json:"id"`
+	// Status is queued, running, done or failed
+	Status      string        `json:"status"`
+	Format      string        `json:"format"`
+	Filters     ExportFilters `json:"filters"`
+	Rows        int           `json:"rows"`
+	Error       string        `json:"error,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	FinishedAt  *time.Time    `json:"finished_at,omitempty"`
+	DownloadURL string        `json:"download_url,omitempty"`
+}
+
+// Event is something that happened while scraping, published on the
+// internal event bus. Articles are set for article events, Error for
+// source failures
+type Event struct {
+	ID         string        `json:"id"`
+	Type       string        `json:"type"`
+	Source     string        `json:"source"`
+	Articles   []NewsArticle `json:"articles,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// OEmbedResponse is an oEmbed 1.0 "rich" response for an aggregated article
+type OEmbedResponse struct {
+	Type            string `json:"type"`
+	Version         string `json:"version"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	CacheAge        int    `json:"cache_age"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+	HTML            string `json:"html"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
 }
 
 // ErrorResponse represents an error response
@@ -41,4 +631,444 @@ type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
 	Message string `json:"message"`
-} 
\ No newline at end of file
+}
+
+// ReadinessResponse is returned by /readyz
+type ReadinessResponse struct {
+	Ready   bool                    `json:"ready"`
+	Checks  []ReadinessCheck        `json:"checks"`
+	Sources map[string]SourceHealth `json:"sources"`
+}
+
+// ReadinessCheck is one condition an instance must meet to get traffic
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// SelfCheckReport is the result of the configuration self-check
+type SelfCheckReport struct {
+	OK     bool             `json:"ok"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// SourceHealth is the outcome of the last scrape of a source
+type SourceHealth struct {
+	Healthy     bool       `json:"healthy"`
+	Articles    int        `json:"articles"`
+	Error       string     `json:"error,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// VersionResponse describes the running build
+type VersionResponse struct {
+	Success   bool            `json:"success"`
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit,omitempty"`
+	Dirty     bool            `json:"dirty,omitempty"`
+	BuildDate string          `json:"build_date,omitempty"`
+	GoVersion string          `json:"go_version"`
+	Features  map[string]bool `json:"features"`
+}
+
+// ScheduleConfig sets how often the background scheduler scrapes each
+// source. Intervals are Go durations such as "5m"
+type ScheduleConfig struct {
+	// Default applies to active sources not listed in Sources; empty means
+	// only listed sources are scheduled
+	Default string            `json:"default,omitempty"`
+	Sources map[string]string `json:"sources,omitempty"`
+	// Jitter randomly shifts every run by up to this share of its
+	// interval, 0.1 when unset. Cron schedules run on the minute
+	Jitter *float64 `json:"jitter,omitempty"`
+	// Timezone is the IANA zone cron expressions are read in, UTC when
+	// unset
+	Timezone string `json:"timezone,omitempty"`
+	// ServePrefetched answers news requests for scheduled sources with
+	// their last scheduled scrape instead of scraping, true when unset
+	ServePrefetched *bool `json:"serve_prefetched,omitempty"`
+}
+
+// ScheduleResponse lists the scheduled sources and how their runs went
+type ScheduleResponse struct {
+	Success bool              `json:"success"`
+	Sources []ScheduledSource `json:"sources"`
+	// Worker and Workers are this instance and the live workers when
+	// sources are sharded
+	Worker  string   `json:"worker,omitempty"`
+	Workers []string `json:"workers,omitempty"`
+}
+
+// ScheduledSource is the schedule and last run of one source
+type ScheduledSource struct {
+	Source string `json:"source"`
+	// Interval is set for sources run every so often and Cron for sources
+	// run on a cron expression
+	Interval string     `json:"interval,omitempty"`
+	Cron     string     `json:"cron,omitempty"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	// SkippedAt is the last time another instance held the run's lock
+	SkippedAt *time.Time `json:"skipped_at,omitempty"`
+	// DeferredUntil is set while the source's scrape window is closed
+	DeferredUntil *time.Time `json:"deferred_until,omitempty"`
+	// Owner is the worker the source is sharded to
+	Owner     string `json:"owner,omitempty"`
+	Articles  int    `json:"articles"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ScrapeWindow restricts when and how often a source's site may be fetched
+type ScrapeWindow struct {
+	// QuietHours are "HH:MM-HH:MM" ranges in the source's timezone when
+	// nothing is fetched; a range may wrap past midnight
+	QuietHours []string `json:"quiet_hours,omitempty"`
+	// MaxRequestsPerHour caps fetches from the site over any hour, 0 means
+	// no cap
+	MaxRequestsPerHour int `json:"max_requests_per_hour,omitempty"`
+}
+
+// LatestArticleResponse identifies a source's newest stored article, so
+// pollers can tell whether anything changed without pulling the list
+type LatestArticleResponse struct {
+	Success     bool      `json:"success"`
+	Source      string    `json:"source"`
+	ID          string    `json:"id"`
+	Key         string    `json:"key"`
+	PublishedAt time.Time `json:"published_at"`
+	// FetchedAt is when the source was last scraped successfully by this
+	// instance
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
+
+// SearchSuggestResponse lists type-ahead completions for a partial query
+type SearchSuggestResponse struct {
+	Success bool   `json:"success"`
+	Query   string `json:"query"`
+	// Titles are stored headlines containing the query at a word start,
+	// newest first
+	Titles []TitleSuggestion `json:"titles"`
+	// Keywords complete the query's last word with words trending in
+	// recent headlines, most used first
+	Keywords []KeywordSuggestion `json:"keywords"`
+}
+
+// TitleSuggestion is a headline matching a partial query
+type TitleSuggestion struct {
+	Title  string `json:"title"`
+	Key    string `json:"key"`
+	Source string `json:"source"`
+}
+
+// KeywordSuggestion is a completed query and how many recent articles use
+// its last word
+type KeywordSuggestion struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// SearchResponse lists the stored articles matching a query, best first
+type SearchResponse struct {
+	Success bool      `json:"success"`
+	Query   string    `json:"query"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	// Total counts all matches, Count the results returned
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+	Count   int            `json:"count"`
+	// Facets count all matches, not only the returned ones
+	Facets SearchFacets `json:"facets"`
+}
+
+// SearchFacets counts the matches of a search per filterable value
+type SearchFacets struct {
+	BySource   map[string]int `json:"by_source"`
+	ByCategory map[string]int `json:"by_category"`
+	ByLanguage map[string]int `json:"by_language"`
+	// ByDate is keyed by the start of each bucket, in RFC 3339
+	ByDate map[string]int `json:"by_date"`
+}
+
+// SearchResult is a matching article and where the query matched it
+type SearchResult struct {
+	Key         string            `json:"key"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	URL         string            `json:"url"`
+	Source      string            `json:"source"`
+	PublishedAt time.Time         `json:"published_at"`
+	Highlights  []SearchHighlight `json:"highlights"`
+}
+
+// SearchHighlight shows why a field matched
+type SearchHighlight struct {
+	// Field is "title" or "description"
+	Field string `json:"field"`
+	// Snippet is HTML: the field, or the part around the first match for
+	// long fields, escaped and with matches wrapped in <em>
+	Snippet string `json:"snippet"`
+	// Ranges are the matches as character offsets into the whole field
+	Ranges []TextRange `json:"ranges"`
+}
+
+// TextRange is a span of characters (Unicode code points), end exclusive
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SavedSearch is a search a user or tenant keeps, readable as a feed
+type SavedSearch struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Query    string `json:"query"`
+	Source   string `json:"source,omitempty"`
+	Category string `json:"category,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Token is the secret in the feed URLs; anyone with it can read the
+	// feed, so share the URLs with care
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	RSSURL    string    `json:"rss_url,omitempty"`
+	JSONURL   string    `json:"json_url,omitempty"`
+}
+
+// SavedSearchesResponse lists saved searches, newest first
+type SavedSearchesResponse struct {
+	Success bool          `json:"success"`
+	Data    []SavedSearch `json:"data"`
+	Count   int           `json:"count"`
+}
+
+// GeoJSONFeatureCollection is a news response as GeoJSON, one point per
+// place an article mentions
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature places one article at one of its locations
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONPoint      `json:"geometry"`
+	Properties GeoJSONProperties `json:"properties"`
+}
+
+// GeoJSONPoint is a position as [longitude, latitude]
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// GeoJSONProperties describe the article and place behind a feature
+type GeoJSONProperties struct {
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Source      string    `json:"source"`
+	PublishedAt time.Time `json:"published_at"`
+	Location    Location  `json:"location"`
+}
+
+// SpecialEvent is an election or crisis that operators follow live
+type SpecialEvent struct {
+	// Slug names the event's live page, /api/v1/live/{slug}
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	// Sources are scraped every Interval while the event runs, all active
+	// sources when empty
+	Sources  []string `json:"sources,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	// Until ends the event on its own
+	Until       *time.Time `json:"until,omitempty"`
+	Active      bool       `json:"active"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+}
+
+// LiveEventResponse is a special event's live page
+type LiveEventResponse struct {
+	Success bool          `json:"success"`
+	Event   SpecialEvent  `json:"event"`
+	Data    []NewsArticle `json:"data"`
+	Count   int           `json:"count"`
+}
+
+// LiveUpdate is one timestamped entry of a live blog
+type LiveUpdate struct {
+	ID          string     `json:"id,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Body        string     `json:"body"`
+	Author      string     `json:"author,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// LiveUpdatesResponse lists the updates of a live-blog article, newest
+// first
+type LiveUpdatesResponse struct {
+	Success bool         `json:"success"`
+	Article NewsArticle  `json:"article"`
+	Data    []LiveUpdate `json:"data"`
+	Count   int          `json:"count"`
+}
+
+// UserExport is everything the service keeps about a signed-in user
+type UserExport struct {
+	Success       bool          `json:"success"`
+	Subject       string        `json:"subject"`
+	ExportedAt    time.Time     `json:"exported_at"`
+	Bookmarks     []Bookmark    `json:"bookmarks"`
+	SavedSearches []SavedSearch `json:"saved_searches"`
+}
+
+// UserDeletionResponse counts what was removed for a signed-in user
+type UserDeletionResponse struct {
+	Success       bool `json:"success"`
+	Bookmarks     int  `json:"bookmarks"`
+	SavedSearches int  `json:"saved_searches"`
+}
+
+// UsageResponse is the caller's API key's consumption
+type UsageResponse struct {
+	Success bool `json:"success"`
+	// Key is the key's name, or its tenant's for tenant keys
+	Key string `json:"key"`
+	// Tier is the key's quota tier, omitted when it has none
+	Tier     string      `json:"tier,omitempty"`
+	Requests UsageCounts `json:"requests"`
+	// RateLimit is nil when the key's requests are not rate limited
+	RateLimit *RateLimitStatus `json:"rate_limit"`
+	// Quota is nil when the key has no monthly quota
+	Quota *QuotaStatus `json:"quota"`
+}
+
+// UsageCounts are a key's requests since the instance started, in the
+// current UTC day and month and in the current minute
+type UsageCounts struct {
+	Total      int64 `json:"total"`
+	ThisMonth  int64 `json:"this_month"`
+	Today      int64 `json:"today"`
+	ThisMinute int64 `json:"this_minute"`
+}
+
+// RateLimitStatus is how many more requests a key may make before ResetAt
+type RateLimitStatus struct {
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaStatus is how much of its monthly quota a key has left
+type QuotaStatus struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// QuotaTiersConfig names the quota tiers API keys and tenants can be put
+// on. Keys without a tier get Default, or no quota when it is empty
+type QuotaTiersConfig struct {
+	Default string               `json:"default,omitempty"`
+	Tiers   map[string]QuotaTier `json:"tiers"`
+}
+
+// QuotaTier limits the keys on it. Zero leaves a limit off
+type QuotaTier struct {
+	RequestsPerMonth  int64 `json:"requests_per_month,omitempty"`
+	RequestsPerMinute int64 `json:"requests_per_minute,omitempty"`
+	// MaxWebhooks caps a tenant's configured webhooks
+	MaxWebhooks int `json:"max_webhooks,omitempty"`
+	// MaxAlerts caps a tenant's saved searches
+	MaxAlerts int `json:"max_alerts,omitempty"`
+}
+
+// MeteringConfig lists the sinks metering events are exported to. Interval
+// is a Go duration, one minute when empty
+type MeteringConfig struct {
+	Interval string               `json:"interval,omitempty"`
+	Sinks    []MeteringSinkConfig `json:"sinks"`
+}
+
+// MeteringSinkConfig configures one metering sink. Type is file, kafka or
+// stripe; the other fields apply depending on the type
+type MeteringSinkConfig struct {
+	Type string `json:"type"`
+	// Path is the file events are appended to as JSON lines
+	Path string `json:"path,omitempty"`
+	// URL and Topic locate a Kafka REST proxy and the topic to produce to
+	URL   string `json:"url,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	// APIKey is the Stripe secret key
+	APIKey string `json:"api_key,omitempty"`
+	// EventNames maps event kinds to Stripe meter event names; kinds
+	// without one are not sent
+	EventNames map[string]string `json:"event_names,omitempty"`
+	// Customers maps event keys, "key:<name>" or "tenant:<name>", to Stripe
+	// customer IDs; keys without one are not sent
+	Customers map[string]string `json:"customers,omitempty"`
+}
+
+// MeteringEvent is the usage of one API key over an export interval. Kind
+// is requests or enrichment
+type MeteringEvent struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key"`
+	Tier        string    `json:"tier,omitempty"`
+	Kind        string    `json:"kind"`
+	Quantity    int64     `json:"quantity"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// StoreStatsResponse describes the article store and its retention policy
+type StoreStatsResponse struct {
+	Success  bool `json:"success"`
+	Articles int  `json:"articles"`
+	// Sources counts the stored articles of each source
+	Sources map[string]int `json:"sources"`
+	Oldest  *time.Time     `json:"oldest,omitempty"`
+	Newest  *time.Time     `json:"newest,omitempty"`
+	// Bytes is the size of the store file, 0 for in-memory stores
+	Bytes int64 `json:"bytes"`
+	// Retention is nil when articles are kept forever
+	Retention *StoreRetention `json:"retention"`
+}
+
+// StoreRetention is the store's retention policy and what pruning has done
+type StoreRetention struct {
+	MaxAgeDays   int        `json:"max_age_days,omitempty"`
+	MaxPerSource int        `json:"max_per_source,omitempty"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastPruned   int        `json:"last_pruned"`
+	TotalPruned  int        `json:"total_pruned"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Tombstone records an article removed from the store, so clients syncing
+// with since can drop it too. Reason is retention or removed_upstream
+type Tombstone struct {
+	Key       string    `json:"key,omitempty"`
+	URL       string    `json:"url"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// ChangesResponse lists the stored articles published and the ones removed
+// after Since, for clients keeping an incremental copy. Until is the since
+// of the next call
+type ChangesResponse struct {
+	Success bool          `json:"success"`
+	Since   time.Time     `json:"since"`
+	Until   time.Time     `json:"until"`
+	Data    []NewsArticle `json:"data"`
+	Count   int           `json:"count"`
+	Deleted []Tombstone   `json:"deleted"`
+}
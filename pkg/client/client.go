@@ -0,0 +1,470 @@
+// Package client is a typed Go client for the Top News REST API. It
+// retries rate-limited and failed requests with backoff:
+//
+//	c := client.New("https://news.example.com")
+//	news, err := c.ListNews(ctx, client.NewsOptions{Source: "thedailystar", Limit: 10})
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"top-news/models"
+)
+
+// Client talks to one Top News API deployment
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	apiKey     string
+	retries    int
+	backoff    time.Duration
+	userAgent  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient uses a custom HTTP client, e.g. with a different timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAPIKey sends an admin API key, needed for admin-only options
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithRetries sets how many times a failed request is retried (default 3)
+// and the first pause, which doubles on every retry (default 500ms)
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the API at baseURL, e.g. https://news.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		// Scrapes run on demand, so the first request for a source can be slow
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		retries:    3,
+		backoff:    500 * time.Millisecond,
+		userAgent:  "top-news-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is an error response from the API
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("top news api: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("top news api: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// NewsOptions filter a news listing. Zero values leave a filter unset
+type NewsOptions struct {
+	// Source limits the listing to one source, e.g. "cnn"
+	Source string
+	// Limit caps the articles per source
+	Limit int
+	// Enrich lists enrichment stages to run; set it to []string{} to skip
+	// article page fetches for a faster response
+	Enrich []string
+	// Types keeps only these article types: news, opinion, analysis,
+	// gallery, announcement. Without it announcements are left out
+	Types []string
+	// Safe drops articles with a content warning
+	Safe bool
+	// Sponsored and Wire keep only (true) or drop (false) those stories
+	Sponsored *bool
+	Wire      *bool
+	// District keeps only articles mentioning a Bangladeshi district, e.g.
+	// "sylhet"
+	District string
+	// DescLen shortens descriptions to this many characters, 1 to 1000
+	DescLen int
+}
+
+func (o NewsOptions) query() url.Values {
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Enrich != nil {
+		enrich := strings.Join(o.Enrich, ",")
+		if enrich == "" {
+			enrich = "none"
+		}
+		query.Set("enrich", enrich)
+	}
+	if len(o.Types) > 0 {
+		query.Set("type", strings.Join(o.Types, ","))
+	}
+	if o.Safe {
+		query.Set("safe", "true")
+	}
+	if o.Sponsored != nil {
+		query.Set("sponsored", strconv.FormatBool(*o.Sponsored))
+	}
+	if o.Wire != nil {
+		query.Set("wire", strconv.FormatBool(*o.Wire))
+	}
+	if o.District != "" {
+		query.Set("district", o.District)
+	}
+	if o.DescLen > 0 {
+		query.Set("desc_len", strconv.Itoa(o.DescLen))
+	}
+	return query
+}
+
+// Bool returns a pointer to b, for the optional filters of NewsOptions
+func Bool(b bool) *bool {
+	return &b
+}
+
+// ListNews returns the latest articles of every source, or of opts.Source
+func (c *Client) ListNews(ctx context.Context, opts NewsOptions) (*models.NewsResponse, error) {
+	path := "/api/v1/news"
+	if opts.Source != "" {
+		path += "/" + url.PathEscape(opts.Source)
+	}
+	var response models.NewsResponse
+	if err := c.get(ctx, path, opts.query(), &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListNewsBySource returns the latest articles of every source keyed by
+// source name, with the error of each source that failed. opts.Source is
+// ignored
+func (c *Client) ListNewsBySource(ctx context.Context, opts NewsOptions) (*models.GroupedNewsResponse, error) {
+	query := opts.query()
+	query.Set("group_by", "source")
+	var response models.GroupedNewsResponse
+	if err := c.get(ctx, "/api/v1/news", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Sources lists the configured news sources
+func (c *Client) Sources(ctx context.Context) ([]models.Source, error) {
+	var response models.SourcesResponse
+	if err := c.get(ctx, "/api/v1/sources", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Sources, nil
+}
+
+// Digest returns the curated top stories of a day, YYYY-MM-DD or "today"
+func (c *Client) Digest(ctx context.Context, date string) (*models.DigestResponse, error) {
+	var response models.DigestResponse
+	if err := c.get(ctx, "/api/v1/digest/"+url.PathEscape(date), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Range is an optional since/until window; zero times are left unset
+type Range struct {
+	Since time.Time
+	Until time.Time
+}
+
+func (r Range) query() url.Values {
+	query := url.Values{}
+	if !r.Since.IsZero() {
+		query.Set("since", r.Since.Format(time.RFC3339))
+	}
+	if !r.Until.IsZero() {
+		query.Set("until", r.Until.Format(time.RFC3339))
+	}
+	return query
+}
+
+// Coverage compares how each source covered a keyword
+func (c *Client) Coverage(ctx context.Context, keyword string, window Range) (*models.CoverageResponse, error) {
+	query := window.query()
+	query.Set("q", keyword)
+	var response models.CoverageResponse
+	if err := c.get(ctx, "/api/v1/coverage", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SearchOptions narrows a search
+type SearchOptions struct {
+	// Source limits the search to one source, e.g. "cnn"
+	Source string
+	// Category and Language narrow it further, using the values of the
+	// response's facets
+	Category string
+	Language string
+	// Granularity sizes the date facet buckets: hour, day (the default),
+	// week or month
+	Granularity string
+	// Limit caps the results, 0 means the server default
+	Limit int
+	// Range defaults to the last 30 days
+	Range Range
+}
+
+// Search finds stored articles containing every word of q, with
+// highlighted snippets showing where they matched
+func (c *Client) Search(ctx context.Context, q string, opts SearchOptions) (*models.SearchResponse, error) {
+	query := opts.Range.query()
+	query.Set("q", q)
+	for name, value := range map[string]string{"source": opts.Source, "category": opts.Category, "language": opts.Language, "granularity": opts.Granularity} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var response models.SearchResponse
+	if err := c.get(ctx, "/api/v1/search", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Suggest completes a partial search query with matching headlines and
+// trending keywords; limit 0 uses the server default
+func (c *Client) Suggest(ctx context.Context, q string, limit int) (*models.SearchSuggestResponse, error) {
+	query := url.Values{"q": {q}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var response models.SearchSuggestResponse
+	if err := c.get(ctx, "/api/v1/search/suggest", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Stats counts stored articles per source, category and language in
+// buckets of granularity: hour, day, week or month
+func (c *Client) Stats(ctx context.Context, granularity string, window Range) (*models.StatsResponse, error) {
+	query := window.query()
+	if granularity != "" {
+		query.Set("granularity", granularity)
+	}
+	var response models.StatsResponse
+	if err := c.get(ctx, "/api/v1/stats", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Latest identifies a source's newest stored article without scraping,
+// a cheap check for whether ListNews would return anything new
+func (c *Client) Latest(ctx context.Context, source string) (*models.LatestArticleResponse, error) {
+	var response models.LatestArticleResponse
+	if err := c.get(ctx, "/api/v1/news/"+url.PathEscape(source)+"/latest", nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// LocalNews lists hyperlocal news for a Bangladeshi district, e.g. "sylhet"
+func (c *Client) LocalNews(ctx context.Context, district string, limit int) (*models.NewsResponse, error) {
+	return c.localNews(ctx, "/api/v1/news/local/"+url.PathEscape(district), limit)
+}
+
+// DivisionNews lists stored news mentioning any district of a division
+func (c *Client) DivisionNews(ctx context.Context, division string, limit int) (*models.NewsResponse, error) {
+	return c.localNews(ctx, "/api/v1/news/local/division/"+url.PathEscape(division), limit)
+}
+
+func (c *Client) localNews(ctx context.Context, path string, limit int) (*models.NewsResponse, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var response models.NewsResponse
+	if err := c.get(ctx, path, query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Live returns a special event's live page. A non-zero since returns only
+// articles published after it, for polling
+func (c *Client) Live(ctx context.Context, slug string, since time.Time) (*models.LiveEventResponse, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	var response models.LiveEventResponse
+	if err := c.get(ctx, "/api/v1/live/"+url.PathEscape(slug), query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ArticleUpdates returns the timestamped updates of a live-blog article,
+// newest first. A non-zero since returns only newer updates
+func (c *Client) ArticleUpdates(ctx context.Context, key string, since time.Time) (*models.LiveUpdatesResponse, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	var response models.LiveUpdatesResponse
+	if err := c.get(ctx, "/api/v1/article/"+url.PathEscape(key)+"/updates", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Version describes the deployed build and its enabled features
+func (c *Client) Version(ctx context.Context) (*models.VersionResponse, error) {
+	var response models.VersionResponse
+	if err := c.get(ctx, "/api/v1/version", nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Usage reports the client's API key's request counts, rate limit and
+// quota
+func (c *Client) Usage(ctx context.Context) (*models.UsageResponse, error) {
+	var response models.UsageResponse
+	if err := c.get(ctx, "/api/v1/me/usage", nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Changes returns the stored articles published and the ones removed after
+// since, of one source or of all when source is "". Pass the response's
+// Until as the next since
+func (c *Client) Changes(ctx context.Context, since time.Time, source string) (*models.ChangesResponse, error) {
+	query := url.Values{}
+	query.Set("since", since.UTC().Format(time.RFC3339Nano))
+	if source != "" {
+		query.Set("source", source)
+	}
+	var response models.ChangesResponse
+	if err := c.get(ctx, "/api/v1/changes", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// get fetches path and decodes the JSON response into out, retrying
+// network errors, 429s and 5xx responses
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var lastErr error
+	pause := c.backoff
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(jitter(pause)):
+			}
+			pause *= 2
+		}
+
+		retry, wait, err := c.do(ctx, target, out)
+		if err == nil || !retry {
+			return err
+		}
+		lastErr = err
+		if wait > pause {
+			pause = wait
+		}
+	}
+	return lastErr
+}
+
+// do makes one request. It reports whether a failure is worth retrying and
+// how long the server asked to wait
+func (c *Client) do(ctx context.Context, target string, out interface{}) (retry bool, wait time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("top news api: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, 0, fmt.Errorf("top news api: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, 0, fmt.Errorf("top news api: failed to read response: %v", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var payload models.ErrorResponse
+		if json.Unmarshal(body, &payload) == nil {
+			apiErr.Code = payload.Error
+			apiErr.Message = payload.Message
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, wait, apiErr
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return false, 0, fmt.Errorf("top news api: failed to decode response: %v", err)
+	}
+	return false, 0, nil
+}
+
+// jitter spreads retries of many clients apart
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
@@ -0,0 +1,107 @@
+// Package webhook signs and verifies Top News webhook deliveries. Each
+// delivery carries an X-Signature header of the form "t=<unix time>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix time>.<body>" under the
+// subscription's secret. Subscribers call Verify (or VerifyRequest) and
+// should also drop repeated X-Event-ID values
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header names set on every delivery
+const (
+	SignatureHeader = "X-Signature"
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// DefaultTolerance is how old a signature may be before it is treated as
+// a replay
+const DefaultTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("webhook: missing or malformed signature header")
+	ErrInvalidSignature = errors.New("webhook: signature does not match the body")
+	ErrExpired          = errors.New("webhook: signature timestamp is outside the tolerance")
+)
+
+// Sign returns the X-Signature header value for body sent at timestamp
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + compute(secret, unix, body)
+}
+
+func compute(secret []byte, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks an X-Signature header against body. Signatures older or
+// further in the future than tolerance are rejected, so a captured
+// delivery cannot be replayed later; a tolerance of 0 uses
+// DefaultTolerance. Several v1 values are accepted, which allows rotating
+// secrets
+func Verify(secret []byte, header string, body []byte, tolerance time.Duration) error {
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	var unix string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch name {
+		case "t":
+			unix = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+
+	expected := compute(secret, unix, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// VerifyRequest reads and verifies a delivery, returning its body. The
+// request body is replaced so handlers can read it again
+func VerifyRequest(r *http.Request, secret []byte, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: failed to read body: %v", err)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := Verify(secret, r.Header.Get(SignatureHeader), body, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
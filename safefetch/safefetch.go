@@ -0,0 +1,222 @@
+// Package safefetch makes HTTP requests to URLs that API callers, tenants
+// or remote servers supplied without letting them reach the network the
+// API runs in. Addresses are checked when connecting, after DNS, so a name
+// that resolves to a public address when checked and a private one when
+// fetched (DNS rebinding) is refused too:
+//
+//	policy, err := safefetch.FromEnv()
+//	client := policy.Client(10 * time.Second)
+//	resp, err := client.Get(userURL)
+package safefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxRedirects is how many redirects a request follows unless the
+// policy says otherwise
+const DefaultMaxRedirects = 5
+
+// ErrBlocked is wrapped by every error for a request the policy refuses
+var ErrBlocked = errors.New("blocked by outbound policy")
+
+// blockedPrefixes are the ranges no user-supplied URL may reach on top of
+// loopback, private, link-local, multicast and unspecified addresses
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// Policy decides which URLs may be fetched. The zero value allows http
+// and https to public addresses only
+type Policy struct {
+	// Schemes are the URL schemes allowed, http and https when empty
+	Schemes []string
+	// AllowHosts may be fetched even when they resolve to private
+	// addresses, e.g. an internal webhook relay. Subdomains are included
+	AllowHosts []string
+	// AllowNets are private ranges that may be fetched
+	AllowNets []netip.Prefix
+	// MaxRedirects caps the redirects followed, DefaultMaxRedirects when
+	// 0 and none when negative
+	MaxRedirects int
+}
+
+// FromEnv reads OUTBOUND_ALLOW_HOSTS and OUTBOUND_ALLOW_CIDRS, both comma
+// separated, and OUTBOUND_MAX_REDIRECTS
+func FromEnv() (*Policy, error) {
+	p := &Policy{}
+	for _, host := range strings.Split(os.Getenv("OUTBOUND_ALLOW_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			p.AllowHosts = append(p.AllowHosts, host)
+		}
+	}
+	for _, cidr := range strings.Split(os.Getenv("OUTBOUND_ALLOW_CIDRS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OUTBOUND_ALLOW_CIDRS entry %q", cidr)
+		}
+		p.AllowNets = append(p.AllowNets, prefix.Masked())
+	}
+	if value := os.Getenv("OUTBOUND_MAX_REDIRECTS"); value != "" {
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid OUTBOUND_MAX_REDIRECTS %q", value)
+		}
+		// 0 means none here, unlike in the struct
+		p.MaxRedirects = max
+		if max == 0 {
+			p.MaxRedirects = -1
+		}
+	}
+	return p, nil
+}
+
+// CheckURL parses a URL and checks its scheme and, when it names an
+// address or a host the policy does not allow, the address. Names are
+// checked again when connecting
+func (p *Policy) CheckURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	if !p.schemeAllowed(parsed.Scheme) {
+		return nil, fmt.Errorf("%w: scheme %q is not allowed", ErrBlocked, parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("invalid url: no host")
+	}
+	if parsed.User != nil {
+		return nil, fmt.Errorf("%w: urls with credentials are not allowed", ErrBlocked)
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		if !p.hostAllowed(host) {
+			return nil, fmt.Errorf("%w: %s is a local address", ErrBlocked, host)
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !p.hostAllowed(host) {
+		if err := p.checkAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// Transport returns a transport that refuses connections to blocked
+// addresses. It never uses a proxy, which would connect on its behalf
+func (p *Policy) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if p.hostAllowed(strings.ToLower(host)) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		// Check each address the name resolved to as it is dialed
+		checked := *dialer
+		checked.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: unexpected address %q", ErrBlocked, address)
+			}
+			return p.checkAddr(addrPort.Addr())
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+	return transport
+}
+
+// Client returns a client using Transport that follows at most
+// MaxRedirects redirects, each checked with CheckURL
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: p.Transport(), CheckRedirect: p.CheckRedirect}
+}
+
+// CheckRedirect is an http.Client CheckRedirect for clients with their
+// own transport, which should still come from Transport
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.maxRedirects() {
+		return fmt.Errorf("%w: more than %d redirects", ErrBlocked, p.maxRedirects())
+	}
+	_, err := p.CheckURL(req.URL.String())
+	return err
+}
+
+func (p *Policy) maxRedirects() int {
+	switch {
+	case p.MaxRedirects < 0:
+		return 0
+	case p.MaxRedirects == 0:
+		return DefaultMaxRedirects
+	}
+	return p.MaxRedirects
+}
+
+func (p *Policy) schemeAllowed(scheme string) bool {
+	schemes := p.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, allowed := range schemes {
+		if strings.EqualFold(scheme, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) hostAllowed(host string) bool {
+	host = strings.Trim(host, "[]")
+	for _, allowed := range p.AllowHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddr refuses addresses outside the public internet, unless they are
+// in AllowNets
+func (p *Policy) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, allowed := range p.AllowNets {
+		if allowed.Contains(addr) {
+			return nil
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %s is not a public address", ErrBlocked, addr)
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is not a public address", ErrBlocked, addr)
+		}
+	}
+	return nil
+}
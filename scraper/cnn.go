@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// scrapeCNN finds CNN's articles among its article links
+func scrapeCNN(c *colly.Collector, opts Options, found *[]models.NewsArticle) {
+	rec := opts.Recorder
+	// Counter for article IDs
+	articleID := 0
+
+	// OnHTML callback for article containers
+	containerSelector := "a[data-link-type='article']"
+	c.OnHTML(containerSelector, func(e *colly.HTMLElement) {
+		rec.Hit(containerSelector)
+		if len(*found) >= opts.Limit {
+			rec.Skip("article limit reached")
+			return
+		}
+		fields := map[string]string{"url": containerSelector + "[href]"}
+
+		link := e.Request.AbsoluteURL(e.Attr("href"))
+
+		// Skip duplicates by URL
+		for _, article := range *found {
+			if article.URL == link {
+				rec.Skip("duplicate url")
+				return
+			}
+		}
+
+		var title string
+		// CNN uses spans with data-editable="headline" for many titles
+		rec.Try("span[data-editable='headline']", ".container__headline-text")
+		title = e.ChildText("span[data-editable='headline']")
+		fields["title"] = "span[data-editable='headline']"
+		if title == "" {
+			// Fallback for different card styles
+			title = e.ChildText(".container__headline-text")
+			fields["title"] = ".container__headline-text"
+		}
+		title = strings.TrimSpace(title)
+
+		if title == "" || len(title) < 10 {
+			rec.Skip("missing or short title")
+			return
+		}
+
+		// Skip duplicates by Title
+		for _, article := range *found {
+			if article.Title == title {
+				rec.Skip("duplicate title")
+				return
+			}
+		}
+		rec.Hit(fields["title"])
+
+		article := models.NewsArticle{
+			ID:          fmt.Sprintf("cnn_%d", articleID),
+			Title:       title,
+			Description: "", // Description is not easily available on the homepage
+			ImageURL:    "", // Will be fetched by updateMissingImageURLs
+			URL:         link,
+			Source:      "cnn",
+			PublishedAt: time.Now(),
+		}
+
+		*found = append(*found, article)
+		rec.Article(article.ID, fields)
+		articleID++
+	})
+}
@@ -0,0 +1,150 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"top-news/models"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// scrapeDailyStar finds The Daily Star's articles among its story cards
+func scrapeDailyStar(c *colly.Collector, opts Options, found *[]models.NewsArticle) {
+	rec := opts.Recorder
+	// Counter for article IDs
+	articleID := 0
+
+	// OnHTML callback for article containers
+	containerSelector := ".story, .article, .news-item, .card, .pane-content, .teaser, .post, .news-block"
+	c.OnHTML(containerSelector, func(e *colly.HTMLElement) {
+		matchContainer(rec, containerSelector, e.DOM.Is)
+		if len(*found) >= opts.Limit {
+			rec.Skip("article limit reached")
+			return
+		}
+		fields := map[string]string{}
+
+		// Extract title - get only the first/main title
+		var title string
+		titleSelectors := []string{"h1", "h2", "h3", "h4", ".title", ".headline"}
+		rec.Try(titleSelectors...)
+		for _, selector := range titleSelectors {
+			title = strings.TrimSpace(e.ChildText(selector))
+			if title != "" {
+				fields["title"] = selector
+			}
+			if title != "" && len(title) >= 10 {
+				break
+			}
+		}
+
+		if title == "" {
+			rec.Skip("no title")
+			return
+		}
+		rec.Hit(fields["title"])
+
+		// Clean up title - remove extra whitespace and newlines
+		title = strings.ReplaceAll(title, "\n", " ")
+		title = strings.ReplaceAll(title, "\r", " ")
+		title = strings.ReplaceAll(title, "\t", " ")
+		title = strings.Join(strings.Fields(title), " ") // Normalize whitespace
+
+		// Truncate at first comma or period to get only the main headline
+		if idx := strings.Index(title, ","); idx != -1 {
+			title = strings.TrimSpace(title[:idx])
+		}
+		if idx := strings.Index(title, "."); idx != -1 {
+			title = strings.TrimSpace(title[:idx])
+		}
+
+		// Extract link
+		rec.Try("a[href]")
+		link := e.ChildAttr("a", "href")
+		if link == "" {
+			rec.Skip("no link")
+			return
+		}
+		link = e.Request.AbsoluteURL(link)
+		fields["url"] = "a[href]"
+
+		// Filter out category links (e.g., /news/bangladesh)
+		pathSegments := strings.Split(strings.TrimPrefix(link, "https://www.thedailystar.net"), "/")
+		if len(pathSegments) <= 3 || pathSegments[len(pathSegments)-1] == "" {
+			rec.Skip("category link")
+			return
+		}
+
+		// Skip if not a news article link
+		if !strings.Contains(link, "/news/") &&
+			!strings.Contains(link, "/bangladesh/") &&
+			!strings.Contains(link, "/world/") &&
+			!strings.Contains(link, "/business/") &&
+			!strings.Contains(link, "/sports/") &&
+			!strings.Contains(link, "/entertainment/") {
+			rec.Skip("not a news section link")
+			return
+		}
+
+		// Skip duplicates
+		for _, article := range *found {
+			if article.URL == link {
+				rec.Skip("duplicate url")
+				return
+			}
+		}
+		rec.Hit("a[href]")
+
+		// Extract image URL
+		imageURL := ""
+		for _, attr := range []string{"src", "data-src", "data-lazy-src", "data-srcset", "data-original", "data-image", "data-lazy"} {
+			rec.Try("img[" + attr + "]")
+			imageURL = e.ChildAttr("img", attr)
+			if imageURL != "" {
+				fields["image_url"] = "img[" + attr + "]"
+				break
+			}
+		}
+		if imageURL == "" {
+			rec.Try("picture source[srcset]")
+			imageURL = e.ChildAttr("picture source", "srcset")
+			if imageURL != "" {
+				fields["image_url"] = "picture source[srcset]"
+			}
+		}
+		if imageURL != "" {
+			rec.Hit(fields["image_url"])
+			imageURL = e.Request.AbsoluteURL(imageURL)
+			if strings.Contains(imageURL, ",") {
+				imageURL = strings.Split(imageURL, ",")[0]
+				imageURL = strings.TrimSpace(strings.Split(imageURL, " ")[0])
+			}
+		}
+
+		// Extract description
+		descriptionSelector := "p, .summary, .intro, .teaser-text, .excerpt, .description"
+		rec.Try(descriptionSelector)
+		description := strings.TrimSpace(e.ChildText(descriptionSelector))
+		if description != "" {
+			rec.Hit(descriptionSelector)
+			fields["description"] = descriptionSelector
+		}
+
+		// Create NewsArticle struct
+		article := models.NewsArticle{
+			ID:          fmt.Sprintf("dailystar_%d", articleID),
+			Title:       title,
+			Description: description,
+			ImageURL:    imageURL,
+			URL:         link,
+			Source:      "thedailystar",
+			PublishedAt: time.Now(),
+		}
+
+		*found = append(*found, article)
+		rec.Article(article.ID, fields)
+		articleID++
+	})
+}
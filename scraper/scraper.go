@@ -0,0 +1,220 @@
+// Package scraper is how news is scraped from a source's homepage. Each
+// source's scraper is defined once here, so the HTTP service, the
+// serverless handler and the command-line tools all scrape the same way:
+//
+//	s, err := scraper.New("thedailystar", scraper.Options{})
+//	articles, err := s.Scrape(ctx)
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"top-news/models"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// DefaultPagination matches the common markup for next-page and load-more
+// links
+const DefaultPagination = "a[rel='next'], .pager__item--next a, .pager-next a, .pagination .next a, a.load-more"
+
+// userAgent is sent with every homepage request
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+
+// Scraper fetches the current headlines of one news source
+type Scraper interface {
+	Scrape(ctx context.Context) ([]models.NewsArticle, error)
+}
+
+// Func turns a function into a Scraper
+type Func func(ctx context.Context) ([]models.NewsArticle, error)
+
+// Scrape calls f
+func (f Func) Scrape(ctx context.Context) ([]models.NewsArticle, error) {
+	return f(ctx)
+}
+
+// Options tune a homepage scrape
+type Options struct {
+	// Limit caps the number of articles, 0 means the source default
+	Limit int
+	// MaxPages and Pagination control crawling past the first page. A
+	// MaxPages below 1 means 1 and an empty Pagination DefaultPagination
+	MaxPages   int
+	Pagination string
+	// Transport fetches the pages, http.DefaultTransport when nil
+	Transport http.RoundTripper
+	// Recorder is told about selector matches, nil records nothing
+	Recorder Recorder
+}
+
+// Recorder is told which selectors a scrape tried and matched and why it
+// skipped elements, for debugging selectors
+type Recorder interface {
+	Try(selectors ...string)
+	Hit(selector string)
+	Skip(reason string)
+	Article(id string, fields map[string]string)
+}
+
+// Page is the first page a scrape fetched, successfully or not, kept so a
+// failed scrape can be looked into
+type Page struct {
+	URL    string
+	Status int
+	Body   []byte
+}
+
+// homepage is a source's homepage and the scraper written for it
+type homepage struct {
+	url     string
+	domains []string
+	scrape  func(c *colly.Collector, opts Options, found *[]models.NewsArticle)
+	limit   int
+}
+
+var homepages = map[string]homepage{
+	"thedailystar": {
+		url:     "https://www.thedailystar.net/",
+		domains: []string{"www.thedailystar.net", "thedailystar.net"},
+		scrape:  scrapeDailyStar,
+		limit:   10,
+	},
+	"cnn": {
+		url:     "https://edition.cnn.com/",
+		domains: []string{"edition.cnn.com", "cnn.com"},
+		scrape:  scrapeCNN,
+		limit:   15,
+	},
+}
+
+// Sources lists the sources that have a scraper, by name
+func Sources() []string {
+	names := make([]string, 0, len(homepages))
+	for name := range homepages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the scraper of a source's homepage
+func New(source string, opts Options) (Scraper, error) {
+	h, ok := homepages[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+	return Func(func(ctx context.Context) ([]models.NewsArticle, error) {
+		articles, _, err := Homepage(ctx, source, h.url, opts)
+		return articles, err
+	}), nil
+}
+
+// Homepage scrapes a source's homepage, or another of its listing pages,
+// and returns the articles and the first page fetched. Cancelling ctx
+// cancels the page requests in flight
+func Homepage(ctx context.Context, source, url string, opts Options) ([]models.NewsArticle, *Page, error) {
+	h, ok := homepages[source]
+	if !ok {
+		return nil, &Page{}, fmt.Errorf("unsupported source: %s", source)
+	}
+	if opts.Limit == 0 {
+		opts.Limit = h.limit
+	}
+	if opts.MaxPages < 1 {
+		opts.MaxPages = 1
+	}
+	if opts.Pagination == "" {
+		opts.Pagination = DefaultPagination
+	}
+	if opts.Recorder == nil {
+		opts.Recorder = nopRecorder{}
+	}
+
+	c := colly.NewCollector(
+		colly.AllowedDomains(h.domains...),
+		colly.UserAgent(userAgent),
+		colly.MaxDepth(opts.MaxPages),
+		colly.StdlibContext(ctx),
+	)
+	if opts.Transport != nil {
+		c.WithTransport(opts.Transport)
+	}
+	articles := make([]models.NewsArticle, 0, opts.Limit)
+	h.scrape(c, opts, &articles)
+	followPagination(c, opts, func() bool { return len(articles) < opts.Limit })
+	c.OnError(func(r *colly.Response, err error) {
+		log.Printf("Error scraping %s: %v, Status Code: %d", source, err, r.StatusCode)
+	})
+	page := capturePage(c)
+
+	if err := c.Visit(url); err != nil {
+		return nil, page, fmt.Errorf("failed to visit %s: %v", source, err)
+	}
+	c.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, page, err
+	}
+	return articles, page, nil
+}
+
+// followPagination visits next-page links up to opts.MaxPages deep for as
+// long as wantMore reports that the article limit has not been reached
+func followPagination(c *colly.Collector, opts Options, wantMore func() bool) {
+	if opts.MaxPages <= 1 {
+		return
+	}
+	c.OnHTML(opts.Pagination, func(e *colly.HTMLElement) {
+		if !wantMore() || e.Request.Depth >= opts.MaxPages {
+			return
+		}
+		var visited *colly.AlreadyVisitedError
+		if err := e.Request.Visit(e.Attr("href")); err != nil && !errors.As(err, &visited) {
+			log.Printf("Error following pagination link %s: %v", e.Attr("href"), err)
+		}
+	})
+}
+
+// capturePage keeps a copy of the first page's response, successful or not
+func capturePage(c *colly.Collector) *Page {
+	page := &Page{}
+	keep := func(r *colly.Response) {
+		// Only the first page matters, later ones are pagination
+		if r.Request.Depth > 1 {
+			return
+		}
+		page.URL = r.Request.URL.String()
+		page.Status = r.StatusCode
+		page.Body = r.Body
+	}
+	c.OnResponse(keep)
+	c.OnError(func(r *colly.Response, err error) {
+		keep(r)
+	})
+	return page
+}
+
+// matchContainer records which parts of a comma-separated container
+// selector an element matched
+func matchContainer(r Recorder, selector string, is func(string) bool) {
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		r.Try(part)
+		if is(part) {
+			r.Hit(part)
+		}
+	}
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Try(selectors ...string)                     {}
+func (nopRecorder) Hit(selector string)                         {}
+func (nopRecorder) Skip(reason string)                          {}
+func (nopRecorder) Article(id string, fields map[string]string) {}
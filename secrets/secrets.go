@@ -0,0 +1,236 @@
+// Package secrets encrypts integration credentials at rest with AES-GCM.
+// An encrypted value reads enc:v1:<key id>:<nonce and ciphertext> and
+// stands in for the plain value in config files and environment variables:
+//
+//	keys, err := secrets.FromEnv()
+//	sealed, err := keys.Seal("xoxb-...")
+//	plain, err := keys.Open(sealed)
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// prefix starts every encrypted value
+const prefix = "enc:v1:"
+
+// sealedPattern matches encrypted values as JSON strings. The base64 is
+// URL-safe and unpadded, so JSON never escapes any of it
+var sealedPattern = regexp.MustCompile(`"enc:v1:[0-9a-f]{8}:[A-Za-z0-9_-]+"`)
+
+// Keyring seals with its current key and opens with any of its keys, so
+// values sealed before a rotation keep working until they are rotated
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// New builds a keyring that seals with current and also opens values
+// sealed with old. Keys are 32 bytes
+func New(current []byte, old ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	for i, key := range append([][]byte{current}, old...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %d is %d bytes, want 32", i+1, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i+1, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i+1, err)
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.current = id
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// FromEnv loads the current key from SECRETS_KEY, or the file at
+// SECRETS_KEY_PATH such as one a KMS or secret manager mounts, and keys
+// replaced by a rotation from SECRETS_OLD_KEYS, comma separated. Keys are
+// base64. It returns nil without an error when no key is set
+func FromEnv() (*Keyring, error) {
+	encoded := os.Getenv("SECRETS_KEY")
+	if path := os.Getenv("SECRETS_KEY_PATH"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SECRETS_KEY_PATH: %v", err)
+		}
+		encoded = string(data)
+	}
+	if strings.TrimSpace(encoded) == "" {
+		return nil, nil
+	}
+	current, err := decodeKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets key: %v", err)
+	}
+	old := [][]byte{}
+	for _, encoded := range strings.Split(os.Getenv("SECRETS_OLD_KEYS"), ",") {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in SECRETS_OLD_KEYS: %v", err)
+		}
+		old = append(old, key)
+	}
+	return New(current, old...)
+}
+
+// GenerateKey returns a new random key, base64 encoded for SECRETS_KEY
+func GenerateKey() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// IsSealed reports whether a value is encrypted
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Seal encrypts a value with the current key
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	if k == nil {
+		return "", fmt.Errorf("no secrets key is set")
+	}
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to create nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.current))
+	return prefix + k.current + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value. Plain values are returned as they are, so
+// callers need not care which they were given
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	if k == nil {
+		return "", fmt.Errorf("value is encrypted with key %s but no secrets key is set", id)
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("value is encrypted with unknown key %s", id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value sealed with key %s", id)
+	}
+	return string(plaintext), nil
+}
+
+// OpenJSON decrypts every sealed string in a JSON document, leaving the
+// rest of it untouched
+func (k *Keyring) OpenJSON(data []byte) ([]byte, error) {
+	return replaceSealed(data, func(value string) (string, bool, error) {
+		plaintext, err := k.Open(value)
+		return plaintext, true, err
+	})
+}
+
+// RotateJSON seals again with the current key every sealed string of a
+// JSON document that was sealed with an older one, and returns how many it
+// rotated. Formatting and plain values are kept
+func (k *Keyring) RotateJSON(data []byte) ([]byte, int, error) {
+	if k == nil {
+		return nil, 0, fmt.Errorf("no secrets key is set")
+	}
+	rotated := 0
+	out, err := replaceSealed(data, func(value string) (string, bool, error) {
+		resealed, changed, err := k.Rotate(value)
+		if changed {
+			rotated++
+		}
+		return resealed, changed, err
+	})
+	return out, rotated, err
+}
+
+// Rotate seals a value again with the current key when it was sealed with
+// an older one, and reports whether it did. Plain values and values
+// sealed with the current key are returned as they are
+func (k *Keyring) Rotate(value string) (string, bool, error) {
+	if k == nil {
+		return "", false, fmt.Errorf("no secrets key is set")
+	}
+	if !IsSealed(value) || strings.HasPrefix(value, prefix+k.current+":") {
+		return value, false, nil
+	}
+	plaintext, err := k.Open(value)
+	if err != nil {
+		return "", false, err
+	}
+	resealed, err := k.Seal(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return resealed, true, nil
+}
+
+// replaceSealed calls replace with every sealed string of a JSON document
+// and puts back the values it returns, stopping at the first error
+func replaceSealed(data []byte, replace func(value string) (string, bool, error)) ([]byte, error) {
+	var firstErr error
+	out := sealedPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if firstErr != nil {
+			return match
+		}
+		value, changed, err := replace(string(match[1 : len(match)-1]))
+		if err != nil {
+			firstErr = err
+			return match
+		}
+		if !changed {
+			return match
+		}
+		encoded, _ := json.Marshal(value)
+		return encoded
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// keyID names a key without giving it away
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("not base64: %v", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned change to the stored articles. Migrations see
+// the raw JSON records, so they can read fields the models no longer have.
+// A crash between writing the articles and the schema runs a migration
+// again, so each must be safe to run twice
+type Migration struct {
+	Version int
+	Name    string
+	Apply   func(records []map[string]interface{}) error
+}
+
+// schema records which migrations a store has had
+type schema struct {
+	Version int                `json:"version"`
+	Applied []appliedMigration `json:"applied"`
+}
+
+type appliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// schemaPath is where the schema of the store at path is kept, e.g.
+// articles.schema.json next to articles.json
+func schemaPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".schema.json"
+}
+
+// Migrate brings the store at path up to the last of migrations, which
+// must be numbered from 1 in order, and returns the version it was at and
+// the migrations it ran. With dryRun it only reports the migrations that
+// would run. A store that does not exist yet starts at the last version,
+// and a store at a later version than migrations know is an error, so an
+// older build never writes over a newer store
+func Migrate(path string, migrations []Migration, dryRun bool) (int, []Migration, error) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return 0, nil, fmt.Errorf("migration %q is numbered %d, want %d", migration.Name, migration.Version, i+1)
+		}
+	}
+
+	current := schema{Applied: []appliedMigration{}}
+	data, err := os.ReadFile(schemaPath(path))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &current); err != nil {
+			return 0, nil, fmt.Errorf("failed to decode schema: %v", err)
+		}
+	}
+	if current.Version > len(migrations) {
+		return current.Version, nil, fmt.Errorf("store schema version %d is newer than this build's %d", current.Version, len(migrations))
+	}
+	pending := migrations[current.Version:]
+
+	data, err = os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Nothing to migrate: a new store starts at the latest schema
+		if dryRun || len(pending) == 0 {
+			return current.Version, []Migration{}, nil
+		}
+		current.Version = len(migrations)
+		return current.Version, []Migration{}, writeSchema(path, current)
+	}
+	if err != nil {
+		return current.Version, nil, fmt.Errorf("failed to read store: %v", err)
+	}
+	if dryRun || len(pending) == 0 {
+		return current.Version, pending, nil
+	}
+
+	records := []map[string]interface{}{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return current.Version, nil, fmt.Errorf("failed to decode store: %v", err)
+	}
+	from := current.Version
+	now := time.Now().UTC()
+	for _, migration := range pending {
+		if err := migration.Apply(records); err != nil {
+			return from, nil, fmt.Errorf("migration %d %s failed: %v", migration.Version, migration.Name, err)
+		}
+		current.Version = migration.Version
+		current.Applied = append(current.Applied, appliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: now})
+	}
+	if data, err = json.Marshal(records); err != nil {
+		return from, nil, fmt.Errorf("failed to encode store: %v", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return from, nil, fmt.Errorf("failed to write store: %v", err)
+	}
+	return from, pending, writeSchema(path, current)
+}
+
+// writeSchema saves the schema of the store at path
+func writeSchema(path string, current schema) error {
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create store dir: %v", err)
+	}
+	if err := writeFile(schemaPath(path), data); err != nil {
+		return fmt.Errorf("failed to write schema: %v", err)
+	}
+	return nil
+}
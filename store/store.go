@@ -0,0 +1,446 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"top-news/models"
+)
+
+// maxTombstones caps how many removals are remembered; the oldest are
+// forgotten first
+const maxTombstones = 50000
+
+// Store keeps scraped articles keyed by URL. When opened with a path it
+// persists them to a JSON file so they survive restarts. Removed articles
+// leave a tombstone, kept in a file next to it, as are the checks that
+// found article pages gone
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	articles   map[string]models.NewsArticle
+	tombstones []models.Tombstone // oldest first
+	gone       map[string]GoneCheck
+	// writeErr is the error of the last write to disk, if it failed
+	writeErr error
+}
+
+// Filter narrows down the articles returned by List
+type Filter struct {
+	Source string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Open loads the store from path, or returns an empty in-memory store when
+// path is empty
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		articles: map[string]models.NewsArticle{},
+		gone:     map[string]GoneCheck{},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %v", err)
+	}
+
+	var articles []models.NewsArticle
+	if err := json.Unmarshal(data, &articles); err != nil {
+		return nil, fmt.Errorf("failed to decode store: %v", err)
+	}
+	for _, article := range articles {
+		s.articles[article.URL] = article
+	}
+
+	data, err = os.ReadFile(tombstonesPath(path))
+	if err == nil {
+		if err := json.Unmarshal(data, &s.tombstones); err != nil {
+			return nil, fmt.Errorf("failed to decode tombstones: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read tombstones: %v", err)
+	}
+
+	data, err = os.ReadFile(gonePath(path))
+	if err == nil {
+		if err := json.Unmarshal(data, &s.gone); err != nil {
+			return nil, fmt.Errorf("failed to decode gone checks: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read gone checks: %v", err)
+	}
+	return s, nil
+}
+
+// tombstonesPath is where the tombstones of the store at path are kept,
+// e.g. articles.tombstones.json next to articles.json
+func tombstonesPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".tombstones.json"
+}
+
+// gonePath is where the gone checks of the store at path are kept, e.g.
+// articles.gone.json next to articles.json
+func gonePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".gone.json"
+}
+
+// Save adds or updates articles and returns how many were new. Articles
+// stored again lose their tombstones
+func (s *Store) Save(articles ...models.NewsArticle) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	saved := map[string]bool{}
+	for _, article := range articles {
+		if article.URL == "" {
+			continue
+		}
+		saved[article.URL] = true
+		existing, exists := s.articles[article.URL]
+		if !exists {
+			added++
+		} else if !existing.PublishedAt.IsZero() && existing.PublishedAt.Before(article.PublishedAt) {
+			// Keep the earliest time we saw the article
+			article.PublishedAt = existing.PublishedAt
+		}
+		s.articles[article.URL] = article
+	}
+	if len(s.tombstones) > 0 {
+		kept := s.tombstones[:0]
+		for _, tombstone := range s.tombstones {
+			if !saved[tombstone.URL] {
+				kept = append(kept, tombstone)
+			}
+		}
+		s.tombstones = kept
+	}
+
+	s.writeErr = s.persist()
+	return added, s.writeErr
+}
+
+// Get returns the article stored for url
+func (s *Store) Get(url string) (models.NewsArticle, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	article, ok := s.articles[url]
+	return article, ok
+}
+
+// List returns the articles matching filter, newest first
+func (s *Store) List(filter Filter) []models.NewsArticle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	articles := []models.NewsArticle{}
+	for _, article := range s.articles {
+		if filter.Source != "" && article.Source != filter.Source {
+			continue
+		}
+		if !filter.Since.IsZero() && article.PublishedAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !article.PublishedAt.Before(filter.Until) {
+			continue
+		}
+		articles = append(articles, article)
+	}
+
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].PublishedAt.After(articles[j].PublishedAt)
+	})
+	if filter.Limit > 0 && len(articles) > filter.Limit {
+		articles = articles[:filter.Limit]
+	}
+	return articles
+}
+
+// Retention limits how long articles are kept. Zero fields keep everything
+type Retention struct {
+	// MaxAge drops articles published longer ago
+	MaxAge time.Duration
+	// MaxPerSource keeps only each source's newest articles
+	MaxPerSource int
+	// TombstoneAge forgets removals made longer ago
+	TombstoneAge time.Duration
+}
+
+// Reasons articles are removed for
+const (
+	RemovedRetention = "retention"
+	RemovedUpstream  = "removed_upstream"
+)
+
+// Prune drops the articles the retention policy no longer keeps and
+// returns how many it dropped. Articles without a publish time are only
+// dropped for MaxPerSource
+func (s *Store) Prune(retention Retention, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	bySource := map[string][]models.NewsArticle{}
+	for _, article := range s.articles {
+		if retention.MaxAge > 0 && !article.PublishedAt.IsZero() && now.Sub(article.PublishedAt) > retention.MaxAge {
+			s.remove(article, RemovedRetention, now)
+			removed++
+			continue
+		}
+		bySource[article.Source] = append(bySource[article.Source], article)
+	}
+	if retention.MaxPerSource > 0 {
+		for _, articles := range bySource {
+			if len(articles) <= retention.MaxPerSource {
+				continue
+			}
+			sort.Slice(articles, func(i, j int) bool {
+				return articles[i].PublishedAt.After(articles[j].PublishedAt)
+			})
+			for _, article := range articles[retention.MaxPerSource:] {
+				s.remove(article, RemovedRetention, now)
+				removed++
+			}
+		}
+	}
+	expired := 0
+	if retention.TombstoneAge > 0 {
+		for expired < len(s.tombstones) && now.Sub(s.tombstones[expired].RemovedAt) > retention.TombstoneAge {
+			expired++
+		}
+		s.tombstones = s.tombstones[expired:]
+	}
+	if removed == 0 && expired == 0 {
+		return 0, nil
+	}
+	s.writeErr = s.persist()
+	return removed, s.writeErr
+}
+
+// Remove drops an article, leaving a tombstone with the reason. It returns
+// false when the article is not stored
+func (s *Store) Remove(url, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	article, ok := s.articles[url]
+	if !ok {
+		return false, nil
+	}
+	s.remove(article, reason, time.Now().UTC())
+	s.writeErr = s.persist()
+	return true, s.writeErr
+}
+
+// remove drops an article and records its tombstone; callers must hold the
+// lock
+func (s *Store) remove(article models.NewsArticle, reason string, now time.Time) {
+	delete(s.articles, article.URL)
+	delete(s.gone, article.URL)
+	s.tombstones = append(s.tombstones, models.Tombstone{
+		Key:       article.Key,
+		URL:       article.URL,
+		Source:    article.Source,
+		Reason:    reason,
+		RemovedAt: now,
+	})
+	if len(s.tombstones) > maxTombstones {
+		s.tombstones = s.tombstones[len(s.tombstones)-maxTombstones:]
+	}
+}
+
+// GoneCheck counts the checks that found an article's page gone, and
+// when the last counted one was made
+type GoneCheck struct {
+	Checks int       `json:"checks"`
+	Last   time.Time `json:"last"`
+}
+
+// MarkGone records that a check found the page of the article stored for
+// url gone and returns how many checks have now. A check made less than
+// interval after the last counted one is not counted again. Articles that
+// aren't stored have no checks
+func (s *Store) MarkGone(url string, now time.Time, interval time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.articles[url]; !ok {
+		return 0, nil
+	}
+	check, seen := s.gone[url]
+	if seen && now.Sub(check.Last) < interval {
+		return check.Checks, nil
+	}
+	s.gone[url] = GoneCheck{Checks: check.Checks + 1, Last: now}
+	s.writeErr = s.persist()
+	return check.Checks + 1, s.writeErr
+}
+
+// ClearGone forgets the gone checks of the article stored for url, once
+// its page was fetched again
+func (s *Store) ClearGone(url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, seen := s.gone[url]; !seen {
+		return nil
+	}
+	delete(s.gone, url)
+	s.writeErr = s.persist()
+	return s.writeErr
+}
+
+// Tombstones returns the removals made after since, oldest first, of one
+// source or of all when source is ""
+func (s *Store) Tombstones(since time.Time, source string) []models.Tombstone {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tombstones := []models.Tombstone{}
+	for _, tombstone := range s.tombstones {
+		if tombstone.RemovedAt.After(since) && (source == "" || tombstone.Source == source) {
+			tombstones = append(tombstones, tombstone)
+		}
+	}
+	return tombstones
+}
+
+// Stats describes what the store holds
+type Stats struct {
+	Articles int
+	// Sources counts the articles of each source
+	Sources map[string]int
+	// Oldest and Newest are the earliest and latest publish times, zero
+	// when the store is empty
+	Oldest, Newest time.Time
+	// Bytes is the size of the store file, 0 for in-memory stores
+	Bytes int64
+}
+
+// Stats counts the stored articles by source and sizes the store file
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := Stats{Articles: len(s.articles), Sources: map[string]int{}}
+	for _, article := range s.articles {
+		stats.Sources[article.Source]++
+		if article.PublishedAt.IsZero() {
+			continue
+		}
+		if stats.Oldest.IsZero() || article.PublishedAt.Before(stats.Oldest) {
+			stats.Oldest = article.PublishedAt
+		}
+		if article.PublishedAt.After(stats.Newest) {
+			stats.Newest = article.PublishedAt
+		}
+	}
+	if s.path != "" {
+		if info, err := os.Stat(s.path); err == nil {
+			stats.Bytes = info.Size()
+		}
+	}
+	return stats
+}
+
+// Len returns the number of stored articles
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.articles)
+}
+
+// Check reports whether the store can be written: its directory must be
+// there (it is created if need be) and the last write must have succeeded.
+// In-memory stores always can
+func (s *Store) Check() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("store directory is unavailable: %v", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.writeErr != nil {
+		return fmt.Errorf("last write failed: %v", s.writeErr)
+	}
+	return nil
+}
+
+// persist writes the store to disk; callers must hold the lock
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	articles := make([]models.NewsArticle, 0, len(s.articles))
+	for _, article := range s.articles {
+		articles = append(articles, article)
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].URL < articles[j].URL
+	})
+
+	data, err := json.Marshal(articles)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create store dir: %v", err)
+	}
+	if err := writeFile(s.path, data); err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if len(s.tombstones) == 0 {
+		if err := os.Remove(tombstonesPath(s.path)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove tombstones: %v", err)
+		}
+	} else {
+		if data, err = json.Marshal(s.tombstones); err != nil {
+			return fmt.Errorf("failed to encode tombstones: %v", err)
+		}
+		if err := writeFile(tombstonesPath(s.path), data); err != nil {
+			return fmt.Errorf("failed to write tombstones: %v", err)
+		}
+	}
+	if len(s.gone) == 0 {
+		if err := os.Remove(gonePath(s.path)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove gone checks: %v", err)
+		}
+		return nil
+	}
+	if data, err = json.Marshal(s.gone); err != nil {
+		return fmt.Errorf("failed to encode gone checks: %v", err)
+	}
+	if err := writeFile(gonePath(s.path), data); err != nil {
+		return fmt.Errorf("failed to write gone checks: %v", err)
+	}
+	return nil
+}
+
+// writeFile replaces the file at path with data through a temporary file,
+// so a crash never leaves it half written
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,105 @@
+// Package textnorm normalizes Bangla text so scrapers and search treat
+// equivalent spellings, digits and dates the same way
+package textnorm
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// banglaDigits maps Bangla digits to ASCII ones
+var banglaDigits = strings.NewReplacer(
+	"০", "0", "১", "1", "২", "2", "৩", "3", "৪", "4",
+	"৫", "5", "৬", "6", "৭", "7", "৮", "8", "৯", "9",
+)
+
+// banglaDateWords maps Bangla month names (with common spelling variants),
+// meridiem markers and weekdays to the English words Go's time layouts
+// understand. Keys are NFC, where য় is always written as য + nukta
+var banglaDateWords = strings.NewReplacer(
+	"জানুয়ারি", "January", "জানুয়ারী", "January",
+	"ফেব্রুয়ারি", "February", "ফেব্রুয়ারী", "February",
+	"মার্চ", "March",
+	"এপ্রিল", "April",
+	"মে", "May",
+	"জুন", "June",
+	"জুলাই", "July",
+	"আগস্ট", "August", "আগষ্ট", "August",
+	"সেপ্টেম্বর", "September",
+	"অক্টোবর", "October",
+	"নভেম্বর", "November",
+	"ডিসেম্বর", "December",
+	"পূর্বাহ্ণ", "AM", "অপরাহ্ণ", "PM",
+	"রবিবার", "Sunday", "সোমবার", "Monday", "মঙ্গলবার", "Tuesday",
+	"বুধবার", "Wednesday", "বৃহস্পতিবার", "Thursday", "শুক্রবার", "Friday", "শনিবার", "Saturday",
+)
+
+// NFC returns s in Unicode normalization form C, which merges the
+// precomposed and decomposed spellings Bangla sites mix freely
+func NFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Digits converts Bangla digits to ASCII digits, leaving everything else
+func Digits(s string) string {
+	return banglaDigits.Replace(s)
+}
+
+// Date rewrites a Bangla or mixed-script date into English month, weekday
+// and AM/PM words with ASCII digits, e.g. "৮ মে ২০২৫" becomes "8 May 2025"
+func Date(s string) string {
+	return banglaDateWords.Replace(Digits(NFC(s)))
+}
+
+// ForSearch folds text for indexing and matching: NFC, ASCII digits, lower
+// case, no zero-width joiners and single spaces between words
+func ForSearch(s string) string {
+	s = Digits(NFC(s))
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u200c' || r == '\u200d' || r == '\ufeff':
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Words splits text folded with ForSearch into words, dropping punctuation.
+// Bangla vowel signs and other combining marks stay part of their word
+func Words(s string) []string {
+	return strings.FieldsFunc(ForSearch(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// Language guesses the language of a headline from its script: "bn" when
+// most letters are Bengali, "en" when most are Latin, "" otherwise
+func Language(s string) string {
+	bengali, latin, letters := 0, 0, 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Bengali, r):
+			bengali++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	switch {
+	case letters == 0:
+		return ""
+	case bengali*2 > letters:
+		return "bn"
+	case latin*2 > letters:
+		return "en"
+	}
+	return ""
+}